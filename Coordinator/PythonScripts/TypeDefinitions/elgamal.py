@@ -120,9 +120,7 @@ class ElGamalCipher(Pair):
     fl.verify(priv_key.len() == 1)
     # This check is not needed, either.
     assert(type(priv_key) is fl.Ed25519IntArrayIdentifier)
-    with fl.massop():
-      rc = self.second - self.first * priv_key
-    return rc
+    return fl.elgamal_decrypt(self.first, self.second, priv_key)
 
   def __pos__(self):
     return self  # No real need to return a copy here.
@@ -137,8 +135,8 @@ class ElGamalCipher(Pair):
     length = fc.calc_broadcast_length([self, other])
     (other, is_copy) = other.broadcast_value(length)
     (self2, is_copy) = self.broadcast_value(length)
-    return ElGamalCipher(self2.first + other.first, \
-                           self2.second + other.second)
+    return ElGamalCipher(*fl.elgamal_add(self2.first, self2.second, \
+                                           other.first, other.second))
 
   def __iadd__(self, other):
     if type(other) is not ElGamalCipher:
@@ -227,20 +225,18 @@ def elgamal_encrypt(plaintext, pub_key):
   fc = fl.get_context([plaintext, pub_key])
   (plaintext, is_copy) = fc.promote(plaintext)
     # The above line also verifies the scope of plaintext.
-  with fl.massop(): # This context defines a single mass operation.
-    nonce = fc.randomarray('I', plaintext.len(), False)
-    rc = ElGamalCipher(nonce.astype('E'), \
-                         nonce * pub_key + plaintext.astype('E'))
-    del nonce
-  return rc
+  # The peers choose the nonces and encrypt in a single command.
+  (mask, masked_message) = fl.elgamal_encrypt(plaintext, pub_key)
+  return ElGamalCipher(mask, masked_message)
 
 def elgamal_refresh(ciphertext, pub_key):
   fc = fl.get_context([ciphertext, pub_key])
   if type(ciphertext) is not ElGamalCipher:
     raise TypeError("Expected ElGamalCipher as ciphertext in refresh.")
-  zero = fc.array('i', ciphertext.len())
-  nonce = elgamal_encrypt(zero, pub_key)
-  ciphertext += nonce
+  (mask, masked_message) = fl.elgamal_rerandomise(ciphertext.first, \
+                                                   ciphertext.second, pub_key)
+  ciphertext.first = mask
+  ciphertext.second = masked_message
 
 def elgamal_sanitise(ciphertext):
   if type(ciphertext) is not ElGamalCipher:
@@ -35,6 +35,8 @@ class FTILConf:
         self.compute_manager_logger = None
         self.segment_client_logger = None
         self.peer_names = []
+        # Hex encoded Ed25519 private key which signs the grants for each transmit
+        self.grant_key = os.environ.get("FTILITE_GRANT_SIGNING_KEY") or None
   
     def set_app_name(self, name):
         self.app_name = name
@@ -66,6 +68,10 @@ class FTILConf:
         self.peer_names = peer_names
         return self
 
+    def set_grant_key(self, grant_key):
+        self.grant_key = grant_key
+        return self
+
 def close():
     # Closes the FTIL Context and backend object
     backend.clear_variable_registry()
@@ -74,7 +80,7 @@ def close():
 class FTILContext:
 
     def __init__(self, conf):
-        self._backend = ComputeManager(conf.rabbitmq_conf, conf.compute_manager_logger, conf.segment_client_logger, conf.peer_names )
+        self._backend = ComputeManager(conf.rabbitmq_conf, conf.compute_manager_logger, conf.segment_client_logger, conf.peer_names, conf.grant_key)
         global backend
         backend = self._backend # Sets the global backend object
         self.rabbitmq_conf = conf.rabbitmq_conf
@@ -135,6 +141,12 @@ class FTILContext:
                 return Ed25519IntArrayIdentifier(self, rc[2])
             elif rc[1] == "E":
                 return Ed25519ArrayIdentifier(self, rc[2])
+            elif rc[1] == "R":
+                return RistrettoArrayIdentifier(self, rc[2])
+            elif rc[1][0] == "P":
+                return PaillierArrayIdentifier(self, rc[2], rc[1])
+            elif rc[1][0] in "BC":
+                return FilterArrayIdentifier(self, rc[2], rc[1])
             elif rc[1][0] == "b":
                 return BytearrayArrayIdentifier(self, rc[2], rc[1])
             else:
@@ -465,12 +477,78 @@ class FTILContext:
 
         return (privateKey, publicKey)
 
+    def ed25519_keygen(self):
+        privateKey = self._exec_command(f'ed25519_keygen 1')
+        publicKey = self._exec_command(f'ed25519_public_key 1 {privateKey.handle()}')
+
+        return (privateKey, publicKey)
+
+    def x25519_keygen(self):
+        privateKey = self._exec_command(f'x25519_keygen 1')
+        publicKey = self._exec_command(f'x25519_public_key 1 {privateKey.handle()}')
+
+        return (privateKey, publicKey)
+
     def rsa3072_keygen(self):
         privateKey = self._exec_command(f'rsa3072_keygen 1')
         publicKey = self._exec_command(f'rsa3072_public_key 1 {privateKey.handle()}')
 
         return (privateKey, publicKey)
 
+    # rsa_keygen generates a key pair of 2048, 3072 or 4096 bits, for use with the
+    # RSA-OAEP and RSA-PSS functions.
+    def rsa_keygen(self, bits=3072):
+        privateKey = self._exec_command(f'rsa_keygen 1 {bits}')
+        publicKey = self._exec_command(f'rsa_public_key 1 {privateKey.handle()}')
+
+        return (privateKey, publicKey)
+
+    # paillier_keygen generates a Paillier key pair of 2048, 3072 or 4096 bits. Ciphertexts
+    # are arrays of typecode P<width>, where width is 2*bits/8.
+    def paillier_keygen(self, bits=2048):
+        privateKey = self._exec_command(f'paillier_keygen 1 {bits}')
+        publicKey = self._exec_command(f'paillier_public_key 1 {privateKey.handle()}')
+
+        return (privateKey, publicKey)
+
+    # privacy_budget returns the epsilon and delta left in the privacy budget of each peer in
+    # scope for this session. The budget is configured on the peers.
+    def privacy_budget(self):
+        return self._exec_command('privacy_budget 1')
+
+    # The keystore holds keys on each peer sealed under the peer's master key. Keys are
+    # generated into the keystore by each peer in scope, so the coordinator never sees them.
+    # kind is one of ecdsa256, rsa2048, rsa3072, rsa4096, ed25519, x25519, I or bN.
+    def keystore_generate(self, name, kind):
+        self._exec_command(f'keystore_generate 0 {name} {kind}')
+
+    def keystore_rotate(self, name):
+        self._exec_command(f'keystore_rotate 0 {name}')
+
+    def keystore_load(self, name, version=None):
+        suffix = "" if version is None else f" {int(version)}"
+        return self._exec_command(f'keystore_load 1 {name}{suffix}')
+
+    def keystore_delete(self, name, version=None):
+        suffix = "" if version is None else f" {int(version)}"
+        self._exec_command(f'keystore_delete 0 {name}{suffix}')
+
+    # elgamal_dkg runs a distributed key generation between the nodes in scope. Each node
+    # receives a share of the private key, of which any threshold can decrypt together.
+    def elgamal_dkg(self, threshold):
+        nodes = ' '.join(str(x.num()) for x in self.scope())
+        commitments = self._exec_command(f'elgamal_dkg_deal 1 {threshold} {nodes}')
+        share, publicKey, verificationKeys = self._exec_command(f'elgamal_dkg_combine 3 {commitments.handle()} {threshold} {nodes}')
+
+        return (share, publicKey, verificationKeys)
+
+    # share splits an Integer array held by the dealer into additive shares modulo 2^64, one for
+    # each node in scope. The dealer sends each share directly to its node, so the coordinator
+    # never sees the values.
+    def share(self, values, dealer):
+        nodes = ' '.join(str(x.num()) for x in self.scope())
+        return self._exec_command(f'share 1 {values.handle()} {dealer.num()} {nodes}')
+
     def calc_broadcast_length(self, params):
         if isinstance(params, list):
             for i, p in enumerate(params):
@@ -1119,7 +1197,7 @@ class IntArrayIdentifier(ArrayIdentifier, FtilliteBuiltin):
     def __mul__(self, other):
         # Handle specific case for i * E
         lhs = self
-        if isinstance(other, Ed25519ArrayIdentifier):
+        if isinstance(other, (Ed25519ArrayIdentifier, RistrettoArrayIdentifier)):
             lhs = lhs.astype('I')
         return self._operator("mul", lhs, other, type_checker=lambda lhs, rhs: \
             type(lhs) in [Ed25519IntArrayIdentifier])
@@ -1449,7 +1527,7 @@ class Ed25519IntArrayIdentifier(ArrayIdentifier, FtilliteBuiltin):
         
     def __mul__(self, other):
         return self._operator("mul", self, other, type_checker=lambda lhs, rhs: \
-            type(rhs) in [Ed25519ArrayIdentifier, Ed25519IntArrayIdentifier])
+            type(rhs) in [Ed25519ArrayIdentifier, RistrettoArrayIdentifier, Ed25519IntArrayIdentifier])
 
     def __imul__(self, other):
         self[:] = self.__mul__(other)
@@ -1561,6 +1639,166 @@ class Ed25519ArrayIdentifier(ArrayIdentifier, FtilliteBuiltin):
         return self.__mul__(other)
 
 
+class RistrettoArrayIdentifier(ArrayIdentifier, FtilliteBuiltin):
+    def __init__(self, fc, handle):
+        super(RistrettoArrayIdentifier, self).__init__(fc=fc, handle=handle)
+
+    def typecode(self):
+        return 'R'
+
+    def sametype(self, other):
+        return type(other) is RistrettoArrayIdentifier
+
+    def astype(self, typecode):
+        return self.context()._exec_command(f"astype 1 {self.handle()} {typecode}")
+
+    def len(self):
+        return self.context()._exec_command("len 1 " + self.handle())
+
+    def reduce_sum(self, key, value):
+        key, value = self._handle_key_value(key, value)
+        self.context()._exec_command(f"reducesum 0 {self.handle()} {value.handle()} {key.handle()}")
+
+    def reduce_isum(self, key, value):
+        key, value = self._handle_key_value(key, value)
+        self.context()._exec_command(f"reduceisum 0 {self.handle()} {value.handle()} {key.handle()}")
+
+    def contains(self, other):
+        return self.context()._exec_command(f"contains 1 {self.handle()} {other.handle()}")
+
+    def cumsum(self):
+        return self.context()._exec_command(f"cumsum 1 {self.handle()}")
+
+    def index(self):
+        return self.context()._exec_command(f"index 1 {self.handle()}")
+
+    def __pos__(self):
+        raise NotImplementedError("Awaiting Golang implementation.")
+
+    def __neg__(self):
+        return self.context()._exec_command(f"neg 1 {self.handle()}")
+
+    def __add__(self, other):
+        return self._operator("add", self, other)
+
+    def __iadd__(self, other):
+        self[:] = self.__add__(other)
+        return self
+
+    def __sub__(self, other):
+        return self._operator("sub", self, other)
+
+    def __isub__(self, other):
+        self[:] = self.__sub__(other)
+        return self
+
+    def __mul__(self, other):
+        # Handle specific case for R * i
+        other = self._handle_native_type(other)
+        if isinstance(other, IntArrayIdentifier):
+            other = other.astype('I')
+        return self._operator("mul", self, other, type_checker=lambda lhs, rhs: \
+            type(rhs) in [Ed25519IntArrayIdentifier])
+
+    def __imul__(self, other):
+        self[:] = self.__mul__(other)
+        return self
+
+    def __rmul__(self, other):
+        return self.__mul__(other)
+
+
+class PaillierArrayIdentifier(ArrayIdentifier, FtilliteBuiltin):
+    def __init__(self, fc, handle, typecode):
+        super(PaillierArrayIdentifier, self).__init__(fc=fc, handle=handle)
+        self._typecode = typecode
+
+    def typecode(self):
+        return self._typecode
+
+    def sametype(self, other):
+        return type(other) is PaillierArrayIdentifier and \
+            self.typecode() == other.typecode()
+
+    def astype(self, typecode):
+        return self.context()._exec_command(f"astype 1 {self.handle()} {typecode}")
+
+    def len(self):
+        return self.context()._exec_command("len 1 " + self.handle())
+
+    def reduce_sum(self, key, value):
+        key, value = self._handle_key_value(key, value)
+        self.context()._exec_command(f"reducesum 0 {self.handle()} {value.handle()} {key.handle()}")
+
+    def reduce_isum(self, key, value):
+        key, value = self._handle_key_value(key, value)
+        self.context()._exec_command(f"reduceisum 0 {self.handle()} {value.handle()} {key.handle()}")
+
+    def cumsum(self):
+        return self.context()._exec_command(f"cumsum 1 {self.handle()}")
+
+    def __neg__(self):
+        return self.context()._exec_command(f"neg 1 {self.handle()}")
+
+    def __add__(self, other):
+        return self._operator("add", self, other)
+
+    def __iadd__(self, other):
+        self[:] = self.__add__(other)
+        return self
+
+    def __sub__(self, other):
+        return self._operator("sub", self, other)
+
+    def __isub__(self, other):
+        self[:] = self.__sub__(other)
+        return self
+
+    def __mul__(self, other):
+        # Ciphertexts are only multiplied by plaintext integers
+        return self._operator("mul", self, other, type_checker=lambda lhs, rhs: \
+            type(rhs) in [IntArrayIdentifier])
+
+    def __imul__(self, other):
+        self[:] = self.__mul__(other)
+        return self
+
+    def __rmul__(self, other):
+        return self.__mul__(other)
+
+
+# FilterArrayIdentifier is an array of Bloom (typecode B<width>) or Cuckoo (C<width>) filters,
+# built with bloom_filter and cuckoo_filter.
+class FilterArrayIdentifier(ArrayIdentifier, FtilliteBuiltin):
+    def __init__(self, fc, handle, typecode):
+        super(FilterArrayIdentifier, self).__init__(fc=fc, handle=handle)
+        self._typecode = typecode
+
+    def typecode(self):
+        return self._typecode
+
+    def sametype(self, other):
+        return type(other) is FilterArrayIdentifier and \
+            self.typecode() == other.typecode()
+
+    def astype(self, typecode):
+        return self.context()._exec_command(f"astype 1 {self.handle()} {typecode}")
+
+    def len(self):
+        return self.context()._exec_command("len 1 " + self.handle())
+
+    # may_contain returns a mask of the values which may be in the filter. There are no false
+    # negatives.
+    def may_contain(self, values):
+        return self.context()._exec_command(f"filter_contains 1 {self.handle()} {values.handle()}")
+
+    def union(self, other):
+        return self.context()._exec_command(f"filter_union 1 {self.handle()} {other.handle()}")
+
+    def intersect(self, other):
+        return self.context()._exec_command(f"filter_intersect 1 {self.handle()} {other.handle()}")
+
+
 class BytearrayArrayIdentifier(ArrayIdentifier, FtilliteBuiltin):
     def __init__(self, fc, handle, typecode):
         super(BytearrayArrayIdentifier, self).__init__(fc=fc, handle=handle)
@@ -1954,6 +2192,57 @@ def sha3_256(data):
     return data.context()._exec_command(f'sha3_256 1 {data.handle()}')
 
 
+# digest hashes each element with one of sha256, sha512, blake2b or shake256 to the given
+# width. An optional salt array, of length 1 or the length of data, is hashed before the data.
+def digest(data, algo, width, salt=None):
+    suffix = "" if salt is None else f" {salt.handle()}"
+    return data.context()._exec_command(f'hash 1 {algo} {int(width)} {data.handle()}{suffix}')
+
+
+def _check_dst(dst):
+    # Commands are split on "__" by the peers, which would cut the dst short
+    if dst == "" or "__" in dst or any(c.isspace() for c in dst):
+        raise ValueError('dst must be a non-empty string without whitespace or "__"')
+
+
+# hash_to_curve hashes each element to an Ed25519 point with the RFC 9380
+# edwards25519_XMD:SHA-512_ELL2_RO_ suite, under the domain separation tag dst.
+def hash_to_curve(data, dst):
+    _check_dst(dst)
+    return data.context()._exec_command(f'hash_to_curve 1 {data.handle()} {dst}')
+
+
+def hash_to_scalar(data, dst):
+    _check_dst(dst)
+    return data.context()._exec_command(f'hash_to_scalar 1 {data.handle()} {dst}')
+
+
+def _mac(name, data, key, width, label):
+    # Commands are split on "__" by the peers, which would cut the label short
+    if label is not None and (label == "" or "__" in label or any(c.isspace() for c in label)):
+        raise ValueError('label must be a non-empty string without whitespace or "__"')
+    suffix = "" if label is None else f" {label}"
+    return data.context()._exec_command(f'{name} 1 {data.handle()} {key.handle()} {int(width)}{suffix}')
+
+
+def hmac_sha256(data, key, width=32, label=None):
+    return _mac("hmac_sha256", data, key, width, label)
+
+
+def hmac_sha3_256(data, key, width=32, label=None):
+    return _mac("hmac_sha3_256", data, key, width, label)
+
+
+def kmac256(data, key, width=32, label=None):
+    return _mac("kmac256", data, key, width, label)
+
+
+# x25519_derive derives a key of the given width with each of the public keys, which the
+# peer holding each public key can derive in turn with its own private key.
+def x25519_derive(priv_key, pub_keys, width=32, label=None):
+    return _mac("x25519_derive", priv_key, pub_keys, width, label)
+
+
 def ecdsa256_sign(data, priv_key):
     return data.context()._exec_command(f'ecdsa256_sign 1 {data.handle()} {priv_key.handle()}')
 
@@ -1962,6 +2251,14 @@ def ecdsa256_verify(data, signature, pub_key):
     return data.context()._exec_command(f'ecdsa256_verify 1 {data.handle()} {signature.handle()} {pub_key.handle()}')
 
 
+def ed25519_sign(data, priv_key):
+    return data.context()._exec_command(f'ed25519_sign 1 {data.handle()} {priv_key.handle()}')
+
+
+def ed25519_verify(data, signature, pub_key):
+    return data.context()._exec_command(f'ed25519_verify 1 {data.handle()} {signature.handle()} {pub_key.handle()}')
+
+
 def aes256_encrypt(data, key):
     return data.context()._exec_command(f'aes256_encrypt 1 {data.handle()} {key.handle()}')
 
@@ -1970,12 +2267,36 @@ def aes256_decrypt(data, key):
     return data.context()._exec_command(f'aes256_decrypt 1 {data.handle()} {key.handle()}')
 
 
+def aes256gcm_encrypt(data, key):
+    return data.context()._exec_command(f'aes256gcm_encrypt 3 {data.handle()} {key.handle()}')
+
+
+def aes256gcm_decrypt(data, nonces, tags, key):
+    return data.context()._exec_command(f'aes256gcm_decrypt 2 {data.handle()} {nonces.handle()} {tags.handle()} {key.handle()}')
+
+
+def aes256ctr_encrypt(data, key):
+    return data.context()._exec_command(f'aes256ctr_encrypt 2 {data.handle()} {key.handle()}')
+
+
+def aes256ctr_decrypt(data, ivs, key):
+    return data.context()._exec_command(f'aes256ctr_decrypt 1 {data.handle()} {ivs.handle()} {key.handle()}')
+
+
 def grain128aeadv2(key, iv, size, length):
     v_size = key.context().promote(size, "i")
 
     return key.context()._exec_command(f'grain128aeadv2 1 {key.handle()} {iv.handle()} {v_size[0].handle()} {length.handle()}')
 
 
+def grain128aeadv2_seal(data, associated_data, key):
+    return data.context()._exec_command(f'grain128aeadv2_seal 3 {data.handle()} {associated_data.handle()} {key.handle()}')
+
+
+def grain128aeadv2_open(data, nonces, tags, associated_data, key):
+    return data.context()._exec_command(f'grain128aeadv2_open 2 {data.handle()} {nonces.handle()} {tags.handle()} {associated_data.handle()} {key.handle()}')
+
+
 def rsa3072_encrypt(data, pub_key):
     return data.context()._exec_command(f'rsa3072_encrypt 1 {data.handle()} {pub_key.handle()}')
 
@@ -1984,6 +2305,139 @@ def rsa3072_decrypt(data, priv_key):
     return data.context()._exec_command(f'rsa3072_decrypt 1 {data.handle()} {priv_key.handle()}')
 
 
+def rsa_oaep_encrypt(data, pub_key):
+    return data.context()._exec_command(f'rsa_oaep_encrypt 1 {data.handle()} {pub_key.handle()}')
+
+
+def rsa_oaep_decrypt(data, priv_key):
+    return data.context()._exec_command(f'rsa_oaep_decrypt 1 {data.handle()} {priv_key.handle()}')
+
+
+def rsa_pss_sign(data, priv_key):
+    return data.context()._exec_command(f'rsa_pss_sign 1 {data.handle()} {priv_key.handle()}')
+
+
+def rsa_pss_verify(data, signature, pub_key):
+    return data.context()._exec_command(f'rsa_pss_verify 1 {data.handle()} {signature.handle()} {pub_key.handle()}')
+
+
+def paillier_encrypt(data, pub_key):
+    return data.context()._exec_command(f'paillier_encrypt 1 {data.handle()} {pub_key.handle()}')
+
+
+def paillier_decrypt(data, priv_key):
+    return data.context()._exec_command(f'paillier_decrypt 1 {data.handle()} {priv_key.handle()}')
+
+
+# The noise functions add differentially private noise to data in place, and charge epsilon
+# and delta to the privacy budget of the peers, which refuse the release once it is spent.
+# Laplace noise is added to float arrays, and geometric and discrete Gaussian noise only to
+# int arrays with a whole number sensitivity.
+def laplace_noise(data, epsilon, sensitivity=1):
+    data.context()._exec_command(f'laplace_noise 0 {data.handle()} {float(epsilon)} {float(sensitivity)}')
+
+
+def geometric_noise(data, epsilon, sensitivity=1):
+    data.context()._exec_command(f'geometric_noise 0 {data.handle()} {float(epsilon)} {float(sensitivity)}')
+
+
+def gaussian_noise(data, epsilon, delta, sensitivity=1):
+    data.context()._exec_command(f'gaussian_noise 0 {data.handle()} {float(epsilon)} {float(delta)} {float(sensitivity)}')
+
+
+# bloom_filter and cuckoo_filter build a filter of the items of an int or bytearray array, with
+# the given false positive rate for the number of items or capacity, whichever is larger.
+def bloom_filter(values, fp_rate=0.01, capacity=0):
+    return values.context()._exec_command(f'bloom_filter 1 {values.handle()} {float(fp_rate)} {int(capacity)}')
+
+
+def cuckoo_filter(values, fp_rate=0.01, capacity=0):
+    return values.context()._exec_command(f'cuckoo_filter 1 {values.handle()} {float(fp_rate)} {int(capacity)}')
+
+
+def share_add(lhs, rhs):
+    return lhs.context()._exec_command(f'share_add 1 {lhs.handle()} {rhs.handle()}')
+
+
+def share_sub(lhs, rhs):
+    return lhs.context()._exec_command(f'share_sub 1 {lhs.handle()} {rhs.handle()}')
+
+
+def share_mul(shares, constant):
+    return shares.context()._exec_command(f'share_mul 1 {shares.handle()} {constant.handle()}')
+
+
+# reconstruct sums shares that have been transmitted to the nodes in scope.
+def reconstruct(*shares):
+    handles = ' '.join(x.handle() for x in shares)
+    return shares[0].context()._exec_command(f'reconstruct 1 {handles}')
+
+
+def elgamal_encrypt(plaintext, pub_key):
+    return plaintext.context()._exec_command(f'elgamal_encrypt 2 {plaintext.handle()} {pub_key.handle()}')
+
+
+def elgamal_decrypt(mask, masked_message, priv_key):
+    return mask.context()._exec_command(f'elgamal_decrypt 1 {mask.handle()} {masked_message.handle()} {priv_key.handle()}')
+
+
+def elgamal_rerandomise(mask, masked_message, pub_key):
+    return mask.context()._exec_command(f'elgamal_rerandomise 2 {mask.handle()} {masked_message.handle()} {pub_key.handle()}')
+
+
+def elgamal_add(mask1, masked_message1, mask2, masked_message2):
+    return mask1.context()._exec_command(f'elgamal_add 2 {mask1.handle()} {masked_message1.handle()} {mask2.handle()} {masked_message2.handle()}')
+
+
+def elgamal_partial_decrypt(mask, share):
+    return mask.context()._exec_command(f'elgamal_partial_decrypt 1 {mask.handle()} {share.handle()}')
+
+
+def elgamal_combine_partials(masked_message, threshold, partials):
+    args = ' '.join(f'{node.num()} {partial.handle()}' for node, partial in partials.items())
+    return masked_message.context()._exec_command(f'elgamal_combine_partials 1 {masked_message.handle()} {threshold} {args}')
+
+
+def schnorr_prove(priv_key):
+    return priv_key.context()._exec_command(f'schnorr_prove 1 {priv_key.handle()}')
+
+
+def schnorr_verify(proofs, pub_key):
+    return proofs.context()._exec_command(f'schnorr_verify 1 {proofs.handle()} {pub_key.handle()}')
+
+
+def elgamal_decrypt_prove(mask, masked_message, priv_key):
+    return mask.context()._exec_command(f'elgamal_decrypt_prove 2 {mask.handle()} {masked_message.handle()} {priv_key.handle()}')
+
+
+def elgamal_decrypt_verify(proofs, plaintext, mask, masked_message, pub_key):
+    return proofs.context()._exec_command(f'elgamal_decrypt_verify 1 {proofs.handle()} {plaintext.handle()} {mask.handle()} {masked_message.handle()} {pub_key.handle()}')
+
+
+def elgamal_partial_decrypt_prove(mask, share):
+    return mask.context()._exec_command(f'elgamal_partial_decrypt_prove 2 {mask.handle()} {share.handle()}')
+
+
+def elgamal_partial_decrypt_verify(proofs, partial, mask, verification_key):
+    return proofs.context()._exec_command(f'elgamal_partial_decrypt_verify 1 {proofs.handle()} {partial.handle()} {mask.handle()} {verification_key.handle()}')
+
+
+def elgamal_rerandomise_prove(mask, masked_message, pub_key):
+    return mask.context()._exec_command(f'elgamal_rerandomise_prove 3 {mask.handle()} {masked_message.handle()} {pub_key.handle()}')
+
+
+def elgamal_rerandomise_verify(proofs, mask_r, masked_message_r, mask, masked_message, pub_key):
+    return proofs.context()._exec_command(f'elgamal_rerandomise_verify 1 {proofs.handle()} {mask_r.handle()} {masked_message_r.handle()} {mask.handle()} {masked_message.handle()} {pub_key.handle()}')
+
+
+def elgamal_shuffle(mask, masked_message, pub_key):
+    return mask.context()._exec_command(f'elgamal_shuffle 4 {mask.handle()} {masked_message.handle()} {pub_key.handle()}')
+
+
+def elgamal_shuffle_verify(proof, proof_elements, mask_r, masked_message_r, mask, masked_message, pub_key):
+    return proof.context()._exec_command(f'elgamal_shuffle_verify 1 {proof.handle()} {proof_elements.handle()} {mask_r.handle()} {masked_message_r.handle()} {mask.handle()} {masked_message.handle()} {pub_key.handle()}')
+
+
 def mux(conditional, iftrue, iffalse):
     if hasattr(iftrue, "__mux__"):
         return iftrue.__mux__(conditional, iffalse)
@@ -2038,7 +2492,7 @@ def cos(x):
     return x.__cos__()
 
 def typecode_list(tc):
-    return re.split(r'([ifIE]|b[1-9][0-9]*)\s*', tc)[1::2]
+    return re.split(r'([ifIER]|[bPBC][1-9][0-9]*)\s*', tc)[1::2]
 
 # Support for "massop()" given below is a do-nothing placeholder.
 
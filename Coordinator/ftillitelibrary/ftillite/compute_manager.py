@@ -36,11 +36,18 @@
 
 
 import random
+import time
 from ftillite.segment_client import SegmentClient
 from ftillite.segment_node import SegmentNode
 import threading
 import logging
 from typing import Callable, List
+
+# Grants are checked by the peer when a transfer starts, and an interrupted transfer can be
+# resumed after its grant expires, so a grant only needs to last until the transfer starts.
+# No peer accepts a grant for longer than 5 minutes.
+GRANT_LIFETIME_SECONDS = 240
+
 class ComputeManager:
 
     def add_sm(self, name):
@@ -55,7 +62,7 @@ class ComputeManager:
     def context(self):
         return self._context
 
-    def __init__(self, rabbitmq_conf=None, logger=None, segment_client_logger=None, peer_names=[]):
+    def __init__(self, rabbitmq_conf=None, logger=None, segment_client_logger=None, peer_names=[], grant_key=None):
         self.variable_registry = {}
         self._context = None
         self.segment_clients = []
@@ -65,6 +72,10 @@ class ComputeManager:
         self.segment_client_logger = segment_client_logger
         self.del_await_resp = rabbitmq_conf.get('del_await_resp', True)
         self.peer_names = peer_names
+        self.grant_key = None
+        if grant_key is not None:
+            from cryptography.hazmat.primitives.asymmetric.ed25519 import Ed25519PrivateKey
+            self.grant_key = Ed25519PrivateKey.from_private_bytes(bytes.fromhex(grant_key))
 
         # The first to be added will be the coordinator node.
         for p in peer_names:
@@ -152,6 +163,13 @@ class ComputeManager:
         return self.variable_registry[handle][0] + " " \
             + self.variable_registry[handle][1] + " " + handle
 
+    def _transfer_grant(self, source, handle, destination):
+        # Signs a grant for the destination to read handle from the source. The format is
+        # described in Peer/segment/grant.
+        expiry = int(time.time()) + GRANT_LIFETIME_SECONDS
+        message = f"ftillite-transfer-grant {source} {handle} {destination} {expiry}".encode()
+        return message.hex() + "." + self.grant_key.sign(message).hex()
+
     def transmit(self, opcode, dtype, request):
         # To use textual interface if separating processes.
         request = request.split(" ")
@@ -169,13 +187,17 @@ class ComputeManager:
                     self.variable_registry[rc[i]] = (opcode, dtype, set())
                 pair = (i, node)
                 transmit_pairs.append(pair)
-                transmit_commands[pair] = (i, f"transmit {node} {rc[i]} {request[1]} {dtype} {opcode}")
+                transmit_commands[pair] = (i, request[1], f"transmit {node} {rc[i]} {request[1]} {dtype} {opcode}")
                 self.variable_registry[rc[i]][2].add(node)
 
             del request[:2]
 
         def transmit_single(pair):
-            node_id, node_cmd = transmit_commands[pair]
+            node_id, handle, node_cmd = transmit_commands[pair]
+            # Grants are signed as each phase starts, so that they do not expire while
+            # earlier phases run.
+            if self.grant_key is not None:
+                node_cmd += " " + self._transfer_grant(node_id, handle, pair[1])
             retval = self.segment_clients[node_id].run_command(node_cmd)
             if retval != "ack":
                 raise RuntimeError(
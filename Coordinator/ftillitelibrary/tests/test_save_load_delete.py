@@ -93,4 +93,32 @@ def test_save_load_empty_listmap(fc):
     listmap_2 = fc.load(destination)
 
     assert listmap_2.listmap_contains_all_keys(keys1)
-      
\ No newline at end of file
+      
+def test_save_load_ristrettoarray(fc):
+    i1 = fc.array("i", [1, 2, 3, 4, 5])
+    r1 = i1.astype("R")
+    destination = "pytesting"
+    fc.save(r1, destination)
+    r2 = fc.load(destination)
+    assert r2.typecode() == "R"
+    assert fl.verify(r1.astype("b32") == r2.astype("b32"))
+
+def test_save_load_paillierarray(fc):
+    i1 = fc.array("i", [1, 2, 3, 4, 5])
+    private_key, public_key = fc.paillier_keygen()
+    p1 = fl.paillier_encrypt(i1, public_key)
+    destination = "pytesting"
+    fc.save(p1, destination)
+    p2 = fc.load(destination)
+    assert p2.typecode() == p1.typecode()
+    assert fl.verify(fl.paillier_decrypt(p2, private_key) == i1)
+
+def test_save_load_filterarray(fc):
+    i1 = fc.array("i", [1, 2, 3, 4, 5])
+    for build in [fl.bloom_filter, fl.cuckoo_filter]:
+        f1 = build(i1)
+        destination = "pytesting"
+        fc.save(f1, destination)
+        f2 = fc.load(destination)
+        assert f2.typecode() == f1.typecode()
+        assert fl.verify(f2.may_contain(i1))
@@ -79,5 +79,26 @@ with engine.connect() as connection:
         connection.execute(text(f'''GRANT ALL ON TABLE {s_lc}.pickle TO "{username}";'''))
         
         print(f"Pickle table setup in schema {s_lc} for user {n_lc}")
+
+        # Keystore table, holding keys sealed under each peer's keystore master key
+        connection.execute(text(f'''CREATE TABLE IF NOT EXISTS {s_lc}.keystore
+            (
+                name character varying COLLATE pg_catalog."default",
+                version integer,
+                kind character varying(20) COLLATE pg_catalog."default",
+                nonce bytea,
+                data bytea,
+                created timestamp without time zone,
+                PRIMARY KEY (name, version)
+            )
+            TABLESPACE pg_default;'''))
+
+        connection.execute(text(f'''ALTER TABLE IF EXISTS {s_lc}.keystore
+                OWNER TO "{username}";'''))
+
+        connection.execute(text(f'''GRANT SELECT, INSERT, DELETE ON TABLE {s_lc}.keystore TO "{n_lc}";'''))
+        connection.execute(text(f'''GRANT ALL ON TABLE {s_lc}.keystore TO "{username}";'''))
+
+        print(f"Keystore table setup in schema {s_lc} for user {n_lc}")
     
     connection.commit()
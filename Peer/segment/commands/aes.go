@@ -19,20 +19,39 @@ import (
 )
 
 const (
-	CommandAES256Encrypt = "command_aes256_encrypt"
-	CommandAES256Decrypt = "command_aes256_decrypt"
+	CommandAES256Encrypt    = "command_aes256_encrypt"
+	CommandAES256Decrypt    = "command_aes256_decrypt"
+	CommandAES256GCMEncrypt = "command_aes256gcm_encrypt"
+	CommandAES256GCMDecrypt = "command_aes256gcm_decrypt"
+	CommandAES256CTREncrypt = "command_aes256ctr_encrypt"
+	CommandAES256CTRDecrypt = "command_aes256ctr_decrypt"
 )
 
+func getAes256Key(s SegmentHost, hKey variables.Handle) ([]byte, error) {
+	keyArray, err := variables.GetAs[*types.FTBytearrayArray](s.Variables(), hKey)
+	if err != nil {
+		return nil, err
+	}
+	return keyArray.Single()
+}
+
+func getBytearrays(s SegmentHost, h variables.Handle, width int64) (*types.FTBytearrayArray, error) {
+	xs, err := variables.GetAs[*types.FTBytearrayArray](s.Variables(), h)
+	if err != nil {
+		return nil, err
+	}
+	if width > 0 && xs.Width() != width {
+		return nil, fmt.Errorf("%v must be b%v", h, width)
+	}
+	return xs, nil
+}
+
 func aes256(s SegmentHost, args []string, f func(key []byte, data [][]byte) ([][]byte, error)) (string, error) {
 	hTarget := variables.Handle(args[0])
 	hData := variables.Handle(args[1])
 	hKey := variables.Handle(args[2])
 
-	keyArray, err := variables.GetAs[*types.FTBytearrayArray](s.Variables(), hKey)
-	if err != nil {
-		return "", err
-	}
-	key, err := keyArray.Single()
+	key, err := getAes256Key(s, hKey)
 	if err != nil {
 		return "", err
 	}
@@ -70,3 +89,168 @@ func Aes256Decrypt(s SegmentHost, args []string) (string, error) {
 		return crypto.Aes256Decrypt(key, data)
 	})
 }
+
+// Aes256GCMEncrypt encrypts each element under a fresh nonce, returning the ciphertexts, the
+// nonces and the authentication tags as separate arrays.
+func Aes256GCMEncrypt(s SegmentHost, args []string) (string, error) {
+	hCiphertexts := variables.Handle(args[0])
+	hNonces := variables.Handle(args[1])
+	hTags := variables.Handle(args[2])
+	hData := variables.Handle(args[3])
+	hKey := variables.Handle(args[4])
+
+	key, err := getAes256Key(s, hKey)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := getBytearrays(s, hData, 0)
+	if err != nil {
+		return "", err
+	}
+
+	ciphertextBytes, nonceBytes, tagBytes, err := crypto.Aes256GCMEncrypt(key, data.Values())
+	if err != nil {
+		return "", err
+	}
+
+	ciphertexts, err := types.NewFTBytearrayArray(int(data.Width()), ciphertextBytes...)
+	if err != nil {
+		return "", err
+	}
+	nonces, err := types.NewFTBytearrayArray(crypto.Aes256GCMNonceBytes, nonceBytes...)
+	if err != nil {
+		return "", err
+	}
+	tags, err := types.NewFTBytearrayArray(crypto.Aes256GCMTagBytes, tagBytes...)
+	if err != nil {
+		return "", err
+	}
+
+	s.Variables().Set(hCiphertexts, ciphertexts)
+	s.Variables().Set(hNonces, nonces)
+	s.Variables().Set(hTags, tags)
+
+	return fmt.Sprintf("array b%v %v array b%v %v array b%v %v",
+		ciphertexts.Width(), hCiphertexts,
+		crypto.Aes256GCMNonceBytes, hNonces,
+		crypto.Aes256GCMTagBytes, hTags,
+	), nil
+}
+
+// Aes256GCMDecrypt also returns an integer mask, holding 0 for each element which failed
+// authentication. Such elements decrypt to zeroes.
+func Aes256GCMDecrypt(s SegmentHost, args []string) (string, error) {
+	hPlaintexts := variables.Handle(args[0])
+	hValid := variables.Handle(args[1])
+	hCiphertexts := variables.Handle(args[2])
+	hNonces := variables.Handle(args[3])
+	hTags := variables.Handle(args[4])
+	hKey := variables.Handle(args[5])
+
+	key, err := getAes256Key(s, hKey)
+	if err != nil {
+		return "", err
+	}
+
+	ciphertexts, err := getBytearrays(s, hCiphertexts, 0)
+	if err != nil {
+		return "", err
+	}
+	nonces, err := getBytearrays(s, hNonces, crypto.Aes256GCMNonceBytes)
+	if err != nil {
+		return "", err
+	}
+	tags, err := getBytearrays(s, hTags, crypto.Aes256GCMTagBytes)
+	if err != nil {
+		return "", err
+	}
+
+	plaintextBytes, valid, err := crypto.Aes256GCMDecrypt(key, ciphertexts.Values(), nonces.Values(), tags.Values())
+	if err != nil {
+		return "", err
+	}
+
+	plaintexts, err := types.NewFTBytearrayArray(int(ciphertexts.Width()), plaintextBytes...)
+	if err != nil {
+		return "", err
+	}
+
+	s.Variables().Set(hPlaintexts, plaintexts)
+	s.Variables().Set(hValid, types.NewFTIntegerArray(valid...))
+
+	return fmt.Sprintf("array b%v %v array i %v", plaintexts.Width(), hPlaintexts, hValid), nil
+}
+
+// Aes256CTREncrypt encrypts elements of any width, returning the ciphertexts and the random
+// IV used for each.
+func Aes256CTREncrypt(s SegmentHost, args []string) (string, error) {
+	hCiphertexts := variables.Handle(args[0])
+	hIVs := variables.Handle(args[1])
+	hData := variables.Handle(args[2])
+	hKey := variables.Handle(args[3])
+
+	key, err := getAes256Key(s, hKey)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := getBytearrays(s, hData, 0)
+	if err != nil {
+		return "", err
+	}
+
+	ciphertextBytes, ivBytes, err := crypto.Aes256CTREncrypt(key, data.Values())
+	if err != nil {
+		return "", err
+	}
+
+	ciphertexts, err := types.NewFTBytearrayArray(int(data.Width()), ciphertextBytes...)
+	if err != nil {
+		return "", err
+	}
+	ivs, err := types.NewFTBytearrayArray(crypto.Aes256CTRIVBytes, ivBytes...)
+	if err != nil {
+		return "", err
+	}
+
+	s.Variables().Set(hCiphertexts, ciphertexts)
+	s.Variables().Set(hIVs, ivs)
+
+	return fmt.Sprintf("array b%v %v array b%v %v", ciphertexts.Width(), hCiphertexts, crypto.Aes256CTRIVBytes, hIVs), nil
+}
+
+func Aes256CTRDecrypt(s SegmentHost, args []string) (string, error) {
+	hPlaintexts := variables.Handle(args[0])
+	hCiphertexts := variables.Handle(args[1])
+	hIVs := variables.Handle(args[2])
+	hKey := variables.Handle(args[3])
+
+	key, err := getAes256Key(s, hKey)
+	if err != nil {
+		return "", err
+	}
+
+	ciphertexts, err := getBytearrays(s, hCiphertexts, 0)
+	if err != nil {
+		return "", err
+	}
+	ivs, err := getBytearrays(s, hIVs, crypto.Aes256CTRIVBytes)
+	if err != nil {
+		return "", err
+	}
+
+	plaintextBytes, err := crypto.Aes256CTRDecrypt(key, ciphertexts.Values(), ivs.Values())
+	if err != nil {
+		return "", err
+	}
+
+	plaintexts, err := types.NewFTBytearrayArray(int(ciphertexts.Width()), plaintextBytes...)
+	if err != nil {
+		return "", err
+	}
+
+	s.Variables().Set(hPlaintexts, plaintexts)
+
+	return fmt.Sprintf("array b%v %v", plaintexts.Width(), hPlaintexts), nil
+}
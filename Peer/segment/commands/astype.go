@@ -28,7 +28,7 @@ func AsType(s SegmentHost, args []string) (string, error) {
 		return "", err
 	}
 
-	target, err := value.AsType(tc)
+	target, err := types.AsTypeWithBackend(value, tc, s.Ed25519Backend())
 	if err != nil {
 		return "", err
 	}
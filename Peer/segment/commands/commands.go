@@ -10,8 +10,8 @@
 package commands
 
 import (
-	"errors"
-
+	"github.com/AUSTRAC/ftillite/Peer/segment/keystore"
+	"github.com/AUSTRAC/ftillite/Peer/segment/privacy"
 	"github.com/AUSTRAC/ftillite/Peer/segment/types"
 	"github.com/AUSTRAC/ftillite/Peer/segment/variables"
 )
@@ -20,7 +20,8 @@ type SegmentHost interface {
 	Node() *types.Node
 	SetPeerAddress(nodeID string, addr string)
 	GetPeerAddress(nodeID string) string
-	RequestTransferBytes(nodeAddress string, handle string, newHandle string, dtype string, opcode string) error
+	RequestTransferBytes(nodeAddress string, handle string, newHandle string, dtype string, opcode string, grant string) error
+	IssueTransferGrant(nodeID string, h variables.Handle) string
 
 	Register(name string, f CommandFunc)
 
@@ -29,6 +30,9 @@ type SegmentHost interface {
 	Log(format string, v ...any)
 
 	IsGPUAvailable() bool
+	Ed25519Backend() types.Ed25519Backend
+	Keystore() (*keystore.Keystore, error)
+	PrivacyAccountant() (*privacy.Accountant, error)
 
 	DeleteFromPickleTable(destination string) error
 	LoadFromPickleTable(h variables.Handle) ([]variables.Pickle, error)
@@ -48,8 +52,6 @@ type SegmentHost interface {
 
 const Ack = "ack"
 
-var ErrEd25519Unavailable = errors.New("ed25519 arrays are not available as GPU support is disabled")
-
 type CommandFunc func(s SegmentHost, args []string) (string, error)
 
 func RegisterCommands(s SegmentHost) {
@@ -88,6 +90,10 @@ func RegisterCommands(s SegmentHost) {
 	s.Register(CommandArange, Arange)
 	s.Register(CommandRandomArray, RandomArray)
 	s.Register(CommandRandomPerm, RandomPerm)
+	s.Register(CommandLaplaceNoise, LaplaceNoise)
+	s.Register(CommandGeometricNoise, GeometricNoise)
+	s.Register(CommandGaussianNoise, GaussianNoise)
+	s.Register(CommandPrivacyBudget, PrivacyBudget)
 	s.Register(CommandConcat, Concat)
 	s.Register(CommandByteProject, ByteProject)
 
@@ -122,17 +128,78 @@ func RegisterCommands(s SegmentHost) {
 	s.Register(CommandIndexSorted, IndexSorted)
 
 	s.Register(CommandSHA3256, Sha3_256)
+	s.Register(CommandHash, Hash)
+	s.Register(CommandHashToCurve, HashToCurve)
+	s.Register(CommandHashToScalar, HashToScalar)
+	s.Register(CommandHMACSHA256, HmacSha256)
+	s.Register(CommandHMACSHA3256, HmacSha3256)
+	s.Register(CommandKMAC256, Kmac256)
 	s.Register(CommandAES256Encrypt, Aes256Encrypt)
 	s.Register(CommandAES256Decrypt, Aes256Decrypt)
+	s.Register(CommandAES256GCMEncrypt, Aes256GCMEncrypt)
+	s.Register(CommandAES256GCMDecrypt, Aes256GCMDecrypt)
+	s.Register(CommandAES256CTREncrypt, Aes256CTREncrypt)
+	s.Register(CommandAES256CTRDecrypt, Aes256CTRDecrypt)
 	s.Register(CommandGrain128aeadv2, Grain128Aeadv2)
+	s.Register(CommandGrain128aeadv2Seal, Grain128Aeadv2Seal)
+	s.Register(CommandGrain128aeadv2Open, Grain128Aeadv2Open)
 	s.Register(CommandECDSA256Keygen, ECDSA256Keygen)
 	s.Register(CommandECDSA256PublicKey, ECDSA256PublicKey)
 	s.Register(CommandECDSA256Sign, ECDSA256Sign)
 	s.Register(CommandECDSA256Verify, ECDSA256Verify)
+	s.Register(CommandEd25519Keygen, Ed25519Keygen)
+	s.Register(CommandEd25519PublicKey, Ed25519PublicKey)
+	s.Register(CommandEd25519Sign, Ed25519Sign)
+	s.Register(CommandEd25519Verify, Ed25519Verify)
+	s.Register(CommandX25519Keygen, X25519Keygen)
+	s.Register(CommandX25519PublicKey, X25519PublicKey)
+	s.Register(CommandX25519Derive, X25519Derive)
+	s.Register(CommandKeystoreGenerate, KeystoreGenerate)
+	s.Register(CommandKeystoreRotate, KeystoreRotate)
+	s.Register(CommandKeystoreLoad, KeystoreLoad)
+	s.Register(CommandKeystoreDelete, KeystoreDelete)
 	s.Register(CommandRSA3072Keygen, RSA3072Keygen)
 	s.Register(CommandRSA3072PublicKey, RSA3072PublicKey)
 	s.Register(CommandRSA3072Encrypt, RSA3072Encrypt)
 	s.Register(CommandRSA3072Decrypt, RSA3072Decrypt)
+	s.Register(CommandRSAKeygen, RSAKeygen)
+	s.Register(CommandRSAPublicKey, RSA3072PublicKey)
+	s.Register(CommandRSAOAEPEncrypt, RSAOAEPEncrypt)
+	s.Register(CommandRSAOAEPDecrypt, RSAOAEPDecrypt)
+	s.Register(CommandRSAPSSSign, RSAPSSSign)
+	s.Register(CommandRSAPSSVerify, RSAPSSVerify)
+	s.Register(CommandPaillierKeygen, PaillierKeygen)
+	s.Register(CommandPaillierPublicKey, PaillierPublicKey)
+	s.Register(CommandPaillierEncrypt, PaillierEncrypt)
+	s.Register(CommandPaillierDecrypt, PaillierDecrypt)
+	s.Register(CommandShare, Share)
+	s.Register(CommandShareAdd, ShareAdd)
+	s.Register(CommandShareSub, ShareSub)
+	s.Register(CommandShareMul, ShareMul)
+	s.Register(CommandReconstruct, Reconstruct)
+	s.Register(CommandBloomFilter, BloomFilter)
+	s.Register(CommandCuckooFilter, CuckooFilter)
+	s.Register(CommandFilterContains, FilterContains)
+	s.Register(CommandFilterUnion, FilterUnion)
+	s.Register(CommandFilterIntersect, FilterIntersect)
+	s.Register(CommandElGamalEncrypt, ElGamalEncrypt)
+	s.Register(CommandElGamalDecrypt, ElGamalDecrypt)
+	s.Register(CommandElGamalRerandomise, ElGamalRerandomise)
+	s.Register(CommandElGamalAdd, ElGamalAdd)
+	s.Register(CommandElGamalDKGDeal, ElGamalDKGDeal)
+	s.Register(CommandElGamalDKGCombine, ElGamalDKGCombine)
+	s.Register(CommandElGamalPartialDecrypt, ElGamalPartialDecrypt)
+	s.Register(CommandElGamalCombinePartials, ElGamalCombinePartials)
+	s.Register(CommandSchnorrProve, SchnorrProve)
+	s.Register(CommandSchnorrVerify, SchnorrVerify)
+	s.Register(CommandElGamalDecryptProve, ElGamalDecryptProve)
+	s.Register(CommandElGamalDecryptVerify, ElGamalDecryptVerify)
+	s.Register(CommandElGamalPartialDecryptProve, ElGamalPartialDecryptProve)
+	s.Register(CommandElGamalPartialDecryptVerify, ElGamalPartialDecryptVerify)
+	s.Register(CommandElGamalRerandomiseProve, ElGamalRerandomiseProve)
+	s.Register(CommandElGamalRerandomiseVerify, ElGamalRerandomiseVerify)
+	s.Register(CommandElGamalShuffle, ElGamalShuffle)
+	s.Register(CommandElGamalShuffleVerify, ElGamalShuffleVerify)
 
 	s.Register(CommandNewListmap, NewListmap)
 	s.Register(CommandListmapKeys, ListmapGetKeys)
@@ -0,0 +1,132 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package commands
+
+import (
+	"crypto/ed25519"
+	"fmt"
+
+	"github.com/AUSTRAC/ftillite/Peer/segment/crypto"
+	"github.com/AUSTRAC/ftillite/Peer/segment/types"
+	"github.com/AUSTRAC/ftillite/Peer/segment/variables"
+)
+
+// Signatures are verified with the cofactored equation [8][s]B = [8]R + [8][k]A, as in ZIP 215,
+// with R and S required to be canonically encoded. Some signatures with a small order component
+// are accepted which the cofactorless check of crypto/ed25519 rejects.
+const (
+	CommandEd25519Keygen    = "command_ed25519_keygen"     // command_ed25519_keygen <hPrivateKey :: Handle→[1]b64>
+	CommandEd25519PublicKey = "command_ed25519_public_key" // command_ed25519_public_key <hPublicKey :: Handle→[1]b32> <hPrivateKey :: Handle→[1]b64>
+	CommandEd25519Sign      = "command_ed25519_sign"       // command_ed25519_sign <hSignatures :: Handle→[]b64> <hData :: Handle→[]b*> <hPrivateKey :: Handle→[1]b64>
+	CommandEd25519Verify    = "command_ed25519_verify"     // command_ed25519_verify <hResult :: Handle→[]int64> <hData :: Handle→[]b*> <hSignatures :: Handle→[]b64> <hPublicKey :: Handle→[1]b32>
+)
+
+func Ed25519Keygen(s SegmentHost, args []string) (string, error) {
+	hTarget := variables.Handle(args[0])
+
+	targetBytes, err := crypto.Ed25519Keygen()
+	if err != nil {
+		return "", err
+	}
+
+	target, err := types.NewFTBytearrayArray(ed25519.PrivateKeySize, targetBytes)
+	if err != nil {
+		return "", err
+	}
+
+	s.Variables().Set(hTarget, target)
+
+	return fmt.Sprintf("array b%v %v", target.Width(), hTarget), nil
+}
+
+func Ed25519PublicKey(s SegmentHost, args []string) (string, error) {
+	hTarget := variables.Handle(args[0])
+	hPrivateKey := variables.Handle(args[1])
+
+	pkBytes, err := variables.GetAsBytes(s.Variables(), hPrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	pk, err := crypto.Ed25519PublicKey(pkBytes)
+	if err != nil {
+		return "", err
+	}
+
+	target, err := types.NewFTBytearrayArray(ed25519.PublicKeySize, pk)
+	if err != nil {
+		return "", err
+	}
+
+	s.Variables().Set(hTarget, target)
+
+	return fmt.Sprintf("array b%v %v", target.Width(), hTarget), nil
+}
+
+func Ed25519Sign(s SegmentHost, args []string) (string, error) {
+	hTarget := variables.Handle(args[0])
+	hData := variables.Handle(args[1])
+	hPrivateKey := variables.Handle(args[2])
+
+	data, err := variables.GetAs[*types.FTBytearrayArray](s.Variables(), hData)
+	if err != nil {
+		return "", err
+	}
+
+	pkBytes, err := variables.GetAsBytes(s.Variables(), hPrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	targetBytes, err := crypto.Ed25519Sign(pkBytes, data.Values())
+	if err != nil {
+		return "", err
+	}
+
+	target, err := types.NewFTBytearrayArray(ed25519.SignatureSize, targetBytes...)
+	if err != nil {
+		return "", err
+	}
+
+	s.Variables().Set(hTarget, target)
+
+	return fmt.Sprintf("array b%v %v", ed25519.SignatureSize, hTarget), nil
+}
+
+func Ed25519Verify(s SegmentHost, args []string) (string, error) {
+	hTarget := variables.Handle(args[0])
+	hData := variables.Handle(args[1])
+	hSignatures := variables.Handle(args[2])
+	hPublicKey := variables.Handle(args[3])
+
+	data, err := variables.GetAs[*types.FTBytearrayArray](s.Variables(), hData)
+	if err != nil {
+		return "", err
+	}
+
+	sigs, err := variables.GetAs[*types.FTBytearrayArray](s.Variables(), hSignatures)
+	if err != nil {
+		return "", err
+	}
+
+	pkBytes, err := variables.GetAsBytes(s.Variables(), hPublicKey)
+	if err != nil {
+		return "", err
+	}
+
+	target, err := crypto.Ed25519Verify(pkBytes, data.Values(), sigs.Values())
+	if err != nil {
+		return "", err
+	}
+
+	s.Variables().Set(hTarget, types.NewFTIntegerArray(target...))
+
+	return fmt.Sprintf("array i %v", hTarget), nil
+}
@@ -0,0 +1,158 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package commands
+
+import (
+	"fmt"
+
+	"github.com/AUSTRAC/ftillite/Peer/segment/crypto"
+	"github.com/AUSTRAC/ftillite/Peer/segment/types"
+	"github.com/AUSTRAC/ftillite/Peer/segment/variables"
+)
+
+const (
+	CommandElGamalEncrypt     = "command_elgamal_encrypt"     // command_elgamal_encrypt <hMaskResult :: Handle→[]Ed25519> <hMaskedResult :: Handle→[]Ed25519> <hMessages :: Handle→[](int64|Ed25519Int|Ed25519)> <hPublicKey :: Handle→[1]Ed25519>
+	CommandElGamalDecrypt     = "command_elgamal_decrypt"     // command_elgamal_decrypt <hResult :: Handle→[]Ed25519> <hMask :: Handle→[]Ed25519> <hMasked :: Handle→[]Ed25519> <hPrivateKey :: Handle→[1]Ed25519Int>
+	CommandElGamalRerandomise = "command_elgamal_rerandomise" // command_elgamal_rerandomise <hMaskResult :: Handle→[]Ed25519> <hMaskedResult :: Handle→[]Ed25519> <hMask :: Handle→[]Ed25519> <hMasked :: Handle→[]Ed25519> <hPublicKey :: Handle→[1]Ed25519>
+	CommandElGamalAdd         = "command_elgamal_add"         // command_elgamal_add <hMaskResult :: Handle→[]Ed25519> <hMaskedResult :: Handle→[]Ed25519> <hMaskLHS :: Handle→[]Ed25519> <hMaskedLHS :: Handle→[]Ed25519> <hMaskRHS :: Handle→[]Ed25519> <hMaskedRHS :: Handle→[]Ed25519>
+)
+
+func getElGamalCiphertexts(s SegmentHost, hMask variables.Handle, hMasked variables.Handle) (crypto.ElGamalCiphertexts, error) {
+	mask, err := variables.GetAs[*types.Ed25519Array](s.Variables(), hMask)
+	if err != nil {
+		return crypto.ElGamalCiphertexts{}, err
+	}
+
+	masked, err := variables.GetAs[*types.Ed25519Array](s.Variables(), hMasked)
+	if err != nil {
+		return crypto.ElGamalCiphertexts{}, err
+	}
+
+	return crypto.NewElGamalCiphertexts(mask, masked)
+}
+
+func setElGamalCiphertexts(s SegmentHost, hMask variables.Handle, hMasked variables.Handle, c crypto.ElGamalCiphertexts) string {
+	s.Variables().Set(hMask, c.Mask)
+	s.Variables().Set(hMasked, c.Masked)
+
+	return fmt.Sprintf("array E %v array E %v", hMask, hMasked)
+}
+
+func ElGamalEncrypt(s SegmentHost, args []string) (string, error) {
+	hMaskResult := variables.Handle(args[0])
+	hMaskedResult := variables.Handle(args[1])
+	hMessages := variables.Handle(args[2])
+	hPublicKey := variables.Handle(args[3])
+
+	values, err := variables.GetAs[types.ArrayTypeVal](s.Variables(), hMessages)
+	if err != nil {
+		return "", err
+	}
+
+	publicKey, err := variables.GetAs[*types.Ed25519Array](s.Variables(), hPublicKey)
+	if err != nil {
+		return "", err
+	}
+
+	messages, ok := values.(*types.Ed25519Array)
+	if !ok {
+		converted, err := types.AsTypeWithBackend(values, types.Ed25519, publicKey.Backend())
+		if err != nil {
+			return "", err
+		}
+		messages = converted.(*types.Ed25519Array)
+		defer messages.Free()
+	}
+
+	c, err := crypto.ElGamalEncrypt(publicKey, messages)
+	if err != nil {
+		return "", err
+	}
+
+	return setElGamalCiphertexts(s, hMaskResult, hMaskedResult, c), nil
+}
+
+func ElGamalDecrypt(s SegmentHost, args []string) (string, error) {
+	hResult := variables.Handle(args[0])
+	hMask := variables.Handle(args[1])
+	hMasked := variables.Handle(args[2])
+	hPrivateKey := variables.Handle(args[3])
+
+	c, err := getElGamalCiphertexts(s, hMask, hMasked)
+	if err != nil {
+		return "", err
+	}
+
+	privateKeys, err := variables.GetAs[*types.FTEd25519IntArray](s.Variables(), hPrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	privateKey, err := privateKeys.Single()
+	if err != nil {
+		return "", crypto.ErrNotSingletonKey
+	}
+
+	result, err := crypto.ElGamalDecrypt(privateKey, c)
+	if err != nil {
+		return "", err
+	}
+
+	s.Variables().Set(hResult, result)
+
+	return fmt.Sprintf("array E %v", hResult), nil
+}
+
+func ElGamalRerandomise(s SegmentHost, args []string) (string, error) {
+	hMaskResult := variables.Handle(args[0])
+	hMaskedResult := variables.Handle(args[1])
+	hMask := variables.Handle(args[2])
+	hMasked := variables.Handle(args[3])
+	hPublicKey := variables.Handle(args[4])
+
+	c, err := getElGamalCiphertexts(s, hMask, hMasked)
+	if err != nil {
+		return "", err
+	}
+
+	publicKey, err := variables.GetAs[*types.Ed25519Array](s.Variables(), hPublicKey)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := crypto.ElGamalRerandomise(publicKey, c)
+	if err != nil {
+		return "", err
+	}
+
+	return setElGamalCiphertexts(s, hMaskResult, hMaskedResult, result), nil
+}
+
+func ElGamalAdd(s SegmentHost, args []string) (string, error) {
+	hMaskResult := variables.Handle(args[0])
+	hMaskedResult := variables.Handle(args[1])
+
+	lhs, err := getElGamalCiphertexts(s, variables.Handle(args[2]), variables.Handle(args[3]))
+	if err != nil {
+		return "", err
+	}
+
+	rhs, err := getElGamalCiphertexts(s, variables.Handle(args[4]), variables.Handle(args[5]))
+	if err != nil {
+		return "", err
+	}
+
+	result, err := crypto.ElGamalAdd(lhs, rhs)
+	if err != nil {
+		return "", err
+	}
+
+	return setElGamalCiphertexts(s, hMaskResult, hMaskedResult, result), nil
+}
@@ -0,0 +1,127 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package commands
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/AUSTRAC/ftillite/Peer/segment/types"
+	"github.com/AUSTRAC/ftillite/Peer/segment/variables"
+)
+
+// Bloom and Cuckoo filters are held in arrays of typecode B<width> and C<width>, see
+// types.FilterArray. The builders return a singleton array of one filter of all the items,
+// sized for the number of items or the given capacity, whichever is larger.
+const (
+	CommandBloomFilter     = "command_bloom_filter"     // command_bloom_filter <hResult :: Handle→[1]B*> <hValues :: Handle→[](int64|[]byte)> <fpRate :: float64> [ <capacity :: int> ]
+	CommandCuckooFilter    = "command_cuckoo_filter"    // command_cuckoo_filter <hResult :: Handle→[1]C*> <hValues :: Handle→[](int64|[]byte)> <fpRate :: float64> [ <capacity :: int> ]
+	CommandFilterContains  = "command_filter_contains"  // command_filter_contains <hResult :: Handle→[]int64> <hFilter :: Handle→[](B*|C*)> <hValues :: Handle→[](int64|[]byte)>
+	CommandFilterUnion     = "command_filter_union"     // command_filter_union <hResult :: Handle→[](B*|C*)> <hLHS :: Handle→[](B*|C*)> <hRHS :: Handle→[](B*|C*)>
+	CommandFilterIntersect = "command_filter_intersect" // command_filter_intersect <hResult :: Handle→[](B*|C*)> <hLHS :: Handle→[](B*|C*)> <hRHS :: Handle→[](B*|C*)>
+)
+
+func filterBuilder(build func(items types.ArrayTypeVal, capacity int, fpRate float64) (*types.FilterArray, error)) CommandFunc {
+	return func(s SegmentHost, args []string) (string, error) {
+		hResult := variables.Handle(args[0])
+		hValues := variables.Handle(args[1])
+
+		fpRate, err := strconv.ParseFloat(args[2], 64)
+		if err != nil {
+			return "", err
+		}
+
+		values, err := variables.GetAs[types.ArrayTypeVal](s.Variables(), hValues)
+		if err != nil {
+			return "", err
+		}
+
+		capacity := int(values.Length())
+		if len(args) > 3 {
+			c, err := strconv.Atoi(args[3])
+			if err != nil {
+				return "", err
+			}
+			if c > capacity {
+				capacity = c
+			}
+		}
+
+		result, err := build(values, capacity, fpRate)
+		if err != nil {
+			return "", err
+		}
+
+		s.Variables().Set(hResult, result)
+
+		return fmt.Sprintf("array %v %v", result.TypeCode(), hResult), nil
+	}
+}
+
+var (
+	BloomFilter  = filterBuilder(types.NewBloomFilter)
+	CuckooFilter = filterBuilder(types.NewCuckooFilter)
+)
+
+func FilterContains(s SegmentHost, args []string) (string, error) {
+	hResult := variables.Handle(args[0])
+	hFilter := variables.Handle(args[1])
+	hValues := variables.Handle(args[2])
+
+	filter, err := variables.GetAs[*types.FilterArray](s.Variables(), hFilter)
+	if err != nil {
+		return "", err
+	}
+
+	values, err := variables.GetAs[types.ArrayTypeVal](s.Variables(), hValues)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := filter.MayContain(values)
+	if err != nil {
+		return "", err
+	}
+
+	s.Variables().Set(hResult, result)
+
+	return fmt.Sprintf("array i %v", hResult), nil
+}
+
+func filterOperator(f func(a, b *types.FilterArray) (*types.FilterArray, error)) CommandFunc {
+	return func(s SegmentHost, args []string) (string, error) {
+		hResult := variables.Handle(args[0])
+		hLHS := variables.Handle(args[1])
+		hRHS := variables.Handle(args[2])
+
+		lhs, err := variables.GetAs[*types.FilterArray](s.Variables(), hLHS)
+		if err != nil {
+			return "", err
+		}
+		rhs, err := variables.GetAs[*types.FilterArray](s.Variables(), hRHS)
+		if err != nil {
+			return "", err
+		}
+
+		result, err := f(lhs, rhs)
+		if err != nil {
+			return "", err
+		}
+
+		s.Variables().Set(hResult, result)
+
+		return fmt.Sprintf("array %v %v", result.TypeCode(), hResult), nil
+	}
+}
+
+var (
+	FilterUnion     = filterOperator((*types.FilterArray).Union)
+	FilterIntersect = filterOperator((*types.FilterArray).Intersect)
+)
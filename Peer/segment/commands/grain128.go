@@ -17,7 +17,11 @@ import (
 	"github.com/AUSTRAC/ftillite/Peer/segment/variables"
 )
 
-const CommandGrain128aeadv2 = "command_grain128aeadv2"
+const (
+	CommandGrain128aeadv2     = "command_grain128aeadv2"
+	CommandGrain128aeadv2Seal = "command_grain128aeadv2_seal"
+	CommandGrain128aeadv2Open = "command_grain128aeadv2_open"
+)
 
 func Grain128Aeadv2(s SegmentHost, args []string) (string, error) {
 	hTarget := variables.Handle(args[0])
@@ -57,3 +61,105 @@ func Grain128Aeadv2(s SegmentHost, args []string) (string, error) {
 
 	return fmt.Sprintf("array b%v %v", width, hTarget), nil
 }
+
+// Grain128Aeadv2Seal encrypts each element under a fresh nonce, authenticating it together
+// with the associated data, which is either a singleton or one element per element of data.
+func Grain128Aeadv2Seal(s SegmentHost, args []string) (string, error) {
+	hCiphertexts := variables.Handle(args[0])
+	hNonces := variables.Handle(args[1])
+	hTags := variables.Handle(args[2])
+	hData := variables.Handle(args[3])
+	hAssociatedData := variables.Handle(args[4])
+	hKey := variables.Handle(args[5])
+
+	key, err := variables.GetAsBytes(s.Variables(), hKey)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := getBytearrays(s, hData, 0)
+	if err != nil {
+		return "", err
+	}
+	associatedData, err := getBytearrays(s, hAssociatedData, 0)
+	if err != nil {
+		return "", err
+	}
+
+	ciphertextBytes, nonceBytes, tagBytes, err := crypto.Grain128Aeadv2Seal(key, data.Values(), associatedData.Values())
+	if err != nil {
+		return "", err
+	}
+
+	ciphertexts, err := types.NewFTBytearrayArray(int(data.Width()), ciphertextBytes...)
+	if err != nil {
+		return "", err
+	}
+	nonces, err := types.NewFTBytearrayArray(crypto.Grain128Aeadv2NonceBytes, nonceBytes...)
+	if err != nil {
+		return "", err
+	}
+	tags, err := types.NewFTBytearrayArray(crypto.Grain128Aeadv2TagBytes, tagBytes...)
+	if err != nil {
+		return "", err
+	}
+
+	s.Variables().Set(hCiphertexts, ciphertexts)
+	s.Variables().Set(hNonces, nonces)
+	s.Variables().Set(hTags, tags)
+
+	return fmt.Sprintf("array b%v %v array b%v %v array b%v %v",
+		ciphertexts.Width(), hCiphertexts,
+		crypto.Grain128Aeadv2NonceBytes, hNonces,
+		crypto.Grain128Aeadv2TagBytes, hTags,
+	), nil
+}
+
+// Grain128Aeadv2Open also returns an integer mask, holding 0 for each element which failed
+// authentication. Such elements decrypt to zeroes.
+func Grain128Aeadv2Open(s SegmentHost, args []string) (string, error) {
+	hPlaintexts := variables.Handle(args[0])
+	hValid := variables.Handle(args[1])
+	hCiphertexts := variables.Handle(args[2])
+	hNonces := variables.Handle(args[3])
+	hTags := variables.Handle(args[4])
+	hAssociatedData := variables.Handle(args[5])
+	hKey := variables.Handle(args[6])
+
+	key, err := variables.GetAsBytes(s.Variables(), hKey)
+	if err != nil {
+		return "", err
+	}
+
+	ciphertexts, err := getBytearrays(s, hCiphertexts, 0)
+	if err != nil {
+		return "", err
+	}
+	nonces, err := getBytearrays(s, hNonces, crypto.Grain128Aeadv2NonceBytes)
+	if err != nil {
+		return "", err
+	}
+	tags, err := getBytearrays(s, hTags, crypto.Grain128Aeadv2TagBytes)
+	if err != nil {
+		return "", err
+	}
+	associatedData, err := getBytearrays(s, hAssociatedData, 0)
+	if err != nil {
+		return "", err
+	}
+
+	plaintextBytes, valid, err := crypto.Grain128Aeadv2Open(key, ciphertexts.Values(), nonces.Values(), tags.Values(), associatedData.Values())
+	if err != nil {
+		return "", err
+	}
+
+	plaintexts, err := types.NewFTBytearrayArray(int(ciphertexts.Width()), plaintextBytes...)
+	if err != nil {
+		return "", err
+	}
+
+	s.Variables().Set(hPlaintexts, plaintexts)
+	s.Variables().Set(hValid, types.NewFTIntegerArray(valid...))
+
+	return fmt.Sprintf("array b%v %v array i %v", plaintexts.Width(), hPlaintexts, hValid), nil
+}
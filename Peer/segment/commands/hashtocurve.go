@@ -0,0 +1,76 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package commands
+
+import (
+	"fmt"
+
+	"github.com/AUSTRAC/ftillite/Peer/segment/crypto"
+	"github.com/AUSTRAC/ftillite/Peer/segment/types"
+	"github.com/AUSTRAC/ftillite/Peer/segment/variables"
+)
+
+const (
+	CommandHashToCurve  = "command_hash_to_curve"  // command_hash_to_curve <hTarget :: Handle→[]Ed25519> <hData :: Handle→[]b*> <dst :: string>
+	CommandHashToScalar = "command_hash_to_scalar" // command_hash_to_scalar <hTarget :: Handle→[]Ed25519Int> <hData :: Handle→[]b*> <dst :: string>
+)
+
+func HashToCurve(s SegmentHost, args []string) (string, error) {
+	hTarget := variables.Handle(args[0])
+	hData := variables.Handle(args[1])
+
+	dst, err := stringArg(args, 2, "dst")
+	if err != nil {
+		return "", err
+	}
+
+	data, err := variables.GetAs[*types.FTBytearrayArray](s.Variables(), hData)
+	if err != nil {
+		return "", err
+	}
+
+	points, err := crypto.HashToCurve(data.Values(), dst)
+	if err != nil {
+		return "", err
+	}
+
+	target, err := types.NewEd25519ArrayFromPointsWithBackend(s.Ed25519Backend(), points)
+	if err != nil {
+		return "", err
+	}
+
+	s.Variables().Set(hTarget, target)
+
+	return fmt.Sprintf("array E %v", hTarget), nil
+}
+
+func HashToScalar(s SegmentHost, args []string) (string, error) {
+	hTarget := variables.Handle(args[0])
+	hData := variables.Handle(args[1])
+
+	dst, err := stringArg(args, 2, "dst")
+	if err != nil {
+		return "", err
+	}
+
+	data, err := variables.GetAs[*types.FTBytearrayArray](s.Variables(), hData)
+	if err != nil {
+		return "", err
+	}
+
+	scalars, err := crypto.HashToScalar(data.Values(), dst)
+	if err != nil {
+		return "", err
+	}
+
+	s.Variables().Set(hTarget, types.NewFTEd25519IntArray(scalars...))
+
+	return fmt.Sprintf("array I %v", hTarget), nil
+}
@@ -24,6 +24,11 @@ const (
 func Init(s SegmentHost, args []string) (string, error) {
 	s.ClearTimingInformation()
 
+	// Each session starts with a fresh privacy budget
+	if accountant, err := s.PrivacyAccountant(); err == nil {
+		accountant.Reset()
+	}
+
 	s.Variables().Set("0", types.NewFTIntegerArray(s.Node().NodeID()))
 
 	var gpuEnabled string
@@ -0,0 +1,193 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package commands
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/AUSTRAC/ftillite/Peer/segment/crypto"
+	"github.com/AUSTRAC/ftillite/Peer/segment/keystore"
+	"github.com/AUSTRAC/ftillite/Peer/segment/types"
+	"github.com/AUSTRAC/ftillite/Peer/segment/variables"
+)
+
+// Keys are generated directly into the keystore and only leave it when loaded into a handle,
+// so that they never pass through the coordinator or the pickle table. The kind of a key is
+// one of ecdsa256, rsa2048, rsa3072, rsa4096, ed25519, x25519, I for an ElGamal private key,
+// or bN for N random bytes.
+const (
+	CommandKeystoreGenerate = "command_keystore_generate" // command_keystore_generate <name :: string> <kind :: string>
+	CommandKeystoreRotate   = "command_keystore_rotate"   // command_keystore_rotate <name :: string>
+	CommandKeystoreLoad     = "command_keystore_load"     // command_keystore_load <hTarget :: Handle→[1]b*|[1]Ed25519Int> <name :: string> [<version :: int64>]
+	CommandKeystoreDelete   = "command_keystore_delete"   // command_keystore_delete <name :: string> [<version :: int64>]
+)
+
+var keystoreGenerators = map[string]func() ([]byte, error){
+	"ecdsa256": crypto.ECDSA256Keygen,
+	"rsa2048":  func() ([]byte, error) { return crypto.RSAKeygen(2048) },
+	"rsa3072":  func() ([]byte, error) { return crypto.RSAKeygen(3072) },
+	"rsa4096":  func() ([]byte, error) { return crypto.RSAKeygen(4096) },
+	"ed25519":  crypto.Ed25519Keygen,
+	"x25519":   crypto.X25519Keygen,
+	"I": func() ([]byte, error) {
+		k, err := types.NewRandomFTEd25519IntArray(true, 1)
+		if err != nil {
+			return nil, err
+		}
+		return types.ScalarsToBytes(k.Values()), nil
+	},
+}
+
+func generateKeystoreKey(kind string) ([]byte, error) {
+	if f, ok := keystoreGenerators[kind]; ok {
+		return f()
+	}
+
+	tc, err := types.ParseTypeCode(kind)
+	if err != nil || !tc.IsBytearray() || tc.Length() < 1 {
+		return nil, fmt.Errorf("unsupported key kind %v", kind)
+	}
+
+	bs := make([]byte, tc.Length())
+	_, err = rand.Read(bs)
+	if err != nil {
+		return nil, err
+	}
+	return bs, nil
+}
+
+func parseKeystoreVersion(args []string, index int) (int, error) {
+	if len(args) <= index {
+		return 0, nil
+	}
+
+	version, err := strconv.Atoi(args[index])
+	if err != nil {
+		return 0, err
+	}
+	if version < 1 {
+		return 0, errors.New("key version must be at least 1")
+	}
+	return version, nil
+}
+
+func KeystoreGenerate(s SegmentHost, args []string) (string, error) {
+	name := args[0]
+	kind := args[1]
+
+	ks, err := s.Keystore()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = ks.Get(name, 0)
+	if err == nil {
+		return "", fmt.Errorf("key %v already exists, use %v to replace it", name, CommandKeystoreRotate)
+	}
+	if !errors.Is(err, keystore.ErrKeyNotFound) {
+		return "", err
+	}
+
+	key, err := generateKeystoreKey(kind)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = ks.Put(name, kind, key)
+	if err != nil {
+		return "", err
+	}
+
+	return Ack, nil
+}
+
+func KeystoreRotate(s SegmentHost, args []string) (string, error) {
+	name := args[0]
+
+	ks, err := s.Keystore()
+	if err != nil {
+		return "", err
+	}
+
+	latest, err := ks.Get(name, 0)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := generateKeystoreKey(latest.Kind)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = ks.Put(name, latest.Kind, key)
+	if err != nil {
+		return "", err
+	}
+
+	return Ack, nil
+}
+
+func KeystoreLoad(s SegmentHost, args []string) (string, error) {
+	hTarget := variables.Handle(args[0])
+	name := args[1]
+
+	version, err := parseKeystoreVersion(args, 2)
+	if err != nil {
+		return "", err
+	}
+
+	ks, err := s.Keystore()
+	if err != nil {
+		return "", err
+	}
+
+	e, err := ks.Get(name, version)
+	if err != nil {
+		return "", err
+	}
+
+	var target types.ArrayTypeVal
+	if e.Kind == "I" {
+		target, err = types.NewFTEd25519IntArrayFromBytes(e.Key)
+	} else {
+		target, err = types.NewFTBytearrayArray(len(e.Key), e.Key)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	s.Variables().Set(hTarget, target)
+
+	return fmt.Sprintf("array %v %v", target.TypeCode(), hTarget), nil
+}
+
+func KeystoreDelete(s SegmentHost, args []string) (string, error) {
+	name := args[0]
+
+	version, err := parseKeystoreVersion(args, 1)
+	if err != nil {
+		return "", err
+	}
+
+	ks, err := s.Keystore()
+	if err != nil {
+		return "", err
+	}
+
+	err = ks.Delete(name, version)
+	if err != nil {
+		return "", err
+	}
+
+	return Ack, nil
+}
@@ -0,0 +1,89 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/AUSTRAC/ftillite/Peer/segment/crypto"
+	"github.com/AUSTRAC/ftillite/Peer/segment/types"
+	"github.com/AUSTRAC/ftillite/Peer/segment/variables"
+)
+
+const (
+	CommandHMACSHA256  = "command_hmac_sha256"   // command_hmac_sha256 <hTarget :: Handle→[]b*> <hData :: Handle→[]b*> <hKey :: Handle→[1]b*> <width :: int64> [<label :: string>]
+	CommandHMACSHA3256 = "command_hmac_sha3_256" // command_hmac_sha3_256 <hTarget :: Handle→[]b*> <hData :: Handle→[]b*> <hKey :: Handle→[1]b*> <width :: int64> [<label :: string>]
+	CommandKMAC256     = "command_kmac256"       // command_kmac256 <hTarget :: Handle→[]b*> <hData :: Handle→[]b*> <hKey :: Handle→[1]b*> <width :: int64> [<label :: string>]
+)
+
+// stringArg returns the string argument at i, which must be the last argument. Commands are
+// split on "__", so a string which contained it would arrive cut short, or as several
+// arguments, and is refused.
+func stringArg(args []string, i int, name string) ([]byte, error) {
+	if len(args) != i+1 || args[i] == "" || strings.Contains(args[i], "__") {
+		return nil, fmt.Errorf(`%v must be the final argument, non-empty and without "__"`, name)
+	}
+	return []byte(args[i]), nil
+}
+
+func mac(s SegmentHost, args []string, f func(key []byte, data [][]byte, width int, label []byte) ([][]byte, error)) (string, error) {
+	hTarget := variables.Handle(args[0])
+	hData := variables.Handle(args[1])
+	hKey := variables.Handle(args[2])
+
+	width, err := strconv.Atoi(args[3])
+	if err != nil {
+		return "", err
+	}
+
+	var label []byte
+	if len(args) > 4 {
+		label, err = stringArg(args, 4, "label")
+		if err != nil {
+			return "", err
+		}
+	}
+
+	key, err := variables.GetAsBytes(s.Variables(), hKey)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := variables.GetAs[*types.FTBytearrayArray](s.Variables(), hData)
+	if err != nil {
+		return "", err
+	}
+
+	targetBytes, err := f(key, data.Values(), width, label)
+	if err != nil {
+		return "", err
+	}
+
+	target, err := types.NewFTBytearrayArray(width, targetBytes...)
+	if err != nil {
+		return "", err
+	}
+
+	s.Variables().Set(hTarget, target)
+
+	return fmt.Sprintf("array b%v %v", width, hTarget), nil
+}
+
+func HmacSha256(s SegmentHost, args []string) (string, error) {
+	return mac(s, args, crypto.HmacSha256)
+}
+func HmacSha3256(s SegmentHost, args []string) (string, error) {
+	return mac(s, args, crypto.HmacSha3256)
+}
+func Kmac256(s SegmentHost, args []string) (string, error) {
+	return mac(s, args, crypto.Kmac256)
+}
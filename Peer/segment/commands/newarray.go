@@ -123,10 +123,6 @@ func NewArray(s SegmentHost, args []string) (string, error) {
 		s.Variables().Set(hResult, types.NewFTEd25519IntArray(xs...))
 
 	case types.Ed25519B:
-		if !s.IsGPUAvailable() {
-			return "", ErrEd25519Unavailable
-		}
-
 		var xs *types.Ed25519Array
 		var err error
 
@@ -143,7 +139,7 @@ func NewArray(s SegmentHost, args []string) (string, error) {
 					return "", err
 				}
 				v := types.Int64ToScalar(x)
-				xs, err = types.NewEd25519Array(length, v)
+				xs, err = types.NewEd25519ArrayWithBackend(s.Ed25519Backend(), length, v)
 				if err != nil {
 					return "", err
 				}
@@ -154,7 +150,7 @@ func NewArray(s SegmentHost, args []string) (string, error) {
 				if err != nil {
 					return "", err
 				}
-				xs, err = types.NewEd25519Array(length, x)
+				xs, err = types.NewEd25519ArrayWithBackend(s.Ed25519Backend(), length, x)
 				if err != nil {
 					return "", err
 				}
@@ -162,9 +158,65 @@ func NewArray(s SegmentHost, args []string) (string, error) {
 				err = errors.New("value must be a singleton array of Integer, Ed25519Int or Ed25519")
 			}
 		} else {
-			xs, err = types.NewEd25519Array(length, nil)
+			xs, err = types.NewEd25519ArrayWithBackend(s.Ed25519Backend(), length, nil)
+		}
+
+		if err != nil {
+			return "", err
 		}
 
+		s.Variables().Set(hResult, xs)
+
+	case types.RistrettoB:
+		var x *edwards25519.Point
+
+		if len(args) > 3 {
+			value, err := s.Variables().Get(variables.Handle(args[3]))
+			if err != nil {
+				return "", err
+			}
+
+			if vIntegerArr, ok := value.(*types.FTIntegerArray); ok {
+				v, err := vIntegerArr.Single()
+				if err != nil {
+					return "", err
+				}
+				x = new(edwards25519.Point).ScalarBaseMult(types.Int64ToScalar(v))
+			} else if vEd25519IntArr, ok := value.(*types.FTEd25519IntArray); ok {
+				v, err := vEd25519IntArr.Single()
+				if err != nil {
+					return "", err
+				}
+				x = new(edwards25519.Point).ScalarBaseMult(v)
+			} else if vRistrettoArr, ok := value.(*types.RistrettoArray); ok {
+				x, err = vRistrettoArr.Single()
+				if err != nil {
+					return "", err
+				}
+			} else {
+				return "", errors.New("value must be a singleton array of Integer, Ed25519Int or Ristretto")
+			}
+		} else {
+			x = edwards25519.NewIdentityPoint()
+		}
+
+		xs := make([]*edwards25519.Point, length)
+		for i := range xs {
+			xs[i] = x
+		}
+
+		s.Variables().Set(hResult, types.NewRistrettoArray(xs...))
+
+	case types.PaillierB:
+		xs, err := types.NewPaillierArrayOfZeros(tc.Length(), length)
+		if err != nil {
+			return "", err
+		}
+
+		s.Variables().Set(hResult, xs)
+
+	case types.BloomFilterB, types.CuckooFilterB:
+		xs, err := types.NewEmptyFilterArray(tc, length)
 		if err != nil {
 			return "", err
 		}
@@ -0,0 +1,182 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package commands
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/AUSTRAC/ftillite/Peer/segment/privacy"
+	"github.com/AUSTRAC/ftillite/Peer/segment/types"
+	"github.com/AUSTRAC/ftillite/Peer/segment/variables"
+)
+
+// The noise commands add differentially private noise to an array in place. Each command is
+// one release, whose epsilon and delta are charged to the privacy budget of the session
+// before any noise is added, and which is refused once the budget is spent. The sensitivity is
+// the L1 sensitivity of the query for Laplace and geometric noise, and the L2 sensitivity for
+// Gaussian noise. Geometric and Gaussian noise are integers, and are only added to Integer
+// arrays, of queries with an integer sensitivity.
+const (
+	CommandLaplaceNoise   = "command_laplace_noise"   // command_laplace_noise <hData :: Handle→[]float64> <epsilon :: float64> <sensitivity :: float64>
+	CommandGeometricNoise = "command_geometric_noise" // command_geometric_noise <hData :: Handle→[]int64> <epsilon :: float64> <sensitivity :: int64>
+	CommandGaussianNoise  = "command_gaussian_noise"  // command_gaussian_noise <hData :: Handle→[]int64> <epsilon :: float64> <delta :: float64> <sensitivity :: int64>
+	CommandPrivacyBudget  = "command_privacy_budget"  // command_privacy_budget <hResult :: Handle→[2]float64>
+)
+
+func parseNoiseParameters(args []string) ([]float64, error) {
+	result := make([]float64, len(args))
+	for i, v := range args {
+		var err error
+		result[i], err = strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// checkIntegerSensitivity refuses a sensitivity which is not an integer, as integer noise does
+// not hide a difference of a fraction.
+func checkIntegerSensitivity(sensitivity float64) error {
+	if sensitivity != math.Trunc(sensitivity) {
+		return fmt.Errorf("the sensitivity of integer noise must be an integer, got %v", sensitivity)
+	}
+	return nil
+}
+
+func addIntegerNoise(s SegmentHost, hData variables.Handle, epsilon float64, delta float64, sample func(n int) ([]int64, error)) error {
+	data, err := variables.GetAs[*types.FTIntegerArray](s.Variables(), hData)
+	if err != nil {
+		return err
+	}
+
+	accountant, err := s.PrivacyAccountant()
+	if err != nil {
+		return err
+	}
+	if err := accountant.Spend(epsilon, delta); err != nil {
+		return err
+	}
+
+	xs := data.Values()
+	noise, err := sample(len(xs))
+	if err != nil {
+		return err
+	}
+	for i, v := range noise {
+		xs[i] += v
+	}
+
+	return nil
+}
+
+func LaplaceNoise(s SegmentHost, args []string) (string, error) {
+	hData := variables.Handle(args[0])
+
+	params, err := parseNoiseParameters(args[1:3])
+	if err != nil {
+		return "", err
+	}
+	epsilon, sensitivity := params[0], params[1]
+
+	// The parameters are checked before the budget is spent
+	if _, err := privacy.LaplaceScale(epsilon, sensitivity); err != nil {
+		return "", err
+	}
+
+	data, err := variables.GetAs[*types.FTFloatArray](s.Variables(), hData)
+	if err != nil {
+		return "", err
+	}
+
+	accountant, err := s.PrivacyAccountant()
+	if err != nil {
+		return "", err
+	}
+	if err := accountant.Spend(epsilon, 0); err != nil {
+		return "", err
+	}
+
+	if err := privacy.AddLaplace(data.Values(), epsilon, sensitivity); err != nil {
+		return "", err
+	}
+
+	return Ack, nil
+}
+
+func GeometricNoise(s SegmentHost, args []string) (string, error) {
+	hData := variables.Handle(args[0])
+
+	params, err := parseNoiseParameters(args[1:3])
+	if err != nil {
+		return "", err
+	}
+	epsilon, sensitivity := params[0], params[1]
+
+	scale, err := privacy.LaplaceScale(epsilon, sensitivity)
+	if err != nil {
+		return "", err
+	}
+	if err := checkIntegerSensitivity(sensitivity); err != nil {
+		return "", err
+	}
+
+	err = addIntegerNoise(s, hData, epsilon, 0, func(n int) ([]int64, error) {
+		return privacy.SampleGeometric(scale, n)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return Ack, nil
+}
+
+func GaussianNoise(s SegmentHost, args []string) (string, error) {
+	hData := variables.Handle(args[0])
+
+	params, err := parseNoiseParameters(args[1:4])
+	if err != nil {
+		return "", err
+	}
+	epsilon, delta, sensitivity := params[0], params[1], params[2]
+
+	sigma, err := privacy.GaussianSigma(epsilon, delta, sensitivity)
+	if err != nil {
+		return "", err
+	}
+	if err := checkIntegerSensitivity(sensitivity); err != nil {
+		return "", err
+	}
+
+	err = addIntegerNoise(s, hData, epsilon, delta, func(n int) ([]int64, error) {
+		return privacy.SampleDiscreteGaussian(sigma, n)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return Ack, nil
+}
+
+func PrivacyBudget(s SegmentHost, args []string) (string, error) {
+	hResult := variables.Handle(args[0])
+
+	accountant, err := s.PrivacyAccountant()
+	if err != nil {
+		return "", err
+	}
+
+	epsilon, delta := accountant.Remaining()
+	s.Variables().Set(hResult, types.NewFTFloatArray(epsilon, delta))
+
+	return fmt.Sprintf("array f %v", hResult), nil
+}
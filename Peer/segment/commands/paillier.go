@@ -0,0 +1,112 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package commands
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/AUSTRAC/ftillite/Peer/segment/crypto"
+	"github.com/AUSTRAC/ftillite/Peer/segment/types"
+	"github.com/AUSTRAC/ftillite/Peer/segment/variables"
+)
+
+// Paillier ciphertexts are held in arrays of typecode P<width>, which are added, subtracted
+// and multiplied by Integer arrays with the usual operators. The key size is given by the
+// width of the bytearray holding the key, see crypto.PaillierKeySizes.
+const (
+	CommandPaillierKeygen    = "command_paillier_keygen"     // command_paillier_keygen <hPrivateKey :: Handle→[1]b*> <bits :: int>
+	CommandPaillierPublicKey = "command_paillier_public_key" // command_paillier_public_key <hPublicKey :: Handle→[1]b*> <hPrivateKey :: Handle→[1]b*>
+	CommandPaillierEncrypt   = "command_paillier_encrypt"    // command_paillier_encrypt <hTarget :: Handle→[]P*> <hData :: Handle→[]int64> <hPublicKey :: Handle→[1]b*>
+	CommandPaillierDecrypt   = "command_paillier_decrypt"    // command_paillier_decrypt <hTarget :: Handle→[]int64> <hData :: Handle→[]P*> <hPrivateKey :: Handle→[1]b*>
+)
+
+func PaillierKeygen(s SegmentHost, args []string) (string, error) {
+	hTarget := variables.Handle(args[0])
+
+	bits, err := strconv.Atoi(args[1])
+	if err != nil {
+		return "", err
+	}
+
+	bs, err := crypto.PaillierKeygen(bits)
+	if err != nil {
+		return "", err
+	}
+
+	return setRSAKey(s, hTarget, bs)
+}
+
+func PaillierPublicKey(s SegmentHost, args []string) (string, error) {
+	hTarget := variables.Handle(args[0])
+	hPrivateKey := variables.Handle(args[1])
+
+	xs, err := variables.GetAsBytes(s.Variables(), hPrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	bs, err := crypto.PaillierPublicKey(xs)
+	if err != nil {
+		return "", err
+	}
+
+	return setRSAKey(s, hTarget, bs)
+}
+
+func PaillierEncrypt(s SegmentHost, args []string) (string, error) {
+	hTarget := variables.Handle(args[0])
+	hData := variables.Handle(args[1])
+	hPublicKey := variables.Handle(args[2])
+
+	data, err := variables.GetAs[*types.FTIntegerArray](s.Variables(), hData)
+	if err != nil {
+		return "", err
+	}
+
+	pk, err := variables.GetAsBytes(s.Variables(), hPublicKey)
+	if err != nil {
+		return "", err
+	}
+
+	target, err := crypto.PaillierEncrypt(pk, data.Values())
+	if err != nil {
+		return "", err
+	}
+
+	s.Variables().Set(hTarget, target)
+
+	return fmt.Sprintf("array %v %v", target.TypeCode(), hTarget), nil
+}
+
+func PaillierDecrypt(s SegmentHost, args []string) (string, error) {
+	hTarget := variables.Handle(args[0])
+	hData := variables.Handle(args[1])
+	hPrivateKey := variables.Handle(args[2])
+
+	data, err := variables.GetAs[*types.PaillierArray](s.Variables(), hData)
+	if err != nil {
+		return "", err
+	}
+
+	pk, err := variables.GetAsBytes(s.Variables(), hPrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	xs, err := crypto.PaillierDecrypt(pk, data)
+	if err != nil {
+		return "", err
+	}
+
+	s.Variables().Set(hTarget, types.NewFTIntegerArray(xs...))
+
+	return fmt.Sprintf("array i %v", hTarget), nil
+}
@@ -0,0 +1,293 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package commands
+
+import (
+	"fmt"
+
+	"github.com/AUSTRAC/ftillite/Peer/segment/crypto"
+	"github.com/AUSTRAC/ftillite/Peer/segment/types"
+	"github.com/AUSTRAC/ftillite/Peer/segment/variables"
+)
+
+// Each proof command produces one proof per element, as a b64 array, and each verify command
+// returns an integer array holding 1 where the proof holds and 0 where it does not.
+const (
+	CommandSchnorrProve                = "command_schnorr_prove"                  // command_schnorr_prove <hProofs :: Handle→[]b64> <hPrivateKeys :: Handle→[]Ed25519Int>
+	CommandSchnorrVerify               = "command_schnorr_verify"                 // command_schnorr_verify <hResult :: Handle→[]int64> <hProofs :: Handle→[]b64> <hPublicKeys :: Handle→[]Ed25519>
+	CommandElGamalDecryptProve         = "command_elgamal_decrypt_prove"          // command_elgamal_decrypt_prove <hResult :: Handle→[]Ed25519> <hProofs :: Handle→[]b64> <hMask :: Handle→[]Ed25519> <hMasked :: Handle→[]Ed25519> <hPrivateKey :: Handle→[1]Ed25519Int>
+	CommandElGamalDecryptVerify        = "command_elgamal_decrypt_verify"         // command_elgamal_decrypt_verify <hResult :: Handle→[]int64> <hProofs :: Handle→[]b64> <hMessages :: Handle→[]Ed25519> <hMask :: Handle→[]Ed25519> <hMasked :: Handle→[]Ed25519> <hPublicKey :: Handle→[1]Ed25519>
+	CommandElGamalPartialDecryptProve  = "command_elgamal_partial_decrypt_prove"  // command_elgamal_partial_decrypt_prove <hResult :: Handle→[]Ed25519> <hProofs :: Handle→[]b64> <hMask :: Handle→[]Ed25519> <hShare :: Handle→[1]Ed25519Int>
+	CommandElGamalPartialDecryptVerify = "command_elgamal_partial_decrypt_verify" // command_elgamal_partial_decrypt_verify <hResult :: Handle→[]int64> <hProofs :: Handle→[]b64> <hPartial :: Handle→[]Ed25519> <hMask :: Handle→[]Ed25519> <hVerificationKey :: Handle→[1]Ed25519>
+	CommandElGamalRerandomiseProve     = "command_elgamal_rerandomise_prove"      // command_elgamal_rerandomise_prove <hMaskResult :: Handle→[]Ed25519> <hMaskedResult :: Handle→[]Ed25519> <hProofs :: Handle→[]b64> <hMask :: Handle→[]Ed25519> <hMasked :: Handle→[]Ed25519> <hPublicKey :: Handle→[1]Ed25519>
+	CommandElGamalRerandomiseVerify    = "command_elgamal_rerandomise_verify"     // command_elgamal_rerandomise_verify <hResult :: Handle→[]int64> <hProofs :: Handle→[]b64> <hMaskR :: Handle→[]Ed25519> <hMaskedR :: Handle→[]Ed25519> <hMask :: Handle→[]Ed25519> <hMasked :: Handle→[]Ed25519> <hPublicKey :: Handle→[1]Ed25519>
+)
+
+func getProofs(s SegmentHost, hProofs variables.Handle) ([][]byte, error) {
+	proofs, err := variables.GetAs[*types.FTBytearrayArray](s.Variables(), hProofs)
+	if err != nil {
+		return nil, err
+	}
+
+	if proofs.Width() != crypto.ProofBytes {
+		return nil, crypto.ErrInvalidProofWidth
+	}
+
+	return proofs.Values(), nil
+}
+
+func setProofs(s SegmentHost, hProofs variables.Handle, proofs [][]byte) (string, error) {
+	target, err := types.NewFTBytearrayArray(crypto.ProofBytes, proofs...)
+	if err != nil {
+		return "", err
+	}
+
+	s.Variables().Set(hProofs, target)
+
+	return fmt.Sprintf("array b%v %v", crypto.ProofBytes, hProofs), nil
+}
+
+func setVerifyResult(s SegmentHost, hResult variables.Handle, result []int64) string {
+	s.Variables().Set(hResult, types.NewFTIntegerArray(result...))
+
+	return fmt.Sprintf("array i %v", hResult)
+}
+
+func SchnorrProve(s SegmentHost, args []string) (string, error) {
+	hProofs := variables.Handle(args[0])
+	hPrivateKeys := variables.Handle(args[1])
+
+	privateKeys, err := variables.GetAs[*types.FTEd25519IntArray](s.Variables(), hPrivateKeys)
+	if err != nil {
+		return "", err
+	}
+
+	proofs, err := crypto.SchnorrProve(privateKeys.Values())
+	if err != nil {
+		return "", err
+	}
+
+	return setProofs(s, hProofs, proofs)
+}
+
+func SchnorrVerify(s SegmentHost, args []string) (string, error) {
+	hResult := variables.Handle(args[0])
+	hProofs := variables.Handle(args[1])
+	hPublicKeys := variables.Handle(args[2])
+
+	proofs, err := getProofs(s, hProofs)
+	if err != nil {
+		return "", err
+	}
+
+	publicKeys, err := variables.GetAs[*types.Ed25519Array](s.Variables(), hPublicKeys)
+	if err != nil {
+		return "", err
+	}
+
+	points, err := publicKeys.Points()
+	if err != nil {
+		return "", err
+	}
+
+	result, err := crypto.SchnorrVerify(points, proofs)
+	if err != nil {
+		return "", err
+	}
+
+	return setVerifyResult(s, hResult, result), nil
+}
+
+func ElGamalDecryptProve(s SegmentHost, args []string) (string, error) {
+	hResult := variables.Handle(args[0])
+	hProofs := variables.Handle(args[1])
+
+	c, err := getElGamalCiphertexts(s, variables.Handle(args[2]), variables.Handle(args[3]))
+	if err != nil {
+		return "", err
+	}
+
+	privateKeys, err := variables.GetAs[*types.FTEd25519IntArray](s.Variables(), variables.Handle(args[4]))
+	if err != nil {
+		return "", err
+	}
+
+	privateKey, err := privateKeys.Single()
+	if err != nil {
+		return "", crypto.ErrNotSingletonKey
+	}
+
+	result, proofs, err := crypto.ElGamalDecryptProve(privateKey, c)
+	if err != nil {
+		return "", err
+	}
+
+	proofsResponse, err := setProofs(s, hProofs, proofs)
+	if err != nil {
+		result.Free()
+		return "", err
+	}
+
+	s.Variables().Set(hResult, result)
+
+	return fmt.Sprintf("array E %v %v", hResult, proofsResponse), nil
+}
+
+func ElGamalDecryptVerify(s SegmentHost, args []string) (string, error) {
+	hResult := variables.Handle(args[0])
+
+	proofs, err := getProofs(s, variables.Handle(args[1]))
+	if err != nil {
+		return "", err
+	}
+
+	messages, err := variables.GetAs[*types.Ed25519Array](s.Variables(), variables.Handle(args[2]))
+	if err != nil {
+		return "", err
+	}
+
+	c, err := getElGamalCiphertexts(s, variables.Handle(args[3]), variables.Handle(args[4]))
+	if err != nil {
+		return "", err
+	}
+
+	publicKey, err := variables.GetAs[*types.Ed25519Array](s.Variables(), variables.Handle(args[5]))
+	if err != nil {
+		return "", err
+	}
+
+	result, err := crypto.ElGamalDecryptVerify(publicKey, c, messages, proofs)
+	if err != nil {
+		return "", err
+	}
+
+	return setVerifyResult(s, hResult, result), nil
+}
+
+func ElGamalPartialDecryptProve(s SegmentHost, args []string) (string, error) {
+	hResult := variables.Handle(args[0])
+	hProofs := variables.Handle(args[1])
+
+	mask, err := variables.GetAs[*types.Ed25519Array](s.Variables(), variables.Handle(args[2]))
+	if err != nil {
+		return "", err
+	}
+
+	share, err := variables.GetAsEd25519Integer(s.Variables(), variables.Handle(args[3]))
+	if err != nil {
+		return "", err
+	}
+
+	result, proofs, err := crypto.ThresholdPartialDecryptProve(share, mask)
+	if err != nil {
+		return "", err
+	}
+
+	proofsResponse, err := setProofs(s, hProofs, proofs)
+	if err != nil {
+		result.Free()
+		return "", err
+	}
+
+	s.Variables().Set(hResult, result)
+
+	return fmt.Sprintf("array E %v %v", hResult, proofsResponse), nil
+}
+
+func ElGamalPartialDecryptVerify(s SegmentHost, args []string) (string, error) {
+	hResult := variables.Handle(args[0])
+
+	proofs, err := getProofs(s, variables.Handle(args[1]))
+	if err != nil {
+		return "", err
+	}
+
+	partials, err := variables.GetAs[*types.Ed25519Array](s.Variables(), variables.Handle(args[2]))
+	if err != nil {
+		return "", err
+	}
+
+	mask, err := variables.GetAs[*types.Ed25519Array](s.Variables(), variables.Handle(args[3]))
+	if err != nil {
+		return "", err
+	}
+
+	verificationKey, err := variables.GetAs[*types.Ed25519Array](s.Variables(), variables.Handle(args[4]))
+	if err != nil {
+		return "", err
+	}
+
+	result, err := crypto.ThresholdPartialDecryptVerify(verificationKey, mask, partials, proofs)
+	if err != nil {
+		return "", err
+	}
+
+	return setVerifyResult(s, hResult, result), nil
+}
+
+func ElGamalRerandomiseProve(s SegmentHost, args []string) (string, error) {
+	hMaskResult := variables.Handle(args[0])
+	hMaskedResult := variables.Handle(args[1])
+	hProofs := variables.Handle(args[2])
+
+	c, err := getElGamalCiphertexts(s, variables.Handle(args[3]), variables.Handle(args[4]))
+	if err != nil {
+		return "", err
+	}
+
+	publicKey, err := variables.GetAs[*types.Ed25519Array](s.Variables(), variables.Handle(args[5]))
+	if err != nil {
+		return "", err
+	}
+
+	result, proofs, err := crypto.ElGamalRerandomiseProve(publicKey, c)
+	if err != nil {
+		return "", err
+	}
+
+	proofsResponse, err := setProofs(s, hProofs, proofs)
+	if err != nil {
+		result.Free()
+		return "", err
+	}
+
+	return fmt.Sprintf("%v %v", setElGamalCiphertexts(s, hMaskResult, hMaskedResult, result), proofsResponse), nil
+}
+
+func ElGamalRerandomiseVerify(s SegmentHost, args []string) (string, error) {
+	hResult := variables.Handle(args[0])
+
+	proofs, err := getProofs(s, variables.Handle(args[1]))
+	if err != nil {
+		return "", err
+	}
+
+	result, err := getElGamalCiphertexts(s, variables.Handle(args[2]), variables.Handle(args[3]))
+	if err != nil {
+		return "", err
+	}
+
+	c, err := getElGamalCiphertexts(s, variables.Handle(args[4]), variables.Handle(args[5]))
+	if err != nil {
+		return "", err
+	}
+
+	publicKey, err := variables.GetAs[*types.Ed25519Array](s.Variables(), variables.Handle(args[6]))
+	if err != nil {
+		return "", err
+	}
+
+	verified, err := crypto.ElGamalRerandomiseVerify(publicKey, c, result, proofs)
+	if err != nil {
+		return "", err
+	}
+
+	return setVerifyResult(s, hResult, verified), nil
+}
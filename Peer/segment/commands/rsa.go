@@ -10,7 +10,9 @@
 package commands
 
 import (
+	"crypto/rsa"
 	"fmt"
+	"strconv"
 
 	"github.com/AUSTRAC/ftillite/Peer/segment/crypto"
 	"github.com/AUSTRAC/ftillite/Peer/segment/types"
@@ -24,6 +26,18 @@ const (
 	CommandRSA3072Decrypt   = "command_rsa3072_decrypt"
 )
 
+// The RSA commands below support each of crypto.RSAKeySizes, with the size of a key given by
+// the width of the bytearray holding it. command_rsa3072_public_key also accepts keys of any
+// supported size.
+const (
+	CommandRSAKeygen      = "command_rsa_keygen"       // command_rsa_keygen <hPrivateKey :: Handle→[1]b*> <bits :: int>
+	CommandRSAPublicKey   = "command_rsa_public_key"   // command_rsa_public_key <hPublicKey :: Handle→[1]b*> <hPrivateKey :: Handle→[1]b*>
+	CommandRSAOAEPEncrypt = "command_rsa_oaep_encrypt" // command_rsa_oaep_encrypt <hTarget :: Handle→[]b*> <hData :: Handle→[]b*> <hPublicKey :: Handle→[1]b*>
+	CommandRSAOAEPDecrypt = "command_rsa_oaep_decrypt" // command_rsa_oaep_decrypt <hTarget :: Handle→[]b*> <hData :: Handle→[]b*> <hPrivateKey :: Handle→[1]b*>
+	CommandRSAPSSSign     = "command_rsa_pss_sign"     // command_rsa_pss_sign <hSignatures :: Handle→[]b*> <hData :: Handle→[]b*> <hPrivateKey :: Handle→[1]b*>
+	CommandRSAPSSVerify   = "command_rsa_pss_verify"   // command_rsa_pss_verify <hResult :: Handle→[]int64> <hData :: Handle→[]b*> <hSignatures :: Handle→[]b*> <hPublicKey :: Handle→[1]b*>
+)
+
 func RSA3072Keygen(s SegmentHost, args []string) (string, error) {
 	hTarget := variables.Handle(args[0])
 
@@ -32,6 +46,26 @@ func RSA3072Keygen(s SegmentHost, args []string) (string, error) {
 		return "", err
 	}
 
+	return setRSAKey(s, hTarget, bs)
+}
+
+func RSAKeygen(s SegmentHost, args []string) (string, error) {
+	hTarget := variables.Handle(args[0])
+
+	bits, err := strconv.Atoi(args[1])
+	if err != nil {
+		return "", err
+	}
+
+	bs, err := crypto.RSAKeygen(bits)
+	if err != nil {
+		return "", err
+	}
+
+	return setRSAKey(s, hTarget, bs)
+}
+
+func setRSAKey(s SegmentHost, hTarget variables.Handle, bs []byte) (string, error) {
 	target, err := types.NewFTBytearrayArray(len(bs), bs)
 	if err != nil {
 		return "", err
@@ -137,3 +171,132 @@ func RSA3072Decrypt(s SegmentHost, args []string) (string, error) {
 
 	return fmt.Sprintf("array b%v %v", target.Width(), hTarget), nil
 }
+
+func getRSAPublicKey(s SegmentHost, hPublicKey variables.Handle) (*rsa.PublicKey, error) {
+	pkBytes, err := variables.GetAsBytes(s.Variables(), hPublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return crypto.RSAPublicKeyFromBytes(pkBytes)
+}
+
+func getRSAPrivateKey(s SegmentHost, hPrivateKey variables.Handle) (*rsa.PrivateKey, error) {
+	pkBytes, err := variables.GetAsBytes(s.Variables(), hPrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return crypto.RSAPrivateKeyFromBytes(pkBytes)
+}
+
+func setRSAResult(s SegmentHost, hTarget variables.Handle, targetBytes [][]byte) (string, error) {
+	target, err := types.NewFTBytearrayArray(types.CalcBytearrayWidth(targetBytes), targetBytes...)
+	if err != nil {
+		return "", err
+	}
+
+	s.Variables().Set(hTarget, target)
+
+	return fmt.Sprintf("array b%v %v", target.Width(), hTarget), nil
+}
+
+func RSAOAEPEncrypt(s SegmentHost, args []string) (string, error) {
+	hTarget := variables.Handle(args[0])
+	hData := variables.Handle(args[1])
+	hPublicKey := variables.Handle(args[2])
+
+	data, err := variables.GetAs[*types.FTBytearrayArray](s.Variables(), hData)
+	if err != nil {
+		return "", err
+	}
+
+	pk, err := getRSAPublicKey(s, hPublicKey)
+	if err != nil {
+		return "", err
+	}
+
+	targetBytes, err := crypto.RSAOAEPEncrypt(pk, data.Values())
+	if err != nil {
+		return "", err
+	}
+
+	return setRSAResult(s, hTarget, targetBytes)
+}
+
+func RSAOAEPDecrypt(s SegmentHost, args []string) (string, error) {
+	hTarget := variables.Handle(args[0])
+	hData := variables.Handle(args[1])
+	hPrivateKey := variables.Handle(args[2])
+
+	data, err := variables.GetAs[*types.FTBytearrayArray](s.Variables(), hData)
+	if err != nil {
+		return "", err
+	}
+
+	pk, err := getRSAPrivateKey(s, hPrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	targetBytes, err := crypto.RSAOAEPDecrypt(pk, data.Values())
+	if err != nil {
+		return "", err
+	}
+
+	return setRSAResult(s, hTarget, targetBytes)
+}
+
+func RSAPSSSign(s SegmentHost, args []string) (string, error) {
+	hTarget := variables.Handle(args[0])
+	hData := variables.Handle(args[1])
+	hPrivateKey := variables.Handle(args[2])
+
+	data, err := variables.GetAs[*types.FTBytearrayArray](s.Variables(), hData)
+	if err != nil {
+		return "", err
+	}
+
+	pk, err := getRSAPrivateKey(s, hPrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	targetBytes, err := crypto.RSAPSSSign(pk, data.Values())
+	if err != nil {
+		return "", err
+	}
+
+	return setRSAResult(s, hTarget, targetBytes)
+}
+
+func RSAPSSVerify(s SegmentHost, args []string) (string, error) {
+	hTarget := variables.Handle(args[0])
+	hData := variables.Handle(args[1])
+	hSignatures := variables.Handle(args[2])
+	hPublicKey := variables.Handle(args[3])
+
+	data, err := variables.GetAs[*types.FTBytearrayArray](s.Variables(), hData)
+	if err != nil {
+		return "", err
+	}
+
+	signatures, err := variables.GetAs[*types.FTBytearrayArray](s.Variables(), hSignatures)
+	if err != nil {
+		return "", err
+	}
+
+	pk, err := getRSAPublicKey(s, hPublicKey)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := crypto.RSAPSSVerify(pk, data.Values(), signatures.Values())
+	if err != nil {
+		return "", err
+	}
+
+	s.Variables().Set(hTarget, types.NewFTIntegerArray(result...))
+
+	return fmt.Sprintf("array i %v", hTarget), nil
+}
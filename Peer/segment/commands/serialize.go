@@ -75,7 +75,7 @@ func Deserialise(s SegmentHost, args []string) (string, error) {
 		return "", err
 	}
 
-	v, err := types.FromBytes(t, value)
+	v, err := types.FromBytesWithBackend(t, value, s.Ed25519Backend())
 	if err != nil {
 		return "", err
 	}
@@ -86,10 +86,6 @@ func Deserialise(s SegmentHost, args []string) (string, error) {
 }
 
 func EdFolded(s SegmentHost, args []string) (string, error) {
-	if !s.IsGPUAvailable() {
-		return "", ErrEd25519Unavailable
-	}
-
 	hTarget := variables.Handle(args[0])
 	hSource := variables.Handle(args[1])
 
@@ -111,10 +107,6 @@ func EdFolded(s SegmentHost, args []string) (string, error) {
 }
 
 func EdAffine(s SegmentHost, args []string) (string, error) {
-	if !s.IsGPUAvailable() {
-		return "", ErrEd25519Unavailable
-	}
-
 	hTarget := variables.Handle(args[0])
 	hSource := variables.Handle(args[1])
 
@@ -136,10 +128,6 @@ func EdAffine(s SegmentHost, args []string) (string, error) {
 }
 
 func EdFoldedProject(s SegmentHost, args []string) (string, error) {
-	if !s.IsGPUAvailable() {
-		return "", ErrEd25519Unavailable
-	}
-
 	hTarget := variables.Handle(args[0])
 	hSource := variables.Handle(args[1])
 
@@ -148,7 +136,7 @@ func EdFoldedProject(s SegmentHost, args []string) (string, error) {
 		return "", err
 	}
 
-	target, err := types.NewEd25519ArrayFromFoldedBytearrayArray(source)
+	target, err := types.NewEd25519ArrayFromFoldedBytearrayArrayWithBackend(s.Ed25519Backend(), source)
 	if err != nil {
 		return "", err
 	}
@@ -159,10 +147,6 @@ func EdFoldedProject(s SegmentHost, args []string) (string, error) {
 }
 
 func EdAffineProject(s SegmentHost, args []string) (string, error) {
-	if !s.IsGPUAvailable() {
-		return "", ErrEd25519Unavailable
-	}
-
 	hTarget := variables.Handle(args[0])
 	hSource := variables.Handle(args[1])
 
@@ -171,7 +155,7 @@ func EdAffineProject(s SegmentHost, args []string) (string, error) {
 		return "", err
 	}
 
-	target, err := types.NewEd25519ArrayFromAffineBytearrayArray(source)
+	target, err := types.NewEd25519ArrayFromAffineBytearrayArrayWithBackend(s.Ed25519Backend(), source)
 	if err != nil {
 		return "", err
 	}
@@ -115,7 +115,7 @@ func Load(s SegmentHost, args []string) (string, error) {
 		}
 
 		tc[index] = t
-		v, err = types.FromBytes(t, d)
+		v, err = types.FromBytesWithBackend(t, d, s.Ed25519Backend())
 		if err != nil {
 			return "", err
 		}
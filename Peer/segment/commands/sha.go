@@ -11,13 +11,17 @@ package commands
 
 import (
 	"fmt"
+	"strconv"
 
 	"github.com/AUSTRAC/ftillite/Peer/segment/crypto"
 	"github.com/AUSTRAC/ftillite/Peer/segment/types"
 	"github.com/AUSTRAC/ftillite/Peer/segment/variables"
 )
 
-const CommandSHA3256 = "command_sha3_256"
+const (
+	CommandSHA3256 = "command_sha3_256"
+	CommandHash    = "command_hash" // command_hash <hTarget :: Handle→[]b*> <algo :: sha256|sha512|blake2b|shake256> <width :: int64> <hData :: Handle→[]b*> [<hSalt :: Handle→[]b*>]
+)
 
 func Sha3_256(s SegmentHost, args []string) (string, error) {
 	hTarget := variables.Handle(args[0])
@@ -38,3 +42,42 @@ func Sha3_256(s SegmentHost, args []string) (string, error) {
 
 	return fmt.Sprintf("array b32 %v", hTarget), nil
 }
+
+func Hash(s SegmentHost, args []string) (string, error) {
+	hTarget := variables.Handle(args[0])
+	algo := args[1]
+	hData := variables.Handle(args[3])
+
+	width, err := strconv.Atoi(args[2])
+	if err != nil {
+		return "", err
+	}
+
+	data, err := variables.GetAs[*types.FTBytearrayArray](s.Variables(), hData)
+	if err != nil {
+		return "", err
+	}
+
+	var salts [][]byte
+	if len(args) > 4 {
+		salt, err := variables.GetAs[*types.FTBytearrayArray](s.Variables(), variables.Handle(args[4]))
+		if err != nil {
+			return "", err
+		}
+		salts = salt.Values()
+	}
+
+	targetBytes, err := crypto.Hash(algo, width, data.Values(), salts)
+	if err != nil {
+		return "", err
+	}
+
+	target, err := types.NewFTBytearrayArray(width, targetBytes...)
+	if err != nil {
+		return "", err
+	}
+
+	s.Variables().Set(hTarget, target)
+
+	return fmt.Sprintf("array b%v %v", width, hTarget), nil
+}
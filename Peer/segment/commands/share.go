@@ -0,0 +1,188 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/AUSTRAC/ftillite/Peer/segment/crypto"
+	"github.com/AUSTRAC/ftillite/Peer/segment/types"
+	"github.com/AUSTRAC/ftillite/Peer/segment/variables"
+)
+
+// Additive secret sharing of Integer arrays, modulo 2^64. command_share is sent to every
+// participant: the dealer keeps one share and pushes each of the others directly to the peer it
+// belongs to, so that the coordinator never sees a share. The other participants only
+// acknowledge the share, which has arrived by the time the dealer has finished.
+//
+// Shares are added and subtracted share-wise, and multiplied by a public constant, with
+// command_share_add, command_share_sub and command_share_mul. To reconstruct the values, the
+// shares are transmitted to one node, which sums them with command_reconstruct.
+const (
+	CommandShare       = "command_share"       // command_share <hShares :: Handle→[]int64> <hValues :: Handle→[]int64> <dealer :: int64> <nodeID :: int64>+
+	CommandShareAdd    = "command_share_add"   // command_share_add <hResult :: Handle→[]int64> <hLHS :: Handle→[]int64> <hRHS :: Handle→[]int64>
+	CommandShareSub    = "command_share_sub"   // command_share_sub <hResult :: Handle→[]int64> <hLHS :: Handle→[]int64> <hRHS :: Handle→[]int64>
+	CommandShareMul    = "command_share_mul"   // command_share_mul <hResult :: Handle→[]int64> <hShares :: Handle→[]int64> <hConstant :: Handle→[]int64>
+	CommandReconstruct = "command_reconstruct" // command_reconstruct <hResult :: Handle→[]int64> <hShares :: Handle→[]int64>+
+)
+
+func parseShareParticipants(s SegmentHost, dealerArg string, nodeArgs []string) (int64, []int64, error) {
+	dealer, err := strconv.ParseInt(dealerArg, 10, 64)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	self := s.Node().NodeID()
+
+	participants := make([]int64, len(nodeArgs))
+	isParticipant, isDealerParticipant := false, false
+	seen := make(map[int64]struct{}, len(nodeArgs))
+	for i, v := range nodeArgs {
+		participants[i], err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return 0, nil, err
+		}
+		if _, ok := seen[participants[i]]; ok {
+			return 0, nil, fmt.Errorf("node %v is listed more than once", participants[i])
+		}
+		seen[participants[i]] = struct{}{}
+
+		isParticipant = isParticipant || participants[i] == self
+		isDealerParticipant = isDealerParticipant || participants[i] == dealer
+	}
+
+	if !isDealerParticipant {
+		return 0, nil, fmt.Errorf("dealer %v is not one of the participants", dealer)
+	}
+	if !isParticipant {
+		return 0, nil, fmt.Errorf("node %v is not one of the participants", self)
+	}
+
+	return dealer, participants, nil
+}
+
+func Share(s SegmentHost, args []string) (string, error) {
+	hShares := variables.Handle(args[0])
+	hValues := variables.Handle(args[1])
+
+	dealer, participants, err := parseShareParticipants(s, args[2], args[3:])
+	if err != nil {
+		return "", err
+	}
+
+	self := s.Node().NodeID()
+	if dealer != self {
+		return fmt.Sprintf("array i %v", hShares), nil
+	}
+
+	values, err := variables.GetAs[*types.FTIntegerArray](s.Variables(), hValues)
+	if err != nil {
+		return "", err
+	}
+
+	shares, err := crypto.AdditiveShare(values.Values(), len(participants))
+	if err != nil {
+		return "", err
+	}
+
+	for i, participant := range participants {
+		share := types.NewFTIntegerArray(shares[i]...)
+
+		if participant == self {
+			s.Variables().Set(hShares, share)
+			continue
+		}
+
+		nodeAddress := s.GetPeerAddress(fmt.Sprint(participant))
+
+		hOutgoing := variables.Handle(fmt.Sprintf("%v_share_outgoing_%v", hShares, participant))
+		s.Variables().Set(hOutgoing, share)
+
+		grant := s.IssueTransferGrant(fmt.Sprint(participant), hOutgoing)
+		err = s.RequestTransferBytes(nodeAddress, string(hOutgoing), string(hShares), string(types.Integer), "array", grant)
+		s.Variables().Delete(hOutgoing)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return fmt.Sprintf("array i %v", hShares), nil
+}
+
+func shareOperator(f func(a, b int64) int64) CommandFunc {
+	return func(s SegmentHost, args []string) (string, error) {
+		hResult := variables.Handle(args[0])
+		hLHS := variables.Handle(args[1])
+		hRHS := variables.Handle(args[2])
+
+		lhs, err := variables.GetAs[*types.FTIntegerArray](s.Variables(), hLHS)
+		if err != nil {
+			return "", err
+		}
+		rhs, err := variables.GetAs[*types.FTIntegerArray](s.Variables(), hRHS)
+		if err != nil {
+			return "", err
+		}
+
+		xs, ys := lhs.Values(), rhs.Values()
+		if len(ys) == 1 && len(xs) != 1 {
+			ys, err = types.SliceBroadcast(ys, int64(len(xs)))
+			if err != nil {
+				return "", err
+			}
+		}
+
+		result, err := types.SliceMapBinary(xs, ys, f)
+		if err != nil {
+			return "", err
+		}
+
+		s.Variables().Set(hResult, types.NewFTIntegerArray(result...))
+
+		return fmt.Sprintf("array i %v", hResult), nil
+	}
+}
+
+// Integer arithmetic wraps on overflow, so these are the operations modulo 2^64. Adding or
+// subtracting shares gives shares of the sum or difference, and multiplying the shares by a
+// public constant, or a singleton broadcast to every element, gives shares of the product.
+var (
+	ShareAdd = shareOperator(func(a, b int64) int64 { return a + b })
+	ShareSub = shareOperator(func(a, b int64) int64 { return a - b })
+	ShareMul = shareOperator(func(a, b int64) int64 { return a * b })
+)
+
+func Reconstruct(s SegmentHost, args []string) (string, error) {
+	hResult := variables.Handle(args[0])
+
+	if len(args) < 2 {
+		return "", errors.New("at least one share is required")
+	}
+
+	shares := make([][]int64, len(args)-1)
+	for i, h := range args[1:] {
+		share, err := variables.GetAs[*types.FTIntegerArray](s.Variables(), variables.Handle(h))
+		if err != nil {
+			return "", err
+		}
+		shares[i] = share.Values()
+	}
+
+	result, err := crypto.AdditiveReconstruct(shares)
+	if err != nil {
+		return "", err
+	}
+
+	s.Variables().Set(hResult, types.NewFTIntegerArray(result...))
+
+	return fmt.Sprintf("array i %v", hResult), nil
+}
@@ -0,0 +1,112 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package commands
+
+import (
+	"fmt"
+
+	"github.com/AUSTRAC/ftillite/Peer/segment/crypto"
+	"github.com/AUSTRAC/ftillite/Peer/segment/types"
+	"github.com/AUSTRAC/ftillite/Peer/segment/variables"
+)
+
+// The proof of a shuffle is held in two bytearray arrays: a singleton header and one
+// element per ciphertext.
+const (
+	CommandElGamalShuffle       = "command_elgamal_shuffle"        // command_elgamal_shuffle <hMaskResult :: Handle→[]Ed25519> <hMaskedResult :: Handle→[]Ed25519> <hProof :: Handle→[1]b160> <hProofElements :: Handle→[]b128> <hMask :: Handle→[]Ed25519> <hMasked :: Handle→[]Ed25519> <hPublicKey :: Handle→[1]Ed25519>
+	CommandElGamalShuffleVerify = "command_elgamal_shuffle_verify" // command_elgamal_shuffle_verify <hResult :: Handle→[1]int64> <hProof :: Handle→[1]b160> <hProofElements :: Handle→[]b128> <hMaskR :: Handle→[]Ed25519> <hMaskedR :: Handle→[]Ed25519> <hMask :: Handle→[]Ed25519> <hMasked :: Handle→[]Ed25519> <hPublicKey :: Handle→[1]Ed25519>
+)
+
+func ElGamalShuffle(s SegmentHost, args []string) (string, error) {
+	hMaskResult := variables.Handle(args[0])
+	hMaskedResult := variables.Handle(args[1])
+	hProof := variables.Handle(args[2])
+	hProofElements := variables.Handle(args[3])
+
+	c, err := getElGamalCiphertexts(s, variables.Handle(args[4]), variables.Handle(args[5]))
+	if err != nil {
+		return "", err
+	}
+
+	publicKey, err := variables.GetAs[*types.Ed25519Array](s.Variables(), variables.Handle(args[6]))
+	if err != nil {
+		return "", err
+	}
+
+	result, proof, err := crypto.ElGamalShuffle(publicKey, c)
+	if err != nil {
+		return "", err
+	}
+
+	header, elements := proof.Bytes()
+
+	proofHeader, err := types.NewFTBytearrayArray(crypto.ShuffleProofHeaderBytes, header)
+	if err != nil {
+		result.Free()
+		return "", err
+	}
+
+	proofElements, err := types.NewFTBytearrayArray(crypto.ShuffleProofElementBytes, elements...)
+	if err != nil {
+		result.Free()
+		return "", err
+	}
+
+	s.Variables().Set(hProof, proofHeader)
+	s.Variables().Set(hProofElements, proofElements)
+
+	return fmt.Sprintf(
+		"%v array b%v %v array b%v %v",
+		setElGamalCiphertexts(s, hMaskResult, hMaskedResult, result),
+		crypto.ShuffleProofHeaderBytes, hProof,
+		crypto.ShuffleProofElementBytes, hProofElements,
+	), nil
+}
+
+func ElGamalShuffleVerify(s SegmentHost, args []string) (string, error) {
+	hResult := variables.Handle(args[0])
+
+	header, err := variables.GetAsBytes(s.Variables(), variables.Handle(args[1]))
+	if err != nil {
+		return "", err
+	}
+
+	elements, err := variables.GetAs[*types.FTBytearrayArray](s.Variables(), variables.Handle(args[2]))
+	if err != nil {
+		return "", err
+	}
+
+	proof, err := crypto.NewShuffleProofFromBytes(header, elements.Values())
+	if err != nil {
+		return "", err
+	}
+
+	result, err := getElGamalCiphertexts(s, variables.Handle(args[3]), variables.Handle(args[4]))
+	if err != nil {
+		return "", err
+	}
+
+	c, err := getElGamalCiphertexts(s, variables.Handle(args[5]), variables.Handle(args[6]))
+	if err != nil {
+		return "", err
+	}
+
+	publicKey, err := variables.GetAs[*types.Ed25519Array](s.Variables(), variables.Handle(args[7]))
+	if err != nil {
+		return "", err
+	}
+
+	verified, err := crypto.ElGamalShuffleVerify(publicKey, c, result, proof)
+	if err != nil {
+		return "", err
+	}
+
+	return setVerifyResult(s, hResult, []int64{types.BToI(verified)}), nil
+}
@@ -0,0 +1,269 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"filippo.io/edwards25519"
+
+	"github.com/AUSTRAC/ftillite/Peer/segment/crypto"
+	"github.com/AUSTRAC/ftillite/Peer/segment/types"
+	"github.com/AUSTRAC/ftillite/Peer/segment/variables"
+)
+
+// The distributed key generation is run by sending command_elgamal_dkg_deal to every
+// participant, followed by command_elgamal_dkg_combine. Dealing pushes each share, together
+// with the commitments of the deal, directly to the peer it belongs to, so that the
+// coordinator never sees a share and a peer only ever holds its own.
+const (
+	CommandElGamalDKGDeal         = "command_elgamal_dkg_deal"         // command_elgamal_dkg_deal <hCommitments :: Handle→[]Ed25519> <threshold :: int64> <nodeID :: int64>+
+	CommandElGamalDKGCombine      = "command_elgamal_dkg_combine"      // command_elgamal_dkg_combine <hShare :: Handle→[1]Ed25519Int> <hPublicKey :: Handle→[1]Ed25519> <hVerificationKeys :: Handle→[]Ed25519> <hCommitments :: Handle→[]Ed25519> <threshold :: int64> <nodeID :: int64>+
+	CommandElGamalPartialDecrypt  = "command_elgamal_partial_decrypt"  // command_elgamal_partial_decrypt <hResult :: Handle→[]Ed25519> <hMask :: Handle→[]Ed25519> <hShare :: Handle→[1]Ed25519Int>
+	CommandElGamalCombinePartials = "command_elgamal_combine_partials" // command_elgamal_combine_partials <hResult :: Handle→[]Ed25519> <hMasked :: Handle→[]Ed25519> <threshold :: int64> ( <nodeID :: int64> <hPartial :: Handle→[]Ed25519> )+
+)
+
+// dkgShareHandle and dkgCommitmentsHandle name the variables pushed to a participant by the
+// deal of another.
+func dkgShareHandle(hCommitments variables.Handle, dealer int64) variables.Handle {
+	return variables.Handle(fmt.Sprintf("%v_dkg_share_%v", hCommitments, dealer))
+}
+func dkgCommitmentsHandle(hCommitments variables.Handle, dealer int64) variables.Handle {
+	return variables.Handle(fmt.Sprintf("%v_dkg_commitments_%v", hCommitments, dealer))
+}
+
+func parseThresholdParticipants(s SegmentHost, thresholdArg string, nodeArgs []string) (int, []int64, error) {
+	threshold, err := strconv.Atoi(thresholdArg)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	participants := make([]int64, len(nodeArgs))
+	isParticipant := false
+	for i, v := range nodeArgs {
+		participants[i], err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return 0, nil, err
+		}
+		isParticipant = isParticipant || participants[i] == s.Node().NodeID()
+	}
+
+	if threshold < 1 || threshold > len(participants) {
+		return 0, nil, crypto.ErrInvalidThreshold
+	}
+	if !isParticipant {
+		return 0, nil, fmt.Errorf("node %v is not one of the participants", s.Node().NodeID())
+	}
+
+	return threshold, participants, nil
+}
+
+func ElGamalDKGDeal(s SegmentHost, args []string) (string, error) {
+	hCommitments := variables.Handle(args[0])
+
+	threshold, participants, err := parseThresholdParticipants(s, args[1], args[2:])
+	if err != nil {
+		return "", err
+	}
+
+	deal, err := crypto.NewFeldmanDeal(threshold)
+	if err != nil {
+		return "", err
+	}
+
+	commitments, err := types.NewEd25519ArrayFromPointsWithBackend(s.Ed25519Backend(), deal.Commitments)
+	if err != nil {
+		return "", err
+	}
+	s.Variables().Set(hCommitments, commitments)
+
+	self := s.Node().NodeID()
+
+	for _, participant := range participants {
+		share := types.NewFTEd25519IntArray(deal.Share(participant))
+
+		if participant == self {
+			s.Variables().Set(dkgShareHandle(hCommitments, self), share)
+			continue
+		}
+
+		nodeAddress := s.GetPeerAddress(fmt.Sprint(participant))
+
+		hOutgoing := variables.Handle(fmt.Sprintf("%v_dkg_outgoing_%v", hCommitments, participant))
+		s.Variables().Set(hOutgoing, share)
+
+		grant := s.IssueTransferGrant(fmt.Sprint(participant), hOutgoing)
+		err = s.RequestTransferBytes(nodeAddress, string(hOutgoing), string(dkgShareHandle(hCommitments, self)), string(types.Ed25519Int), "array", grant)
+		s.Variables().Delete(hOutgoing)
+		if err != nil {
+			return "", err
+		}
+
+		grant = s.IssueTransferGrant(fmt.Sprint(participant), hCommitments)
+		err = s.RequestTransferBytes(nodeAddress, string(hCommitments), string(dkgCommitmentsHandle(hCommitments, self)), string(types.Ed25519), "array", grant)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return fmt.Sprintf("array E %v", hCommitments), nil
+}
+
+func ElGamalDKGCombine(s SegmentHost, args []string) (string, error) {
+	hShare := variables.Handle(args[0])
+	hPublicKey := variables.Handle(args[1])
+	hVerificationKeys := variables.Handle(args[2])
+	hCommitments := variables.Handle(args[3])
+
+	threshold, participants, err := parseThresholdParticipants(s, args[4], args[5:])
+	if err != nil {
+		return "", err
+	}
+
+	self := s.Node().NodeID()
+
+	allCommitments := make([][]*edwards25519.Point, len(participants))
+	keyShare := edwards25519.NewScalar()
+
+	for i, dealer := range participants {
+		hDealerCommitments := dkgCommitmentsHandle(hCommitments, dealer)
+		if dealer == self {
+			hDealerCommitments = hCommitments
+		}
+
+		commitments, err := variables.GetAs[*types.Ed25519Array](s.Variables(), hDealerCommitments)
+		if err != nil {
+			return "", fmt.Errorf("no commitments were received from node %v: %w", dealer, err)
+		}
+
+		allCommitments[i], err = commitments.Points()
+		if err != nil {
+			return "", err
+		}
+		if len(allCommitments[i]) != threshold {
+			return "", fmt.Errorf("node %v dealt a polynomial for a different threshold", dealer)
+		}
+
+		share, err := variables.GetAsEd25519Integer(s.Variables(), dkgShareHandle(hCommitments, dealer))
+		if err != nil {
+			return "", fmt.Errorf("no key share was received from node %v: %w", dealer, err)
+		}
+
+		if !crypto.VerifyFeldmanShare(allCommitments[i], self, share) {
+			return "", fmt.Errorf("the key share dealt by node %v does not match its commitments", dealer)
+		}
+
+		keyShare.Add(keyShare, share)
+	}
+
+	for _, dealer := range participants {
+		s.Variables().Delete(dkgShareHandle(hCommitments, dealer))
+		if dealer != self {
+			s.Variables().Delete(dkgCommitmentsHandle(hCommitments, dealer))
+		}
+	}
+
+	publicKey, err := types.NewEd25519ArrayFromPointsWithBackend(
+		s.Ed25519Backend(),
+		[]*edwards25519.Point{crypto.ThresholdPublicKey(allCommitments)},
+	)
+	if err != nil {
+		return "", err
+	}
+
+	verificationKeys, err := types.NewEd25519ArrayFromPointsWithBackend(
+		s.Ed25519Backend(),
+		crypto.ThresholdVerificationKeys(allCommitments, participants),
+	)
+	if err != nil {
+		publicKey.Free()
+		return "", err
+	}
+
+	s.Variables().Set(hShare, types.NewFTEd25519IntArray(keyShare))
+	s.Variables().Set(hPublicKey, publicKey)
+	s.Variables().Set(hVerificationKeys, verificationKeys)
+
+	return fmt.Sprintf("array I %v array E %v array E %v", hShare, hPublicKey, hVerificationKeys), nil
+}
+
+func ElGamalPartialDecrypt(s SegmentHost, args []string) (string, error) {
+	hResult := variables.Handle(args[0])
+	hMask := variables.Handle(args[1])
+	hShare := variables.Handle(args[2])
+
+	mask, err := variables.GetAs[*types.Ed25519Array](s.Variables(), hMask)
+	if err != nil {
+		return "", err
+	}
+
+	share, err := variables.GetAsEd25519Integer(s.Variables(), hShare)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := crypto.ThresholdPartialDecrypt(share, mask)
+	if err != nil {
+		return "", err
+	}
+
+	s.Variables().Set(hResult, result)
+
+	return fmt.Sprintf("array E %v", hResult), nil
+}
+
+func ElGamalCombinePartials(s SegmentHost, args []string) (string, error) {
+	hResult := variables.Handle(args[0])
+	hMasked := variables.Handle(args[1])
+
+	threshold, err := strconv.Atoi(args[2])
+	if err != nil {
+		return "", err
+	}
+
+	pairs := args[3:]
+	if len(pairs)%2 != 0 {
+		return "", errors.New("each partial decryption must be preceded by the ID of the node which produced it")
+	}
+	if threshold < 1 || len(pairs)/2 < threshold {
+		return "", fmt.Errorf("at least %v partial decryptions are required", threshold)
+	}
+
+	masked, err := variables.GetAs[*types.Ed25519Array](s.Variables(), hMasked)
+	if err != nil {
+		return "", err
+	}
+
+	participants := make([]int64, len(pairs)/2)
+	partials := make([]*types.Ed25519Array, len(pairs)/2)
+
+	for i := range participants {
+		participants[i], err = strconv.ParseInt(pairs[2*i], 10, 64)
+		if err != nil {
+			return "", err
+		}
+
+		partials[i], err = variables.GetAs[*types.Ed25519Array](s.Variables(), variables.Handle(pairs[2*i+1]))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	result, err := crypto.ThresholdCombine(masked, participants, partials)
+	if err != nil {
+		return "", err
+	}
+
+	s.Variables().Set(hResult, result)
+
+	return fmt.Sprintf("array E %v", hResult), nil
+}
@@ -11,7 +11,7 @@ package commands
 
 import "github.com/AUSTRAC/ftillite/Peer/segment/variables"
 
-const CommandTransmit = "command_transmit" // command_transmit
+const CommandTransmit = "command_transmit" // command_transmit [grant]
 
 func Transmit(s SegmentHost, args []string) (string, error) {
 	newHandle := args[1]
@@ -21,6 +21,13 @@ func Transmit(s SegmentHost, args []string) (string, error) {
 	dtype := args[3]
 	opcode := args[4]
 
+	// The grant signed by the coordinator, which is required when the peer has a coordinator
+	// grant key
+	grant := ""
+	if len(args) > 5 {
+		grant = args[5]
+	}
+
 	if s.Node().NodeIDString == targetNode {
 		v, err := s.Variables().Get(variables.Handle(handle))
 		if err != nil {
@@ -30,7 +37,7 @@ func Transmit(s SegmentHost, args []string) (string, error) {
 		s.Variables().Set(variables.Handle(newHandle), v)
 
 	} else {
-		err := s.RequestTransferBytes(nodeAddress, handle, newHandle, dtype, opcode)
+		err := s.RequestTransferBytes(nodeAddress, handle, newHandle, dtype, opcode, grant)
 		if err != nil {
 			return "Error", err
 		}
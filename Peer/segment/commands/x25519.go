@@ -0,0 +1,110 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package commands
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/AUSTRAC/ftillite/Peer/segment/crypto"
+	"github.com/AUSTRAC/ftillite/Peer/segment/types"
+	"github.com/AUSTRAC/ftillite/Peer/segment/variables"
+)
+
+const (
+	CommandX25519Keygen    = "command_x25519_keygen"     // command_x25519_keygen <hPrivateKey :: Handle→[1]b32>
+	CommandX25519PublicKey = "command_x25519_public_key" // command_x25519_public_key <hPublicKey :: Handle→[1]b32> <hPrivateKey :: Handle→[1]b32>
+	CommandX25519Derive    = "command_x25519_derive"     // command_x25519_derive <hTarget :: Handle→[]b*> <hPrivateKey :: Handle→[1]b32> <hPublicKeys :: Handle→[]b32> <width :: int64> [<label :: string>]
+)
+
+func X25519Keygen(s SegmentHost, args []string) (string, error) {
+	hTarget := variables.Handle(args[0])
+
+	targetBytes, err := crypto.X25519Keygen()
+	if err != nil {
+		return "", err
+	}
+
+	target, err := types.NewFTBytearrayArray(crypto.X25519KeyBytes, targetBytes)
+	if err != nil {
+		return "", err
+	}
+
+	s.Variables().Set(hTarget, target)
+
+	return fmt.Sprintf("array b%v %v", target.Width(), hTarget), nil
+}
+
+func X25519PublicKey(s SegmentHost, args []string) (string, error) {
+	hTarget := variables.Handle(args[0])
+	hPrivateKey := variables.Handle(args[1])
+
+	pkBytes, err := variables.GetAsBytes(s.Variables(), hPrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	pk, err := crypto.X25519PublicKey(pkBytes)
+	if err != nil {
+		return "", err
+	}
+
+	target, err := types.NewFTBytearrayArray(crypto.X25519KeyBytes, pk)
+	if err != nil {
+		return "", err
+	}
+
+	s.Variables().Set(hTarget, target)
+
+	return fmt.Sprintf("array b%v %v", target.Width(), hTarget), nil
+}
+
+func X25519Derive(s SegmentHost, args []string) (string, error) {
+	hTarget := variables.Handle(args[0])
+	hPrivateKey := variables.Handle(args[1])
+	hPublicKeys := variables.Handle(args[2])
+
+	width, err := strconv.Atoi(args[3])
+	if err != nil {
+		return "", err
+	}
+
+	var label []byte
+	if len(args) > 4 {
+		label, err = stringArg(args, 4, "label")
+		if err != nil {
+			return "", err
+		}
+	}
+
+	pkBytes, err := variables.GetAsBytes(s.Variables(), hPrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	publicKeys, err := getBytearrays(s, hPublicKeys, crypto.X25519KeyBytes)
+	if err != nil {
+		return "", err
+	}
+
+	targetBytes, err := crypto.X25519Derive(pkBytes, publicKeys.Values(), width, label)
+	if err != nil {
+		return "", err
+	}
+
+	target, err := types.NewFTBytearrayArray(width, targetBytes...)
+	if err != nil {
+		return "", err
+	}
+
+	s.Variables().Set(hTarget, target)
+
+	return fmt.Sprintf("array b%v %v", width, hTarget), nil
+}
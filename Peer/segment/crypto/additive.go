@@ -0,0 +1,67 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+)
+
+// Additive shares are integers modulo 2^64, so that the arithmetic is that of int64 with
+// wrapping on overflow. Any n - 1 of n shares are uniformly random and reveal nothing about
+// the value, and the sum of all n shares is the value.
+
+var errNoShares = errors.New("at least one share is required")
+
+// AdditiveShare splits each value into n shares, returning the values of each share.
+func AdditiveShare(values []int64, n int) ([][]int64, error) {
+	if n < 1 {
+		return nil, errNoShares
+	}
+
+	shares := make([][]int64, n)
+	last := append([]int64{}, values...)
+
+	buf := make([]byte, 8*len(values))
+	for i := 0; i < n-1; i++ {
+		_, err := rand.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+
+		shares[i] = make([]int64, len(values))
+		for j := range values {
+			shares[i][j] = int64(binary.LittleEndian.Uint64(buf[8*j:]))
+			last[j] -= shares[i][j]
+		}
+	}
+	shares[n-1] = last
+
+	return shares, nil
+}
+
+// AdditiveReconstruct sums the shares of each value.
+func AdditiveReconstruct(shares [][]int64) ([]int64, error) {
+	if len(shares) == 0 {
+		return nil, errNoShares
+	}
+
+	result := make([]int64, len(shares[0]))
+	for _, share := range shares {
+		if len(share) != len(result) {
+			return nil, errors.New("shares must all be of the same length")
+		}
+		for j, v := range share {
+			result[j] += v
+		}
+	}
+	return result, nil
+}
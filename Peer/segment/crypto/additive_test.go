@@ -0,0 +1,49 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package crypto
+
+import (
+	"math"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestAdditiveShare_Reconstruct(t *testing.T) {
+	values := []int64{0, 1, -1, math.MaxInt64, math.MinInt64}
+
+	shares, err := AdditiveShare(values, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shares) != 3 {
+		t.Fatalf("expected 3 shares, got %v", len(shares))
+	}
+	if slices.Equal(shares[0], values) {
+		t.Error("the first share should be random")
+	}
+
+	result, err := AdditiveReconstruct(shares)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(result, values) {
+		t.Errorf("expected %v, got %v", values, result)
+	}
+
+	_, err = AdditiveShare(values, 0)
+	if err == nil {
+		t.Error("sharing into no shares should fail")
+	}
+	_, err = AdditiveReconstruct([][]int64{{1, 2}, {3}})
+	if err == nil {
+		t.Error("shares of different lengths should not reconstruct")
+	}
+}
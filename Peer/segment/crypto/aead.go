@@ -0,0 +1,110 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package crypto
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// The AEAD commands seal each element of an array under its own random nonce, and keep the
+// ciphertexts, nonces and tags in separate arrays so that the ciphertexts are the same width
+// as the data. Associated data may be omitted, given once for all elements, or given per
+// element.
+
+func randomBytes(n int, count int) ([][]byte, error) {
+	bs := make([]byte, n*count)
+	_, err := rand.Read(bs)
+	if err != nil {
+		return nil, err
+	}
+
+	target := make([][]byte, count)
+	for i := range target {
+		target[i] = bs[i*n : (i+1)*n]
+	}
+	return target, nil
+}
+
+func associatedDataAt(additionalData [][]byte, i int) []byte {
+	switch len(additionalData) {
+	case 0:
+		return nil
+	case 1:
+		return additionalData[0]
+	default:
+		return additionalData[i]
+	}
+}
+
+func checkAssociatedData(additionalData [][]byte, length int) error {
+	if len(additionalData) > 1 && len(additionalData) != length {
+		return errors.New("associated data must be a singleton or match the length of the data")
+	}
+	return nil
+}
+
+func aeadSeal(a cipher.AEAD, data [][]byte, additionalData [][]byte) ([][]byte, [][]byte, [][]byte, error) {
+	err := checkAssociatedData(additionalData, len(data))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	nonces, err := randomBytes(a.NonceSize(), len(data))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	ciphertexts := make([][]byte, len(data))
+	tags := make([][]byte, len(data))
+	for i, v := range data {
+		sealed := a.Seal(nil, nonces[i], v, associatedDataAt(additionalData, i))
+		ciphertexts[i] = sealed[:len(v)]
+		tags[i] = sealed[len(v):]
+	}
+
+	return ciphertexts, nonces, tags, nil
+}
+
+func aeadOpen(a cipher.AEAD, ciphertexts [][]byte, nonces [][]byte, tags [][]byte, additionalData [][]byte) ([][]byte, []int64, error) {
+	if len(ciphertexts) != len(nonces) || len(ciphertexts) != len(tags) {
+		return nil, nil, errors.New("mismatched number of ciphertexts, nonces and tags")
+	}
+
+	err := checkAssociatedData(additionalData, len(ciphertexts))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	plaintexts := make([][]byte, len(ciphertexts))
+	valid := make([]int64, len(ciphertexts))
+	for i, v := range ciphertexts {
+		if len(nonces[i]) != a.NonceSize() {
+			return nil, nil, fmt.Errorf("nonces must have a length of %v bytes", a.NonceSize())
+		}
+
+		sealed := make([]byte, 0, len(v)+len(tags[i]))
+		sealed = append(sealed, v...)
+		sealed = append(sealed, tags[i]...)
+
+		plaintext, err := a.Open(nil, nonces[i], sealed, associatedDataAt(additionalData, i))
+		if err != nil {
+			plaintexts[i] = make([]byte, len(v))
+			continue
+		}
+
+		plaintexts[i] = plaintext
+		valid[i] = 1
+	}
+
+	return plaintexts, valid, nil
+}
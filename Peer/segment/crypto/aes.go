@@ -16,7 +16,7 @@ import (
 	"fmt"
 )
 
-func aes256(key []byte, data [][]byte, f func(cipher cipher.Block, dst []byte, src []byte)) ([][]byte, error) {
+func newAes256Cipher(key []byte) (cipher.Block, error) {
 	if len(key) != 32 {
 		return nil, errors.New("key must have a length of 32 bytes")
 	}
@@ -25,6 +25,14 @@ func aes256(key []byte, data [][]byte, f func(cipher cipher.Block, dst []byte, s
 	if err != nil {
 		return nil, fmt.Errorf("unable to create AES cypher with key: %e", err)
 	}
+	return cipher, nil
+}
+
+func aes256(key []byte, data [][]byte, f func(cipher cipher.Block, dst []byte, src []byte)) ([][]byte, error) {
+	cipher, err := newAes256Cipher(key)
+	if err != nil {
+		return nil, err
+	}
 	target := make([][]byte, len(data))
 
 	for i, v := range data {
@@ -46,3 +54,83 @@ func Aes256Decrypt(key []byte, data [][]byte) ([][]byte, error) {
 		cipher.Decrypt(dst, src)
 	})
 }
+
+// AES-256-GCM encrypts each element under its own random nonce. As the nonces are random,
+// no more than 2^32 elements should be encrypted under a single key.
+const (
+	Aes256GCMNonceBytes = 12
+	Aes256GCMTagBytes   = 16
+	Aes256CTRIVBytes    = aes.BlockSize
+)
+
+func newAes256GCM(key []byte) (cipher.AEAD, error) {
+	block, err := newAes256Cipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Aes256GCMEncrypt returns the ciphertexts, which are the same width as the data, along with
+// the nonce and tag of each element.
+func Aes256GCMEncrypt(key []byte, data [][]byte) ([][]byte, [][]byte, [][]byte, error) {
+	gcm, err := newAes256GCM(key)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return aeadSeal(gcm, data, nil)
+}
+
+// Aes256GCMDecrypt returns the plaintexts and a mask which is 1 for each element that
+// decrypted successfully. Elements which fail authentication are returned as zeroes.
+func Aes256GCMDecrypt(key []byte, ciphertexts [][]byte, nonces [][]byte, tags [][]byte) ([][]byte, []int64, error) {
+	gcm, err := newAes256GCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return aeadOpen(gcm, ciphertexts, nonces, tags, nil)
+}
+
+// aes256CTR applies the key stream from each IV to the corresponding element.
+func aes256CTR(key []byte, data [][]byte, ivs [][]byte) ([][]byte, error) {
+	if len(data) != len(ivs) {
+		return nil, errors.New("mismatched number of data and IVs")
+	}
+
+	block, err := newAes256Cipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	target := make([][]byte, len(data))
+	for i, v := range data {
+		if len(ivs[i]) != Aes256CTRIVBytes {
+			return nil, fmt.Errorf("IVs must have a length of %v bytes", Aes256CTRIVBytes)
+		}
+
+		target[i] = make([]byte, len(v))
+		cipher.NewCTR(block, ivs[i]).XORKeyStream(target[i], v)
+	}
+
+	return target, nil
+}
+
+// Aes256CTREncrypt returns the ciphertexts, which are the same width as the data, along with
+// the random IV of each element.
+func Aes256CTREncrypt(key []byte, data [][]byte) ([][]byte, [][]byte, error) {
+	ivs, err := randomBytes(Aes256CTRIVBytes, len(data))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	target, err := aes256CTR(key, data, ivs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return target, ivs, nil
+}
+
+func Aes256CTRDecrypt(key []byte, data [][]byte, ivs [][]byte) ([][]byte, error) {
+	return aes256CTR(key, data, ivs)
+}
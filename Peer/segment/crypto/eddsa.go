@@ -0,0 +1,189 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package crypto
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"errors"
+
+	"filippo.io/edwards25519"
+
+	"github.com/AUSTRAC/ftillite/Peer/segment/types"
+)
+
+// Ed25519 signatures follow RFC 8032, so that they can be checked by any conforming verifier.
+// Private keys are stored as the 64 byte seed and public key used by crypto/ed25519.
+//
+// Verification uses the cofactored equation [8][s]B = [8]R + [8][k]A, both for single
+// signatures and for batches, so that a signature is accepted or rejected independently of
+// the other signatures it is verified with. As in ZIP 215, a signature whose R has a small
+// order component may therefore be accepted where the cofactorless equation of crypto/ed25519
+// rejects it. Unlike ZIP 215, R and S must both be canonically encoded.
+
+var (
+	ErrInvalidEd25519PrivateKey = errors.New("Ed25519 private keys must be 64 bytes")
+	ErrInvalidEd25519PublicKey  = errors.New("Ed25519 public keys must be 32 bytes")
+)
+
+func Ed25519Keygen() ([]byte, error) {
+	_, k, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func Ed25519PublicKey(privateKey []byte) ([]byte, error) {
+	if len(privateKey) != ed25519.PrivateKeySize {
+		return nil, ErrInvalidEd25519PrivateKey
+	}
+	return ed25519.PrivateKey(privateKey).Public().(ed25519.PublicKey), nil
+}
+
+func Ed25519Sign(privateKey []byte, data [][]byte) ([][]byte, error) {
+	if len(privateKey) != ed25519.PrivateKeySize {
+		return nil, ErrInvalidEd25519PrivateKey
+	}
+
+	target := make([][]byte, len(data))
+	for i, v := range data {
+		target[i] = ed25519.Sign(ed25519.PrivateKey(privateKey), v)
+	}
+	return target, nil
+}
+
+// ed25519Signature is a signature decoded for verification.
+type ed25519Signature struct {
+	r *edwards25519.Point
+	s *edwards25519.Scalar
+	k *edwards25519.Scalar
+}
+
+func decodeEd25519Signature(publicKey []byte, message []byte, signature []byte) (ed25519Signature, bool) {
+	if len(signature) != ed25519.SignatureSize {
+		return ed25519Signature{}, false
+	}
+
+	// SetBytes accepts encodings of y which are not reduced modulo p, which strict RFC 8032
+	// verifiers reject
+	r, err := new(edwards25519.Point).SetBytes(signature[:32])
+	if err != nil || !bytes.Equal(r.Bytes(), signature[:32]) {
+		return ed25519Signature{}, false
+	}
+
+	s, err := edwards25519.NewScalar().SetCanonicalBytes(signature[32:])
+	if err != nil {
+		return ed25519Signature{}, false
+	}
+
+	h := sha512.New()
+	h.Write(signature[:32])
+	h.Write(publicKey)
+	h.Write(message)
+
+	return ed25519Signature{r, s, hashToScalar(h)}, true
+}
+
+// isSmallOrder reports whether [8]p is the identity.
+func isSmallOrder(p *edwards25519.Point) bool {
+	return new(edwards25519.Point).MultByCofactor(p).Equal(edwards25519.NewIdentityPoint()) == 1
+}
+
+func ed25519VerifySingle(a *edwards25519.Point, sig ed25519Signature) bool {
+	// [s]B - [k]A - R
+	negK := edwards25519.NewScalar().Negate(sig.k)
+	p := new(edwards25519.Point).VarTimeDoubleScalarBaseMult(negK, a, sig.s)
+	return isSmallOrder(p.Subtract(p, sig.r))
+}
+
+// ed25519VerifyBatch checks a random linear combination of the verification equations,
+// which holds for all valid batches and fails except with negligible probability otherwise.
+func ed25519VerifyBatch(a *edwards25519.Point, sigs []ed25519Signature) (bool, error) {
+	weights := make([]byte, 16*len(sigs))
+	_, err := rand.Read(weights)
+	if err != nil {
+		return false, err
+	}
+
+	scalars := make([]*edwards25519.Scalar, 0, len(sigs)+2)
+	points := make([]*edwards25519.Point, 0, len(sigs)+2)
+
+	sSum := edwards25519.NewScalar()
+	kSum := edwards25519.NewScalar()
+	for i, sig := range sigs {
+		buf := make([]byte, 32)
+		copy(buf, weights[16*i:16*(i+1)])
+		z, err := edwards25519.NewScalar().SetCanonicalBytes(buf)
+		if err != nil {
+			return false, err
+		}
+
+		sSum.MultiplyAdd(z, sig.s, sSum)
+		kSum.MultiplyAdd(z, sig.k, kSum)
+
+		scalars = append(scalars, edwards25519.NewScalar().Negate(z))
+		points = append(points, sig.r)
+	}
+
+	// [Σz_i*s_i]B - [Σz_i*k_i]A - Σ[z_i]R_i
+	scalars = append(scalars, sSum, edwards25519.NewScalar().Negate(kSum))
+	points = append(points, edwards25519.NewGeneratorPoint(), a)
+
+	return isSmallOrder(new(edwards25519.Point).VarTimeMultiScalarMult(scalars, points)), nil
+}
+
+// Ed25519Verify returns 1 for each valid signature and 0 otherwise. The signatures are first
+// checked together, and only checked one by one if the batch fails.
+func Ed25519Verify(publicKey []byte, data [][]byte, signatures [][]byte) ([]int64, error) {
+	if len(data) != len(signatures) {
+		return nil, errors.New("mismatched number of signatures and data")
+	}
+	if len(publicKey) != ed25519.PublicKeySize {
+		return nil, ErrInvalidEd25519PublicKey
+	}
+
+	target := make([]int64, len(data))
+
+	a, err := new(edwards25519.Point).SetBytes(publicKey)
+	if err != nil {
+		return target, nil
+	}
+
+	sigs := make([]ed25519Signature, len(data))
+	decoded := true
+	for i, v := range data {
+		var ok bool
+		sigs[i], ok = decodeEd25519Signature(publicKey, v, signatures[i])
+		decoded = decoded && ok
+	}
+
+	if decoded && len(sigs) > 1 {
+		ok, err := ed25519VerifyBatch(a, sigs)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			for i := range target {
+				target[i] = 1
+			}
+			return target, nil
+		}
+	}
+
+	for i, sig := range sigs {
+		if sig.r != nil {
+			target[i] = types.BToI(ed25519VerifySingle(a, sig))
+		}
+	}
+	return target, nil
+}
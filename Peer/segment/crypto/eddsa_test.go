@@ -0,0 +1,154 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package crypto
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha512"
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	"filippo.io/edwards25519"
+)
+
+// RFC 8032 section 7.1, test 2
+func TestEd25519Sign_RFC8032(t *testing.T) {
+	seed, _ := hex.DecodeString("4ccd089b28ff96da9db6c346ec114e0f5b8a319f35aba624da8cf6ed4fb8a6fb")
+	publicKey, _ := hex.DecodeString("3d4017c3e843895a92b70aa74d1b7ebc9c982ccf2ec4968cc0cd55f12af4660c")
+	signature, _ := hex.DecodeString("92a009a9f0d4cab8720e820b5f642540a2b27b5416503f8fb3762223ebdb69da085ac1e43e15996e458f3613d0f11d8c387b2eaeb4302aeeb00d291612bb0c00")
+
+	privateKey := ed25519.NewKeyFromSeed(seed)
+
+	pk, err := Ed25519PublicKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(pk, publicKey) {
+		t.Errorf("unexpected public key %x", pk)
+	}
+
+	sigs, err := Ed25519Sign(privateKey, [][]byte{{0x72}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sigs[0], signature) {
+		t.Errorf("unexpected signature %x", sigs[0])
+	}
+
+	result, err := Ed25519Verify(publicKey, [][]byte{{0x72}}, sigs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result, []int64{1}) {
+		t.Errorf("unexpected verification result %v", result)
+	}
+}
+
+func TestEd25519Verify_Batch(t *testing.T) {
+	privateKey, err := Ed25519Keygen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, _ := Ed25519PublicKey(privateKey)
+
+	data := [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d")}
+	sigs, err := Ed25519Sign(privateKey, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range data {
+		if !ed25519.Verify(publicKey, data[i], sigs[i]) {
+			t.Fatal("signature rejected by crypto/ed25519")
+		}
+	}
+
+	result, err := Ed25519Verify(publicKey, data, sigs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result, []int64{1, 1, 1, 1}) {
+		t.Errorf("unexpected verification result %v", result)
+	}
+
+	// A bad signature fails the batch, and is then found on its own
+	sigs[1][5] ^= 1
+	sigs[3] = sigs[2]
+
+	result, err = Ed25519Verify(publicKey, data, sigs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result, []int64{1, 0, 1, 0}) {
+		t.Errorf("unexpected verification result %v", result)
+	}
+}
+
+func TestEd25519Verify_NonCanonicalScalar(t *testing.T) {
+	privateKey, _ := Ed25519Keygen()
+	publicKey, _ := Ed25519PublicKey(privateKey)
+
+	sigs, _ := Ed25519Sign(privateKey, [][]byte{[]byte("a"), []byte("b")})
+	for i := 32; i < 64; i++ {
+		sigs[0][i] = 0xff
+	}
+
+	result, err := Ed25519Verify(publicKey, [][]byte{[]byte("a"), []byte("b")}, sigs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result, []int64{0, 1}) {
+		t.Errorf("unexpected verification result %v", result)
+	}
+}
+
+func TestEd25519Verify_NonCanonicalR(t *testing.T) {
+	privateKey, _ := Ed25519Keygen()
+	publicKey, _ := Ed25519PublicKey(privateKey)
+	message := []byte("a")
+
+	h := sha512.Sum512(privateKey[:32])
+	a, err := edwards25519.NewScalar().SetBytesWithClamping(h[:32])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// With R the identity, S = [k]a satisfies the verification equation. The identity (0, 1)
+	// is also encoded by y = p + 1, which is not reduced.
+	canonical := append([]byte{1}, make([]byte, 31)...)
+	nonCanonical := append([]byte{0xee}, bytes.Repeat([]byte{0xff}, 30)...)
+	nonCanonical = append(nonCanonical, 0x7f)
+
+	for _, tc := range []struct {
+		name     string
+		r        []byte
+		expected int64
+	}{
+		{"canonical", canonical, 1},
+		{"not reduced", nonCanonical, 0},
+	} {
+		d := sha512.New()
+		d.Write(tc.r)
+		d.Write(publicKey)
+		d.Write(message)
+		s := edwards25519.NewScalar().Multiply(hashToScalar(d), a)
+		signature := append(append([]byte{}, tc.r...), s.Bytes()...)
+
+		result, err := Ed25519Verify(publicKey, [][]byte{message}, [][]byte{signature})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result[0] != tc.expected {
+			t.Errorf("%v: expected %v, got %v", tc.name, tc.expected, result[0])
+		}
+	}
+}
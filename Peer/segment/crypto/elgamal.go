@@ -0,0 +1,169 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package crypto
+
+import (
+	"errors"
+
+	"filippo.io/edwards25519"
+
+	"github.com/AUSTRAC/ftillite/Peer/segment/types"
+)
+
+var ErrNotSingletonKey = errors.New("key must be a singleton array")
+
+// ElGamalCiphertexts is an array of ElGamal ciphertexts over Ed25519, stored as the same
+// pair of arrays used by the ElGamalCipher Python class. The ciphertext of a message point
+// M under public key P with nonce r is (Mask, Masked) = (r*G, r*P + M).
+type ElGamalCiphertexts struct {
+	Mask   *types.Ed25519Array
+	Masked *types.Ed25519Array
+}
+
+func (c ElGamalCiphertexts) Length() int64 {
+	return c.Mask.Length()
+}
+
+func (c ElGamalCiphertexts) Free() {
+	c.Mask.Free()
+	c.Masked.Free()
+}
+
+func NewElGamalCiphertexts(mask *types.Ed25519Array, masked *types.Ed25519Array) (ElGamalCiphertexts, error) {
+	if mask.Length() != masked.Length() {
+		return ElGamalCiphertexts{}, errors.New("ciphertext arrays must be the same length")
+	}
+	return ElGamalCiphertexts{mask, masked}, nil
+}
+
+func asEd25519Array(v types.ArrayTypeVal, err error) (*types.Ed25519Array, error) {
+	if err != nil {
+		return nil, err
+	}
+	return v.(*types.Ed25519Array), nil
+}
+
+// elGamalNonceMasks returns r*G and r*P for each nonce r, which are the two halves of an
+// encryption of zero.
+func elGamalNonceMasks(publicKey *types.Ed25519Array, nonces *types.FTEd25519IntArray) (ElGamalCiphertexts, error) {
+	if publicKey.Length() != 1 {
+		return ElGamalCiphertexts{}, ErrNotSingletonKey
+	}
+
+	mask, err := types.NewEd25519ArrayFromIntWithBackend(publicKey.Backend(), nonces.Values())
+	if err != nil {
+		return ElGamalCiphertexts{}, err
+	}
+
+	keys, err := types.NewEd25519ArrayFromPoint(nonces.Length(), publicKey, 0)
+	if err != nil {
+		mask.Free()
+		return ElGamalCiphertexts{}, err
+	}
+	defer keys.Free()
+
+	masked, err := asEd25519Array(keys.Mul(nonces))
+	if err != nil {
+		mask.Free()
+		return ElGamalCiphertexts{}, err
+	}
+
+	return ElGamalCiphertexts{mask, masked}, nil
+}
+
+// ElGamalEncryptWithNonces encrypts each message point under publicKey using the given nonces.
+func ElGamalEncryptWithNonces(publicKey *types.Ed25519Array, messages *types.Ed25519Array, nonces *types.FTEd25519IntArray) (ElGamalCiphertexts, error) {
+	if messages.Length() != nonces.Length() {
+		return ElGamalCiphertexts{}, errors.New("messages and nonces must be the same length")
+	}
+
+	zeros, err := elGamalNonceMasks(publicKey, nonces)
+	if err != nil {
+		return ElGamalCiphertexts{}, err
+	}
+	defer zeros.Masked.Free()
+
+	masked, err := asEd25519Array(zeros.Masked.Add(messages))
+	if err != nil {
+		zeros.Mask.Free()
+		return ElGamalCiphertexts{}, err
+	}
+
+	return ElGamalCiphertexts{zeros.Mask, masked}, nil
+}
+
+// ElGamalEncrypt encrypts each message point under publicKey using fresh random nonces.
+func ElGamalEncrypt(publicKey *types.Ed25519Array, messages *types.Ed25519Array) (ElGamalCiphertexts, error) {
+	nonces, err := types.NewRandomFTEd25519IntArray(false, messages.Length())
+	if err != nil {
+		return ElGamalCiphertexts{}, err
+	}
+
+	return ElGamalEncryptWithNonces(publicKey, messages, nonces)
+}
+
+// ElGamalDecrypt returns the message points Masked - privateKey*Mask.
+func ElGamalDecrypt(privateKey *edwards25519.Scalar, c ElGamalCiphertexts) (*types.Ed25519Array, error) {
+	shared, err := c.Mask.Copy()
+	if err != nil {
+		return nil, err
+	}
+	defer shared.Free()
+
+	err = shared.Scale(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return asEd25519Array(c.Masked.Sub(shared))
+}
+
+// ElGamalAdd returns the component-wise sum of two arrays of ciphertexts, which encrypts
+// the sums of their messages.
+func ElGamalAdd(c ElGamalCiphertexts, d ElGamalCiphertexts) (ElGamalCiphertexts, error) {
+	mask, err := asEd25519Array(c.Mask.Add(d.Mask))
+	if err != nil {
+		return ElGamalCiphertexts{}, err
+	}
+
+	masked, err := asEd25519Array(c.Masked.Add(d.Masked))
+	if err != nil {
+		mask.Free()
+		return ElGamalCiphertexts{}, err
+	}
+
+	return ElGamalCiphertexts{mask, masked}, nil
+}
+
+// ElGamalRerandomise adds a fresh encryption of zero to each ciphertext, so that the result
+// decrypts to the same messages but can not be linked to the input.
+func ElGamalRerandomise(publicKey *types.Ed25519Array, c ElGamalCiphertexts) (ElGamalCiphertexts, error) {
+	nonces, err := types.NewRandomFTEd25519IntArray(false, c.Length())
+	if err != nil {
+		return ElGamalCiphertexts{}, err
+	}
+
+	return ElGamalRerandomiseWithNonces(publicKey, c, nonces)
+}
+
+// ElGamalRerandomiseWithNonces is ElGamalRerandomise using the given nonces.
+func ElGamalRerandomiseWithNonces(publicKey *types.Ed25519Array, c ElGamalCiphertexts, nonces *types.FTEd25519IntArray) (ElGamalCiphertexts, error) {
+	if c.Length() != nonces.Length() {
+		return ElGamalCiphertexts{}, errors.New("ciphertexts and nonces must be the same length")
+	}
+
+	zeros, err := elGamalNonceMasks(publicKey, nonces)
+	if err != nil {
+		return ElGamalCiphertexts{}, err
+	}
+	defer zeros.Free()
+
+	return ElGamalAdd(c, zeros)
+}
@@ -40,3 +40,29 @@ func Grain128Aeadv2(key []byte, iv []byte, width int64, length int64) ([][]byte,
 
 	return target, nil
 }
+
+const (
+	Grain128Aeadv2NonceBytes = grain.NonceSize
+	Grain128Aeadv2TagBytes   = grain.TagSize
+)
+
+// Grain128Aeadv2Seal encrypts and authenticates each element under a fresh nonce, returning
+// the ciphertexts, which are the same width as the data, along with the nonce and tag of
+// each element.
+func Grain128Aeadv2Seal(key []byte, data [][]byte, additionalData [][]byte) ([][]byte, [][]byte, [][]byte, error) {
+	aead, err := grain.New(key)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("unable to create Grain128-AEAD cypher: %v", err)
+	}
+	return aeadSeal(aead, data, additionalData)
+}
+
+// Grain128Aeadv2Open returns the plaintexts and a mask which is 1 for each element that
+// decrypted successfully. Elements which fail authentication are returned as zeroes.
+func Grain128Aeadv2Open(key []byte, ciphertexts [][]byte, nonces [][]byte, tags [][]byte, additionalData [][]byte) ([][]byte, []int64, error) {
+	aead, err := grain.New(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to create Grain128-AEAD cypher: %v", err)
+	}
+	return aeadOpen(aead, ciphertexts, nonces, tags, additionalData)
+}
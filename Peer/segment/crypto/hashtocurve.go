@@ -0,0 +1,288 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package crypto
+
+import (
+	"crypto/sha512"
+	"errors"
+	"math/big"
+
+	"filippo.io/edwards25519"
+	"filippo.io/edwards25519/field"
+)
+
+// HashToCurve implements the edwards25519_XMD:SHA-512_ELL2_RO_ suite of RFC 9380, so that the
+// points it produces match those of any other conforming implementation. HashToScalar expands
+// each element to 64 bytes with the same expand_message_xmd and reduces them modulo the group
+// order, which gives a negligible bias.
+//
+// The maps are constant time in the data; only the length of the data is revealed.
+
+var ErrEmptyDST = errors.New("domain separation tag must not be empty")
+
+const (
+	h2cFieldBytes  = 48 // L for p = 2^255 - 19 and k = 128
+	h2cScalarBytes = 64
+)
+
+var (
+	h2cSqrtMinusOne        *field.Element // c3 = sqrt(-1)
+	h2cTwoToC1             *field.Element // c2 = 2^((p + 3) / 8)
+	h2cSqrtMinusA          *field.Element // sqrt(-486664), with sgn0 equal to 0
+	h2cMinusJ              *field.Element // -486662
+	h2cJ                   *field.Element // 486662
+	h2cOversizeDSTPrefix   = []byte("H2C-OVERSIZE-DST-")
+	errExpandMessageLength = errors.New("expand_message_xmd output length is too large")
+)
+
+func init() {
+	p := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+
+	c1 := new(big.Int).Rsh(new(big.Int).Add(p, big.NewInt(3)), 3)
+	h2cTwoToC1 = bigIntToElement(new(big.Int).Exp(big.NewInt(2), c1, p))
+
+	h2cSqrtMinusOne = bigIntToElement(new(big.Int).ModSqrt(new(big.Int).Sub(p, big.NewInt(1)), p))
+
+	sqrtMinusA := new(big.Int).ModSqrt(new(big.Int).Sub(p, big.NewInt(486664)), p)
+	if sqrtMinusA.Bit(0) == 1 {
+		sqrtMinusA.Sub(p, sqrtMinusA)
+	}
+	h2cSqrtMinusA = bigIntToElement(sqrtMinusA)
+
+	h2cJ = bigIntToElement(big.NewInt(486662))
+	h2cMinusJ = new(field.Element).Negate(h2cJ)
+}
+
+// bigIntToElement converts a non-negative integer less than p to a field element.
+func bigIntToElement(x *big.Int) *field.Element {
+	buf := make([]byte, 32)
+	x.FillBytes(buf)
+	for i, j := 0, len(buf)-1; i < j; i, j = i+1, j-1 {
+		buf[i], buf[j] = buf[j], buf[i]
+	}
+
+	e, err := new(field.Element).SetBytes(buf)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+// expandMessageXMD is expand_message_xmd from RFC 9380 section 5.3.1 with SHA-512.
+func expandMessageXMD(msg []byte, dst []byte, length int) ([]byte, error) {
+	const bInBytes = sha512.Size
+	const sInBytes = sha512.BlockSize
+
+	if len(dst) > 255 {
+		h := sha512.New()
+		h.Write(h2cOversizeDSTPrefix)
+		h.Write(dst)
+		dst = h.Sum(nil)
+	}
+
+	ell := (length + bInBytes - 1) / bInBytes
+	if ell > 255 || length > 65535 {
+		return nil, errExpandMessageLength
+	}
+
+	dstPrime := append(append([]byte{}, dst...), byte(len(dst)))
+
+	h := sha512.New()
+	h.Write(make([]byte, sInBytes))
+	h.Write(msg)
+	h.Write([]byte{byte(length >> 8), byte(length), 0})
+	h.Write(dstPrime)
+	b0 := h.Sum(nil)
+
+	h.Reset()
+	h.Write(b0)
+	h.Write([]byte{1})
+	h.Write(dstPrime)
+	bi := h.Sum(nil)
+
+	uniform := append(make([]byte, 0, ell*bInBytes), bi...)
+	for i := 2; i <= ell; i++ {
+		x := make([]byte, bInBytes)
+		for j := range x {
+			x[j] = b0[j] ^ bi[j]
+		}
+
+		h.Reset()
+		h.Write(x)
+		h.Write([]byte{byte(i)})
+		h.Write(dstPrime)
+		bi = h.Sum(nil)
+
+		uniform = append(uniform, bi...)
+	}
+
+	return uniform[:length], nil
+}
+
+// hashToField is hash_to_field from RFC 9380 section 5.2 for the field of edwards25519.
+func hashToField(msg []byte, dst []byte, count int) ([]*field.Element, error) {
+	uniform, err := expandMessageXMD(msg, dst, count*h2cFieldBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	u := make([]*field.Element, count)
+	for i := range u {
+		u[i] = reduceFieldBytes(uniform[i*h2cFieldBytes : (i+1)*h2cFieldBytes])
+	}
+	return u, nil
+}
+
+// reduceFieldBytes reduces a big-endian integer of h2cFieldBytes bytes modulo p in constant
+// time, by reversing it into a little-endian 64 byte buffer for SetWideBytes.
+func reduceFieldBytes(b []byte) *field.Element {
+	wide := make([]byte, 64)
+	for j, v := range b {
+		wide[len(b)-1-j] = v
+	}
+
+	e, err := new(field.Element).SetWideBytes(wide)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+// cmov returns b if c is set and a otherwise, as CMOV in RFC 9380.
+func cmov(a, b *field.Element, c int) *field.Element {
+	return new(field.Element).Select(b, a, c)
+}
+
+// mapToCurveElligator2Curve25519 is the straight-line map of RFC 9380 appendix G.2.1. It
+// returns the point (xn / xd, y) on curve25519.
+func mapToCurveElligator2Curve25519(u *field.Element) (xn, xd, y *field.Element) {
+	tv1 := new(field.Element).Square(u)
+	tv1.Add(tv1, tv1)
+	xd = new(field.Element).Add(tv1, new(field.Element).One())
+	x1n := h2cMinusJ
+	tv2 := new(field.Element).Square(xd)
+	gxd := new(field.Element).Multiply(tv2, xd)
+	gx1 := new(field.Element).Multiply(h2cJ, tv1)
+	gx1.Multiply(gx1, x1n)
+	gx1.Add(gx1, tv2)
+	gx1.Multiply(gx1, x1n)
+	tv3 := new(field.Element).Square(gxd)
+	tv2.Square(tv3)
+	tv3.Multiply(tv3, gxd)
+	tv3.Multiply(tv3, gx1)
+	tv2.Multiply(tv2, tv3)
+	y11 := new(field.Element).Pow22523(tv2)
+	y11.Multiply(y11, tv3)
+	y12 := new(field.Element).Multiply(y11, h2cSqrtMinusOne)
+	tv2.Square(y11)
+	tv2.Multiply(tv2, gxd)
+	e1 := tv2.Equal(gx1)
+	y1 := cmov(y12, y11, e1)
+	x2n := new(field.Element).Multiply(x1n, tv1)
+	y21 := new(field.Element).Multiply(y11, u)
+	y21.Multiply(y21, h2cTwoToC1)
+	y22 := new(field.Element).Multiply(y21, h2cSqrtMinusOne)
+	gx2 := new(field.Element).Multiply(gx1, tv1)
+	tv2.Square(y21)
+	tv2.Multiply(tv2, gxd)
+	e2 := tv2.Equal(gx2)
+	y2 := cmov(y22, y21, e2)
+	tv2.Square(y1)
+	tv2.Multiply(tv2, gxd)
+	e3 := tv2.Equal(gx1)
+	xn = cmov(x2n, x1n, e3)
+	y = cmov(y2, y1, e3)
+	e4 := y.IsNegative()
+	y = cmov(y, new(field.Element).Negate(y), e3^e4)
+	return xn, xd, y
+}
+
+// mapToCurveElligator2Edwards25519 is the map of RFC 9380 appendix G.2.2, applying the
+// rational map from curve25519 to edwards25519.
+func mapToCurveElligator2Edwards25519(u *field.Element) (*edwards25519.Point, error) {
+	xMn, xMd, yMn := mapToCurveElligator2Curve25519(u)
+
+	xn := new(field.Element).Multiply(xMn, h2cSqrtMinusA)
+	xd := new(field.Element).Multiply(xMd, yMn)
+	yn := new(field.Element).Subtract(xMn, xMd)
+	yd := new(field.Element).Add(xMn, xMd)
+
+	e := new(field.Element).Multiply(xd, yd).Equal(new(field.Element).Zero())
+	one := new(field.Element).One()
+	xn = cmov(xn, new(field.Element).Zero(), e)
+	xd = cmov(xd, one, e)
+	yn = cmov(yn, one, e)
+	yd = cmov(yd, one, e)
+
+	// (x, y) = (xn / xd, yn / yd) in extended coordinates
+	return new(edwards25519.Point).SetExtendedCoordinates(
+		new(field.Element).Multiply(xn, yd),
+		new(field.Element).Multiply(yn, xd),
+		new(field.Element).Multiply(xd, yd),
+		new(field.Element).Multiply(xn, yn),
+	)
+}
+
+func hashToCurve(msg []byte, dst []byte) (*edwards25519.Point, error) {
+	u, err := hashToField(msg, dst, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	q0, err := mapToCurveElligator2Edwards25519(u[0])
+	if err != nil {
+		return nil, err
+	}
+	q1, err := mapToCurveElligator2Edwards25519(u[1])
+	if err != nil {
+		return nil, err
+	}
+
+	r := new(edwards25519.Point).Add(q0, q1)
+	return r.MultByCofactor(r), nil
+}
+
+// HashToCurve hashes each element to a point of the prime order subgroup.
+func HashToCurve(data [][]byte, dst []byte) ([]*edwards25519.Point, error) {
+	if len(dst) == 0 {
+		return nil, ErrEmptyDST
+	}
+
+	target := make([]*edwards25519.Point, len(data))
+	for i, v := range data {
+		p, err := hashToCurve(v, dst)
+		if err != nil {
+			return nil, err
+		}
+		target[i] = p
+	}
+	return target, nil
+}
+
+// HashToScalar hashes each element to a scalar by wide reduction.
+func HashToScalar(data [][]byte, dst []byte) ([]*edwards25519.Scalar, error) {
+	if len(dst) == 0 {
+		return nil, ErrEmptyDST
+	}
+
+	target := make([]*edwards25519.Scalar, len(data))
+	for i, v := range data {
+		uniform, err := expandMessageXMD(v, dst, h2cScalarBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		target[i], err = edwards25519.NewScalar().SetUniformBytes(uniform)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return target, nil
+}
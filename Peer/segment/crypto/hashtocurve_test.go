@@ -0,0 +1,120 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package crypto
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"filippo.io/edwards25519/field"
+)
+
+// affineHex returns the big endian hex encoding of an element, as in the RFC test vectors.
+func affineHex(e *field.Element) string {
+	bs := e.Bytes()
+	for i, j := 0, len(bs)-1; i < j; i, j = i+1, j-1 {
+		bs[i], bs[j] = bs[j], bs[i]
+	}
+	return hex.EncodeToString(bs)
+}
+
+// RFC 9380 appendix J.5.1
+func TestHashToCurve_Vectors(t *testing.T) {
+	dst := []byte("QUUX-V01-CS02-with-edwards25519_XMD:SHA-512_ELL2_RO_")
+
+	tests := []struct {
+		msg string
+		x   string
+		y   string
+	}{
+		{"", "3c3da6925a3c3c268448dcabb47ccde5439559d9599646a8260e47b1e4822fc6", "09a6c8561a0b22bef63124c588ce4c62ea83a3c899763af26d795302e115dc21"},
+		{"abc", "608040b42285cc0d72cbb3985c6b04c935370c7361f4b7fbdb1ae7f8c1a8ecad", "1a8395b88338f22e435bbd301183e7f20a5f9de643f11882fb237f88268a5531"},
+	}
+
+	for _, tt := range tests {
+		points, err := HashToCurve([][]byte{[]byte(tt.msg)}, dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		x, y, z, _ := points[0].ExtendedCoordinates()
+		zInv := new(field.Element).Invert(z)
+
+		if got := affineHex(new(field.Element).Multiply(x, zInv)); got != tt.x {
+			t.Errorf("unexpected x %v for %q", got, tt.msg)
+		}
+		if got := affineHex(new(field.Element).Multiply(y, zInv)); got != tt.y {
+			t.Errorf("unexpected y %v for %q", got, tt.msg)
+		}
+	}
+}
+
+// RFC 9380 appendix K.3
+func TestExpandMessageXMD_Vector(t *testing.T) {
+	uniform, err := expandMessageXMD(nil, []byte("QUUX-V01-CS02-with-expander-SHA512-256"), 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(uniform); got != "6b9a7312411d92f921c6f68ca0b6380730a1a4d982c507211a90964c394179ba" {
+		t.Errorf("unexpected output %v", got)
+	}
+}
+
+func TestReduceFieldBytes(t *testing.T) {
+	p := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+	max := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 8*h2cFieldBytes), big.NewInt(1))
+
+	for _, x := range []*big.Int{
+		big.NewInt(0),
+		new(big.Int).Sub(p, big.NewInt(1)),
+		p,
+		new(big.Int).Add(p, big.NewInt(1)),
+		new(big.Int).Lsh(big.NewInt(1), 255),
+		new(big.Int).Lsh(big.NewInt(1), 256),
+		max,
+	} {
+		b := make([]byte, h2cFieldBytes)
+		x.FillBytes(b)
+
+		expected := new(big.Int).Mod(x, p)
+		if got := affineHex(reduceFieldBytes(b)); got != hex.EncodeToString(expected.FillBytes(make([]byte, 32))) {
+			t.Errorf("%x: expected %x, got %v", x, expected, got)
+		}
+	}
+}
+
+func TestHashToScalar_DST(t *testing.T) {
+	data := [][]byte{[]byte("account 1"), []byte("account 2")}
+
+	a, err := HashToScalar(data, []byte("tag a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := HashToScalar(data, []byte("tag b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := HashToScalar(data, []byte("tag a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a[0].Equal(again[0]) != 1 || a[1].Equal(again[1]) != 1 {
+		t.Error("hashing is not deterministic")
+	}
+	if a[0].Equal(a[1]) == 1 || a[0].Equal(b[0]) == 1 {
+		t.Error("different data or tags hash to the same scalar")
+	}
+
+	if _, err := HashToScalar(data, nil); err != ErrEmptyDST {
+		t.Errorf("expected an empty tag to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// Keyed PRFs map identifiers to pseudonyms which are the same on every peer holding the key,
+// but can not be recomputed by anyone without it. Each output is truncated to the requested
+// width. An optional label separates the pseudonyms derived for different purposes: for
+// HMAC it is appended to each element, which is unambiguous as all elements of an array have
+// the same width, and for KMAC it is the customisation string.
+
+var ErrEmptyMACKey = errors.New("key must not be empty")
+
+func checkMACWidth(width int, max int) error {
+	if width < 1 || (max > 0 && width > max) {
+		if max > 0 {
+			return fmt.Errorf("width must be between 1 and %v", max)
+		}
+		return errors.New("width must be at least 1")
+	}
+	return nil
+}
+
+func hmacSum(newHash func() hash.Hash, key []byte, data [][]byte, width int, label []byte) ([][]byte, error) {
+	if len(key) == 0 {
+		return nil, ErrEmptyMACKey
+	}
+
+	mac := hmac.New(newHash, key)
+
+	err := checkMACWidth(width, mac.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	target := make([][]byte, len(data))
+	for i, v := range data {
+		mac.Reset()
+		mac.Write(v)
+		mac.Write(label)
+		target[i] = mac.Sum(nil)[:width]
+	}
+	return target, nil
+}
+
+func HmacSha256(key []byte, data [][]byte, width int, label []byte) ([][]byte, error) {
+	return hmacSum(sha256.New, key, data, width, label)
+}
+
+func HmacSha3256(key []byte, data [][]byte, width int, label []byte) ([][]byte, error) {
+	return hmacSum(sha3.New256, key, data, width, label)
+}
+
+// leftEncode, rightEncode, encodeString and bytepad are the encodings of NIST SP 800-185.
+func leftEncode(x uint64) []byte {
+	buf := make([]byte, 9)
+	binary.BigEndian.PutUint64(buf[1:], x)
+
+	n := 1
+	for n < 8 && buf[n] == 0 {
+		n++
+	}
+	buf[n-1] = byte(9 - n)
+	return buf[n-1:]
+}
+
+func rightEncode(x uint64) []byte {
+	buf := make([]byte, 9)
+	binary.BigEndian.PutUint64(buf, x)
+
+	n := 0
+	for n < 7 && buf[n] == 0 {
+		n++
+	}
+	buf[8] = byte(8 - n)
+	return buf[n:]
+}
+
+func encodeString(s []byte) []byte {
+	return append(leftEncode(uint64(len(s))*8), s...)
+}
+
+func bytepad(x []byte, w int) []byte {
+	result := append(leftEncode(uint64(w)), x...)
+	if padding := len(result) % w; padding != 0 {
+		result = append(result, make([]byte, w-padding)...)
+	}
+	return result
+}
+
+// kmac256Rate is the rate of cSHAKE256 in bytes.
+const kmac256Rate = 136
+
+// Kmac256 is KMAC256 from NIST SP 800-185, with the label as the customisation string.
+func Kmac256(key []byte, data [][]byte, width int, label []byte) ([][]byte, error) {
+	if len(key) == 0 {
+		return nil, ErrEmptyMACKey
+	}
+
+	err := checkMACWidth(width, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := bytepad(encodeString(key), kmac256Rate)
+	suffix := rightEncode(uint64(width) * 8)
+
+	target := make([][]byte, len(data))
+	for i, v := range data {
+		h := sha3.NewCShake256([]byte("KMAC"), label)
+		h.Write(prefix)
+		h.Write(v)
+		h.Write(suffix)
+
+		target[i] = make([]byte, width)
+		h.Read(target[i])
+	}
+	return target, nil
+}
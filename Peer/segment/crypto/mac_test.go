@@ -0,0 +1,70 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// NIST SP 800-185 KMAC sample #4
+func TestKmac256_Sample(t *testing.T) {
+	key, _ := hex.DecodeString("404142434445464748494A4B4C4D4E4F505152535455565758595A5B5C5D5E5F")
+	expected, _ := hex.DecodeString("20C570C31346F703C9AC36C61C03CB64C3970D0CFC787E9B79599D273A68D2F7F69D4CC3DE9D104A351689F27CF6F5951F0103F33F4F24871024D9C27773A8DD")
+
+	result, err := Kmac256(key, [][]byte{{0, 1, 2, 3}}, 64, []byte("My Tagged Application"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(result[0], expected) {
+		t.Errorf("unexpected KMAC256 output %X", result[0])
+	}
+}
+
+// RFC 4231 test case 2
+func TestHmacSha256_RFC4231(t *testing.T) {
+	expected, _ := hex.DecodeString("5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843")
+
+	// The label is appended to the data
+	result, err := HmacSha256([]byte("Jefe"), [][]byte{[]byte("what do ya want ")}, 32, []byte("for nothing?"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(result[0], expected) {
+		t.Errorf("unexpected HMAC-SHA256 output %x", result[0])
+	}
+
+	result, err = HmacSha256([]byte("Jefe"), [][]byte{[]byte("what do ya want for nothing?")}, 16, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(result[0], expected[:16]) {
+		t.Errorf("unexpected truncated HMAC-SHA256 output %x", result[0])
+	}
+}
+
+func TestLeftRightEncode(t *testing.T) {
+	for _, tc := range []struct {
+		x           uint64
+		left, right []byte
+	}{
+		{0, []byte{1, 0}, []byte{0, 1}},
+		{136, []byte{1, 136}, []byte{136, 1}},
+		{512, []byte{2, 2, 0}, []byte{2, 0, 2}},
+	} {
+		if l := leftEncode(tc.x); !bytes.Equal(l, tc.left) {
+			t.Errorf("left_encode(%v) = %v", tc.x, l)
+		}
+		if r := rightEncode(tc.x); !bytes.Equal(r, tc.right) {
+			t.Errorf("right_encode(%v) = %v", tc.x, r)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/AUSTRAC/ftillite/Peer/segment/types"
+)
+
+// Paillier encryption uses the generator g = n + 1, so that a value m encrypts to
+// (1 + m*n) * r^n mod n^2 for a random r. Plaintexts are integers modulo n, with values above
+// n/2 decrypting to negative integers, which lets encrypted amounts be both added and
+// subtracted.
+//
+// A public key is the modulus n, of bits/8 bytes. A private key is n followed by the primes p
+// and q, of bits/16 bytes each. Ciphertexts are of 2*bits/8 bytes, see types.PaillierArray.
+
+// PaillierKeySizes are the supported sizes of the modulus in bits.
+var PaillierKeySizes = []int{2048, 3072, 4096}
+
+var errPaillierPlaintextRange = errors.New("Paillier plaintext can not be represented as a 64-bit integer")
+
+func PaillierKeygen(bits int) ([]byte, error) {
+	supported := false
+	for _, v := range PaillierKeySizes {
+		supported = supported || v == bits
+	}
+	if !supported {
+		return nil, fmt.Errorf("key size must be one of %v bits", PaillierKeySizes)
+	}
+
+	// The primes of an RSA key are of equal length, so gcd(n, (p - 1)(q - 1)) = 1 as required.
+	k, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		return nil, err
+	}
+
+	size := bits / 8
+	bs := make([]byte, 2*size)
+	k.N.FillBytes(bs[:size])
+	k.Primes[0].FillBytes(bs[size : size+size/2])
+	k.Primes[1].FillBytes(bs[size+size/2:])
+	return bs, nil
+}
+
+func paillierKeySize(width int) (int, error) {
+	for _, bits := range PaillierKeySizes {
+		if bits/8 == width {
+			return bits, nil
+		}
+	}
+	return 0, fmt.Errorf("Paillier public keys must be one of %v bits", PaillierKeySizes)
+}
+
+// PaillierPublicKey returns the public key of a private key.
+func PaillierPublicKey(privateKey []byte) ([]byte, error) {
+	if _, err := paillierKeySize(len(privateKey) / 2); err != nil || len(privateKey)%2 != 0 {
+		return nil, errors.New("invalid Paillier private key")
+	}
+	return privateKey[:len(privateKey)/2], nil
+}
+
+func paillierModulus(publicKey []byte) (*big.Int, error) {
+	if _, err := paillierKeySize(len(publicKey)); err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(publicKey), nil
+}
+
+// PaillierEncrypt encrypts each value under the public key.
+func PaillierEncrypt(publicKey []byte, values []int64) (*types.PaillierArray, error) {
+	n, err := paillierModulus(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	nSquared := new(big.Int).Mul(n, n)
+
+	xs := make([]*big.Int, len(values))
+	for i, v := range values {
+		r, err := paillierRandomUnit(n)
+		if err != nil {
+			return nil, err
+		}
+
+		m := new(big.Int).Mod(big.NewInt(v), n)
+		c := m.Mul(m, n)
+		c.Add(c, big.NewInt(1))
+		c.Mul(c, r.Exp(r, n, nSquared))
+		xs[i] = c.Mod(c, nSquared)
+	}
+
+	return types.NewPaillierArray(n, xs...)
+}
+
+func paillierRandomUnit(n *big.Int) (*big.Int, error) {
+	for {
+		r, err := rand.Int(rand.Reader, n)
+		if err != nil {
+			return nil, err
+		}
+		if r.Sign() > 0 && new(big.Int).GCD(nil, nil, r, n).Cmp(big.NewInt(1)) == 0 {
+			return r, nil
+		}
+	}
+}
+
+// PaillierDecrypt decrypts each ciphertext with the private key. It fails if the ciphertexts
+// are under a different key, or if a plaintext does not fit in an int64.
+func PaillierDecrypt(privateKey []byte, ciphertexts *types.PaillierArray) ([]int64, error) {
+	publicKey, err := PaillierPublicKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	n := new(big.Int).SetBytes(publicKey)
+
+	if ciphertexts.Modulus() != nil && ciphertexts.Modulus().Cmp(n) != 0 {
+		return nil, errors.New("Paillier ciphertexts are not encrypted under this key")
+	}
+
+	size := len(publicKey)
+	p := new(big.Int).SetBytes(privateKey[size : size+size/2])
+	q := new(big.Int).SetBytes(privateKey[size+size/2:])
+
+	one := big.NewInt(1)
+	pMinusOne := new(big.Int).Sub(p, one)
+	qMinusOne := new(big.Int).Sub(q, one)
+	gcd := new(big.Int).GCD(nil, nil, pMinusOne, qMinusOne)
+	lambda := new(big.Int).Mul(pMinusOne, qMinusOne)
+	lambda.Div(lambda, gcd)
+
+	mu := new(big.Int).ModInverse(lambda, n)
+	if mu == nil {
+		return nil, errors.New("invalid Paillier private key")
+	}
+
+	nSquared := new(big.Int).Mul(n, n)
+	half := new(big.Int).Rsh(n, 1)
+
+	result := make([]int64, ciphertexts.Length())
+	for i, c := range ciphertexts.Values() {
+		// m = L(c^lambda mod n^2) * mu mod n, where L(x) = (x - 1) / n
+		m := new(big.Int).Exp(c, lambda, nSquared)
+		m.Sub(m, one)
+		m.Div(m, n)
+		m.Mul(m, mu)
+		m.Mod(m, n)
+
+		if m.Cmp(half) > 0 {
+			m.Sub(m, n)
+		}
+		if !m.IsInt64() {
+			return nil, errPaillierPlaintextRange
+		}
+		result[i] = m.Int64()
+	}
+	return result, nil
+}
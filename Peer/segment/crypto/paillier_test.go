@@ -0,0 +1,81 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package crypto
+
+import (
+	"testing"
+
+	"github.com/AUSTRAC/ftillite/Peer/segment/types"
+	"golang.org/x/exp/slices"
+)
+
+func TestPaillier_Homomorphic(t *testing.T) {
+	sk, err := PaillierKeygen(2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, err := PaillierPublicKey(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	xs, err := PaillierEncrypt(pk, []int64{100, -25, 1 << 40})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if xs.TypeCode() != types.Paillier(512) {
+		t.Fatalf("unexpected typecode %v", xs.TypeCode())
+	}
+
+	ys, err := PaillierEncrypt(pk, []int64{5, 5, 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum, err := xs.Add(ys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	product, err := sum.(*types.PaillierArray).Mul(types.NewFTIntegerArray(2, -3, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bs, err := product.GetBinaryArray(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zs, err := types.NewPaillierArrayFromBytes(bs, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := PaillierDecrypt(sk, zs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []int64{210, 60, 1<<40 + 5}; !slices.Equal(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+
+	other, err := PaillierKeygen(2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = PaillierDecrypt(other, zs)
+	if err == nil {
+		t.Error("ciphertexts should not decrypt under another key")
+	}
+
+	_, err = PaillierKeygen(1024)
+	if err == nil {
+		t.Error("1024 bit keys should not be supported")
+	}
+}
@@ -0,0 +1,380 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package crypto
+
+import (
+	"crypto/sha512"
+	"errors"
+	"fmt"
+
+	"filippo.io/edwards25519"
+
+	"github.com/AUSTRAC/ftillite/Peer/segment/types"
+)
+
+// Proofs are non-interactive, using the Fiat-Shamir transform with SHA-512, and are encoded
+// as the challenge c followed by the response z, each a canonical 32 byte scalar.
+//
+// A Schnorr proof shows knowledge of the private key x of a public key X = x*G. A
+// Chaum-Pedersen proof shows that X1 = x*G1 and X2 = x*G2 for the same, unrevealed, x.
+
+const ProofBytes = 64
+
+const (
+	schnorrProofLabel = "ftillite/schnorr/v1"
+	dleqProofLabel    = "ftillite/dleq/v1"
+)
+
+var ErrInvalidProofWidth = fmt.Errorf("proofs must be %v byte arrays", ProofBytes)
+
+func proofChallenge(label string, points ...*edwards25519.Point) *edwards25519.Scalar {
+	h := sha512.New()
+	h.Write([]byte(label))
+	for _, p := range points {
+		h.Write(p.Bytes())
+	}
+
+	c, err := edwards25519.NewScalar().SetUniformBytes(h.Sum(nil))
+	if err != nil {
+		panic(err) // SHA-512 always produces 64 bytes
+	}
+	return c
+}
+
+func encodeProof(c, z *edwards25519.Scalar) []byte {
+	proof := make([]byte, 0, ProofBytes)
+	proof = append(proof, c.Bytes()...)
+	return append(proof, z.Bytes()...)
+}
+
+func decodeProof(proof []byte) (*edwards25519.Scalar, *edwards25519.Scalar, bool) {
+	if len(proof) != ProofBytes {
+		return nil, nil, false
+	}
+
+	c, err := edwards25519.NewScalar().SetCanonicalBytes(proof[:32])
+	if err != nil {
+		return nil, nil, false
+	}
+
+	z, err := edwards25519.NewScalar().SetCanonicalBytes(proof[32:])
+	if err != nil {
+		return nil, nil, false
+	}
+
+	return c, z, true
+}
+
+func proofNonces(length int) ([]*edwards25519.Scalar, error) {
+	nonces, err := types.NewRandomFTEd25519IntArray(true, int64(length))
+	if err != nil {
+		return nil, err
+	}
+	return nonces.Values(), nil
+}
+
+// SchnorrProve returns a proof of knowledge of each of the given private keys.
+func SchnorrProve(privateKeys []*edwards25519.Scalar) ([][]byte, error) {
+	nonces, err := proofNonces(len(privateKeys))
+	if err != nil {
+		return nil, err
+	}
+
+	proofs := make([][]byte, len(privateKeys))
+	for i, x := range privateKeys {
+		publicKey := new(edwards25519.Point).ScalarBaseMult(x)
+		commitment := new(edwards25519.Point).ScalarBaseMult(nonces[i])
+
+		c := proofChallenge(schnorrProofLabel, publicKey, commitment)
+		z := edwards25519.NewScalar().MultiplyAdd(c, x, nonces[i])
+
+		proofs[i] = encodeProof(c, z)
+	}
+	return proofs, nil
+}
+
+// SchnorrVerify returns 1 for each proof which shows knowledge of the private key of the
+// corresponding public key, and 0 otherwise.
+func SchnorrVerify(publicKeys []*edwards25519.Point, proofs [][]byte) ([]int64, error) {
+	if len(publicKeys) != len(proofs) {
+		return nil, errors.New("mismatched number of proofs and public keys")
+	}
+
+	results := make([]int64, len(proofs))
+	for i, proof := range proofs {
+		c, z, ok := decodeProof(proof)
+		if !ok {
+			continue
+		}
+
+		// R = z*G - c*X
+		negC := edwards25519.NewScalar().Negate(c)
+		commitment := new(edwards25519.Point).VarTimeDoubleScalarBaseMult(negC, publicKeys[i], z)
+
+		results[i] = types.BToI(proofChallenge(schnorrProofLabel, publicKeys[i], commitment).Equal(c) == 1)
+	}
+	return results, nil
+}
+
+// DLEQStatement is the claim that X1 = x*G1 and X2 = x*G2.
+type DLEQStatement struct {
+	G1, X1, G2, X2 *edwards25519.Point
+}
+
+func dleqProve(x *edwards25519.Scalar, nonce *edwards25519.Scalar, st DLEQStatement) []byte {
+	a := new(edwards25519.Point).ScalarMult(nonce, st.G1)
+	b := new(edwards25519.Point).ScalarMult(nonce, st.G2)
+
+	c := proofChallenge(dleqProofLabel, st.G1, st.X1, st.G2, st.X2, a, b)
+	z := edwards25519.NewScalar().MultiplyAdd(c, x, nonce)
+
+	return encodeProof(c, z)
+}
+
+func dleqVerify(proof []byte, st DLEQStatement) bool {
+	c, z, ok := decodeProof(proof)
+	if !ok {
+		return false
+	}
+
+	// A = z*G1 - c*X1, B = z*G2 - c*X2
+	negC := edwards25519.NewScalar().Negate(c)
+	a := new(edwards25519.Point).VarTimeMultiScalarMult([]*edwards25519.Scalar{z, negC}, []*edwards25519.Point{st.G1, st.X1})
+	b := new(edwards25519.Point).VarTimeMultiScalarMult([]*edwards25519.Scalar{z, negC}, []*edwards25519.Point{st.G2, st.X2})
+
+	return proofChallenge(dleqProofLabel, st.G1, st.X1, st.G2, st.X2, a, b).Equal(c) == 1
+}
+
+// DLEQProve returns a Chaum-Pedersen proof for each statement, where secrets holds either a
+// single secret shared by all of the statements or one secret per statement.
+func DLEQProve(secrets []*edwards25519.Scalar, statements []DLEQStatement) ([][]byte, error) {
+	if len(secrets) != 1 && len(secrets) != len(statements) {
+		return nil, errors.New("mismatched number of secrets and statements")
+	}
+
+	nonces, err := proofNonces(len(statements))
+	if err != nil {
+		return nil, err
+	}
+
+	proofs := make([][]byte, len(statements))
+	for i, st := range statements {
+		x := secrets[0]
+		if len(secrets) > 1 {
+			x = secrets[i]
+		}
+		proofs[i] = dleqProve(x, nonces[i], st)
+	}
+	return proofs, nil
+}
+
+// DLEQVerify returns 1 for each proof which holds for the corresponding statement, and 0
+// otherwise.
+func DLEQVerify(proofs [][]byte, statements []DLEQStatement) ([]int64, error) {
+	if len(proofs) != len(statements) {
+		return nil, errors.New("mismatched number of proofs and statements")
+	}
+
+	results := make([]int64, len(proofs))
+	for i, proof := range proofs {
+		results[i] = types.BToI(dleqVerify(proof, statements[i]))
+	}
+	return results, nil
+}
+
+func singletonPoint(publicKey *types.Ed25519Array) (*edwards25519.Point, error) {
+	if publicKey.Length() != 1 {
+		return nil, ErrNotSingletonKey
+	}
+
+	points, err := publicKey.Points()
+	if err != nil {
+		return nil, err
+	}
+	return points[0], nil
+}
+
+func pointDifferences(xs []*edwards25519.Point, ys []*edwards25519.Point) []*edwards25519.Point {
+	results := make([]*edwards25519.Point, len(xs))
+	for i := range xs {
+		results[i] = new(edwards25519.Point).Subtract(xs[i], ys[i])
+	}
+	return results
+}
+
+// decryptionStatements returns the statements that each shared point was computed from the
+// mask with the private key of verificationKey.
+func decryptionStatements(verificationKey *edwards25519.Point, mask *types.Ed25519Array, shared []*edwards25519.Point) ([]DLEQStatement, error) {
+	masks, err := mask.Points()
+	if err != nil {
+		return nil, err
+	}
+	if len(masks) != len(shared) {
+		return nil, errors.New("mismatched number of ciphertexts and decryptions")
+	}
+
+	statements := make([]DLEQStatement, len(masks))
+	for i := range masks {
+		statements[i] = DLEQStatement{edwards25519.NewGeneratorPoint(), verificationKey, masks[i], shared[i]}
+	}
+	return statements, nil
+}
+
+// ThresholdPartialDecryptProve is ThresholdPartialDecrypt, also returning proofs that each
+// partial decryption used the key share behind the verification key share*G.
+func ThresholdPartialDecryptProve(share *edwards25519.Scalar, mask *types.Ed25519Array) (*types.Ed25519Array, [][]byte, error) {
+	partials, err := ThresholdPartialDecrypt(share, mask)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	points, err := partials.Points()
+	if err != nil {
+		partials.Free()
+		return nil, nil, err
+	}
+
+	verificationKey := new(edwards25519.Point).ScalarBaseMult(share)
+	statements, err := decryptionStatements(verificationKey, mask, points)
+	if err != nil {
+		partials.Free()
+		return nil, nil, err
+	}
+
+	proofs, err := DLEQProve([]*edwards25519.Scalar{share}, statements)
+	if err != nil {
+		partials.Free()
+		return nil, nil, err
+	}
+
+	return partials, proofs, nil
+}
+
+// ThresholdPartialDecryptVerify checks proofs from ThresholdPartialDecryptProve.
+func ThresholdPartialDecryptVerify(verificationKey *types.Ed25519Array, mask *types.Ed25519Array, partials *types.Ed25519Array, proofs [][]byte) ([]int64, error) {
+	key, err := singletonPoint(verificationKey)
+	if err != nil {
+		return nil, err
+	}
+
+	points, err := partials.Points()
+	if err != nil {
+		return nil, err
+	}
+
+	statements, err := decryptionStatements(key, mask, points)
+	if err != nil {
+		return nil, err
+	}
+
+	return DLEQVerify(proofs, statements)
+}
+
+// ElGamalDecryptProve is ElGamalDecrypt, also returning proofs that each message was
+// decrypted with the private key behind the public key privateKey*G.
+func ElGamalDecryptProve(privateKey *edwards25519.Scalar, c ElGamalCiphertexts) (*types.Ed25519Array, [][]byte, error) {
+	shared, proofs, err := ThresholdPartialDecryptProve(privateKey, c.Mask)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer shared.Free()
+
+	result, err := asEd25519Array(c.Masked.Sub(shared))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return result, proofs, nil
+}
+
+// ElGamalDecryptVerify checks proofs from ElGamalDecryptProve.
+func ElGamalDecryptVerify(publicKey *types.Ed25519Array, c ElGamalCiphertexts, messages *types.Ed25519Array, proofs [][]byte) ([]int64, error) {
+	shared, err := asEd25519Array(c.Masked.Sub(messages))
+	if err != nil {
+		return nil, err
+	}
+	defer shared.Free()
+
+	return ThresholdPartialDecryptVerify(publicKey, c.Mask, shared, proofs)
+}
+
+// rerandomiseStatements returns the statements that result differs from c by an encryption
+// of zero, (r*G, r*P).
+func rerandomiseStatements(publicKey *edwards25519.Point, c ElGamalCiphertexts, result ElGamalCiphertexts) ([]DLEQStatement, error) {
+	if c.Length() != result.Length() {
+		return nil, errors.New("mismatched number of ciphertexts")
+	}
+
+	var points [4][]*edwards25519.Point
+	for i, xs := range []*types.Ed25519Array{c.Mask, c.Masked, result.Mask, result.Masked} {
+		var err error
+		points[i], err = xs.Points()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	masks := pointDifferences(points[2], points[0])
+	maskeds := pointDifferences(points[3], points[1])
+
+	statements := make([]DLEQStatement, len(masks))
+	for i := range masks {
+		statements[i] = DLEQStatement{edwards25519.NewGeneratorPoint(), masks[i], publicKey, maskeds[i]}
+	}
+	return statements, nil
+}
+
+// ElGamalRerandomiseProve is ElGamalRerandomise, also returning proofs that each result
+// decrypts to the same message as the corresponding ciphertext.
+func ElGamalRerandomiseProve(publicKey *types.Ed25519Array, c ElGamalCiphertexts) (ElGamalCiphertexts, [][]byte, error) {
+	key, err := singletonPoint(publicKey)
+	if err != nil {
+		return ElGamalCiphertexts{}, nil, err
+	}
+
+	nonces, err := types.NewRandomFTEd25519IntArray(false, c.Length())
+	if err != nil {
+		return ElGamalCiphertexts{}, nil, err
+	}
+
+	result, err := ElGamalRerandomiseWithNonces(publicKey, c, nonces)
+	if err != nil {
+		return ElGamalCiphertexts{}, nil, err
+	}
+
+	statements, err := rerandomiseStatements(key, c, result)
+	if err != nil {
+		result.Free()
+		return ElGamalCiphertexts{}, nil, err
+	}
+
+	proofs, err := DLEQProve(nonces.Values(), statements)
+	if err != nil {
+		result.Free()
+		return ElGamalCiphertexts{}, nil, err
+	}
+
+	return result, proofs, nil
+}
+
+// ElGamalRerandomiseVerify checks proofs from ElGamalRerandomiseProve.
+func ElGamalRerandomiseVerify(publicKey *types.Ed25519Array, c ElGamalCiphertexts, result ElGamalCiphertexts, proofs [][]byte) ([]int64, error) {
+	key, err := singletonPoint(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	statements, err := rerandomiseStatements(key, c, result)
+	if err != nil {
+		return nil, err
+	}
+
+	return DLEQVerify(proofs, statements)
+}
@@ -0,0 +1,144 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package crypto
+
+import (
+	"reflect"
+	"testing"
+
+	"filippo.io/edwards25519"
+
+	"github.com/AUSTRAC/ftillite/Peer/segment/types"
+)
+
+func TestSchnorr_ProveVerify(t *testing.T) {
+	keys := types.NewFTEd25519IntArrayFromInt64s(3, 5, 7).Values()
+
+	proofs, err := SchnorrProve(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publicKeys := make([]*edwards25519.Point, len(keys))
+	for i, x := range keys {
+		publicKeys[i] = new(edwards25519.Point).ScalarBaseMult(x)
+	}
+
+	// Swap the last two public keys, so that their proofs no longer apply
+	publicKeys[1], publicKeys[2] = publicKeys[2], publicKeys[1]
+
+	result, err := SchnorrVerify(publicKeys, proofs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result, []int64{1, 0, 0}) {
+		t.Errorf("unexpected verification result %v", result)
+	}
+}
+
+func TestDLEQ_TamperedProof(t *testing.T) {
+	x := types.Int64ToScalar(11)
+	h := new(edwards25519.Point).ScalarBaseMult(types.Int64ToScalar(13))
+
+	st := DLEQStatement{
+		G1: edwards25519.NewGeneratorPoint(),
+		X1: new(edwards25519.Point).ScalarBaseMult(x),
+		G2: h,
+		X2: new(edwards25519.Point).ScalarMult(x, h),
+	}
+
+	proofs, err := DLEQProve([]*edwards25519.Scalar{x}, []DLEQStatement{st, st})
+	if err != nil {
+		t.Fatal(err)
+	}
+	proofs[1][40] ^= 1
+
+	result, err := DLEQVerify(proofs, []DLEQStatement{st, st})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result, []int64{1, 0}) {
+		t.Errorf("unexpected verification result %v", result)
+	}
+}
+
+func TestElGamalDecryptProve_Verify(t *testing.T) {
+	privateKey := types.Int64ToScalar(9)
+	publicKey := types.NewEd25519ArrayFromInt64sOrPanic(9)
+	messages := types.NewEd25519ArrayFromInt64sOrPanic(1, 2, 3)
+
+	c, err := ElGamalEncrypt(publicKey, messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, proofs, err := ElGamalDecryptProve(privateKey, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Equals(messages) {
+		t.Fatal("decryption did not return the messages")
+	}
+
+	verified, err := ElGamalDecryptVerify(publicKey, c, result, proofs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(verified, []int64{1, 1, 1}) {
+		t.Errorf("unexpected verification result %v", verified)
+	}
+
+	// A claimed decryption to the wrong message does not verify
+	wrong := types.NewEd25519ArrayFromInt64sOrPanic(1, 4, 3)
+	verified, err = ElGamalDecryptVerify(publicKey, c, wrong, proofs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(verified, []int64{1, 0, 1}) {
+		t.Errorf("unexpected verification result %v", verified)
+	}
+}
+
+func TestElGamalRerandomiseProve_Verify(t *testing.T) {
+	publicKey := types.NewEd25519ArrayFromInt64sOrPanic(9)
+	messages := types.NewEd25519ArrayFromInt64sOrPanic(1, 2)
+
+	c, err := ElGamalEncrypt(publicKey, messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, proofs, err := ElGamalRerandomiseProve(publicKey, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verified, err := ElGamalRerandomiseVerify(publicKey, c, result, proofs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(verified, []int64{1, 1}) {
+		t.Errorf("unexpected verification result %v", verified)
+	}
+
+	// A fresh encryption of a different message does not verify as a rerandomisation
+	other, err := ElGamalEncrypt(publicKey, types.NewEd25519ArrayFromInt64sOrPanic(1, 5))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verified, err = ElGamalRerandomiseVerify(publicKey, c, other, proofs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(verified, []int64{0, 0}) {
+		t.Errorf("unexpected verification result %v", verified)
+	}
+}
@@ -10,23 +10,71 @@
 package crypto
 
 import (
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"math/big"
+
+	"github.com/AUSTRAC/ftillite/Peer/segment/types"
 )
 
 const RSAPrivateKeyBytearraySize = 2720
 const RSAPublicKeyBytearraySize = 400
 
-func RSA3072Keygen() ([]byte, error) {
-	k, err := rsa.GenerateKey(rand.Reader, 3072)
+// RSAKeySizes are the supported key sizes in bits. The size of a key is given by the width of
+// the bytearray holding it, see RSAPrivateKeyBytearraySizeFor and RSAPublicKeyBytearraySizeFor.
+var RSAKeySizes = []int{2048, 3072, 4096}
+
+// RSAPrivateKeyBytearraySizeFor returns the width of the encoding of a two prime private key
+// with the given number of bits: the modulus, private exponent, two primes and three CRT
+// values, along with four integers.
+func RSAPrivateKeyBytearraySizeFor(bits int) int {
+	return 4*8 + 7*(bits/8)
+}
+
+// RSAPublicKeyBytearraySizeFor returns the width of the encoding of a public key with the
+// given number of bits: the modulus, along with two integers.
+func RSAPublicKeyBytearraySizeFor(bits int) int {
+	return 2*8 + bits/8
+}
+
+func rsaKeySizeFromWidth(width int, sizeFor func(bits int) int) (int, error) {
+	for _, bits := range RSAKeySizes {
+		if sizeFor(bits) == width {
+			return bits, nil
+		}
+	}
+
+	widths := make([]int, len(RSAKeySizes))
+	for i, bits := range RSAKeySizes {
+		widths[i] = sizeFor(bits)
+	}
+	return 0, fmt.Errorf("byte array length must be one of %v, but got %v", widths, width)
+}
+
+func RSAKeygen(bits int) ([]byte, error) {
+	supported := false
+	for _, v := range RSAKeySizes {
+		supported = supported || v == bits
+	}
+	if !supported {
+		return nil, fmt.Errorf("key size must be one of %v bits", RSAKeySizes)
+	}
+
+	k, err := rsa.GenerateKey(rand.Reader, bits)
 	if err != nil {
 		return nil, err
 	}
 	return RSAPrivateKeyToBytes(k), nil
 }
 
+func RSA3072Keygen() ([]byte, error) {
+	return RSAKeygen(3072)
+}
+
 func RSA3072Encrypt(pk *rsa.PublicKey, data [][]byte) ([][]byte, error) {
 	xs := make([][]byte, len(data))
 	for i, v := range data {
@@ -103,8 +151,12 @@ func RSAPrivateKeyToBytes(k *rsa.PrivateKey) []byte {
 }
 
 func RSAPrivateKeyFromBytes(bs []byte) (*rsa.PrivateKey, error) {
-	if len(bs) != RSAPrivateKeyBytearraySize {
-		return nil, fmt.Errorf("byte array length must be %v, but got %v", RSAPrivateKeyBytearraySize, len(bs))
+	bits, err := rsaKeySizeFromWidth(len(bs), RSAPrivateKeyBytearraySizeFor)
+	if err != nil {
+		return nil, err
+	}
+	if readRSAIntegerSize(bs) != bits/8 {
+		return nil, errors.New("byte array does not hold a private key of the expected size")
 	}
 
 	var bigIntByteSize int
@@ -155,7 +207,7 @@ func RSAPrivateKeyFromBytes(bs []byte) (*rsa.PrivateKey, error) {
 		w.Read(AsBigInteger{bigIntByteSize, k.Precomputed.CRTValues[i].R})
 	}
 
-	err := k.Validate()
+	err = k.Validate()
 	if err != nil {
 		return nil, err
 	}
@@ -174,8 +226,12 @@ func RSAPublicKeyToBytes(k *rsa.PublicKey) []byte {
 }
 
 func RSAPublicKeyFromBytes(bs []byte) (*rsa.PublicKey, error) {
-	if len(bs) != RSAPublicKeyBytearraySize {
-		return nil, fmt.Errorf("byte array length must be %v, but got %v", RSAPublicKeyBytearraySize, len(bs))
+	bits, err := rsaKeySizeFromWidth(len(bs), RSAPublicKeyBytearraySizeFor)
+	if err != nil {
+		return nil, err
+	}
+	if readRSAIntegerSize(bs) != bits/8 {
+		return nil, errors.New("byte array does not hold a public key of the expected size")
 	}
 
 	var bigIntByteSize int
@@ -192,3 +248,67 @@ func RSAPublicKeyFromBytes(bs []byte) (*rsa.PublicKey, error) {
 
 	return k, nil
 }
+
+// readRSAIntegerSize returns the size of the big integers in an encoded key, which is the
+// first value written.
+func readRSAIntegerSize(bs []byte) int {
+	var bigIntByteSize int
+	w := &Stream{bs, 0}
+	w.Read(AsInteger{&bigIntByteSize})
+	return bigIntByteSize
+}
+
+// RSAOAEPEncrypt encrypts each element with RSA-OAEP using SHA-256.
+func RSAOAEPEncrypt(pk *rsa.PublicKey, data [][]byte) ([][]byte, error) {
+	xs := make([][]byte, len(data))
+	for i, v := range data {
+		ve, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pk, v, nil)
+		if err != nil {
+			return nil, err
+		}
+		xs[i] = ve
+	}
+	return xs, nil
+}
+
+func RSAOAEPDecrypt(pk *rsa.PrivateKey, data [][]byte) ([][]byte, error) {
+	xs := make([][]byte, len(data))
+	for i, v := range data {
+		ve, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, pk, v, nil)
+		if err != nil {
+			return nil, err
+		}
+		xs[i] = ve
+	}
+	return xs, nil
+}
+
+var rsaPSSOptions = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}
+
+// RSAPSSSign signs the SHA-256 digest of each element with RSA-PSS.
+func RSAPSSSign(pk *rsa.PrivateKey, data [][]byte) ([][]byte, error) {
+	target := make([][]byte, len(data))
+	for i, v := range data {
+		digest := sha256.Sum256(v)
+		sig, err := rsa.SignPSS(rand.Reader, pk, crypto.SHA256, digest[:], rsaPSSOptions)
+		if err != nil {
+			return nil, err
+		}
+		target[i] = sig
+	}
+	return target, nil
+}
+
+func RSAPSSVerify(pk *rsa.PublicKey, data [][]byte, signatures [][]byte) ([]int64, error) {
+	if len(data) != len(signatures) {
+		return nil, errors.New("mismatched number of signatures and data")
+	}
+
+	target := make([]int64, len(data))
+	for i, v := range data {
+		digest := sha256.Sum256(v)
+		err := rsa.VerifyPSS(pk, crypto.SHA256, digest[:], signatures[i], rsaPSSOptions)
+		target[i] = types.BToI(err == nil)
+	}
+	return target, nil
+}
@@ -48,3 +48,33 @@ func TestRSA3073PublicKey_ToFromBytes(t *testing.T) {
 		t.Error("public keys are not equal after to/from bytes")
 	}
 }
+
+func TestRSAKeygen_Sizes(t *testing.T) {
+	for _, bits := range []int{2048, 4096} {
+		bs, err := RSAKeygen(bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(bs) != RSAPrivateKeyBytearraySizeFor(bits) {
+			t.Errorf("unexpected private key size %v for %v bits", len(bs), bits)
+		}
+
+		k, err := RSAPrivateKeyFromBytes(bs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if k.N.BitLen() != bits {
+			t.Errorf("unexpected modulus size %v for %v bits", k.N.BitLen(), bits)
+		}
+		if len(RSAPublicKeyToBytes(&k.PublicKey)) != RSAPublicKeyBytearraySizeFor(bits) {
+			t.Errorf("unexpected public key size for %v bits", bits)
+		}
+	}
+
+	if _, err := RSAKeygen(1024); err == nil {
+		t.Error("expected 1024 bit keys to be rejected")
+	}
+	if _, err := RSAPublicKeyFromBytes(make([]byte, 100)); err == nil {
+		t.Error("expected a public key of unsupported width to be rejected")
+	}
+}
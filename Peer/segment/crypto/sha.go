@@ -10,6 +10,13 @@
 package crypto
 
 import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"hash"
+
+	"golang.org/x/crypto/blake2b"
 	"golang.org/x/crypto/sha3"
 )
 
@@ -23,3 +30,75 @@ func Sha3256Sum(xs [][]byte) [][]byte {
 
 	return target
 }
+
+// Hash algorithms for Hash. SHA-256 and SHA-512 digests are truncated to the width, BLAKE2b
+// is keyed to the width as its output size, and SHAKE256 is read to any width.
+const (
+	HashSHA256   = "sha256"
+	HashSHA512   = "sha512"
+	HashBLAKE2b  = "blake2b"
+	HashSHAKE256 = "shake256"
+)
+
+// newHash returns a function that sums data to the given width.
+func newHash(algo string, width int) (func(data ...[]byte) []byte, error) {
+	var newDigest func() (hash.Hash, error)
+	var max int
+
+	switch algo {
+	case HashSHA256:
+		newDigest, max = func() (hash.Hash, error) { return sha256.New(), nil }, sha256.Size
+	case HashSHA512:
+		newDigest, max = func() (hash.Hash, error) { return sha512.New(), nil }, sha512.Size
+	case HashBLAKE2b:
+		newDigest, max = func() (hash.Hash, error) { return blake2b.New(width, nil) }, blake2b.Size
+	case HashSHAKE256:
+		if err := checkMACWidth(width, 0); err != nil {
+			return nil, err
+		}
+		return func(data ...[]byte) []byte {
+			h := sha3.NewShake256()
+			for _, v := range data {
+				h.Write(v)
+			}
+			target := make([]byte, width)
+			h.Read(target)
+			return target
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown hash algorithm %v, must be one of %v, %v, %v or %v", algo, HashSHA256, HashSHA512, HashBLAKE2b, HashSHAKE256)
+	}
+
+	if err := checkMACWidth(width, max); err != nil {
+		return nil, err
+	}
+
+	return func(data ...[]byte) []byte {
+		// BLAKE2b only fails for sizes that the width check excludes
+		h, _ := newDigest()
+		for _, v := range data {
+			h.Write(v)
+		}
+		return h.Sum(nil)[:width]
+	}, nil
+}
+
+// Hash returns the digest of each element with the given algorithm and width. Salts may be
+// omitted, given once for all elements, or given per element, and are hashed before the data.
+// Since the salts of an array all have the same width, the salt and data cannot be confused.
+func Hash(algo string, width int, data [][]byte, salts [][]byte) ([][]byte, error) {
+	if len(salts) > 1 && len(salts) != len(data) {
+		return nil, errors.New("salt must be a singleton or match the length of the data")
+	}
+
+	sum, err := newHash(algo, width)
+	if err != nil {
+		return nil, err
+	}
+
+	target := make([][]byte, len(data))
+	for i, v := range data {
+		target[i] = sum(associatedDataAt(salts, i), v)
+	}
+	return target, nil
+}
@@ -0,0 +1,60 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package crypto
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestHash_Algorithms(t *testing.T) {
+	tests := []struct {
+		algo     string
+		width    int
+		expected string
+	}{
+		{HashSHA256, 16, "ba7816bf8f01cfea414140de5dae2223"},
+		{HashSHA512, 64, "ddaf35a193617abacc417349ae20413112e6fa4e89a97ea20a9eeee64b55d39a2192992a274fc1a836ba3c23a3feebbd454d4423643ce80e2a9ac94fa54ca49f"},
+		{HashBLAKE2b, 32, "bddd813c634239723171ef3fee98579b94964e3bb1cb3e427262c8c068d52319"},
+		{HashSHAKE256, 100, "483366601360a8771c6863080cc4114d8db44530f8f1e1ee4f94ea37e78b5739d5a15bef186a5386c75744c0527e1faa9f8726e462a12a4feb06bd8801e751e41385141204f329979fd3047a13c5657724ada64d2470157b3cdc288620944d78dbcddbd9"},
+	}
+
+	for _, tt := range tests {
+		result, err := Hash(tt.algo, tt.width, [][]byte{[]byte("abc")}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(result[0]) != tt.expected {
+			t.Errorf("unexpected %v digest %x", tt.algo, result[0])
+		}
+	}
+}
+
+func TestHash_Salt(t *testing.T) {
+	result, err := Hash(HashSHA256, 8, [][]byte{[]byte("abc"), []byte("abc")}, [][]byte{[]byte("salt"), []byte("pepr")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(result[0]) != "3681099918be28c9" {
+		t.Errorf("unexpected salted digest %x", result[0])
+	}
+	if hex.EncodeToString(result[1]) == "3681099918be28c9" {
+		t.Error("digests with different salts are equal")
+	}
+
+	_, err = Hash(HashSHA256, 33, [][]byte{[]byte("abc")}, nil)
+	if err == nil {
+		t.Error("expected a width wider than the digest to be rejected")
+	}
+	_, err = Hash("md5", 16, [][]byte{[]byte("abc")}, nil)
+	if err == nil {
+		t.Error("expected an unknown algorithm to be rejected")
+	}
+}
@@ -0,0 +1,515 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package crypto
+
+import (
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"hash"
+
+	"filippo.io/edwards25519"
+
+	"github.com/AUSTRAC/ftillite/Peer/segment/types"
+)
+
+// A shuffle permutes an array of ElGamal ciphertexts and rerandomises each of them, so that
+// an output can not be linked to the input it came from. The proof of shuffle is the
+// Terelius-Wikström proof, following the pseudo-code of Haenni et al., "Pseudo-Code
+// Algorithms for Verifiable Re-Encryption Mix-Nets" (FC 2017). The prover commits to the
+// permutation matrix and shows, for challenges u, that the committed matrix maps u to a
+// permutation u' of itself, and that the outputs weighted by u' are a rerandomisation of the
+// inputs weighted by u.
+//
+// The proof is made of a header of five scalars and, for each element, two commitments and
+// two scalars.
+
+const (
+	ShuffleProofHeaderBytes  = 5 * 32
+	ShuffleProofElementBytes = 4 * 32
+)
+
+const (
+	shuffleGeneratorLabel = "ftillite/shuffle/generator/v1"
+	shuffleChallengeLabel = "ftillite/shuffle/challenge/v1"
+	shuffleProofLabel     = "ftillite/shuffle/proof/v1"
+)
+
+var ErrInvalidShuffleProof = errors.New("shuffle proof is malformed")
+
+// ShuffleProof is a proof that one array of ciphertexts is a shuffle of another.
+type ShuffleProof struct {
+	Challenge *edwards25519.Scalar
+	S1        *edwards25519.Scalar
+	S2        *edwards25519.Scalar
+	S3        *edwards25519.Scalar
+	S4        *edwards25519.Scalar
+
+	PermutationCommitments []*edwards25519.Point
+	ChainCommitments       []*edwards25519.Point
+	ChainResponses         []*edwards25519.Scalar
+	PermutationResponses   []*edwards25519.Scalar
+}
+
+// Bytes returns the header and the per element parts of the proof.
+func (p *ShuffleProof) Bytes() ([]byte, [][]byte) {
+	header := make([]byte, 0, ShuffleProofHeaderBytes)
+	for _, s := range []*edwards25519.Scalar{p.Challenge, p.S1, p.S2, p.S3, p.S4} {
+		header = append(header, s.Bytes()...)
+	}
+
+	elements := make([][]byte, len(p.PermutationCommitments))
+	for i := range elements {
+		element := make([]byte, 0, ShuffleProofElementBytes)
+		element = append(element, p.PermutationCommitments[i].Bytes()...)
+		element = append(element, p.ChainCommitments[i].Bytes()...)
+		element = append(element, p.ChainResponses[i].Bytes()...)
+		elements[i] = append(element, p.PermutationResponses[i].Bytes()...)
+	}
+
+	return header, elements
+}
+
+func NewShuffleProofFromBytes(header []byte, elements [][]byte) (*ShuffleProof, error) {
+	if len(header) != ShuffleProofHeaderBytes {
+		return nil, ErrInvalidShuffleProof
+	}
+
+	var scalars [5]*edwards25519.Scalar
+	for i := range scalars {
+		s, err := edwards25519.NewScalar().SetCanonicalBytes(header[32*i : 32*(i+1)])
+		if err != nil {
+			return nil, ErrInvalidShuffleProof
+		}
+		scalars[i] = s
+	}
+
+	p := &ShuffleProof{
+		Challenge:              scalars[0],
+		S1:                     scalars[1],
+		S2:                     scalars[2],
+		S3:                     scalars[3],
+		S4:                     scalars[4],
+		PermutationCommitments: make([]*edwards25519.Point, len(elements)),
+		ChainCommitments:       make([]*edwards25519.Point, len(elements)),
+		ChainResponses:         make([]*edwards25519.Scalar, len(elements)),
+		PermutationResponses:   make([]*edwards25519.Scalar, len(elements)),
+	}
+
+	for i, element := range elements {
+		if len(element) != ShuffleProofElementBytes {
+			return nil, ErrInvalidShuffleProof
+		}
+
+		var err error
+		if p.PermutationCommitments[i], err = new(edwards25519.Point).SetBytes(element[0:32]); err != nil {
+			return nil, ErrInvalidShuffleProof
+		}
+		if p.ChainCommitments[i], err = new(edwards25519.Point).SetBytes(element[32:64]); err != nil {
+			return nil, ErrInvalidShuffleProof
+		}
+		if p.ChainResponses[i], err = edwards25519.NewScalar().SetCanonicalBytes(element[64:96]); err != nil {
+			return nil, ErrInvalidShuffleProof
+		}
+		if p.PermutationResponses[i], err = edwards25519.NewScalar().SetCanonicalBytes(element[96:128]); err != nil {
+			return nil, ErrInvalidShuffleProof
+		}
+	}
+
+	return p, nil
+}
+
+// shuffleGenerators returns n+1 points of unknown discrete logarithm, found by hashing a
+// counter until it decodes to a point and clearing the cofactor.
+func shuffleGenerators(n int) []*edwards25519.Point {
+	results := make([]*edwards25519.Point, n+1)
+	buf := make([]byte, 16)
+
+	for i := range results {
+		binary.LittleEndian.PutUint64(buf[:8], uint64(i))
+		for ctr := uint64(0); results[i] == nil; ctr++ {
+			binary.LittleEndian.PutUint64(buf[8:], ctr)
+
+			h := sha512.New()
+			h.Write([]byte(shuffleGeneratorLabel))
+			h.Write(buf)
+
+			p, err := new(edwards25519.Point).SetBytes(h.Sum(nil)[:32])
+			if err != nil {
+				continue
+			}
+			p.MultByCofactor(p)
+			if p.Equal(edwards25519.NewIdentityPoint()) == 1 {
+				continue
+			}
+			results[i] = p
+		}
+	}
+
+	return results
+}
+
+func writePoints(h hash.Hash, points ...[]*edwards25519.Point) {
+	for _, ps := range points {
+		for _, p := range ps {
+			h.Write(p.Bytes())
+		}
+	}
+}
+
+func hashToScalar(h hash.Hash) *edwards25519.Scalar {
+	s, err := edwards25519.NewScalar().SetUniformBytes(h.Sum(nil))
+	if err != nil {
+		panic(err) // SHA-512 always produces 64 bytes
+	}
+	return s
+}
+
+// shuffleStatement holds the inputs and outputs of a shuffle as points.
+type shuffleStatement struct {
+	publicKey              *edwards25519.Point
+	masks, maskeds         []*edwards25519.Point
+	masksR, maskedsR       []*edwards25519.Point
+	generators             []*edwards25519.Point
+	permutationCommitments []*edwards25519.Point
+}
+
+func (st *shuffleStatement) digest() []byte {
+	h := sha512.New()
+	h.Write([]byte(shuffleChallengeLabel))
+	writePoints(h, []*edwards25519.Point{st.publicKey}, st.masks, st.maskeds, st.masksR, st.maskedsR, st.permutationCommitments)
+	return h.Sum(nil)
+}
+
+// challenges returns the vector u from the statement digest.
+func (st *shuffleStatement) challenges(digest []byte) []*edwards25519.Scalar {
+	results := make([]*edwards25519.Scalar, len(st.masks))
+	buf := make([]byte, 8)
+	for i := range results {
+		binary.LittleEndian.PutUint64(buf, uint64(i))
+
+		h := sha512.New()
+		h.Write(digest)
+		h.Write(buf)
+		results[i] = hashToScalar(h)
+	}
+	return results
+}
+
+func shuffleProofChallenge(digest []byte, chain []*edwards25519.Point, ts []*edwards25519.Point, chainTs []*edwards25519.Point) *edwards25519.Scalar {
+	h := sha512.New()
+	h.Write([]byte(shuffleProofLabel))
+	h.Write(digest)
+	writePoints(h, chain, ts, chainTs)
+	return hashToScalar(h)
+}
+
+func newShuffleStatement(publicKey *types.Ed25519Array, c ElGamalCiphertexts, result ElGamalCiphertexts) (*shuffleStatement, error) {
+	if c.Length() != result.Length() {
+		return nil, errors.New("mismatched number of ciphertexts")
+	}
+
+	key, err := singletonPoint(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var points [4][]*edwards25519.Point
+	for i, xs := range []*types.Ed25519Array{c.Mask, c.Masked, result.Mask, result.Masked} {
+		points[i], err = xs.Points()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &shuffleStatement{
+		publicKey:  key,
+		masks:      points[0],
+		maskeds:    points[1],
+		masksR:     points[2],
+		maskedsR:   points[3],
+		generators: shuffleGenerators(int(c.Length())),
+	}, nil
+}
+
+// randomScalars returns n uniformly random scalars.
+func randomScalars(n int) ([]*edwards25519.Scalar, error) {
+	xs, err := types.NewRandomFTEd25519IntArray(false, int64(n))
+	if err != nil {
+		return nil, err
+	}
+	return xs.Values(), nil
+}
+
+// ElGamalShuffle returns a random permutation of the rerandomised ciphertexts, together with
+// a proof that it is one.
+func ElGamalShuffle(publicKey *types.Ed25519Array, c ElGamalCiphertexts) (ElGamalCiphertexts, *ShuffleProof, error) {
+	n := int(c.Length())
+
+	key, err := singletonPoint(publicKey)
+	if err != nil {
+		return ElGamalCiphertexts{}, nil, err
+	}
+
+	masks, err := c.Mask.Points()
+	if err != nil {
+		return ElGamalCiphertexts{}, nil, err
+	}
+	maskeds, err := c.Masked.Points()
+	if err != nil {
+		return ElGamalCiphertexts{}, nil, err
+	}
+
+	perm, err := types.NewRandomPermFTIntegerArray(int64(n), int64(n))
+	if err != nil {
+		return ElGamalCiphertexts{}, nil, err
+	}
+	pi := perm.Values()
+
+	nonces, err := randomScalars(n)
+	if err != nil {
+		return ElGamalCiphertexts{}, nil, err
+	}
+
+	// Output i is a rerandomisation of input pi[i]
+	st := &shuffleStatement{
+		publicKey:  key,
+		masks:      masks,
+		maskeds:    maskeds,
+		masksR:     make([]*edwards25519.Point, n),
+		maskedsR:   make([]*edwards25519.Point, n),
+		generators: shuffleGenerators(n),
+	}
+	for i, j := range pi {
+		st.masksR[i] = new(edwards25519.Point).ScalarBaseMult(nonces[i])
+		st.masksR[i].Add(st.masksR[i], masks[j])
+		st.maskedsR[i] = new(edwards25519.Point).ScalarMult(nonces[i], key)
+		st.maskedsR[i].Add(st.maskedsR[i], maskeds[j])
+	}
+
+	proof, err := proveShuffle(st, pi, nonces)
+	if err != nil {
+		return ElGamalCiphertexts{}, nil, err
+	}
+
+	mask, err := types.NewEd25519ArrayFromPointsWithBackend(c.Mask.Backend(), st.masksR)
+	if err != nil {
+		return ElGamalCiphertexts{}, nil, err
+	}
+	masked, err := types.NewEd25519ArrayFromPointsWithBackend(c.Masked.Backend(), st.maskedsR)
+	if err != nil {
+		mask.Free()
+		return ElGamalCiphertexts{}, nil, err
+	}
+
+	return ElGamalCiphertexts{mask, masked}, proof, nil
+}
+
+func proveShuffle(st *shuffleStatement, pi []int64, nonces []*edwards25519.Scalar) (*ShuffleProof, error) {
+	n := len(pi)
+	h0, hs := st.generators[0], st.generators[1:]
+
+	commitmentNonces, err := randomScalars(n)
+	if err != nil {
+		return nil, err
+	}
+	chainNonces, err := randomScalars(n)
+	if err != nil {
+		return nil, err
+	}
+	omegas, err := randomScalars(4)
+	if err != nil {
+		return nil, err
+	}
+	chainOmegas, err := randomScalars(n)
+	if err != nil {
+		return nil, err
+	}
+	permutationOmegas, err := randomScalars(n)
+	if err != nil {
+		return nil, err
+	}
+
+	// Commit to the permutation matrix, column pi[i] holding generator i
+	st.permutationCommitments = make([]*edwards25519.Point, n)
+	for i, j := range pi {
+		st.permutationCommitments[j] = new(edwards25519.Point).ScalarBaseMult(commitmentNonces[j])
+		st.permutationCommitments[j].Add(st.permutationCommitments[j], hs[i])
+	}
+
+	digest := st.digest()
+	u := st.challenges(digest)
+
+	uPermuted := make([]*edwards25519.Scalar, n)
+	for i, j := range pi {
+		uPermuted[i] = u[j]
+	}
+
+	// Commit to the product of uPermuted through a chain of commitments
+	chain := make([]*edwards25519.Point, n)
+	previous := h0
+	for i := range chain {
+		chain[i] = new(edwards25519.Point).VarTimeMultiScalarMult(
+			[]*edwards25519.Scalar{chainNonces[i], uPermuted[i]},
+			[]*edwards25519.Point{edwards25519.NewGeneratorPoint(), previous},
+		)
+		previous = chain[i]
+	}
+
+	t1 := new(edwards25519.Point).ScalarBaseMult(omegas[0])
+	t2 := new(edwards25519.Point).ScalarBaseMult(omegas[1])
+
+	t3 := new(edwards25519.Point).ScalarBaseMult(omegas[2])
+	t3.Add(t3, new(edwards25519.Point).VarTimeMultiScalarMult(permutationOmegas, hs))
+
+	negOmega4 := edwards25519.NewScalar().Negate(omegas[3])
+	t4a := new(edwards25519.Point).VarTimeMultiScalarMult(
+		append([]*edwards25519.Scalar{negOmega4}, permutationOmegas...),
+		append([]*edwards25519.Point{edwards25519.NewGeneratorPoint()}, st.masksR...),
+	)
+	t4b := new(edwards25519.Point).VarTimeMultiScalarMult(
+		append([]*edwards25519.Scalar{negOmega4}, permutationOmegas...),
+		append([]*edwards25519.Point{st.publicKey}, st.maskedsR...),
+	)
+
+	chainTs := make([]*edwards25519.Point, n)
+	previous = h0
+	for i := range chainTs {
+		chainTs[i] = new(edwards25519.Point).VarTimeMultiScalarMult(
+			[]*edwards25519.Scalar{chainOmegas[i], permutationOmegas[i]},
+			[]*edwards25519.Point{edwards25519.NewGeneratorPoint(), previous},
+		)
+		previous = chain[i]
+	}
+
+	challenge := shuffleProofChallenge(digest, chain, []*edwards25519.Point{t1, t2, t3, t4a, t4b}, chainTs)
+	negChallenge := edwards25519.NewScalar().Negate(challenge)
+
+	// response returns omega - challenge*x
+	response := func(omega, x *edwards25519.Scalar) *edwards25519.Scalar {
+		return edwards25519.NewScalar().MultiplyAdd(negChallenge, x, omega)
+	}
+
+	commitmentSum := edwards25519.NewScalar()
+	commitmentWeightedSum := edwards25519.NewScalar()
+	for j := range commitmentNonces {
+		commitmentSum.Add(commitmentSum, commitmentNonces[j])
+		commitmentWeightedSum.MultiplyAdd(commitmentNonces[j], u[j], commitmentWeightedSum)
+	}
+
+	chainSum := edwards25519.NewScalar()
+	v := types.Int64ToScalar(1)
+	for i := n - 1; i >= 0; i-- {
+		chainSum.MultiplyAdd(chainNonces[i], v, chainSum)
+		v.Multiply(v, uPermuted[i])
+	}
+
+	nonceWeightedSum := edwards25519.NewScalar()
+	for i := range nonces {
+		nonceWeightedSum.MultiplyAdd(nonces[i], uPermuted[i], nonceWeightedSum)
+	}
+
+	proof := &ShuffleProof{
+		Challenge:              challenge,
+		S1:                     response(omegas[0], commitmentSum),
+		S2:                     response(omegas[1], chainSum),
+		S3:                     response(omegas[2], commitmentWeightedSum),
+		S4:                     response(omegas[3], nonceWeightedSum),
+		PermutationCommitments: st.permutationCommitments,
+		ChainCommitments:       chain,
+		ChainResponses:         make([]*edwards25519.Scalar, n),
+		PermutationResponses:   make([]*edwards25519.Scalar, n),
+	}
+	for i := 0; i < n; i++ {
+		proof.ChainResponses[i] = response(chainOmegas[i], chainNonces[i])
+		proof.PermutationResponses[i] = response(permutationOmegas[i], uPermuted[i])
+	}
+
+	return proof, nil
+}
+
+// ElGamalShuffleVerify checks that result is a shuffle of c, using the proof from
+// ElGamalShuffle.
+func ElGamalShuffleVerify(publicKey *types.Ed25519Array, c ElGamalCiphertexts, result ElGamalCiphertexts, proof *ShuffleProof) (bool, error) {
+	st, err := newShuffleStatement(publicKey, c, result)
+	if err != nil {
+		return false, err
+	}
+
+	n := len(st.masks)
+	if len(proof.PermutationCommitments) != n || len(proof.ChainCommitments) != n ||
+		len(proof.ChainResponses) != n || len(proof.PermutationResponses) != n {
+		return false, errors.New("mismatched number of ciphertexts and proof elements")
+	}
+	st.permutationCommitments = proof.PermutationCommitments
+
+	h0, hs := st.generators[0], st.generators[1:]
+	g := edwards25519.NewGeneratorPoint()
+	ch := proof.Challenge
+
+	digest := st.digest()
+	u := st.challenges(digest)
+
+	uProduct := types.Int64ToScalar(1)
+	for _, x := range u {
+		uProduct.Multiply(uProduct, x)
+	}
+
+	// t1 = ch*(Σc_j - Σh_i) + s1*G
+	commitmentSum := edwards25519.NewIdentityPoint()
+	for i := range st.permutationCommitments {
+		commitmentSum.Add(commitmentSum, st.permutationCommitments[i])
+		commitmentSum.Subtract(commitmentSum, hs[i])
+	}
+	t1 := new(edwards25519.Point).VarTimeDoubleScalarBaseMult(ch, commitmentSum, proof.S1)
+
+	// t2 = ch*(ĉ_n - Πu*h0) + s2*G
+	chainEnd := h0
+	if n > 0 {
+		chainEnd = proof.ChainCommitments[n-1]
+	}
+	chainEnd = new(edwards25519.Point).Subtract(chainEnd, new(edwards25519.Point).ScalarMult(uProduct, h0))
+	t2 := new(edwards25519.Point).VarTimeDoubleScalarBaseMult(ch, chainEnd, proof.S2)
+
+	// t3 = ch*Σu_j*c_j + s3*G + Σs'_i*h_i
+	t3 := new(edwards25519.Point).VarTimeMultiScalarMult(
+		append(append([]*edwards25519.Scalar{proof.S3}, scaleScalars(ch, u)...), proof.PermutationResponses...),
+		append(append([]*edwards25519.Point{g}, st.permutationCommitments...), hs...),
+	)
+
+	// t4 = ch*Σu_j*e_j + Σs'_i*e'_i - s4*(G, P)
+	negS4 := edwards25519.NewScalar().Negate(proof.S4)
+	t4Scalars := append(append([]*edwards25519.Scalar{negS4}, scaleScalars(ch, u)...), proof.PermutationResponses...)
+	t4a := new(edwards25519.Point).VarTimeMultiScalarMult(t4Scalars, append(append([]*edwards25519.Point{g}, st.masks...), st.masksR...))
+	t4b := new(edwards25519.Point).VarTimeMultiScalarMult(t4Scalars, append(append([]*edwards25519.Point{st.publicKey}, st.maskeds...), st.maskedsR...))
+
+	// t̂_i = ch*ĉ_i + ŝ_i*G + s'_i*ĉ_{i-1}
+	chainTs := make([]*edwards25519.Point, n)
+	previous := h0
+	for i := range chainTs {
+		chainTs[i] = new(edwards25519.Point).VarTimeMultiScalarMult(
+			[]*edwards25519.Scalar{ch, proof.ChainResponses[i], proof.PermutationResponses[i]},
+			[]*edwards25519.Point{proof.ChainCommitments[i], g, previous},
+		)
+		previous = proof.ChainCommitments[i]
+	}
+
+	expected := shuffleProofChallenge(digest, proof.ChainCommitments, []*edwards25519.Point{t1, t2, t3, t4a, t4b}, chainTs)
+
+	return expected.Equal(ch) == 1, nil
+}
+
+func scaleScalars(x *edwards25519.Scalar, ys []*edwards25519.Scalar) []*edwards25519.Scalar {
+	results := make([]*edwards25519.Scalar, len(ys))
+	for i, y := range ys {
+		results[i] = edwards25519.NewScalar().Multiply(x, y)
+	}
+	return results
+}
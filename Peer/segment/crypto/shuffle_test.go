@@ -0,0 +1,151 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package crypto
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/AUSTRAC/ftillite/Peer/segment/types"
+)
+
+func shuffleTestCiphertexts(t *testing.T, values ...int64) (*types.Ed25519Array, ElGamalCiphertexts) {
+	t.Helper()
+
+	publicKey := types.NewEd25519ArrayFromInt64sOrPanic(9)
+	c, err := ElGamalEncrypt(publicKey, types.NewEd25519ArrayFromInt64sOrPanic(values...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return publicKey, c
+}
+
+func decryptedFoldedBytes(t *testing.T, c ElGamalCiphertexts) []string {
+	t.Helper()
+
+	messages, err := ElGamalDecrypt(types.Int64ToScalar(9), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	points, err := messages.Points()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := make([]string, len(points))
+	for i, p := range points {
+		results[i] = string(p.Bytes())
+	}
+	return results
+}
+
+func TestElGamalShuffle_Verify(t *testing.T) {
+	publicKey, c := shuffleTestCiphertexts(t, 1, 2, 3, 4, 5)
+
+	result, proof, err := ElGamalShuffle(publicKey, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The proof survives encoding
+	header, elements := proof.Bytes()
+	proof, err = NewShuffleProofFromBytes(header, elements)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verified, err := ElGamalShuffleVerify(publicKey, c, result, proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !verified {
+		t.Error("shuffle proof did not verify")
+	}
+
+	before := decryptedFoldedBytes(t, c)
+	after := decryptedFoldedBytes(t, result)
+	sort.Strings(before)
+	sort.Strings(after)
+	for i := range before {
+		if before[i] != after[i] {
+			t.Fatal("shuffle did not preserve the messages")
+		}
+	}
+}
+
+func TestElGamalShuffle_Empty(t *testing.T) {
+	publicKey, c := shuffleTestCiphertexts(t)
+
+	result, proof, err := ElGamalShuffle(publicKey, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verified, err := ElGamalShuffleVerify(publicKey, c, result, proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !verified {
+		t.Error("shuffle proof did not verify")
+	}
+}
+
+func TestElGamalShuffleVerify_Rejects(t *testing.T) {
+	publicKey, c := shuffleTestCiphertexts(t, 1, 2, 3)
+
+	result, proof, err := ElGamalShuffle(publicKey, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Replacing an output with an encryption of another message
+	other, err := ElGamalEncrypt(publicKey, types.NewEd25519ArrayFromInt64sOrPanic(1, 2, 7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	verified, err := ElGamalShuffleVerify(publicKey, c, other, proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verified {
+		t.Error("shuffle proof verified for different outputs")
+	}
+
+	// Reordering the outputs after the proof was made
+	swap := types.NewFTIntegerArray(1, 0, 2)
+	mask, err := result.Mask.Get(swap, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	masked, err := result.Masked.Get(swap, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	swapped := ElGamalCiphertexts{mask.(*types.Ed25519Array), masked.(*types.Ed25519Array)}
+
+	verified, err = ElGamalShuffleVerify(publicKey, c, swapped, proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verified {
+		t.Error("shuffle proof verified for reordered outputs")
+	}
+
+	// Tampering with a response
+	proof.S4.Add(proof.S4, types.Int64ToScalar(1))
+	verified, err = ElGamalShuffleVerify(publicKey, c, result, proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verified {
+		t.Error("shuffle proof verified after tampering")
+	}
+}
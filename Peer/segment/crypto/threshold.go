@@ -0,0 +1,198 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package crypto
+
+import (
+	"errors"
+	"fmt"
+
+	"filippo.io/edwards25519"
+
+	"github.com/AUSTRAC/ftillite/Peer/segment/types"
+)
+
+// Threshold ElGamal keys are created with a Pedersen style distributed key generation using
+// Feldman verifiable secret sharing. Every participant deals a random polynomial f_i of degree
+// threshold-1 and sends f_i(x_j) to participant j. The key share of participant j is the sum
+// of the values it receives, and the private key, which no participant ever holds, is the sum
+// of the f_i(0).
+//
+// Participants are identified by their node IDs. The share of a node is evaluated at
+// x = nodeID+1, as evaluating at x = 0 would reveal the secret.
+
+var ErrInvalidThreshold = errors.New("threshold must be between 1 and the number of participants")
+
+func participantScalar(participant int64) *edwards25519.Scalar {
+	return types.Int64ToScalar(participant + 1)
+}
+
+// FeldmanDeal is the contribution of one participant to the distributed key generation.
+type FeldmanDeal struct {
+	coefficients []*edwards25519.Scalar
+
+	// Commitments holds a_k*G for each coefficient a_k of the polynomial.
+	Commitments []*edwards25519.Point
+}
+
+func NewFeldmanDeal(threshold int) (*FeldmanDeal, error) {
+	if threshold < 1 {
+		return nil, ErrInvalidThreshold
+	}
+
+	coefficients, err := types.NewRandomFTEd25519IntArray(true, int64(threshold))
+	if err != nil {
+		return nil, err
+	}
+
+	deal := &FeldmanDeal{
+		coefficients: coefficients.Values(),
+		Commitments:  make([]*edwards25519.Point, threshold),
+	}
+	for i, a := range deal.coefficients {
+		deal.Commitments[i] = new(edwards25519.Point).ScalarBaseMult(a)
+	}
+
+	return deal, nil
+}
+
+// Share returns the value of the polynomial for the given participant.
+func (d *FeldmanDeal) Share(participant int64) *edwards25519.Scalar {
+	x := participantScalar(participant)
+
+	result := edwards25519.NewScalar()
+	for i := len(d.coefficients) - 1; i >= 0; i-- {
+		result.MultiplyAdd(result, x, d.coefficients[i])
+	}
+	return result
+}
+
+// FeldmanEvaluateCommitments returns f(x)*G for the given participant, computed from the
+// commitments of a deal alone.
+func FeldmanEvaluateCommitments(commitments []*edwards25519.Point, participant int64) *edwards25519.Point {
+	x := participantScalar(participant)
+
+	result := edwards25519.NewIdentityPoint()
+	for i := len(commitments) - 1; i >= 0; i-- {
+		result.ScalarMult(x, result)
+		result.Add(result, commitments[i])
+	}
+	return result
+}
+
+// VerifyFeldmanShare checks that share was produced by the deal with the given commitments.
+func VerifyFeldmanShare(commitments []*edwards25519.Point, participant int64, share *edwards25519.Scalar) bool {
+	expected := FeldmanEvaluateCommitments(commitments, participant)
+	return new(edwards25519.Point).ScalarBaseMult(share).Equal(expected) == 1
+}
+
+// ThresholdPublicKey returns the public key from the commitments of all the deals.
+func ThresholdPublicKey(commitments [][]*edwards25519.Point) *edwards25519.Point {
+	result := edwards25519.NewIdentityPoint()
+	for _, c := range commitments {
+		result.Add(result, c[0])
+	}
+	return result
+}
+
+// ThresholdVerificationKeys returns x_j*G for the key share x_j of each participant, which
+// allows partial decryptions to be checked.
+func ThresholdVerificationKeys(commitments [][]*edwards25519.Point, participants []int64) []*edwards25519.Point {
+	results := make([]*edwards25519.Point, len(participants))
+	for j, participant := range participants {
+		results[j] = edwards25519.NewIdentityPoint()
+		for _, c := range commitments {
+			results[j].Add(results[j], FeldmanEvaluateCommitments(c, participant))
+		}
+	}
+	return results
+}
+
+// LagrangeCoefficients returns the coefficients which interpolate the polynomial through the
+// given participants at zero.
+func LagrangeCoefficients(participants []int64) ([]*edwards25519.Scalar, error) {
+	results := make([]*edwards25519.Scalar, len(participants))
+
+	for j, pj := range participants {
+		xj := participantScalar(pj)
+		numerator := types.Int64ToScalar(1)
+		denominator := types.Int64ToScalar(1)
+
+		for m, pm := range participants {
+			if m == j {
+				continue
+			}
+			if pm == pj {
+				return nil, fmt.Errorf("participant %v appears more than once", pj)
+			}
+
+			xm := participantScalar(pm)
+			numerator.Multiply(numerator, xm)
+			denominator.Multiply(denominator, edwards25519.NewScalar().Subtract(xm, xj))
+		}
+
+		results[j] = numerator.Multiply(numerator, denominator.Invert(denominator))
+	}
+
+	return results, nil
+}
+
+// ThresholdPartialDecrypt returns share*Mask, the contribution of one key share to the
+// decryption of each ciphertext.
+func ThresholdPartialDecrypt(share *edwards25519.Scalar, mask *types.Ed25519Array) (*types.Ed25519Array, error) {
+	result, err := mask.Copy()
+	if err != nil {
+		return nil, err
+	}
+
+	err = result.Scale(share)
+	if err != nil {
+		result.Free()
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ThresholdCombine decrypts ciphertexts from the partial decryptions of at least threshold
+// participants.
+func ThresholdCombine(masked *types.Ed25519Array, participants []int64, partials []*types.Ed25519Array) (*types.Ed25519Array, error) {
+	if len(participants) != len(partials) {
+		return nil, errors.New("each partial decryption must belong to exactly one participant")
+	}
+
+	lambdas, err := LagrangeCoefficients(participants)
+	if err != nil {
+		return nil, err
+	}
+
+	shared, err := types.NewEd25519ArrayWithBackend(masked.Backend(), masked.Length(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { shared.Free() }()
+
+	for i, partial := range partials {
+		term, err := ThresholdPartialDecrypt(lambdas[i], partial)
+		if err != nil {
+			return nil, err
+		}
+
+		sum, err := asEd25519Array(shared.Add(term))
+		term.Free()
+		if err != nil {
+			return nil, err
+		}
+
+		shared.Free()
+		shared = sum
+	}
+
+	return asEd25519Array(masked.Sub(shared))
+}
@@ -0,0 +1,150 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package crypto
+
+import (
+	"testing"
+
+	"filippo.io/edwards25519"
+
+	"github.com/AUSTRAC/ftillite/Peer/segment/types"
+)
+
+func runTestDKG(t *testing.T, threshold int, participants []int64) (*types.Ed25519Array, []*edwards25519.Scalar) {
+	t.Helper()
+
+	commitments := make([][]*edwards25519.Point, len(participants))
+	shares := make([]*edwards25519.Scalar, len(participants))
+	for j := range shares {
+		shares[j] = edwards25519.NewScalar()
+	}
+
+	for i := range participants {
+		deal, err := NewFeldmanDeal(threshold)
+		if err != nil {
+			t.Fatal(err)
+		}
+		commitments[i] = deal.Commitments
+
+		for j, participant := range participants {
+			share := deal.Share(participant)
+			if !VerifyFeldmanShare(deal.Commitments, participant, share) {
+				t.Fatalf("share for participant %v does not match the commitments", participant)
+			}
+			shares[j].Add(shares[j], share)
+		}
+	}
+
+	verificationKeys := ThresholdVerificationKeys(commitments, participants)
+	for j := range participants {
+		if new(edwards25519.Point).ScalarBaseMult(shares[j]).Equal(verificationKeys[j]) != 1 {
+			t.Fatalf("verification key %v does not match the key share", j)
+		}
+	}
+
+	publicKey, err := types.NewEd25519ArrayFromPointsWithBackend(
+		types.NewCPUEd25519Backend(),
+		[]*edwards25519.Point{ThresholdPublicKey(commitments)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return publicKey, shares
+}
+
+func TestThreshold_DecryptWithAnySubset(t *testing.T) {
+	participants := []int64{0, 1, 2}
+	publicKey, shares := runTestDKG(t, 2, participants)
+
+	messages, err := types.NewEd25519ArrayFromInt64sWithBackend(publicKey.Backend(), 5, 6, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := ElGamalEncrypt(publicKey, messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, subset := range [][]int{{0, 1}, {1, 2}, {2, 0}, {0, 1, 2}} {
+		ids := make([]int64, len(subset))
+		partials := make([]*types.Ed25519Array, len(subset))
+		for i, j := range subset {
+			ids[i] = participants[j]
+			partials[i], err = ThresholdPartialDecrypt(shares[j], c.Mask)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		result, err := ThresholdCombine(c.Masked, ids, partials)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !result.Equals(messages) {
+			t.Errorf("participants %v did not decrypt the messages", ids)
+		}
+	}
+}
+
+func TestThreshold_TooFewPartials(t *testing.T) {
+	participants := []int64{0, 1, 2}
+	publicKey, shares := runTestDKG(t, 2, participants)
+
+	messages, err := types.NewEd25519ArrayFromInt64sWithBackend(publicKey.Backend(), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := ElGamalEncrypt(publicKey, messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	partial, err := ThresholdPartialDecrypt(shares[1], c.Mask)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := ThresholdCombine(c.Masked, []int64{1}, []*types.Ed25519Array{partial})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Equals(messages) {
+		t.Error("a single partial decryption should not decrypt a 2 of 3 ciphertext")
+	}
+}
+
+func TestVerifyFeldmanShare_Tampered(t *testing.T) {
+	deal, err := NewFeldmanDeal(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	share := deal.Share(4)
+	share.Add(share, types.Int64ToScalar(1))
+
+	if VerifyFeldmanShare(deal.Commitments, 4, share) {
+		t.Error("a tampered share should not match the commitments")
+	}
+	if VerifyFeldmanShare(deal.Commitments, 5, deal.Share(4)) {
+		t.Error("a share should not match the commitments of another participant")
+	}
+}
+
+func TestLagrangeCoefficients_Duplicate(t *testing.T) {
+	_, err := LagrangeCoefficients([]int64{1, 2, 1})
+	if err == nil {
+		t.Error("expected an error for a repeated participant")
+	}
+}
@@ -0,0 +1,110 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package crypto
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// X25519 keys let two peers agree a symmetric key without it passing through the coordinator.
+// The shared secret is expanded with HKDF-SHA256, where the info binds the key to both public
+// keys, in sorted order so that both peers derive the same key, and to an optional label that
+// separates the keys derived for different purposes.
+
+const (
+	X25519KeyBytes = 32
+
+	// X25519MaxDerivedBytes is the most HKDF-SHA256 can produce from one secret.
+	X25519MaxDerivedBytes = 255 * sha256.Size
+)
+
+var (
+	ErrInvalidX25519PrivateKey = errors.New("X25519 private keys must be 32 bytes")
+	ErrInvalidX25519PublicKey  = errors.New("X25519 public keys must be 32 bytes")
+)
+
+var x25519DeriveLabel = []byte("ftillite x25519 derive")
+
+func X25519Keygen() ([]byte, error) {
+	k, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return k.Bytes(), nil
+}
+
+func x25519PrivateKey(privateKey []byte) (*ecdh.PrivateKey, error) {
+	if len(privateKey) != X25519KeyBytes {
+		return nil, ErrInvalidX25519PrivateKey
+	}
+	return ecdh.X25519().NewPrivateKey(privateKey)
+}
+
+func X25519PublicKey(privateKey []byte) ([]byte, error) {
+	k, err := x25519PrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return k.PublicKey().Bytes(), nil
+}
+
+// X25519Derive derives a key of the given width with each of the public keys. It fails if any
+// public key is of small order, since the shared secret would then be known to anyone.
+func X25519Derive(privateKey []byte, publicKeys [][]byte, width int, label []byte) ([][]byte, error) {
+	err := checkMACWidth(width, X25519MaxDerivedBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := x25519PrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	own := k.PublicKey().Bytes()
+
+	target := make([][]byte, len(publicKeys))
+	for i, v := range publicKeys {
+		if len(v) != X25519KeyBytes {
+			return nil, ErrInvalidX25519PublicKey
+		}
+
+		pk, err := ecdh.X25519().NewPublicKey(v)
+		if err != nil {
+			return nil, err
+		}
+
+		secret, err := k.ECDH(pk)
+		if err != nil {
+			return nil, err
+		}
+
+		info := append([]byte{}, x25519DeriveLabel...)
+		if bytes.Compare(own, v) < 0 {
+			info = append(append(info, own...), v...)
+		} else {
+			info = append(append(info, v...), own...)
+		}
+		info = append(info, label...)
+
+		target[i] = make([]byte, width)
+		_, err = io.ReadFull(hkdf.New(sha256.New, secret, nil, info), target[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return target, nil
+}
@@ -0,0 +1,61 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// Keys from RFC 7748 section 6.1
+func TestX25519_Derive(t *testing.T) {
+	alice, _ := hex.DecodeString("77076d0a7318a57d3c16c17251b26645df4c2f87ebc0992ab177fba51db92c2a")
+	bob, _ := hex.DecodeString("5dab087e624a8a4b79e17f8b83800ee66f3bb1292618b6fd1c2f8b27ff88e0eb")
+
+	alicePublic, err := X25519PublicKey(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(alicePublic) != "8520f0098930a754748b7ddcb43ef75a0dbf3a0d26381af4eba4a98eaa9b4e6a" {
+		t.Fatalf("unexpected public key %x", alicePublic)
+	}
+
+	bobPublic, err := X25519PublicKey(bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k1, err := X25519Derive(alice, [][]byte{bobPublic}, 48, []byte("aes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	k2, err := X25519Derive(bob, [][]byte{alicePublic}, 48, []byte("aes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(k1[0], k2[0]) {
+		t.Error("peers derived different keys")
+	}
+
+	k3, err := X25519Derive(alice, [][]byte{bobPublic}, 48, []byte("grain"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(k1[0], k3[0]) {
+		t.Error("keys with different labels are equal")
+	}
+
+	// The identity is of small order
+	_, err = X25519Derive(alice, [][]byte{make([]byte, X25519KeyBytes)}, 32, nil)
+	if err == nil {
+		t.Error("expected a small order public key to be rejected")
+	}
+}
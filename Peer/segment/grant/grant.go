@@ -0,0 +1,179 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+// Package grant authorises the transfer of arrays between peers. A grant names the node and
+// handle an array is read from, the node it is sent to, and when the grant expires, and is
+// signed with Ed25519 by the coordinator for each transmit. A peer also signs grants with a key
+// of its own for the transfers it starts itself, such as the shares it deals to other peers.
+//
+// A grant is encoded as the hex message and signature separated by a dot, where the message is
+//
+//	ftillite-transfer-grant <source node> <handle> <destination node> <expiry unix seconds>
+//
+// Hex is used as grants are passed as command arguments, which are split on "__".
+package grant
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxLifetime is the longest a grant may be valid for.
+const MaxLifetime = 5 * time.Minute
+
+const grantContext = "ftillite-transfer-grant"
+
+var (
+	ErrNoCoordinatorKey      = errors.New("no coordinator grant key is configured")
+	ErrInvalidCoordinatorKey = fmt.Errorf("coordinator grant key must be a %v byte Ed25519 public key", ed25519.PublicKeySize)
+	ErrMissing               = errors.New("transfer grant is missing")
+	ErrInvalid               = errors.New("transfer grant is invalid")
+	ErrExpired               = errors.New("transfer grant has expired")
+)
+
+// Grant authorises Destination to read the array at Handle on Source until Expiry.
+type Grant struct {
+	Source      string
+	Handle      string
+	Destination string
+	Expiry      time.Time
+}
+
+func (g Grant) message() []byte {
+	return []byte(fmt.Sprintf("%v %v %v %v %v", grantContext, g.Source, g.Handle, g.Destination, g.Expiry.Unix()))
+}
+
+// Sign returns the grant signed with key.
+func (g Grant) Sign(key ed25519.PrivateKey) string {
+	m := g.message()
+	return hex.EncodeToString(m) + "." + hex.EncodeToString(ed25519.Sign(key, m))
+}
+
+// Allows checks that the grant is for the transfer of handle from source to destination. An
+// empty destination is not checked, as when the peer reading the array is not authenticated.
+func (g Grant) Allows(source string, handle string, destination string) error {
+	if g.Source != source || g.Handle != handle {
+		return fmt.Errorf("%w: grant is for handle %v on node %v, not handle %v on node %v",
+			ErrInvalid, g.Handle, g.Source, handle, source)
+	}
+	if destination != "" && g.Destination != destination {
+		return fmt.Errorf("%w: grant is for node %v, not node %v", ErrInvalid, g.Destination, destination)
+	}
+	return nil
+}
+
+func parse(token string) (Grant, []byte, []byte, error) {
+	encodedMessage, encodedSignature, ok := strings.Cut(token, ".")
+	if !ok {
+		return Grant{}, nil, nil, ErrInvalid
+	}
+	m, err := hex.DecodeString(encodedMessage)
+	if err != nil {
+		return Grant{}, nil, nil, ErrInvalid
+	}
+	sig, err := hex.DecodeString(encodedSignature)
+	if err != nil {
+		return Grant{}, nil, nil, ErrInvalid
+	}
+
+	fields := strings.Split(string(m), " ")
+	if len(fields) != 5 || fields[0] != grantContext {
+		return Grant{}, nil, nil, ErrInvalid
+	}
+	expiry, err := strconv.ParseInt(fields[4], 10, 64)
+	if err != nil {
+		return Grant{}, nil, nil, ErrInvalid
+	}
+
+	return Grant{fields[1], fields[2], fields[3], time.Unix(expiry, 0)}, m, sig, nil
+}
+
+// LoadCoordinatorKey returns the public key of the coordinator given as hex, or if that is
+// empty, read from the file at path. The file may hold either the raw key or its hex encoding.
+func LoadCoordinatorKey(hexKey string, path string) (ed25519.PublicKey, error) {
+	if hexKey == "" && path == "" {
+		return nil, ErrNoCoordinatorKey
+	}
+
+	if hexKey == "" {
+		bs, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read coordinator grant key: %w", err)
+		}
+		if len(bs) == ed25519.PublicKeySize {
+			return bs, nil
+		}
+		hexKey = strings.TrimSpace(string(bs))
+	}
+
+	key, err := hex.DecodeString(hexKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, ErrInvalidCoordinatorKey
+	}
+	return key, nil
+}
+
+// Authority verifies the grants of the coordinator, and issues and verifies the grants of the
+// peer itself.
+type Authority struct {
+	coordinator ed25519.PublicKey
+	local       ed25519.PrivateKey
+
+	now func() time.Time
+}
+
+func NewAuthority(coordinatorKey ed25519.PublicKey) (*Authority, error) {
+	if len(coordinatorKey) != ed25519.PublicKeySize {
+		return nil, ErrInvalidCoordinatorKey
+	}
+
+	_, local, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &Authority{coordinatorKey, local, time.Now}, nil
+}
+
+// Issue returns a grant signed by the peer for a transfer it starts itself.
+func (a *Authority) Issue(source string, handle string, destination string) string {
+	return Grant{source, handle, destination, a.now().Add(MaxLifetime)}.Sign(a.local)
+}
+
+// Verify returns the grant encoded in token if it is signed by the coordinator or this peer,
+// and has not expired.
+func (a *Authority) Verify(token string) (Grant, error) {
+	if token == "" {
+		return Grant{}, ErrMissing
+	}
+
+	g, m, sig, err := parse(token)
+	if err != nil {
+		return Grant{}, err
+	}
+
+	if !ed25519.Verify(a.coordinator, m, sig) && !ed25519.Verify(a.local.Public().(ed25519.PublicKey), m, sig) {
+		return Grant{}, fmt.Errorf("%w: signature does not verify", ErrInvalid)
+	}
+
+	now := a.now()
+	if !now.Before(g.Expiry) {
+		return Grant{}, ErrExpired
+	}
+	if g.Expiry.Sub(now) > MaxLifetime {
+		return Grant{}, fmt.Errorf("%w: grant is valid for longer than %v", ErrInvalid, MaxLifetime)
+	}
+	return g, nil
+}
@@ -0,0 +1,130 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package grant
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestAuthority(t *testing.T) (*Authority, ed25519.PrivateKey, *time.Time) {
+	t.Helper()
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewAuthority(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	a.now = func() time.Time { return now }
+	return a, private, &now
+}
+
+func TestAuthority_Verify(t *testing.T) {
+	a, coordinator, now := newTestAuthority(t)
+
+	token := Grant{"1", "42", "2", now.Add(time.Minute)}.Sign(coordinator)
+	g, err := a.Verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Allows("1", "42", "2"); err != nil {
+		t.Error(err)
+	}
+	if err := g.Allows("1", "42", ""); err != nil {
+		t.Error(err)
+	}
+	for _, tc := range [][3]string{{"0", "42", "2"}, {"1", "43", "2"}, {"1", "42", "3"}} {
+		if err := g.Allows(tc[0], tc[1], tc[2]); !errors.Is(err, ErrInvalid) {
+			t.Errorf("expected %v to be refused, got %v", tc, err)
+		}
+	}
+
+	// Grants the peer issues itself are accepted too
+	g, err = a.Verify(a.Issue("1", "42_share", "3"))
+	if err != nil || g.Destination != "3" {
+		t.Fatalf("unexpected grant %v, %v", g, err)
+	}
+
+	*now = now.Add(time.Minute)
+	if _, err := a.Verify(token); !errors.Is(err, ErrExpired) {
+		t.Errorf("expected the grant to have expired, got %v", err)
+	}
+}
+
+func TestAuthority_VerifyRejects(t *testing.T) {
+	a, coordinator, now := newTestAuthority(t)
+
+	_, other, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid := Grant{"1", "42", "2", now.Add(time.Minute)}.Sign(coordinator)
+	tampered := Grant{"1", "43", "2", now.Add(time.Minute)}.Sign(coordinator)
+	tampered = tampered[:strings.Index(tampered, ".")] + valid[strings.Index(valid, "."):]
+
+	for name, token := range map[string]string{
+		"another key":     Grant{"1", "42", "2", now.Add(time.Minute)}.Sign(other),
+		"tampered":        tampered,
+		"too long":        Grant{"1", "42", "2", now.Add(MaxLifetime + time.Minute)}.Sign(coordinator),
+		"malformed":       "not a grant",
+		"bad hex":         "!!.!!",
+		"wrong structure": hex.EncodeToString([]byte("a b c")) + "." + hex.EncodeToString(make([]byte, 64)),
+	} {
+		if _, err := a.Verify(token); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%v: expected the grant to be rejected, got %v", name, err)
+		}
+	}
+
+	if _, err := a.Verify(""); !errors.Is(err, ErrMissing) {
+		t.Errorf("expected a missing grant, got %v", err)
+	}
+}
+
+func TestLoadCoordinatorKey(t *testing.T) {
+	key, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k, err := LoadCoordinatorKey(hex.EncodeToString(key), "")
+	if err != nil || !bytes.Equal(k, key) {
+		t.Fatalf("unexpected key %x, %v", k, err)
+	}
+
+	encoded := filepath.Join(t.TempDir(), "hex")
+	if err := os.WriteFile(encoded, []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	k, err = LoadCoordinatorKey("", encoded)
+	if err != nil || !bytes.Equal(k, key) {
+		t.Fatalf("unexpected key %x, %v", k, err)
+	}
+
+	if _, err := LoadCoordinatorKey("abcd", ""); !errors.Is(err, ErrInvalidCoordinatorKey) {
+		t.Errorf("expected a short key to be rejected, got %v", err)
+	}
+	if _, err := LoadCoordinatorKey("", ""); !errors.Is(err, ErrNoCoordinatorKey) {
+		t.Errorf("expected no key to be configured, got %v", err)
+	}
+}
@@ -0,0 +1,214 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+// Package keystore holds long-lived key material for a peer in the keystore table of its
+// database. Each key is stored under a name and a version, and sealed with AES-256-GCM under a
+// master key that is never stored in the database. Rotating a key adds a new version, and
+// earlier versions remain available until they are deleted.
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const MasterKeyBytes = 32
+
+var (
+	ErrNoMasterKey      = errors.New("no keystore master key is configured")
+	ErrInvalidMasterKey = fmt.Errorf("keystore master key must be %v bytes", MasterKeyBytes)
+	ErrKeyNotFound      = errors.New("key not found in keystore")
+)
+
+// Entry is a version of a named key.
+type Entry struct {
+	Name    string
+	Version int
+	Kind    string
+	Key     []byte
+}
+
+type Keystore struct {
+	dbType    string
+	dbConnStr string
+	aead      cipher.AEAD
+}
+
+// LoadMasterKey returns the master key given as hex, or if that is empty, read from the file
+// at path. The file may hold either the raw key or its hex encoding.
+func LoadMasterKey(hexKey string, path string) ([]byte, error) {
+	if hexKey == "" && path == "" {
+		return nil, ErrNoMasterKey
+	}
+
+	if hexKey == "" {
+		bs, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read keystore master key: %w", err)
+		}
+		if len(bs) == MasterKeyBytes {
+			return bs, nil
+		}
+		hexKey = strings.TrimSpace(string(bs))
+	}
+
+	key, err := hex.DecodeString(hexKey)
+	if err != nil || len(key) != MasterKeyBytes {
+		return nil, ErrInvalidMasterKey
+	}
+	return key, nil
+}
+
+func New(dbType string, dbConnStr string, masterKey []byte) (*Keystore, error) {
+	if len(masterKey) != MasterKeyBytes {
+		return nil, ErrInvalidMasterKey
+	}
+
+	block, err := aes.NewCipher(masterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Keystore{dbType, dbConnStr, aead}, nil
+}
+
+func (k *Keystore) open() (*sql.DB, error) {
+	db, err := sql.Open(k.dbType, k.dbConnStr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check DB Connection
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// associatedData binds a sealed key to its name, version and kind, so that rows cannot be
+// swapped with each other.
+func associatedData(name string, version int, kind string) []byte {
+	ad := binary.BigEndian.AppendUint32(nil, uint32(len(name)))
+	ad = append(ad, name...)
+	ad = binary.BigEndian.AppendUint32(ad, uint32(version))
+	return append(ad, kind...)
+}
+
+// Put stores key as a new version of the named key, and returns the version.
+func (k *Keystore) Put(name string, kind string, key []byte) (int, error) {
+	if name == "" {
+		return 0, errors.New("key name must not be empty")
+	}
+
+	db, err := k.open()
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var latest sql.NullInt64
+	err = tx.QueryRow(`SELECT MAX(version) FROM keystore WHERE name = $1`, name).Scan(&latest)
+	if err != nil {
+		return 0, err
+	}
+	version := int(latest.Int64) + 1
+
+	nonce := make([]byte, k.aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return 0, err
+	}
+	data := k.aead.Seal(nil, nonce, key, associatedData(name, version, kind))
+
+	sqlStatement := `
+		INSERT INTO keystore (name, version, kind, nonce, data, created)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+
+	_, err = tx.Exec(sqlStatement, name, version, kind, nonce, data, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// Get returns a version of the named key, or the latest version if version is 0.
+func (k *Keystore) Get(name string, version int) (Entry, error) {
+	db, err := k.open()
+	if err != nil {
+		return Entry{}, err
+	}
+	defer db.Close()
+
+	sqlStatement := `SELECT version, kind, nonce, data
+	FROM keystore
+	WHERE name = $1 AND ($2 = 0 OR version = $2)
+	ORDER BY version DESC
+	LIMIT 1`
+
+	e := Entry{Name: name}
+	var nonce, data []byte
+
+	err = db.QueryRow(sqlStatement, name, version).Scan(&e.Version, &e.Kind, &nonce, &data)
+	if err == sql.ErrNoRows {
+		return Entry{}, fmt.Errorf("%w: %v", ErrKeyNotFound, name)
+	}
+	if err != nil {
+		return Entry{}, err
+	}
+
+	if len(nonce) != k.aead.NonceSize() {
+		return Entry{}, fmt.Errorf("key %v version %v has an invalid nonce", name, e.Version)
+	}
+
+	e.Key, err = k.aead.Open(nil, nonce, data, associatedData(name, e.Version, e.Kind))
+	if err != nil {
+		return Entry{}, fmt.Errorf("unable to unseal key %v version %v, the master key may be incorrect", name, e.Version)
+	}
+	return e, nil
+}
+
+// Delete removes a version of the named key, or every version if version is 0.
+func (k *Keystore) Delete(name string, version int) error {
+	db, err := k.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	sqlStatement := `DELETE FROM keystore
+	WHERE name = $1 AND ($2 = 0 OR version = $2)`
+
+	_, err = db.Exec(sqlStatement, name, version)
+	return err
+}
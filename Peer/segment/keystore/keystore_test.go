@@ -0,0 +1,55 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package keystore
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadMasterKey(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, MasterKeyBytes)
+
+	k, err := LoadMasterKey(hex.EncodeToString(key), "")
+	if err != nil || !bytes.Equal(k, key) {
+		t.Fatalf("unexpected key %x, %v", k, err)
+	}
+
+	dir := t.TempDir()
+
+	raw := filepath.Join(dir, "raw")
+	if err := os.WriteFile(raw, key, 0600); err != nil {
+		t.Fatal(err)
+	}
+	k, err = LoadMasterKey("", raw)
+	if err != nil || !bytes.Equal(k, key) {
+		t.Fatalf("unexpected key %x, %v", k, err)
+	}
+
+	encoded := filepath.Join(dir, "hex")
+	if err := os.WriteFile(encoded, []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	k, err = LoadMasterKey("", encoded)
+	if err != nil || !bytes.Equal(k, key) {
+		t.Fatalf("unexpected key %x, %v", k, err)
+	}
+
+	if _, err := LoadMasterKey("abcd", ""); !errors.Is(err, ErrInvalidMasterKey) {
+		t.Errorf("expected a short key to be rejected, got %v", err)
+	}
+	if _, err := LoadMasterKey("", ""); !errors.Is(err, ErrNoMasterKey) {
+		t.Errorf("expected no key to be configured, got %v", err)
+	}
+}
@@ -28,10 +28,15 @@ type requestBuilder struct {
 	responseBody   interface{}
 	expectedStatus int
 	timeout        time.Duration
+	header         http.Header
+	acceptGzip     bool
+	elementWidth   int
+	bufferSize     int
+	open           func(algorithm string, sealed []byte) ([]byte, error)
 }
 
 func request(transport *http.Transport, url string) *requestBuilder {
-	return &requestBuilder{transport, http.MethodGet, url, nil, nil, http.StatusOK, 0}
+	return &requestBuilder{transport, http.MethodGet, url, nil, nil, http.StatusOK, 0, make(http.Header), false, 0, 0, nil}
 }
 
 func (b *requestBuilder) withMethod(method string) *requestBuilder {
@@ -43,6 +48,26 @@ func (b *requestBuilder) withBody(value interface{}) *requestBuilder {
 	b.requestBody = value
 	return b
 }
+func (b *requestBuilder) withHeader(key string, value string) *requestBuilder {
+	if value != "" {
+		b.header.Set(key, value)
+	}
+	return b
+}
+func (b *requestBuilder) withCompression(enabled bool, elementWidth int) *requestBuilder {
+	b.acceptGzip = enabled
+	b.elementWidth = elementWidth
+	return b
+}
+func (b *requestBuilder) withBufferSize(size int) *requestBuilder {
+	b.bufferSize = size
+	return b
+}
+func (b *requestBuilder) withOpener(open func(algorithm string, sealed []byte) ([]byte, error)) *requestBuilder {
+	b.open = open
+	return b
+}
+
 func (b *requestBuilder) withTimeout(timeInSeconds time.Duration) *requestBuilder {
 	b.timeout = timeInSeconds
 	return b
@@ -107,6 +132,13 @@ func (b *requestBuilder) submit() error {
 func (b *requestBuilder) retrieveDownload(writer io.Writer) (int, error) {
 	downloadOptions := DefaultOptions()
 	downloadOptions.HTTPTransport = b.transport
+	downloadOptions.Header = b.header
+	downloadOptions.AcceptGzip = b.acceptGzip
+	downloadOptions.ElementWidth = b.elementWidth
+	downloadOptions.Open = b.open
+	if b.bufferSize > 0 {
+		downloadOptions.BufferSize = b.bufferSize
+	}
 	arraylength, err := DownloadStreamOpts(context.Background(), b.requestURL, writer, downloadOptions)
 
 	if err != nil {
@@ -156,5 +188,9 @@ func createRequest(b *requestBuilder) (*http.Request, error) {
 			return nil, err
 		}
 	}
+
+	for key, values := range b.header {
+		request.Header[key] = values
+	}
 	return request, nil
 }
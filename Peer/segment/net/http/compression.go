@@ -0,0 +1,270 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Arrays are compressed with gzip when they are transmitted, if the peer reading the array
+// accepts it and the array is at least as long as the compression threshold for that peer. A
+// compressed array is served with its uncompressed length, which bounds its decompression and
+// is checked against the width of its elements.
+//
+// A sealed array is not served with a Content-Encoding, which a proxy could decode. Its encoding
+// is sealed with it instead, as a byte before the array, so that it is authenticated too.
+
+const (
+	acceptEncodingHeader  = "Accept-Encoding"
+	contentEncodingHeader = "Content-Encoding"
+	varyHeader            = "Vary"
+
+	gzipEncoding     = "gzip"
+	identityEncoding = "identity"
+)
+
+// UncompressedLengthHeader is the length in bytes of a compressed array before compression.
+const UncompressedLengthHeader string = "uncompressedlength"
+
+// Compression configures the compression of the arrays a peer transmits and receives.
+type Compression struct {
+	// Threshold is the length in bytes from which arrays are compressed. Arrays are not
+	// compressed when it is zero.
+	Threshold int
+
+	// Peers overrides Threshold for the peers with the given node IDs.
+	Peers map[string]int
+}
+
+func (c Compression) threshold(nodeID string) int {
+	if t, ok := c.Peers[nodeID]; ok {
+		return t
+	}
+	return c.Threshold
+}
+
+// enabled reports whether arrays are compressed between this peer and the node.
+func (c Compression) enabled(nodeID string) bool {
+	return c.threshold(nodeID) > 0
+}
+
+// ParseCompressionPeers parses the per-peer compression thresholds, given as a comma separated
+// list of <node ID>=<threshold>.
+func ParseCompressionPeers(s string) (map[string]int, error) {
+	peers := make(map[string]int)
+	if strings.TrimSpace(s) == "" {
+		return peers, nil
+	}
+
+	for _, entry := range strings.Split(s, ",") {
+		nodeID, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || nodeID == "" {
+			return nil, fmt.Errorf("invalid compression setting %q, expected <node ID>=<threshold>", entry)
+		}
+		threshold, err := strconv.Atoi(value)
+		if err != nil || threshold < 0 {
+			return nil, fmt.Errorf("invalid compression threshold for node %v: %q", nodeID, value)
+		}
+		peers[nodeID] = threshold
+	}
+	return peers, nil
+}
+
+// acceptsGzip reports whether the Accept-Encoding header of the request allows gzip.
+func acceptsGzip(r *http.Request) bool {
+	for _, value := range r.Header.Values(acceptEncodingHeader) {
+		for _, coding := range strings.Split(value, ",") {
+			name, param, _ := strings.Cut(coding, ";")
+			if strings.TrimSpace(name) != gzipEncoding {
+				continue
+			}
+
+			// A quality of 0 refuses the encoding
+			if q, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				weight, err := strconv.ParseFloat(q, 64)
+				return err == nil && weight > 0
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// encodedResponseWriter sets the Content-Encoding of a successful response as its header is
+// written. http.ServeContent does not set the Content-Length of a response which already has a
+// Content-Encoding, as it may be compressed as it is written, but the content it serves here has
+// been compressed up front.
+type encodedResponseWriter struct {
+	http.ResponseWriter
+	encoding string
+}
+
+func (w encodedResponseWriter) WriteHeader(code int) {
+	if code == http.StatusOK || code == http.StatusPartialContent {
+		w.Header().Set(contentEncodingHeader, w.encoding)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+var sealedEncodings = []string{identityEncoding, gzipEncoding}
+
+// sealedPayload prefixes the array b with its encoding, before it is sealed.
+func sealedPayload(encoding string, b []byte) []byte {
+	prefix := byte(0)
+	for i, e := range sealedEncodings {
+		if e == encoding {
+			prefix = byte(i)
+		}
+	}
+	return append([]byte{prefix}, b...)
+}
+
+// openedPayload returns the encoding and the array of a sealed payload once it is opened.
+func openedPayload(payload []byte) (string, []byte, error) {
+	if len(payload) == 0 || int(payload[0]) >= len(sealedEncodings) {
+		return "", nil, errors.New("the sealed content has no valid encoding")
+	}
+	return sealedEncodings[payload[0]], payload[1:], nil
+}
+
+// maxCompressionRatio is the most deflate can compress data by, which bounds how long an
+// array may claim to be once it is decompressed.
+const maxCompressionRatio = 1032
+
+// compressionCacheBytes bounds the memory held by the compressed arrays in a compressionCache.
+const compressionCacheBytes = 256 << 20
+
+type compressionCacheKey struct {
+	handle string
+	index  int
+}
+
+type compressedArray struct {
+	digest     [sha256.Size]byte
+	compressed []byte
+	used       uint64
+}
+
+// compressionCache keeps the arrays most recently compressed for transmission, so that the
+// HEAD request and each range request of a download do not compress the array again. An entry
+// is only used while the digest of the array is unchanged.
+type compressionCache struct {
+	mu      sync.Mutex
+	entries map[compressionCacheKey]*compressedArray
+	size    int
+	clock   uint64
+}
+
+func newCompressionCache() *compressionCache {
+	return &compressionCache{entries: make(map[compressionCacheKey]*compressedArray)}
+}
+
+// compress returns the compression of the array b at handle and index, or nil if it does not
+// compress.
+func (c *compressionCache) compress(handle string, index int, b []byte) ([]byte, error) {
+	key := compressionCacheKey{handle, index}
+	digest := sha256.Sum256(b)
+
+	c.mu.Lock()
+	c.clock++
+	if e, ok := c.entries[key]; ok && e.digest == digest {
+		e.used = c.clock
+		c.mu.Unlock()
+		return e.compressed, nil
+	}
+	c.mu.Unlock()
+
+	compressed, err := compress(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(compressed) >= len(b) {
+		compressed = nil
+	}
+	if len(compressed) > compressionCacheBytes {
+		return compressed, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.clock++
+	if e, ok := c.entries[key]; ok {
+		c.size -= len(e.compressed)
+	}
+	c.entries[key] = &compressedArray{digest, compressed, c.clock}
+	c.size += len(compressed)
+
+	// Evict the least recently used arrays
+	for c.size > compressionCacheBytes {
+		var oldest compressionCacheKey
+		var oldestUsed uint64
+		for k, e := range c.entries {
+			if oldestUsed == 0 || e.used < oldestUsed {
+				oldest, oldestUsed = k, e.used
+			}
+		}
+		c.size -= len(c.entries[oldest].compressed)
+		delete(c.entries, oldest)
+	}
+	return compressed, nil
+}
+
+func compress(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompress returns the decompression of a gzip array, which must be length bytes long and a
+// whole number of elements of elementWidth bytes. The length is bounded by the compressed
+// length, so that a server can not make the peer allocate more than the compression could hold.
+func decompress(compressed []byte, length int, elementWidth int) ([]byte, error) {
+	if length < 0 {
+		return nil, fmt.Errorf("invalid uncompressed length %v", length)
+	}
+	if elementWidth > 0 && length%elementWidth != 0 {
+		return nil, fmt.Errorf("uncompressed length %v is not a multiple of the element width %v", length, elementWidth)
+	}
+	if length/maxCompressionRatio > len(compressed) {
+		return nil, fmt.Errorf("uncompressed length %v is too long for %v compressed bytes", length, len(compressed))
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decompress array: %w", err)
+	}
+
+	// Read at most one byte more than expected, so that a longer array is detected without
+	// decompressing all of it
+	b, err := io.ReadAll(io.LimitReader(zr, int64(length)+1))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decompress array: %w", err)
+	}
+	if len(b) != length {
+		return nil, fmt.Errorf("decompressed array is %v bytes, expected %v", len(b), length)
+	}
+	return b, nil
+}
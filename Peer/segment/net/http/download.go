@@ -12,6 +12,8 @@ package http
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"net"
@@ -29,8 +31,28 @@ const (
 	acceptRangeHeader  = "Accept-Ranges"
 	rangeHeader        = "Range"
 	contentRangeHeader = "Content-Range"
+	ifRangeHeader      = "If-Range"
+	etagHeader         = "ETag"
 )
 
+// ContentDigestHeader is the hex SHA-256 of the content of a download, as it is served.
+const ContentDigestHeader string = "contentdigest"
+
+// statusError is an HTTP status which may succeed if the request is retried.
+type statusError int
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("transient http status code %d", int(e))
+}
+
+func isTransientStatus(code int) bool {
+	switch code {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
 // Logger is an optional interface used for outputting debug logging
 type Logger interface {
 	Infof(format string, args ...interface{})
@@ -47,13 +69,42 @@ type DownloadOptions struct {
 	BufferSize          int
 	HTTPTransport       http.RoundTripper
 	Logger              Logger
+
+	// Header is sent with each request, in addition to the headers of the download itself.
+	Header http.Header
+
+	// AcceptGzip requests the content compressed with gzip, which is decompressed before it
+	// is written. Its length must then be a multiple of ElementWidth, if that is set.
+	AcceptGzip   bool
+	ElementWidth int
+
+	// Open opens sealed content with the algorithm it is sealed with, before it is
+	// decompressed. When it is set, content which is not sealed is refused, and when it is
+	// nil, sealed content is refused.
+	Open func(algorithm string, sealed []byte) ([]byte, error)
+}
+
+// URLInfo describes the content at a download URL.
+type URLInfo struct {
+	ContentLength      int64
+	Resumable          bool
+	ArrayLength        int
+	ContentEncoding    string
+	UncompressedLength int
+
+	// Digest is the hex SHA-256 of the content, and ETag identifies it when a download is
+	// resumed. Either may be empty if the server does not provide it.
+	Digest string
+	ETag   string
+
+	// Sealed is the algorithm the content is sealed with, or empty if it is not sealed.
+	Sealed string
 }
 
 const (
-	headTimeoutSeconds = 60
-	retries            = 10
-	//bufferSizeKb        = 128
-	bufferSizeKb        = 1
+	headTimeoutSeconds  = 60
+	retries             = 10
+	bufferSizeKb        = 128
 	bytesInKb           = 1024
 	retryWaitMultiplier = 1.61803398875 //GoldenMean
 )
@@ -88,8 +139,8 @@ func (o *DownloadOptions) Errorf(format string, args ...interface{}) {
 }
 
 // DownloadStream streams the file at the given URL to the given writer while retrying any broken connections.
-// Consecutive calls with the same URL and filePath will attempt to resume the download.
-// The download stream written to the writer will then be replayed from the beginning of the download.
+// A download which is interrupted is resumed with a range request from the last byte written to the writer,
+// for as long as the content is unchanged, and its digest is checked once it is complete.
 // With the given context the whole operation can be aborted.
 func DownloadStream(ctx context.Context, url string, writer io.Writer) (arraylength int, err error) {
 	return DownloadStreamOpts(ctx, url, writer, DefaultOptions())
@@ -98,48 +149,97 @@ func DownloadStream(ctx context.Context, url string, writer io.Writer) (arraylen
 // DownloadStreamOpts is the same as DownloadStream, but allows you to override the default options with own values.
 // See DownloadStream for more information.
 func DownloadStreamOpts(ctx context.Context, url string, writer io.Writer, options DownloadOptions) (arraylength int, err error) {
-	contentLength, resumable, arraylength, err := fetchURLInfoTries(ctx, url, &options)
+	// The encoding is always given, as otherwise the transport asks for gzip itself and
+	// decompresses the response without the length being checked
+	options.Header = options.Header.Clone()
+	if options.Header == nil {
+		options.Header = make(http.Header)
+	}
+	if options.AcceptGzip {
+		options.Header.Set(acceptEncodingHeader, gzipEncoding)
+	} else {
+		options.Header.Set(acceptEncodingHeader, identityEncoding)
+	}
+
+	info, err := fetchURLInfoTries(ctx, url, &options)
 	if err != nil {
 		return 0, err
 	}
-
-	// This is where the logic for fully resumable download should be added.
-	written := int64(0)
-	if contentLength == -1 {
-		return arraylength, nil
+	if info.ContentLength == -1 {
+		return info.ArrayLength, nil
 	}
-
-	if written == contentLength {
+	if info.ContentLength == 0 {
 		return 0, fmt.Errorf("The HTTP download is already completed.")
 	}
 
-	if !resumable {
+	if !info.Resumable {
 		options.Infof("dlstream.DownloadStreamOpts: Download not resumable")
-	} else if written > 0 {
-		options.Infof("dlstream.DownloadStreamOpts: Current file size: %d, resuming", written)
 	}
 
-	// If we are resuming a download, copy the existing file contents to the writer for replay
-	if written > 0 {
-		if err != nil {
-			options.Errorf("dlstream.DownloadStreamOpts: Error seeking start of stream: %v", err)
-			return 0, errors.Wrap(err, "error seeking file to start")
+	// Content which is compressed or sealed is buffered, and written once it is complete
+	destination := writer
+	var buffered bytes.Buffer
+	switch info.ContentEncoding {
+	case "", identityEncoding:
+	case gzipEncoding:
+		if !options.AcceptGzip {
+			return 0, errors.New("the content is compressed, but compression was not requested")
 		}
+		destination = &buffered
+	default:
+		return 0, errors.Errorf("unsupported content encoding %q", info.ContentEncoding)
+	}
+	switch {
+	case info.Sealed != "" && options.Open == nil:
+		return 0, errors.New("the content is sealed, but no key is configured to open it")
+	case info.Sealed == "" && options.Open != nil:
+		return 0, errors.New("the content is not sealed")
+	case info.Sealed != "":
+		destination = &buffered
+	}
 
+	// The digest covers the content as it is served, so it is checked before it is opened or
+	// decompressed
+	digest := sha256.New()
+	arraylength, err = startDownloadTries(ctx, url, info, io.MultiWriter(destination, digest), &options)
+	if err != nil {
+		return arraylength, err
+	}
+	if info.Digest != "" && hex.EncodeToString(digest.Sum(nil)) != info.Digest {
+		options.Errorf("dlstream.DownloadStreamOpts: Digest of %s does not match, expected %s", url, info.Digest)
+		return arraylength, errors.New("the downloaded content does not match its digest")
+	}
+
+	if destination == writer {
+		return arraylength, nil
+	}
+	b := buffered.Bytes()
+	encoding := info.ContentEncoding
+	if info.Sealed != "" {
+		b, err = options.Open(info.Sealed, b)
 		if err != nil {
-			options.Errorf("dlstream.DownloadStreamOpts: Error replaying file stream: %v", err)
-			return 0, errors.Wrap(err, "error replaying file stream")
+			return arraylength, err
+		}
+		encoding, b, err = openedPayload(b)
+		if err != nil {
+			return arraylength, err
 		}
 	}
-
-	return startDownloadTries(ctx, url, contentLength, written, writer, &options)
+	if encoding == gzipEncoding {
+		b, err = decompress(b, info.UncompressedLength, options.ElementWidth)
+		if err != nil {
+			return arraylength, err
+		}
+	}
+	_, err = writer.Write(b)
+	return arraylength, err
 }
 
 // fetchURLInfoTries tries the configured amount of attempts at doing a HEAD request
 // See FetchURLInfo for more information
-func fetchURLInfoTries(ctx context.Context, url string, options *DownloadOptions) (contentLength int64, resumable bool, arraylength int, err error) {
+func fetchURLInfoTries(ctx context.Context, url string, options *DownloadOptions) (info URLInfo, err error) {
 	for i := 0; i < options.Retries; i++ {
-		contentLength, resumable, arraylength, err = FetchURLInfo(ctx, url, options.InitialHeadTimeout, options.HTTPTransport)
+		info, err = FetchURLInfo(ctx, url, options.InitialHeadTimeout, options.HTTPTransport, options.Header)
 		if err != nil && shouldRetryRequest(err) {
 			options.Infof("dlstream.fetchURLInfoTries: Error fetching URL info: %v, retrying request", err)
 			retryWait(options)
@@ -147,19 +247,19 @@ func fetchURLInfoTries(ctx context.Context, url string, options *DownloadOptions
 		}
 		if err != nil {
 			options.Errorf("dlstream.fetchURLInfoTries: Error fetching URL info: %v, unrecoverable error, will not retry", err)
-			return -1, false, 0, err
+			return URLInfo{ContentLength: -1}, err
 		}
 
-		options.Infof("dlstream.fetchURLInfoTries: Download size: %d, Resumable: %v", contentLength, resumable)
-		return contentLength, resumable, arraylength, nil
+		options.Infof("dlstream.fetchURLInfoTries: Download size: %d, Resumable: %v", info.ContentLength, info.Resumable)
+		return info, nil
 	}
 
-	return -1, false, arraylength, fmt.Errorf("The number of retrieval retries has been exceeded.")
+	return URLInfo{ContentLength: -1, ArrayLength: info.ArrayLength}, fmt.Errorf("The number of retrieval retries has been exceeded.")
 }
 
 // FetchURLInfo does a HEAD request to see if the download URL is valid and returns the size of the content.
-// Also checks if the download can be resumed by looking at capability headers.
-func FetchURLInfo(ctx context.Context, url string, timeout time.Duration, transport http.RoundTripper) (contentLength int64, resumable bool, arraylength int, err error) {
+// Also checks if the download can be resumed by looking at capability headers, and how the content is encoded.
+func FetchURLInfo(ctx context.Context, url string, timeout time.Duration, transport http.RoundTripper, header http.Header) (URLInfo, error) {
 	client := http.Client{
 		Timeout:   timeout,
 		Transport: transport,
@@ -170,32 +270,52 @@ func FetchURLInfo(ctx context.Context, url string, timeout time.Duration, transp
 
 	req, err := http.NewRequest(http.MethodHead, url, nil)
 	if err != nil {
-		return -1, false, 0, errors.Wrap(err, "error creating head request")
+		return URLInfo{ContentLength: -1}, errors.Wrap(err, "error creating head request")
+	}
+	for key, values := range header {
+		req.Header[key] = values
 	}
 	req = req.WithContext(ctx)
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return -1, false, 0, errors.Wrap(err, "error requesting url")
+		return URLInfo{ContentLength: -1}, errors.Wrap(err, "error requesting url")
 	}
 	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
-		return -1, false, 0, errors.Errorf("unexpected head status code %d", resp.StatusCode)
+		return URLInfo{ContentLength: -1}, errors.Errorf("unexpected head status code %d", resp.StatusCode)
 	}
 
 	err = resp.Body.Close()
 	if err != nil {
-		return -1, false, 0, errors.Wrap(err, "error closing response body")
+		return URLInfo{ContentLength: -1}, errors.Wrap(err, "error closing response body")
 	}
 
 	// Resumable is only possible if we can request ranges and know how big the file is gonna be.
-	resumable = resp.Header.Get(acceptRangeHeader) == "bytes" && resp.ContentLength > 0
+	resumable := resp.Header.Get(acceptRangeHeader) == "bytes" && resp.ContentLength > 0
 
 	arrayLength, err := strconv.Atoi(resp.Header.Get(ArrayElementLengthHeader))
 	if err != nil {
 		arrayLength = GetArrayLengthFromURL(url)
 	}
 
-	return resp.ContentLength, resumable, arrayLength, nil
+	info := URLInfo{
+		ContentLength:   resp.ContentLength,
+		Resumable:       resumable,
+		ArrayLength:     arrayLength,
+		ContentEncoding: resp.Header.Get(contentEncodingHeader),
+		Digest:          resp.Header.Get(ContentDigestHeader),
+		ETag:            resp.Header.Get(etagHeader),
+		Sealed:          resp.Header.Get(SealedHeader),
+	}
+	// Sealed content may be compressed before it is sealed, see sealedPayload
+	if info.ContentEncoding == gzipEncoding || info.Sealed != "" && resp.Header.Get(UncompressedLengthHeader) != "" {
+		info.UncompressedLength, err = strconv.Atoi(resp.Header.Get(UncompressedLengthHeader))
+		if err != nil {
+			return URLInfo{ContentLength: -1}, errors.New("compressed content has no uncompressed length")
+		}
+	}
+
+	return info, nil
 }
 
 func GetArrayLengthFromURL(url string) int {
@@ -208,22 +328,34 @@ func GetArrayLengthFromURL(url string) int {
 	return 0
 }
 
-// startDownloadTries starts a loop that retries the download until it either finishes or the retries are depleted
+// startDownloadTries starts a loop that retries the download until it either finishes or the retries are depleted.
+// Each retry resumes from the last byte written, and the retries are replenished whenever an attempt makes progress.
 func startDownloadTries(
-	ctx context.Context, url string, contentLength, written int64, writer io.Writer, options *DownloadOptions,
+	ctx context.Context, url string, info URLInfo, writer io.Writer, options *DownloadOptions,
 ) (arraylength int, err error) {
+	if options.BufferSize <= 0 {
+		options.BufferSize = bufferSizeKb * bytesInKb
+	}
 	buffer := make([]byte, options.BufferSize)
+	contentLength := info.ContentLength
+	written := int64(0)
+	initialRetryWait := options.RetryWait
 
 	// Loop that retries the download
-	for i := 0; i < options.Retries; i++ {
-		options.Infof("dlstream.startDownloadTries: Downloading %s from offset %d, total size: %d, attempt %d", url, written, contentLength, i)
+	for failures := 0; failures < options.Retries; {
+		options.Infof("dlstream.startDownloadTries: Downloading %s from offset %d, total size: %d, failed attempts %d", url, written, contentLength, failures)
+
+		if written > 0 && !info.Resumable {
+			return arraylength, fmt.Errorf("The download was interrupted after %d bytes and can not be resumed.", written)
+		}
 
 		var bodyReader io.ReadCloser
 		var arrayLength int = 0
-		bodyReader, arrayLength, err = doDownloadRequest(ctx, url, written, contentLength, options)
+		bodyReader, arrayLength, err = doDownloadRequest(ctx, url, written, info, options)
 		if err != nil && shouldRetryRequest(err) {
 			options.Infof("dlstream.startDownloadTries: Error retrieving URL: %v, retrying request", err)
 			retryWait(options)
+			failures++
 			continue
 		} else if err != nil {
 			options.Errorf("dlstream.startDownloadTries: Error retrieving URL: %v, unrecoverable error, will not retry", err)
@@ -231,8 +363,15 @@ func startDownloadTries(
 		}
 
 		var shouldContinue bool
+		previouslyWritten := written
 		written, shouldContinue, err = doCopyRequestBody(bodyReader, buffer, contentLength, written, writer, options)
 		if shouldContinue {
+			if written > previouslyWritten {
+				failures = 0
+				options.RetryWait = initialRetryWait
+			} else {
+				failures++
+			}
 			continue
 		}
 		return arrayLength, err
@@ -305,7 +444,9 @@ func retryWait(options *DownloadOptions) {
 //
 //revive:disable-next-line:cognitive-complexity TODO: Review cognitive complexity and possible refactor?
 //revive:disable-next-line:cyclomatic TODO: Review cyclomatic complexity and possible refactor?
-func doDownloadRequest(ctx context.Context, url string, downloadFrom, totalContentLength int64, options *DownloadOptions) (body io.ReadCloser, arrayElementLength int, err error) {
+func doDownloadRequest(ctx context.Context, url string, downloadFrom int64, info URLInfo, options *DownloadOptions) (body io.ReadCloser, arrayElementLength int, err error) {
+	totalContentLength := info.ContentLength
+
 	client := http.Client{
 		Timeout:   options.Timeout,
 		Transport: options.HTTPTransport,
@@ -314,9 +455,18 @@ func doDownloadRequest(ctx context.Context, url string, downloadFrom, totalConte
 	// See: https://stackoverflow.com/a/29200933/3536354
 	req, _ := http.NewRequest(http.MethodGet, url, nil)
 	req = req.WithContext(ctx)
+	for key, values := range options.Header {
+		req.Header[key] = values
+	}
 
 	if downloadFrom > 0 {
 		req.Header.Set(rangeHeader, fmt.Sprintf("bytes=%d-", downloadFrom))
+
+		// The range is only served if the content is unchanged, otherwise the whole content is
+		// served, and the download fails rather than joining two different contents
+		if info.ETag != "" {
+			req.Header.Set(ifRangeHeader, info.ETag)
+		}
 	}
 
 	resp, err := client.Do(req)
@@ -329,6 +479,11 @@ func doDownloadRequest(ctx context.Context, url string, downloadFrom, totalConte
 		a = 0
 	}
 
+	if isTransientStatus(resp.StatusCode) {
+		_ = resp.Body.Close()
+		return nil, a, statusError(resp.StatusCode)
+	}
+
 	if downloadFrom <= 0 {
 		if resp.StatusCode == http.StatusNoContent {
 			return nil, a, nil
@@ -340,6 +495,10 @@ func doDownloadRequest(ctx context.Context, url string, downloadFrom, totalConte
 			return nil, a, errors.Errorf("unexpected response content-length (expected %d, got %d)", totalContentLength, resp.ContentLength)
 		}
 	} else {
+		if resp.StatusCode == http.StatusOK {
+			_ = resp.Body.Close()
+			return nil, a, errors.New("the content changed while it was being downloaded")
+		}
 		if resp.StatusCode != http.StatusPartialContent {
 			return nil, a, errors.Errorf("unexpected download http status code %d", resp.StatusCode)
 		}
@@ -368,42 +527,34 @@ func doDownloadRequest(ctx context.Context, url string, downloadFrom, totalConte
 	return resp.Body, a, nil
 }
 
-// shouldRetryRequest analyzes a given request error and determines whether its a good idea to retry the request
-//
-//revive:disable-next-line:cyclomatic TODO: Review cyclomatic complexity
-//revive:disable-next-line:cognitive-complexity TODO: Review cognitive complexity
+// shouldRetryRequest analyzes a given request error and determines whether its a good idea to retry the request.
+// The errors of requests are wrapped, so their causes are found by unwrapping them.
 func shouldRetryRequest(err error) (shouldRetry bool) {
-	if err == io.ErrUnexpectedEOF {
+	if errors.Is(err, io.ErrUnexpectedEOF) {
 		return true
 	}
 
-	netErr, ok := err.(net.Error)
-	if ok {
-		return netErr.Temporary() || netErr.Timeout()
+	var status statusError
+	if errors.As(err, &status) {
+		return true
 	}
 
-	switch t := err.(type) {
-	case *net.OpError:
-		if t.Op == "dial" {
-			// Unknown host
-			return false
-		}
-		if t.Op == "read" {
-			// Connection refused
-			return true
-		}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
 
-	case syscall.Errno:
-		if t == syscall.ECONNREFUSED {
-			// Connection refused
-			return true
-		}
-		if t == syscall.ECONNRESET {
-			// Connection reset
-			return true
-		}
-		if t == syscall.ECONNABORTED {
-			// Connection aborted
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "read" {
+		// Connection dropped while reading
+		return true
+	}
+
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		switch errno {
+		case syscall.ECONNREFUSED, syscall.ECONNRESET, syscall.ECONNABORTED, syscall.EPIPE:
+			// Connection refused, reset or aborted
 			return true
 		}
 	}
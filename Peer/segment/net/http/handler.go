@@ -19,7 +19,8 @@ import (
 // EndpointSetupFunc represents a function which configures the endpoints on a Router
 type EndpointSetupFunc func(r chi.Router)
 
-// NewAnonHandler returns a new http.Handler that doesn't require certificate exchange.
+// NewAnonHandler returns a new http.Handler that doesn't require certificate exchange. When the
+// server uses mutual TLS, certificates are exchanged by the listener, see LoadMutualTLSConfig.
 func NewAnonHandler(setupFuncs ...EndpointSetupFunc) http.Handler {
 	r := chi.NewRouter()
 	r.Use(middleware.AllowContentType("application/json", "application/octet-stream"))
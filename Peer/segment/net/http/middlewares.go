@@ -11,6 +11,7 @@ package http
 
 import (
 	"context"
+	"log"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
@@ -22,6 +23,7 @@ type nodeContextKey struct{}
 type opcodeContextKey struct{}
 type dtypeContextKey struct{}
 type indexContextKey struct{}
+type peerContextKey struct{}
 
 func parseHandlerID(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -70,3 +72,31 @@ func parseIndex(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// verifyPeer identifies the peer from its client certificate when the server uses mutual TLS,
+// and rejects peers which are not one of the nodes of the session.
+func verifyPeer(h SegmentHandler) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			identity, err := PeerIdentity(r.TLS)
+			if err != nil {
+				log.Printf("Rejected request from %v: %v", r.RemoteAddr, err)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if _, ok := h.SegmentNodes()[identity]; !ok {
+				log.Printf("Rejected request from %v: node %q is not a peer", r.RemoteAddr, identity)
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), peerContextKey{}, identity)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -10,12 +10,14 @@
 package http
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/AUSTRAC/ftillite/Peer/segment/types"
 )
@@ -23,24 +25,76 @@ import (
 type SegmentClient struct {
 	transport *http.Transport
 	nodeID    int
+	scheme    string
+
+	compression        Compression
+	downloadBufferSize int
+
+	peersMu sync.RWMutex
+	peers   map[string]string
 }
 
 func NewSegmentClient(nodeID int) *SegmentClient {
 	t := &http.Transport{}
 	return &SegmentClient{
-		t,
-		nodeID,
+		transport: t,
+		nodeID:    nodeID,
+		scheme:    "http",
+		peers:     make(map[string]string),
+	}
+}
+
+// NewMutualTLSSegmentClient returns a client which presents the certificate of config to other
+// peers, and only connects to a peer if its certificate is for the node registered at its
+// address, see SetPeerIdentity.
+func NewMutualTLSSegmentClient(nodeID int, config *tls.Config) *SegmentClient {
+	s := &SegmentClient{
+		nodeID: nodeID,
+		scheme: "https",
+		peers:  make(map[string]string),
+	}
+	s.transport = &http.Transport{
+		DialTLSContext: s.dialPinnedTLS(config),
 	}
+	return s
+}
+
+// SetCompression sets which peers arrays are received compressed from.
+func (s *SegmentClient) SetCompression(c Compression) {
+	s.compression = c
+}
+
+// SetDownloadBufferSize sets the size in bytes of the buffer arrays are received through. The
+// default size of DownloadOptions is used when it is zero.
+func (s *SegmentClient) SetDownloadBufferSize(size int) {
+	s.downloadBufferSize = size
+}
+
+// SetPeerIdentity registers the node ID of the peer at an address.
+func (s *SegmentClient) SetPeerIdentity(address string, nodeID string) {
+	s.peersMu.Lock()
+	defer s.peersMu.Unlock()
+
+	s.peers[address] = nodeID
+}
+
+func (s *SegmentClient) peerIdentity(address string) (string, bool) {
+	s.peersMu.RLock()
+	defer s.peersMu.RUnlock()
+
+	nodeID, ok := s.peers[address]
+	return nodeID, ok
 }
 
-func (s *SegmentClient) RequestTransmission(address string, handle string, newHandle string, dtype string, opcode string) error {
-	endpoint, err := resolveSegmentURL(address, routeTransmitNodeID, handle, dtype, newHandle, s.nodeID, opcode)
+func (s *SegmentClient) RequestTransmission(address string, handle string, newHandle string, dtype string, opcode string, grant string) error {
+	endpoint, err := resolveSegmentURL(s.scheme, address, routeTransmitNodeID, handle, dtype, newHandle, s.nodeID, opcode)
 	if err != nil {
 		return err
 	}
 
 	err = request(s.transport, endpoint).
 		withMethod(http.MethodPost).
+		withHeader(TransferGrantHeader, grant).
 		expect(http.StatusOK).
 		submit()
 
@@ -51,15 +105,26 @@ func (s *SegmentClient) RequestTransmission(address string, handle string, newHa
 	return nil
 }
 
-func (s *SegmentClient) ReceiveTransmission(address string, handle string, tc types.TypeCode, index int, writer io.Writer) (int, error) {
-	endpoint, err := resolveSegmentURL(address, routeTransmitIndexID, handle, tc, fmt.Sprint(index))
+// ReceiveTransmission downloads the array at handle and index from the peer at address. The
+// array must be sealed, and is opened with open, unless open is nil.
+func (s *SegmentClient) ReceiveTransmission(
+	address string, handle string, tc types.TypeCode, index int, grant string,
+	open func(algorithm string, sealed []byte) ([]byte, error), writer io.Writer,
+) (int, error) {
+	endpoint, err := resolveSegmentURL(s.scheme, address, routeTransmitIndexID, handle, tc, fmt.Sprint(index))
 	var arraylength int = 0
 	if err != nil {
 		return arraylength, err
 	}
 
+	nodeID, _ := s.peerIdentity(address)
 	arraylength, err = request(s.transport, endpoint).
 		withMethod(http.MethodGet).
+		withHeader(TransferGrantHeader, grant).
+		withHeader(TransferDestinationHeader, fmt.Sprint(s.nodeID)).
+		withCompression(s.compression.enabled(nodeID), tc.ElementBytes()).
+		withBufferSize(s.downloadBufferSize).
+		withOpener(open).
 		expect(http.StatusOK).
 		retrieveDownload(writer)
 
@@ -70,7 +135,7 @@ func (s *SegmentClient) ReceiveTransmission(address string, handle string, tc ty
 	return arraylength, nil
 }
 
-func resolveSegmentURL(address string, route RoutePattern, params ...interface{}) (string, error) {
+func resolveSegmentURL(scheme string, address string, route RoutePattern, params ...interface{}) (string, error) {
 	if len(address) == 0 {
 		return "", errors.New("Address is empty. cannot resolve.")
 	}
@@ -78,5 +143,5 @@ func resolveSegmentURL(address string, route RoutePattern, params ...interface{}
 	add := url[0]
 	port, _ := strconv.Atoi(url[1])
 
-	return route.ToURL(add, int32(port), params...)
+	return route.ToURL(scheme, add, int32(port), params...)
 }
@@ -11,12 +11,15 @@ package http
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"log"
 	"net/http"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/AUSTRAC/ftillite/Peer/segment/types"
@@ -28,27 +31,49 @@ import (
 const forwardSlash = "/"
 const ArrayElementLengthHeader string = "arrayelementlength"
 
+// TransferGrantHeader carries the grant authorising a transfer, see package grant.
+const TransferGrantHeader string = "transfergrant"
+
+// TransferDestinationHeader names the node reading an array, which it is sealed for when the
+// peer is not authenticated by TLS.
+const TransferDestinationHeader string = "transferdestination"
+
+// SealedHeader is the algorithm a transmitted array is sealed with, see package seal.
+const SealedHeader string = "sealed"
+
 var routeTransmit = NewRoutePattern("/transmit/{handler_id}/{dtype}")
 var routeTransmitIndexID = routeTransmit.SubRoute("/rec/{index}")
 var routeTransmitNewHandlerID = routeTransmit.SubRoute("/req/{newhandler_id}")
 var routeTransmitNodeID = routeTransmitNewHandlerID.SubRoute("/{node_id}/{opcode}")
 
 type SegmentHandler interface {
-	TransferBytes(address string, handle string, newHandle string, dtype string, opcode string) error
+	TransferBytes(address string, handle string, newHandle string, dtype string, opcode string, grant string) error
 	GetVariable(h variables.Handle) (types.TypeVal, error)
-	RequestTransferBytes(nodeAddress string, handle string, newHandle string, dtype string, opcode string) error
+	RequestTransferBytes(nodeAddress string, handle string, newHandle string, dtype string, opcode string, grant string) error
 	SegmentNodes() map[string]string
+
+	// AuthoriseTransfer checks that the grant allows the array at handle to be sent to the
+	// destination node. The destination is the node the peer names when it is not
+	// authenticated by TLS, and may be empty. Only the first request of a download is checked,
+	// and the download may be resumed by range once the grant expires.
+	AuthoriseTransfer(grant string, handle string, destination string) error
+
+	// SealTransfer seals the bytes of the array at handle and index for the destination node,
+	// and returns them with the algorithm they are sealed with. The bytes are returned
+	// unchanged, with no algorithm, when transfers are not sealed.
+	SealTransfer(destination string, handle string, index int, b []byte) ([]byte, string, error)
 }
 
-func SegmentEndpoints(h SegmentHandler) EndpointSetupFunc {
+func SegmentEndpoints(h SegmentHandler, compression Compression) EndpointSetupFunc {
 	return func(r chi.Router) {
 		r.Route(routeTransmit.Pattern(), func(r chi.Router) {
+			r.Use(verifyPeer(h))
 			r.Use(parseHandlerID)
 			r.Use(parseDtype)
 			r.Route(routeTransmitIndexID.Pattern(), func(r chi.Router) {
 				r.Use(parseIndex)
-				r.Get(forwardSlash, getSegmentTransmitBytes(h))
-				r.Head(forwardSlash, getSegmentTransmitBytes(h))
+				r.Get(forwardSlash, getSegmentTransmitBytes(h, compression))
+				r.Head(forwardSlash, getSegmentTransmitBytes(h, compression))
 			})
 			r.Route(routeTransmitNewHandlerID.Pattern(), func(r chi.Router) {
 				r.Use(parseNewHandlerID)
@@ -62,13 +87,98 @@ func SegmentEndpoints(h SegmentHandler) EndpointSetupFunc {
 	}
 }
 
-func getSegmentTransmitBytes(h SegmentHandler) http.HandlerFunc {
+// transferResumeWindow is how long after its last request a download may be resumed, once the
+// grant which authorised it has expired.
+const transferResumeWindow = 30 * time.Minute
+
+// authorisedTransfer is a download which was authorised by a grant, of the content with the
+// given ETag.
+type authorisedTransfer struct {
+	handle      string
+	index       int
+	destination string
+	grant       string
+	etag        string
+}
+
+// authorisedTransfers remembers the downloads which grants have authorised, so that a grant is
+// only checked when a download starts, and an interrupted download can be resumed by range after
+// its grant has expired, for as long as the content is unchanged.
+type authorisedTransfers struct {
+	mu        sync.Mutex
+	transfers map[authorisedTransfer]time.Time
+	now       func() time.Time
+}
+
+func newAuthorisedTransfers() *authorisedTransfers {
+	return &authorisedTransfers{transfers: make(map[authorisedTransfer]time.Time), now: time.Now}
+}
+
+// add records that the transfer has been authorised, and forgets the transfers which can no
+// longer be resumed.
+func (a *authorisedTransfers) add(t authorisedTransfer) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	for k, expiry := range a.transfers {
+		if !now.Before(expiry) {
+			delete(a.transfers, k)
+		}
+	}
+	a.transfers[t] = now.Add(transferResumeWindow)
+}
+
+// resume reports whether the transfer was authorised and can still be resumed, and if so,
+// extends the time it can be resumed for.
+func (a *authorisedTransfers) resume(t authorisedTransfer) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	expiry, ok := a.transfers[t]
+	if !ok || !now.Before(expiry) {
+		return false
+	}
+	a.transfers[t] = now.Add(transferResumeWindow)
+	return true
+}
+
+func getSegmentTransmitBytes(h SegmentHandler, compression Compression) http.HandlerFunc {
+	transfers := newAuthorisedTransfers()
+	compressed := newCompressionCache()
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		handlerID := variables.Handle(r.Context().Value(handlerContextKey{}).(string))
 		//dtype := types.TypeCode(r.Context().Value(dtypeContextKey{}).(string))
 		temp := r.Context().Value(indexContextKey{}).(string)
 		index, _ := strconv.Atoi(temp)
 
+		// A peer authenticated by TLS is always the destination, whatever node it names
+		identity, _ := r.Context().Value(peerContextKey{}).(string)
+		destination := identity
+		if destination == "" {
+			destination = r.Header.Get(TransferDestinationHeader)
+		}
+
+		// A range request which resumes an authorised download is served without checking the
+		// grant again, provided the content has not changed, which is checked once the ETag of
+		// the content is known
+		grant := r.Header.Get(TransferGrantHeader)
+		transfer := authorisedTransfer{string(handlerID), index, destination, grant, r.Header.Get(ifRangeHeader)}
+		resumed := r.Header.Get(rangeHeader) != "" && transfer.etag != "" && transfers.resume(transfer)
+		authorise := func() bool {
+			if err := h.AuthoriseTransfer(grant, string(handlerID), destination); err != nil {
+				log.Printf("Rejected request from %v for handle %v: %v", r.RemoteAddr, handlerID, err)
+				w.WriteHeader(http.StatusForbidden)
+				return false
+			}
+			return true
+		}
+		if !resumed && !authorise() {
+			return
+		}
+
 		v, err := h.GetVariable(handlerID)
 
 		if err != nil {
@@ -89,6 +199,50 @@ func getSegmentTransmitBytes(h SegmentHandler) http.HandlerFunc {
 		}
 
 		if len(b) > 0 {
+			// The compression is deterministic, so that ranges of the compressed array are
+			// consistent between requests
+			w.Header().Set(varyHeader, acceptEncodingHeader)
+			encoding := identityEncoding
+			if threshold := compression.threshold(identity); threshold > 0 && len(b) >= threshold && acceptsGzip(r) {
+				c, err := compressed.compress(string(handlerID), index, b)
+				if err == nil && c != nil {
+					encoding = gzipEncoding
+					w.Header().Set(UncompressedLengthHeader, fmt.Sprint(len(b)))
+					b = c
+				}
+			}
+
+			// The array is sealed after it is compressed, as a sealed array does not compress
+			sealed, algorithm, err := h.SealTransfer(destination, string(handlerID), index, sealedPayload(encoding, b))
+			if err != nil {
+				log.Printf("Rejected request from %v for handle %v: %v", r.RemoteAddr, handlerID, err)
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			if algorithm != "" {
+				w.Header().Set(SealedHeader, algorithm)
+				b = sealed
+			} else if encoding != identityEncoding {
+				w = encodedResponseWriter{w, encoding}
+			}
+
+			// The digest is checked once a download is complete, and as the ETag, it stops a
+			// download being resumed from a different array
+			digest := sha256.Sum256(b)
+			etag := fmt.Sprintf("%q", hex.EncodeToString(digest[:]))
+			if resumed && etag != transfer.etag {
+				if !authorise() {
+					return
+				}
+				resumed = false
+			}
+			if !resumed && grant != "" {
+				transfer.etag = etag
+				transfers.add(transfer)
+			}
+			w.Header().Set(ContentDigestHeader, hex.EncodeToString(digest[:]))
+			w.Header().Set(etagHeader, etag)
+
 			reader := bytes.NewReader(b)
 
 			w.Header().Set("Content-Type", "application/octet-stream")
@@ -110,9 +264,17 @@ func postSegmentTransmitBytes(h SegmentHandler) http.HandlerFunc {
 		nodeID := variables.Handle(r.Context().Value(nodeContextKey{}).(string))
 		dtype := r.Context().Value(dtypeContextKey{}).(string)
 		opcode := r.Context().Value(opcodeContextKey{}).(string)
+		grant := r.Header.Get(TransferGrantHeader)
+
+		// With mutual TLS, a peer can only ask for a transfer from itself
+		if identity, ok := r.Context().Value(peerContextKey{}).(string); ok && identity != string(nodeID) {
+			log.Printf("Rejected request from node %q to transfer from node %q", identity, nodeID)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
 
 		nodeAddress := h.SegmentNodes()[string(nodeID)]
-		err := h.TransferBytes(nodeAddress, string(handlerID), string(newHandlerID), dtype, opcode)
+		err := h.TransferBytes(nodeAddress, string(handlerID), string(newHandlerID), dtype, opcode, grant)
 		if err != nil {
 			log.Printf("Error: %s", err)
 			w.WriteHeader(http.StatusInternalServerError)
@@ -140,8 +302,9 @@ func (r RoutePattern) Pattern() string { return string(r.path) }
 var paramRegexp = regexp.MustCompile(`{\w+}`)
 
 // ToURL creates a URL to this route taking into consideration the parent routes. The route
-// parameters are substituted, in order, with the parameters passed to this function.
-func (r RoutePattern) ToURL(host string, port int32, substitutionValues ...interface{}) (string, error) {
+// parameters are substituted, in order, with the parameters passed to this function. The
+// scheme is http, or https for peers using mutual TLS.
+func (r RoutePattern) ToURL(scheme string, host string, port int32, substitutionValues ...interface{}) (string, error) {
 	p := strings.TrimPrefix(r.path, forwardSlash)
 
 	for b := r.base; b != nil; b = b.base {
@@ -167,7 +330,7 @@ func (r RoutePattern) ToURL(host string, port int32, substitutionValues ...inter
 		)
 	}
 
-	return fmt.Sprintf("http://%v:%v/%v", host, port, p), nil
+	return fmt.Sprintf("%v://%v:%v/%v", scheme, host, port, p), nil
 }
 
 // SubRoute creates a new RoutePattern with the current RoutePattern as the parent route.
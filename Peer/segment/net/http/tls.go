@@ -0,0 +1,111 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+)
+
+// Peers authenticate each other with certificates issued by a shared CA. The identity of a
+// peer is the common name of its certificate, which must be its node ID, so that a peer can
+// only act as the node it was enrolled as. Hostnames are not checked: a peer is instead pinned
+// to the node ID it was given for its address by netinit.
+
+// LoadMutualTLSConfig returns the TLS configuration of a peer, with its own certificate and key
+// and the CA which issues the certificates of all peers.
+func LoadMutualTLSConfig(certFile string, keyFile string, caFile string) (*tls.Config, error) {
+	if certFile == "" || keyFile == "" || caFile == "" {
+		return nil, errors.New("a certificate, key and CA are all required for mutual TLS")
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load TLS certificate: %w", err)
+	}
+
+	ca, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load TLS CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("no certificates found in the TLS CA file")
+	}
+
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}, nil
+}
+
+// PeerIdentity returns the node ID of the peer on the other side of a verified connection.
+func PeerIdentity(state *tls.ConnectionState) (string, error) {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return "", errors.New("peer did not present a verified certificate")
+	}
+	return state.VerifiedChains[0][0].Subject.CommonName, nil
+}
+
+// verifyServer verifies the certificate chain of a server against the CA, and that it belongs
+// to the expected node.
+func verifyServer(config *tls.Config, nodeID string) func(tls.ConnectionState) error {
+	return func(state tls.ConnectionState) error {
+		if len(state.PeerCertificates) == 0 {
+			return errors.New("peer did not present a certificate")
+		}
+
+		intermediates := x509.NewCertPool()
+		for _, c := range state.PeerCertificates[1:] {
+			intermediates.AddCert(c)
+		}
+
+		_, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+			Roots:         config.RootCAs,
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		})
+		if err != nil {
+			return err
+		}
+
+		if identity := state.PeerCertificates[0].Subject.CommonName; identity != nodeID {
+			return fmt.Errorf("peer certificate is for node %q, expected node %q", identity, nodeID)
+		}
+		return nil
+	}
+}
+
+// dialPinnedTLS returns a dialer which only completes the handshake with the node that the
+// address was registered for with SetPeerIdentity.
+func (s *SegmentClient) dialPinnedTLS(config *tls.Config) func(ctx context.Context, network string, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		nodeID, ok := s.peerIdentity(addr)
+		if !ok {
+			return nil, fmt.Errorf("no node is known at %v", addr)
+		}
+
+		c := config.Clone()
+		// The chain and node ID are verified in VerifyConnection instead of the hostname
+		c.InsecureSkipVerify = true
+		c.VerifyConnection = verifyServer(config, nodeID)
+
+		d := tls.Dialer{Config: c}
+		return d.DialContext(ctx, network, addr)
+	}
+}
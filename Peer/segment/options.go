@@ -9,6 +9,12 @@
 
 package segment
 
+import (
+	"crypto/ed25519"
+
+	"github.com/AUSTRAC/ftillite/Peer/segment/types"
+)
+
 type Options struct {
 	NodeIDString           string
 	NodeName               string
@@ -21,4 +27,46 @@ type Options struct {
 	ExternalFQDN           string
 	EnableGPU              bool
 	DbChunkSize            int
+
+	// Ed25519Backend stores the Ed25519 arrays of the segment. When nil, the GPU backend
+	// is used if EnableGPU is set and the CPU backend otherwise.
+	Ed25519Backend types.Ed25519Backend
+
+	// KeystoreMasterKey seals the keys held in the keystore table. When nil, the keystore
+	// commands are unavailable.
+	KeystoreMasterKey []byte
+
+	// PrivacyBudgetEpsilon and PrivacyBudgetDelta are the differential privacy budget of each
+	// session. When PrivacyBudgetEpsilon is zero, the noise commands are unavailable.
+	PrivacyBudgetEpsilon float64
+	PrivacyBudgetDelta   float64
+
+	// TLSCertFile, TLSKeyFile and TLSCAFile are the PEM files of the certificate and key of the
+	// peer, and of the CA of all peers. When set, peers transmit to each other over mutual TLS,
+	// and the common name of each certificate must be the node ID of the peer.
+	TLSCertFile string
+	TLSKeyFile  string
+	TLSCAFile   string
+
+	// CoordinatorGrantKey verifies the grants the coordinator signs for each transmit. When
+	// set, a peer only sends an array to the peer named in a grant for it. When nil, transfers
+	// are not authorised.
+	CoordinatorGrantKey ed25519.PublicKey
+
+	// CompressionThreshold is the length in bytes from which arrays are compressed when they
+	// are transmitted, and CompressionPeers overrides it for the peers with the given node IDs.
+	// Arrays are not compressed with a threshold of zero.
+	CompressionThreshold int
+	CompressionPeers     map[string]int
+
+	// DownloadBufferSize is the size in bytes of the buffer arrays are received through from
+	// other peers. The default is used when it is zero.
+	DownloadBufferSize int
+
+	// TransferSealKey is the static X25519 private key of the peer, and TransferSealPeers maps
+	// the node IDs of the other peers to their public keys. When set, the arrays transmitted
+	// between peers are sealed so that only the peer reading an array can open it, and arrays
+	// are only sent to and read from the peers in TransferSealPeers.
+	TransferSealKey   []byte
+	TransferSealPeers map[string][]byte
 }
@@ -0,0 +1,88 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+// Package privacy adds differentially private noise to arrays held by a peer, and keeps the
+// ledger of the privacy budget spent by the noisy releases of a session. The budget is
+// configured on the peer rather than by the coordinator, so that it is enforced where the data
+// lives.
+package privacy
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+)
+
+var (
+	ErrNoBudget        = errors.New("no privacy budget is configured")
+	ErrBudgetExhausted = errors.New("privacy budget is exhausted")
+)
+
+// Accountant is an epsilon/delta ledger. Releases are composed sequentially, so that the
+// epsilon and delta of each release are added to those already spent.
+type Accountant struct {
+	mu sync.Mutex
+
+	epsilon float64
+	delta   float64
+
+	spentEpsilon float64
+	spentDelta   float64
+}
+
+func NewAccountant(epsilon float64, delta float64) (*Accountant, error) {
+	if !(epsilon > 0) || math.IsInf(epsilon, 0) {
+		return nil, errors.New("privacy budget epsilon must be positive")
+	}
+	if !(delta >= 0 && delta < 1) {
+		return nil, errors.New("privacy budget delta must be in [0, 1)")
+	}
+	return &Accountant{epsilon: epsilon, delta: delta}, nil
+}
+
+// Spend records a release of the given epsilon and delta. It fails without recording anything
+// if the release would exceed the budget.
+func (a *Accountant) Spend(epsilon float64, delta float64) error {
+	if !(epsilon > 0) || math.IsInf(epsilon, 0) {
+		return errors.New("epsilon must be positive")
+	}
+	if !(delta >= 0 && delta < 1) {
+		return errors.New("delta must be in [0, 1)")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.spentEpsilon+epsilon > a.epsilon || a.spentDelta+delta > a.delta {
+		return fmt.Errorf("%w: releasing (%v, %v) would exceed the remaining (%v, %v)",
+			ErrBudgetExhausted, epsilon, delta, a.epsilon-a.spentEpsilon, a.delta-a.spentDelta)
+	}
+
+	a.spentEpsilon += epsilon
+	a.spentDelta += delta
+	return nil
+}
+
+// Remaining returns the epsilon and delta that are left to spend.
+func (a *Accountant) Remaining() (float64, float64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.epsilon - a.spentEpsilon, a.delta - a.spentDelta
+}
+
+// Reset clears the ledger at the start of a session.
+func (a *Accountant) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.spentEpsilon = 0
+	a.spentDelta = 0
+}
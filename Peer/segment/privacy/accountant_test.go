@@ -0,0 +1,48 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package privacy
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAccountant_Spend(t *testing.T) {
+	a, err := NewAccountant(1, 1e-6)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 4; i++ {
+		if err := a.Spend(0.25, 0); err != nil {
+			t.Fatalf("release %v: %v", i, err)
+		}
+	}
+	if err := a.Spend(0.01, 0); !errors.Is(err, ErrBudgetExhausted) {
+		t.Fatalf("expected the budget to be exhausted, got %v", err)
+	}
+
+	a.Reset()
+	if err := a.Spend(0.5, 1e-6); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Spend(0.1, 1e-7); !errors.Is(err, ErrBudgetExhausted) {
+		t.Fatalf("expected the delta budget to be exhausted, got %v", err)
+	}
+
+	epsilon, delta := a.Remaining()
+	if epsilon != 0.5 || delta != 0 {
+		t.Errorf("unexpected remaining budget (%v, %v)", epsilon, delta)
+	}
+
+	if err := a.Spend(0, 0); err == nil {
+		t.Error("a release of zero epsilon should be refused")
+	}
+}
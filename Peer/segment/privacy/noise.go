@@ -0,0 +1,270 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package privacy
+
+import (
+	"crypto/rand"
+	"errors"
+	"math"
+	"math/big"
+)
+
+// The discrete samplers are the exact samplers of Canonne, Kamath and Steinke, "The Discrete
+// Gaussian for Differential Privacy" (2020), which use only rational arithmetic and uniform
+// integers from crypto/rand, so that the noise does not leak through floating point rounding.
+// Laplace noise for Float arrays is discrete Laplace noise on a fine grid, see AddLaplace.
+
+var errNoiseParameters = errors.New("epsilon and sensitivity must be positive and finite")
+
+func checkNoiseParameters(epsilon float64, sensitivity float64) error {
+	if !(epsilon > 0) || !(sensitivity > 0) || math.IsInf(epsilon, 0) || math.IsInf(sensitivity, 0) {
+		return errNoiseParameters
+	}
+	return nil
+}
+
+// LaplaceScale returns the scale of Laplace or geometric noise for a release of the given
+// epsilon, of a query with the given L1 sensitivity.
+func LaplaceScale(epsilon float64, sensitivity float64) (float64, error) {
+	if err := checkNoiseParameters(epsilon, sensitivity); err != nil {
+		return 0, err
+	}
+	return sensitivity / epsilon, nil
+}
+
+// GaussianSigma returns the standard deviation of Gaussian noise for a release of the given
+// epsilon and delta, of a query with the given L2 sensitivity. This is the classical bound
+// sigma = sensitivity * sqrt(2 ln(1.25/delta)) / epsilon, which holds for epsilon < 1.
+func GaussianSigma(epsilon float64, delta float64, sensitivity float64) (float64, error) {
+	if err := checkNoiseParameters(epsilon, sensitivity); err != nil {
+		return 0, err
+	}
+	if epsilon >= 1 {
+		return 0, errors.New("Gaussian noise requires epsilon < 1")
+	}
+	if !(delta > 0 && delta < 1) {
+		return 0, errors.New("Gaussian noise requires delta in (0, 1)")
+	}
+	return sensitivity * math.Sqrt(2*math.Log(1.25/delta)) / epsilon, nil
+}
+
+// laplaceGridBits is how much finer than the sensitivity the grid of AddLaplace is, in bits.
+const laplaceGridBits = 40
+
+// AddLaplace adds Laplace noise for a release of the given epsilon, of a query with the given L1
+// sensitivity, to xs in place. Sampling continuous Laplace noise from floats leaks the data
+// through the gaps between them (Mironov, "On Significance of the Least Significant Bits for
+// Differential Privacy", 2012), so xs is rounded onto a grid of a power of two, and discrete
+// Laplace noise is added in steps of the grid. The grid is 2^-40 of the sensitivity, and since
+// rounding may move each value by up to one step, the sensitivity on the grid is increased by
+// one step for each value. The noisy values are rounded back to float64, which as
+// post-processing costs no privacy.
+func AddLaplace(xs []float64, epsilon float64, sensitivity float64) error {
+	if err := checkNoiseParameters(epsilon, sensitivity); err != nil {
+		return err
+	}
+	for _, x := range xs {
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return errors.New("Laplace noise can only be added to finite values")
+		}
+	}
+
+	// The grid is 2^exp, and sensitivity < 2^(exp+laplaceGridBits+1)
+	_, exp := math.Frexp(sensitivity)
+	exp -= laplaceGridBits + 1
+
+	// In steps of the grid, the scale of the noise is t/s = (ceil(sensitivity/2^exp) + n) / epsilon
+	steps, _ := new(big.Float).SetMantExp(big.NewFloat(sensitivity), -exp).Int(nil)
+	if new(big.Float).SetInt(steps).Cmp(new(big.Float).SetMantExp(big.NewFloat(sensitivity), -exp)) != 0 {
+		steps.Add(steps, big.NewInt(1))
+	}
+	steps.Add(steps, big.NewInt(int64(len(xs))))
+	e := new(big.Rat).SetFloat64(epsilon)
+	t := steps.Mul(steps, e.Denom())
+	s := e.Num()
+
+	for i, x := range xs {
+		// Round half away from zero onto the grid, where the truncation and the fraction it
+		// drops are both exact
+		scaled := new(big.Float).SetMantExp(big.NewFloat(x), -exp)
+		k, _ := scaled.Int(nil)
+		fraction := new(big.Float).Sub(scaled, new(big.Float).SetInt(k))
+		if fraction.Abs(fraction).Cmp(big.NewFloat(0.5)) >= 0 {
+			k.Add(k, big.NewInt(int64(scaled.Sign())))
+		}
+
+		y, err := sampleDiscreteLaplace(s, t)
+		if err != nil {
+			return err
+		}
+		k.Add(k, y)
+
+		xs[i], _ = new(big.Float).SetMantExp(new(big.Float).SetInt(k), exp).Float64()
+	}
+	return nil
+}
+
+// SampleGeometric returns n samples of two-sided geometric noise, that is discrete Laplace
+// noise, of the given scale.
+func SampleGeometric(scale float64, n int) ([]int64, error) {
+	s, err := positiveRat(scale)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]int64, n)
+	for i := range result {
+		y, err := sampleDiscreteLaplace(s.Denom(), s.Num())
+		if err != nil {
+			return nil, err
+		}
+		if !y.IsInt64() {
+			return nil, errors.New("geometric noise is out of range")
+		}
+		result[i] = y.Int64()
+	}
+	return result, nil
+}
+
+// SampleDiscreteGaussian returns n samples of discrete Gaussian noise with parameter sigma.
+func SampleDiscreteGaussian(sigma float64, n int) ([]int64, error) {
+	if _, err := positiveRat(sigma); err != nil {
+		return nil, err
+	}
+	sigma2, err := positiveRat(sigma * sigma)
+	if err != nil {
+		return nil, err
+	}
+
+	// t = floor(sigma) + 1
+	t := new(big.Int).SetInt64(int64(math.Floor(sigma)) + 1)
+	t2Sigma2 := new(big.Rat).Mul(sigma2, big.NewRat(2, 1))
+	sigma2OverT := new(big.Rat).Quo(sigma2, new(big.Rat).SetInt(t))
+
+	result := make([]int64, n)
+	for i := range result {
+		for {
+			y, err := sampleDiscreteLaplace(big.NewInt(1), t)
+			if err != nil {
+				return nil, err
+			}
+
+			// Accept with probability exp(-(|y| - sigma^2/t)^2 / 2sigma^2)
+			gamma := new(big.Rat).SetInt(new(big.Int).Abs(y))
+			gamma.Sub(gamma, sigma2OverT)
+			gamma.Mul(gamma, gamma)
+			gamma.Quo(gamma, t2Sigma2)
+
+			c, err := bernoulliExp(gamma)
+			if err != nil {
+				return nil, err
+			}
+			if c {
+				if !y.IsInt64() {
+					return nil, errors.New("discrete Gaussian noise is out of range")
+				}
+				result[i] = y.Int64()
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+func positiveRat(x float64) (*big.Rat, error) {
+	if !(x > 0) || math.IsInf(x, 0) {
+		return nil, errNoiseParameters
+	}
+	return new(big.Rat).SetFloat64(x), nil
+}
+
+// sampleDiscreteLaplace samples y with probability proportional to exp(-|y| s/t), that is
+// with scale t/s.
+func sampleDiscreteLaplace(s *big.Int, t *big.Int) (*big.Int, error) {
+	for {
+		u, err := rand.Int(rand.Reader, t)
+		if err != nil {
+			return nil, err
+		}
+
+		d, err := bernoulliExp(new(big.Rat).SetFrac(u, t))
+		if err != nil {
+			return nil, err
+		}
+		if !d {
+			continue
+		}
+
+		v := new(big.Int)
+		for {
+			a, err := bernoulliExp(big.NewRat(1, 1))
+			if err != nil {
+				return nil, err
+			}
+			if !a {
+				break
+			}
+			v.Add(v, big.NewInt(1))
+		}
+
+		// y = floor((u + t*v) / s)
+		x := v.Mul(v, t)
+		x.Add(x, u)
+		y := x.Quo(x, s)
+
+		b, err := bernoulli(big.NewRat(1, 2))
+		if err != nil {
+			return nil, err
+		}
+		if b && y.Sign() == 0 {
+			continue
+		}
+		if b {
+			y.Neg(y)
+		}
+		return y, nil
+	}
+}
+
+// bernoulliExp returns true with probability exp(-gamma), for gamma >= 0.
+func bernoulliExp(gamma *big.Rat) (bool, error) {
+	one := big.NewRat(1, 1)
+
+	g := new(big.Rat).Set(gamma)
+	for g.Cmp(one) > 0 {
+		b, err := bernoulliExp(one)
+		if err != nil || !b {
+			return false, err
+		}
+		g.Sub(g, one)
+	}
+
+	// For gamma in [0, 1], the parity of the first k that fails a Bernoulli(gamma/k) trial
+	k := int64(1)
+	for {
+		a, err := bernoulli(new(big.Rat).Quo(g, big.NewRat(k, 1)))
+		if err != nil {
+			return false, err
+		}
+		if !a {
+			return k%2 == 1, nil
+		}
+		k++
+	}
+}
+
+// bernoulli returns true with probability p, for p in [0, 1].
+func bernoulli(p *big.Rat) (bool, error) {
+	u, err := rand.Int(rand.Reader, p.Denom())
+	if err != nil {
+		return false, err
+	}
+	return u.Cmp(p.Num()) < 0, nil
+}
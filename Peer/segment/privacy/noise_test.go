@@ -0,0 +1,111 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package privacy
+
+import (
+	"math"
+	"testing"
+)
+
+const noiseSamples = 20000
+
+func moments[T int64 | float64](xs []T) (float64, float64) {
+	var sum, sumSquares float64
+	for _, x := range xs {
+		sum += float64(x)
+		sumSquares += float64(x) * float64(x)
+	}
+	mean := sum / float64(len(xs))
+	return mean, sumSquares/float64(len(xs)) - mean*mean
+}
+
+func assertMoments(t *testing.T, name string, mean, variance, expectedVariance float64) {
+	t.Helper()
+
+	// Loose bounds, well outside the sampling error for this many samples
+	if math.Abs(mean) > 5*math.Sqrt(expectedVariance/noiseSamples) {
+		t.Errorf("%v: mean %v is too far from 0", name, mean)
+	}
+	if math.Abs(variance-expectedVariance) > 0.1*expectedVariance {
+		t.Errorf("%v: variance %v is too far from %v", name, variance, expectedVariance)
+	}
+}
+
+func TestAddLaplace(t *testing.T) {
+	xs := make([]float64, noiseSamples)
+	if err := AddLaplace(xs, 0.5, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rounding onto the grid adds 2^-40 of the sensitivity to the scale for each value
+	scale := (1 + noiseSamples*math.Pow(2, -laplaceGridBits)) / 0.5
+	mean, variance := moments(xs)
+	assertMoments(t, "laplace", mean, variance, 2*scale*scale)
+
+	// With little noise, the values are kept to within the grid
+	ys := []float64{1e9, -3.25, 0.3, 1e-20, -1e300}
+	noisy := append([]float64{}, ys...)
+	if err := AddLaplace(noisy, 1e12, 1); err != nil {
+		t.Fatal(err)
+	}
+	for i, y := range ys {
+		if math.Abs(noisy[i]-y) > 1e-9*math.Max(1, math.Abs(y)) {
+			t.Errorf("%v became %v", y, noisy[i])
+		}
+	}
+
+	if err := AddLaplace([]float64{math.NaN()}, 1, 1); err == nil {
+		t.Error("NaN should be refused")
+	}
+	if err := AddLaplace(xs, 1, 0); err == nil {
+		t.Error("sensitivity of 0 should be refused")
+	}
+}
+
+func TestSampleGeometric(t *testing.T) {
+	xs, err := SampleGeometric(2.5, noiseSamples)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The variance of discrete Laplace noise is 2p/(1-p)^2 for p = exp(-1/scale)
+	p := math.Exp(-1 / 2.5)
+	mean, variance := moments(xs)
+	assertMoments(t, "geometric", mean, variance, 2*p/((1-p)*(1-p)))
+}
+
+func TestSampleDiscreteGaussian(t *testing.T) {
+	xs, err := SampleDiscreteGaussian(3, noiseSamples)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// For sigma this large, the variance is within 1e-10 of sigma^2
+	mean, variance := moments(xs)
+	assertMoments(t, "discrete gaussian", mean, variance, 9)
+
+	if _, err := SampleDiscreteGaussian(0, 1); err == nil {
+		t.Error("sigma of 0 should be refused")
+	}
+}
+
+func TestGaussianSigma(t *testing.T) {
+	sigma, err := GaussianSigma(0.5, 1e-5, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(sigma-2*math.Sqrt(2*math.Log(1.25e5))) > 1e-12 {
+		t.Errorf("unexpected sigma %v", sigma)
+	}
+
+	if _, err := GaussianSigma(1, 1e-5, 1); err == nil {
+		t.Error("epsilon of 1 should be refused")
+	}
+}
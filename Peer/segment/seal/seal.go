@@ -0,0 +1,203 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+// Package seal encrypts the arrays transmitted between peers end to end, so that only the peer
+// an array is sent to can read it, whatever proxies the connection passes through. Each peer has
+// a static X25519 key and knows the public keys of the other peers, from which each pair of
+// peers derives a key of their own.
+//
+// Each transfer is sealed with AES-256-GCM under a key derived from the pair key, a salt, and
+// the source, destination and array of the transfer. The salt is a MAC of the array under the
+// pair key, so that the key is never reused for different arrays, while an array is sealed the
+// same way each time it is requested and an interrupted transfer can be resumed. A sealed array
+// is the salt followed by the ciphertext and tag.
+package seal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/AUSTRAC/ftillite/Peer/segment/crypto"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Algorithm names the construction arrays are sealed with.
+const Algorithm = "x25519-hkdf-sha256-aes256gcm"
+
+const (
+	keyBytes  = 32
+	saltBytes = sha256.Size
+)
+
+var (
+	ErrNoKey             = errors.New("no transfer seal key is configured")
+	ErrInvalidPrivateKey = fmt.Errorf("transfer seal keys must be %v byte X25519 private keys", crypto.X25519KeyBytes)
+	ErrInvalidPublicKey  = fmt.Errorf("transfer seal keys of peers must be %v byte X25519 public keys", crypto.X25519KeyBytes)
+	ErrUnknownPeer       = errors.New("no transfer seal key is known for the peer")
+	ErrUnsupported       = errors.New("unsupported seal algorithm")
+	ErrOpen              = errors.New("unable to open sealed array")
+)
+
+var pairLabel = []byte("ftillite transfer seal")
+
+// LoadPrivateKey returns the X25519 private key given as hex, or if that is empty, read from
+// the file at path. The file may hold either the raw key or its hex encoding.
+func LoadPrivateKey(hexKey string, path string) ([]byte, error) {
+	if hexKey == "" && path == "" {
+		return nil, ErrNoKey
+	}
+
+	if hexKey == "" {
+		bs, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read transfer seal key: %w", err)
+		}
+		if len(bs) == crypto.X25519KeyBytes {
+			return bs, nil
+		}
+		hexKey = strings.TrimSpace(string(bs))
+	}
+
+	key, err := hex.DecodeString(hexKey)
+	if err != nil || len(key) != crypto.X25519KeyBytes {
+		return nil, ErrInvalidPrivateKey
+	}
+	return key, nil
+}
+
+// ParsePeerKeys parses the public keys of peers, given as a comma separated list of
+// <node ID>=<hex public key>.
+func ParsePeerKeys(s string) (map[string][]byte, error) {
+	peers := make(map[string][]byte)
+	if strings.TrimSpace(s) == "" {
+		return peers, nil
+	}
+
+	for _, entry := range strings.Split(s, ",") {
+		nodeID, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || nodeID == "" {
+			return nil, fmt.Errorf("invalid transfer seal key %q, expected <node ID>=<public key>", entry)
+		}
+		key, err := hex.DecodeString(value)
+		if err != nil || len(key) != crypto.X25519KeyBytes {
+			return nil, fmt.Errorf("%w: node %v", ErrInvalidPublicKey, nodeID)
+		}
+		peers[nodeID] = key
+	}
+	return peers, nil
+}
+
+// Keys seals the arrays a peer transmits and opens the arrays it receives.
+type Keys struct {
+	nodeID string
+	pairs  map[string][]byte
+}
+
+// New derives the pair keys of the peer with the given node ID and private key with each of the
+// peers in peerKeys, which maps node IDs to public keys.
+func New(nodeID string, privateKey []byte, peerKeys map[string][]byte) (*Keys, error) {
+	if len(privateKey) != crypto.X25519KeyBytes {
+		return nil, ErrInvalidPrivateKey
+	}
+
+	pairs := make(map[string][]byte, len(peerKeys))
+	for peer, publicKey := range peerKeys {
+		if len(publicKey) != crypto.X25519KeyBytes {
+			return nil, fmt.Errorf("%w: node %v", ErrInvalidPublicKey, peer)
+		}
+		derived, err := crypto.X25519Derive(privateKey, [][]byte{publicKey}, keyBytes, pairLabel)
+		if err != nil {
+			return nil, fmt.Errorf("unable to derive the transfer seal key of node %v: %w", peer, err)
+		}
+		pairs[peer] = derived[0]
+	}
+	return &Keys{nodeID, pairs}, nil
+}
+
+func (k *Keys) pair(nodeID string) ([]byte, error) {
+	key, ok := k.pairs[nodeID]
+	if !ok || nodeID == "" {
+		return nil, fmt.Errorf("%w: node %q", ErrUnknownPeer, nodeID)
+	}
+	return key, nil
+}
+
+// info binds a transfer key to the direction of the transfer and the array transferred.
+func info(source string, destination string, transfer string) []byte {
+	return []byte(fmt.Sprintf("%s\x00%s\x00%s\x00%s", pairLabel, source, destination, transfer))
+}
+
+func transferAEAD(pair []byte, salt []byte, info []byte) (cipher.AEAD, error) {
+	key := make([]byte, keyBytes)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, pair, salt, info), key); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Seal seals b for the destination node, where transfer identifies the array.
+func (k *Keys) Seal(destination string, transfer string, b []byte) ([]byte, error) {
+	pair, err := k.pair(destination)
+	if err != nil {
+		return nil, err
+	}
+	info := info(k.nodeID, destination, transfer)
+
+	mac := hmac.New(sha256.New, pair)
+	mac.Write(info)
+	mac.Write(b)
+	salt := mac.Sum(nil)
+
+	aead, err := transferAEAD(pair, salt, info)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each key seals a single array, so the nonce can be fixed
+	nonce := make([]byte, aead.NonceSize())
+	return aead.Seal(salt, nonce, b, info), nil
+}
+
+// Open opens an array sealed with algorithm by the source node, where transfer identifies the
+// array.
+func (k *Keys) Open(algorithm string, source string, transfer string, sealed []byte) ([]byte, error) {
+	if algorithm != Algorithm {
+		return nil, fmt.Errorf("%w %q", ErrUnsupported, algorithm)
+	}
+	pair, err := k.pair(source)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < saltBytes {
+		return nil, ErrOpen
+	}
+	info := info(source, k.nodeID, transfer)
+
+	aead, err := transferAEAD(pair, sealed[:saltBytes], info)
+	if err != nil {
+		return nil, err
+	}
+	b, err := aead.Open(nil, make([]byte, aead.NonceSize()), sealed[saltBytes:], info)
+	if err != nil {
+		return nil, ErrOpen
+	}
+	return b, nil
+}
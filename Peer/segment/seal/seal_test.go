@@ -0,0 +1,176 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package seal
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AUSTRAC/ftillite/Peer/segment/crypto"
+)
+
+// newTestKeys returns the keys of peers with the given node IDs, each knowing all the others.
+func newTestKeys(t *testing.T, nodeIDs ...string) map[string]*Keys {
+	t.Helper()
+
+	private := make(map[string][]byte)
+	public := make(map[string][]byte)
+	for _, nodeID := range nodeIDs {
+		k, err := crypto.X25519Keygen()
+		if err != nil {
+			t.Fatal(err)
+		}
+		private[nodeID] = k
+		public[nodeID], err = crypto.X25519PublicKey(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keys := make(map[string]*Keys)
+	for _, nodeID := range nodeIDs {
+		peers := make(map[string][]byte)
+		for peer, k := range public {
+			if peer != nodeID {
+				peers[peer] = k
+			}
+		}
+		k, err := New(nodeID, private[nodeID], peers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[nodeID] = k
+	}
+	return keys
+}
+
+func TestKeys_SealOpen(t *testing.T) {
+	keys := newTestKeys(t, "1", "2", "3")
+	array := bytes.Repeat([]byte("array"), 100)
+
+	sealed, err := keys["1"].Seal("2", "42/0", array)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, []byte("array")) {
+		t.Error("the sealed array contains the array")
+	}
+
+	b, err := keys["2"].Open(Algorithm, "1", "42/0", sealed)
+	if err != nil || !bytes.Equal(b, array) {
+		t.Fatalf("unexpected array %q, %v", b, err)
+	}
+
+	// Arrays are sealed the same way each time, so that transfers can be resumed
+	again, err := keys["1"].Seal("2", "42/0", array)
+	if err != nil || !bytes.Equal(again, sealed) {
+		t.Errorf("expected the array to be sealed the same way again, %v", err)
+	}
+	other, err := keys["1"].Seal("2", "42/0", append([]byte{0}, array[1:]...))
+	if err != nil || bytes.Equal(other[:saltBytes], sealed[:saltBytes]) {
+		t.Errorf("expected different arrays to be sealed under different keys, %v", err)
+	}
+
+	empty, err := keys["1"].Seal("2", "43/0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := keys["2"].Open(Algorithm, "1", "43/0", empty); err != nil || len(b) != 0 {
+		t.Errorf("unexpected empty array %q, %v", b, err)
+	}
+}
+
+func TestKeys_OpenRejects(t *testing.T) {
+	keys := newTestKeys(t, "1", "2", "3")
+
+	sealed, err := keys["1"].Seal("2", "42/0", []byte("array"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := append([]byte{}, sealed...)
+	tampered[len(tampered)-1] ^= 1
+
+	for name, tc := range map[string]struct {
+		keys     *Keys
+		source   string
+		transfer string
+		sealed   []byte
+	}{
+		"another destination": {keys["3"], "1", "42/0", sealed},
+		"another source":      {keys["2"], "3", "42/0", sealed},
+		"another array":       {keys["2"], "1", "42/1", sealed},
+		"reflected":           {keys["1"], "2", "42/0", sealed},
+		"tampered":            {keys["2"], "1", "42/0", tampered},
+		"truncated":           {keys["2"], "1", "42/0", sealed[:saltBytes-1]},
+	} {
+		if _, err := tc.keys.Open(Algorithm, tc.source, tc.transfer, tc.sealed); !errors.Is(err, ErrOpen) {
+			t.Errorf("%v: expected the array not to open, got %v", name, err)
+		}
+	}
+
+	if _, err := keys["2"].Open("none", "1", "42/0", sealed); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected the algorithm to be unsupported, got %v", err)
+	}
+	if _, err := keys["1"].Seal("4", "42/0", []byte("array")); !errors.Is(err, ErrUnknownPeer) {
+		t.Errorf("expected node 4 to be unknown, got %v", err)
+	}
+	if _, err := keys["2"].Open(Algorithm, "", "42/0", sealed); !errors.Is(err, ErrUnknownPeer) {
+		t.Errorf("expected an unnamed peer to be unknown, got %v", err)
+	}
+}
+
+func TestLoadPrivateKey(t *testing.T) {
+	key, err := crypto.X25519Keygen()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k, err := LoadPrivateKey(hex.EncodeToString(key), "")
+	if err != nil || !bytes.Equal(k, key) {
+		t.Fatalf("unexpected key %x, %v", k, err)
+	}
+
+	raw := filepath.Join(t.TempDir(), "raw")
+	if err := os.WriteFile(raw, key, 0600); err != nil {
+		t.Fatal(err)
+	}
+	k, err = LoadPrivateKey("", raw)
+	if err != nil || !bytes.Equal(k, key) {
+		t.Fatalf("unexpected key %x, %v", k, err)
+	}
+
+	if _, err := LoadPrivateKey("abcd", ""); !errors.Is(err, ErrInvalidPrivateKey) {
+		t.Errorf("expected a short key to be rejected, got %v", err)
+	}
+	if _, err := LoadPrivateKey("", ""); !errors.Is(err, ErrNoKey) {
+		t.Errorf("expected no key to be configured, got %v", err)
+	}
+}
+
+func TestParsePeerKeys(t *testing.T) {
+	a := bytes.Repeat([]byte{9}, crypto.X25519KeyBytes)
+
+	peers, err := ParsePeerKeys("1=" + hex.EncodeToString(a) + ", 2=" + hex.EncodeToString(a))
+	if err != nil || len(peers) != 2 || !bytes.Equal(peers["2"], a) {
+		t.Fatalf("unexpected peers %v, %v", peers, err)
+	}
+	if peers, err := ParsePeerKeys(" "); err != nil || len(peers) != 0 {
+		t.Errorf("unexpected peers %v, %v", peers, err)
+	}
+	for _, s := range []string{"1", "=" + hex.EncodeToString(a), "1=abcd", "1=zz"} {
+		if _, err := ParsePeerKeys(s); err == nil {
+			t.Errorf("expected %q to be rejected", s)
+		}
+	}
+}
@@ -11,6 +11,7 @@ package segment
 
 import (
 	"bytes"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -26,7 +27,11 @@ import (
 	"github.com/streadway/amqp"
 
 	"github.com/AUSTRAC/ftillite/Peer/segment/commands"
+	"github.com/AUSTRAC/ftillite/Peer/segment/grant"
+	"github.com/AUSTRAC/ftillite/Peer/segment/keystore"
 	fthttp "github.com/AUSTRAC/ftillite/Peer/segment/net/http"
+	"github.com/AUSTRAC/ftillite/Peer/segment/privacy"
+	"github.com/AUSTRAC/ftillite/Peer/segment/seal"
 	"github.com/AUSTRAC/ftillite/Peer/segment/types"
 	"github.com/AUSTRAC/ftillite/Peer/segment/variables"
 )
@@ -40,6 +45,11 @@ type Segment struct {
 	dbConnStr        string
 	dbChunkSize      int
 	gpuEnabled       bool
+	ed25519Backend   types.Ed25519Backend
+	keystore         *keystore.Keystore
+	privacy          *privacy.Accountant
+	grants           *grant.Authority
+	seals            *seal.Keys
 
 	inSession       bool
 	variables       variables.Store
@@ -60,26 +70,75 @@ type CommandTiming struct {
 	EndTime   time.Time
 }
 
-var GPUHasBeenInitialized = false
+func NewSegment(options Options, dbType string, dbConnStr string) (*Segment, error) {
+	ed25519Backend := options.Ed25519Backend
+	if ed25519Backend == nil {
+		if options.EnableGPU {
+			var err error
+			ed25519Backend, err = types.NewGPUEd25519Backend()
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			ed25519Backend = types.NewCPUEd25519Backend()
+		}
+	}
 
-var ErrEd25519Unavailable = errors.New("the Ed25519 is not available as GPU is not enabled")
+	var ks *keystore.Keystore
+	if options.KeystoreMasterKey != nil {
+		var err error
+		ks, err = keystore.New(dbType, dbConnStr, options.KeystoreMasterKey)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-func NewSegment(options Options, dbType string, dbConnStr string) (*Segment, error) {
-	if options.EnableGPU && !GPUHasBeenInitialized {
-		err := types.InitializeGPU()
+	var accountant *privacy.Accountant
+	if options.PrivacyBudgetEpsilon != 0 {
+		var err error
+		accountant, err = privacy.NewAccountant(options.PrivacyBudgetEpsilon, options.PrivacyBudgetDelta)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var grants *grant.Authority
+	if options.CoordinatorGrantKey != nil {
+		var err error
+		grants, err = grant.NewAuthority(options.CoordinatorGrantKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var seals *seal.Keys
+	if options.TransferSealKey != nil {
+		var err error
+		seals, err = seal.New(options.NodeIDString, options.TransferSealKey, options.TransferSealPeers)
 		if err != nil {
 			return nil, err
 		}
-		GPUHasBeenInitialized = true
 	}
 
 	incomingQueue := fmt.Sprintf("%v%v", options.RabbitMQIncomingPrefix, options.NodeIDString)
 	outgoingQueue := fmt.Sprintf("%v%v", options.RabbitMQOutgoingPrefix, options.NodeIDString)
 
+	var tlsConfig *tls.Config
+	if options.TLSCertFile != "" || options.TLSKeyFile != "" || options.TLSCAFile != "" {
+		var err error
+		tlsConfig, err = fthttp.LoadMutualTLSConfig(options.TLSCertFile, options.TLSKeyFile, options.TLSCAFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	httpListener, err := net.Listen("tcp", options.Address)
 	if err != nil {
 		return nil, fmt.Errorf("unable to listen for incoming connections: %w", err)
 	}
+	if tlsConfig != nil {
+		httpListener = tls.NewListener(httpListener, tlsConfig)
+	}
 
 	node := types.Node{
 		NodeIDString: options.NodeIDString,
@@ -88,7 +147,16 @@ func NewSegment(options Options, dbType string, dbConnStr string) (*Segment, err
 		Port:         options.ExternalPort,
 	}
 
-	segmentClient := fthttp.NewSegmentClient(int(node.NodeID()))
+	var segmentClient *fthttp.SegmentClient
+	if tlsConfig != nil {
+		segmentClient = fthttp.NewMutualTLSSegmentClient(int(node.NodeID()), tlsConfig)
+	} else {
+		segmentClient = fthttp.NewSegmentClient(int(node.NodeID()))
+	}
+
+	compression := fthttp.Compression{Threshold: options.CompressionThreshold, Peers: options.CompressionPeers}
+	segmentClient.SetCompression(compression)
+	segmentClient.SetDownloadBufferSize(options.DownloadBufferSize)
 
 	segment := &Segment{
 		&node,
@@ -99,6 +167,11 @@ func NewSegment(options Options, dbType string, dbConnStr string) (*Segment, err
 		dbConnStr,
 		options.DbChunkSize,
 		options.EnableGPU,
+		ed25519Backend,
+		ks,
+		accountant,
+		grants,
+		seals,
 		false,
 		variables.NewStore(),
 		make(map[string]commands.CommandFunc),
@@ -113,7 +186,7 @@ func NewSegment(options Options, dbType string, dbConnStr string) (*Segment, err
 
 	httpServer := &http.Server{
 		Handler: fthttp.NewAnonHandler(
-			fthttp.SegmentEndpoints(segment),
+			fthttp.SegmentEndpoints(segment, compression),
 		),
 	}
 
@@ -141,6 +214,65 @@ func (s *Segment) Log(format string, args ...any) {
 func (s *Segment) IsGPUAvailable() bool {
 	return s.gpuEnabled
 }
+func (s *Segment) Ed25519Backend() types.Ed25519Backend {
+	return s.ed25519Backend
+}
+func (s *Segment) Keystore() (*keystore.Keystore, error) {
+	if s.keystore == nil {
+		return nil, keystore.ErrNoMasterKey
+	}
+	return s.keystore, nil
+}
+func (s *Segment) PrivacyAccountant() (*privacy.Accountant, error) {
+	if s.privacy == nil {
+		return nil, privacy.ErrNoBudget
+	}
+	return s.privacy, nil
+}
+func (s *Segment) IssueTransferGrant(nodeID string, h variables.Handle) string {
+	if s.grants == nil {
+		return ""
+	}
+	return s.grants.Issue(s.node.NodeIDString, string(h), nodeID)
+}
+
+// AuthoriseTransfer checks that the grant allows the array at handle to be sent from this peer
+// to the destination node. Every transfer is allowed when no coordinator grant key is
+// configured. Rejected transfers are logged for audit.
+func (s *Segment) AuthoriseTransfer(token string, handle string, destination string) error {
+	if s.grants == nil {
+		return nil
+	}
+
+	g, err := s.grants.Verify(token)
+	if err == nil {
+		err = g.Allows(s.node.NodeIDString, handle, destination)
+	}
+	if err != nil {
+		s.Log("AUDIT: rejected transfer of handle %v to node %q: %v", handle, destination, err)
+		return err
+	}
+	return nil
+}
+
+// SealTransfer seals the array at handle and index for the destination node, so that only that
+// node can open it. Arrays are sent as they are when no transfer seal key is configured.
+func (s *Segment) SealTransfer(destination string, handle string, index int, b []byte) ([]byte, string, error) {
+	if s.seals == nil {
+		return b, "", nil
+	}
+
+	sealed, err := s.seals.Seal(destination, transferName(handle, index), b)
+	if err != nil {
+		return nil, "", err
+	}
+	return sealed, seal.Algorithm, nil
+}
+
+// transferName identifies the array at handle and index to the keys it is sealed with.
+func transferName(handle string, index int) string {
+	return fmt.Sprintf("%v/%v", handle, index)
+}
 func (s *Segment) Variables() variables.Store {
 	return s.variables
 }
@@ -155,10 +287,19 @@ func (s *Segment) Node() *types.Node {
 }
 func (s *Segment) SetPeerAddress(nodeID string, addr string) {
 	s.segmentNodes[nodeID] = addr
+	s.segmentClient.SetPeerIdentity(addr, nodeID)
 }
 func (s *Segment) GetPeerAddress(nodeID string) string {
 	return s.segmentNodes[nodeID]
 }
+func (s *Segment) peerNodeID(addr string) string {
+	for nodeID, a := range s.segmentNodes {
+		if a == addr {
+			return nodeID
+		}
+	}
+	return ""
+}
 func (s *Segment) SaveDestination() string {
 	return s.saveDestination
 }
@@ -395,11 +536,20 @@ func (s *Segment) DeleteVariable(h variables.Handle) {
 	s.variables.Delete(h)
 }
 
-func (s *Segment) RequestTransferBytes(nodeAddress string, handle string, newHandle string, dtype string, opcode string) error {
-	return s.segmentClient.RequestTransmission(nodeAddress, handle, newHandle, dtype, opcode)
+func (s *Segment) RequestTransferBytes(nodeAddress string, handle string, newHandle string, dtype string, opcode string, grant string) error {
+	// Check the grant before the peer is asked to pull the array, so that an unauthorised
+	// transmit fails here rather than at the peer
+	destination := s.peerNodeID(nodeAddress)
+	if destination == "" && s.grants != nil {
+		return fmt.Errorf("no node is known at %v", nodeAddress)
+	}
+	if err := s.AuthoriseTransfer(grant, handle, destination); err != nil {
+		return err
+	}
+	return s.segmentClient.RequestTransmission(nodeAddress, handle, newHandle, dtype, opcode, grant)
 }
 
-func (s *Segment) TransferBytes(nodeAddress string, handle string, newHandle string, dtype string, opcode string) error {
+func (s *Segment) TransferBytes(nodeAddress string, handle string, newHandle string, dtype string, opcode string, grant string) error {
 	// Read the data and assign to variable
 
 	// if dtype indicates listmap need to break into multiple requests
@@ -416,9 +566,26 @@ func (s *Segment) TransferBytes(nodeAddress string, handle string, newHandle str
 
 	var v types.TypeVal
 
+	// Arrays must be sealed by the peer they are read from when a transfer seal key is configured
+	var source string
+	if s.seals != nil {
+		source = s.peerNodeID(nodeAddress)
+		if source == "" {
+			return fmt.Errorf("no node is known at %v", nodeAddress)
+		}
+	}
+
 	for index, tc := range typeCodes {
+		var open func(algorithm string, sealed []byte) ([]byte, error)
+		if s.seals != nil {
+			transfer := transferName(handle, index)
+			open = func(algorithm string, sealed []byte) ([]byte, error) {
+				return s.seals.Open(algorithm, source, transfer, sealed)
+			}
+		}
+
 		var writer bytes.Buffer
-		arraylength, err := s.segmentClient.ReceiveTransmission(nodeAddress, handle, tc, index, &writer)
+		arraylength, err := s.segmentClient.ReceiveTransmission(nodeAddress, handle, tc, index, grant, open, &writer)
 		if err != nil {
 			return err
 		}
@@ -434,7 +601,7 @@ func (s *Segment) TransferBytes(nodeAddress string, handle string, newHandle str
 			panic("ReceiveTransmission has a different BytearrayArray width than the type code")
 		}
 
-		xs, err := types.FromBytes(tc, rawData)
+		xs, err := types.FromBytesWithBackend(tc, rawData, s.ed25519Backend)
 		if err != nil {
 			return err
 		}
@@ -22,8 +22,6 @@ import (
 )
 
 func BenchmarkEd25519ReduceSum(b *testing.B) {
-	types.SkipIfGPUUnavailable(b)
-
 	log.SetOutput(io.Discard)
 
 	for indexFactor := int64(7); indexFactor > 0; indexFactor-- {
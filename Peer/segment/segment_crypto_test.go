@@ -10,9 +10,12 @@
 package segment
 
 import (
+	"encoding/hex"
+	"math"
 	"testing"
 
 	"github.com/AUSTRAC/ftillite/Peer/segment/commands"
+	"github.com/AUSTRAC/ftillite/Peer/segment/crypto"
 	"github.com/AUSTRAC/ftillite/Peer/segment/types"
 	"github.com/AUSTRAC/ftillite/Peer/segment/variables"
 )
@@ -37,6 +40,101 @@ func TestCommandSHA3_256(t *testing.T) {
 	))
 }
 
+func TestCommand_Hash(t *testing.T) {
+	s := NewTestSegment()
+
+	s.SetVariable("1", types.NewFTBytearrayArrayOrPanic(3, []byte("abc"), []byte("abc")))
+	s.SetVariable("2", types.NewFTBytearrayArrayOrPanic(4, []byte("salt")))
+	s.SetVariable("3", types.NewFTBytearrayArrayOrPanic(4, []byte("salt"), []byte("pepr")))
+
+	AssertCommandResponse(t, s, commands.CommandHash, []string{"4", "shake256", "48", "1"}, "array b48 4")
+
+	// A single salt applies to every element, and per element salts separate equal data
+	AssertCommand(t, s, commands.CommandHash, "5", "sha256", "8", "1", "2")
+	AssertValue(t, s, "5", types.NewFTBytearrayArrayOrPanic(
+		8,
+		[]byte{0x36, 0x81, 0x09, 0x99, 0x18, 0xbe, 0x28, 0xc9},
+		[]byte{0x36, 0x81, 0x09, 0x99, 0x18, 0xbe, 0x28, 0xc9},
+	))
+
+	AssertCommand(t, s, commands.CommandHash, "6", "sha256", "8", "1", "3")
+	AssertValueNot(t, s, "6", AssertVariable[*types.FTBytearrayArray](t, s, "5"))
+
+	AssertCommandFailure(t, s, commands.CommandHash, []string{"7", "blake2b", "65", "1"}, "width must be between 1 and 64")
+	AssertCommandFailure(t, s, commands.CommandHash, []string{"7", "md5", "16", "1"}, "unknown hash algorithm md5")
+}
+
+func TestCommand_HashToCurve_HashToScalar(t *testing.T) {
+	s := NewTestSegment()
+
+	s.SetVariable("1", types.NewFTBytearrayArrayOrPanic(3, []byte("abc"), []byte("xyz")))
+
+	AssertCommandResponse(t, s, commands.CommandHashToCurve, []string{"2", "1", "QUUX-V01-CS02-with-edwards25519_XMD:SHA-512_ELL2_RO_"}, "array E 2")
+
+	points, err := AssertVariable[*types.Ed25519Array](t, s, "2").Points()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The compressed encoding of the RFC 9380 test vector for "abc"
+	if hex.EncodeToString(points[0].Bytes()) != "31558a26887f23fb8218f143e69d5f0af2e7831130bd5b432ef23883b895839a" {
+		t.Errorf("unexpected point %x", points[0].Bytes())
+	}
+
+	AssertCommandResponse(t, s, commands.CommandHashToScalar, []string{"3", "1", "accounts"}, "array I 3")
+	AssertCommand(t, s, commands.CommandHashToScalar, "4", "1", "accounts")
+	AssertValue(t, s, "4", AssertVariable[*types.FTEd25519IntArray](t, s, "3"))
+
+	AssertCommand(t, s, commands.CommandHashToScalar, "5", "1", "other")
+	AssertValueNot(t, s, "5", AssertVariable[*types.FTEd25519IntArray](t, s, "3"))
+
+	// DSTs which would be cut short where commands are split on "__" are refused
+	_, args, _, err := parseMessage([]byte(`{"command": "command_hash_to_scalar 6 1 accounts__v2", "response_required": "True"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertCommandFailure(t, s, commands.CommandHashToScalar, args, `dst must be the final argument, non-empty and without "__"`)
+	AssertCommandFailure(t, s, commands.CommandHashToCurve, []string{"6", "1", "accounts__v2"}, `dst must be the final argument, non-empty and without "__"`)
+}
+
+func TestCommand_HMAC_KMAC(t *testing.T) {
+	s := NewTestSegment()
+
+	s.SetVariable("1", types.NewFTBytearrayArrayOrPanic(4, []byte("Jefe")))
+	s.SetVariable("2", types.NewFTBytearrayArrayOrPanic(
+		28,
+		[]byte("what do ya want for nothing?"),
+		[]byte("what do ya want for nothing!"),
+	))
+
+	AssertCommand(t, s, commands.CommandHMACSHA256, "3", "2", "1", "8")
+	AssertValue(t, s, "3", types.NewFTBytearrayArrayOrPanic(
+		8,
+		[]byte{0x5b, 0xdc, 0xc1, 0x46, 0xbf, 0x60, 0x75, 0x4e},
+		AssertVariable[*types.FTBytearrayArray](t, s, "3").Values()[1],
+	))
+
+	for _, name := range []string{commands.CommandHMACSHA256, commands.CommandHMACSHA3256, commands.CommandKMAC256} {
+		// The same data and key give the same pseudonym, and a label changes it
+		AssertCommand(t, s, name, "4", "2", "1", "16")
+		AssertCommand(t, s, name, "5", "2", "1", "16")
+		AssertCommand(t, s, name, "6", "2", "1", "16", "accounts")
+
+		AssertValue(t, s, "5", AssertVariable[*types.FTBytearrayArray](t, s, "4"))
+		AssertValueNot(t, s, "6", AssertVariable[*types.FTBytearrayArray](t, s, "4"))
+	}
+
+	AssertCommand(t, s, commands.CommandKMAC256, "7", "2", "1", "100")
+	AssertCommandFailure(t, s, commands.CommandHMACSHA256, []string{"8", "2", "1", "33"}, "width must be between 1 and 32")
+
+	// Commands are split on "__", so labels which contain it are refused rather than cut short
+	_, args, _, err := parseMessage([]byte(`{"command": "command_hmac_sha256 8 2 1 16 acct__v2", "response_required": "True"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertCommandFailure(t, s, commands.CommandHMACSHA256, args, `label must be the final argument, non-empty and without "__"`)
+	AssertCommandFailure(t, s, commands.CommandHMACSHA256, []string{"8", "2", "1", "16", "acct__v2"}, `label must be the final argument, non-empty and without "__"`)
+}
+
 func TestCommand_AES256_Encrypt_Decrypt(t *testing.T) {
 	s := NewTestSegment()
 
@@ -61,6 +159,74 @@ func TestCommand_AES256_Encrypt_Decrypt(t *testing.T) {
 	AssertValue(t, s, "6", original)
 }
 
+func TestCommand_AES256GCM_Encrypt_Decrypt(t *testing.T) {
+	s := NewTestSegment()
+
+	AssertCommand(t, s, commands.CommandNewilist, "1", "1") // count
+	AssertCommand(t, s, commands.CommandRandomArray, "2", "b32", "1")
+
+	original := types.NewFTBytearrayArrayOrPanic(
+		5,
+		[]byte{0, 1, 2, 3, 4},
+		[]byte{5, 6, 7, 8, 9},
+		[]byte{10, 11, 12, 13, 14},
+	)
+	s.SetVariable("3", original)
+
+	AssertCommand(t, s, commands.CommandAES256GCMEncrypt, "4", "5", "6", "3", "2")
+	AssertValueNot(t, s, "4", original)
+
+	AssertCommand(t, s, commands.CommandAES256GCMDecrypt, "7", "8", "4", "5", "6", "2")
+	AssertValue(t, s, "7", original)
+	AssertValue(t, s, "8", types.NewFTIntegerArray(1, 1, 1))
+
+	// Tamper with the second ciphertext and the third tag
+	ciphertexts := AssertVariable[*types.FTBytearrayArray](t, s, "4")
+	ciphertexts.Values()[1][0] ^= 1
+	tags := AssertVariable[*types.FTBytearrayArray](t, s, "6")
+	tags.Values()[2][0] ^= 1
+
+	AssertCommand(t, s, commands.CommandAES256GCMDecrypt, "9", "10", "4", "5", "6", "2")
+	AssertValue(t, s, "10", types.NewFTIntegerArray(1, 0, 0))
+	AssertValue(t, s, "9", types.NewFTBytearrayArrayOrPanic(
+		5,
+		[]byte{0, 1, 2, 3, 4},
+		[]byte{0, 0, 0, 0, 0},
+		[]byte{0, 0, 0, 0, 0},
+	))
+}
+
+func TestCommand_AES256GCM_InvalidNonceWidth(t *testing.T) {
+	s := NewTestSegment()
+
+	AssertCommand(t, s, commands.CommandNewilist, "1", "1")
+	AssertCommand(t, s, commands.CommandRandomArray, "2", "b32", "1")
+	AssertCommand(t, s, commands.CommandRandomArray, "3", "b8", "1")
+	AssertCommand(t, s, commands.CommandRandomArray, "4", "b16", "1")
+
+	AssertCommandFailure(t, s, commands.CommandAES256GCMDecrypt, []string{"5", "6", "3", "3", "4", "2"}, "3 must be b12")
+}
+
+func TestCommand_AES256CTR_Encrypt_Decrypt(t *testing.T) {
+	s := NewTestSegment()
+
+	AssertCommand(t, s, commands.CommandNewilist, "1", "1") // count
+	AssertCommand(t, s, commands.CommandRandomArray, "2", "b32", "1")
+
+	original := types.NewFTBytearrayArrayOrPanic(
+		20,
+		[]byte("twenty bytes of data"),
+		[]byte("and twenty bytes too"),
+	)
+	s.SetVariable("3", original)
+
+	AssertCommand(t, s, commands.CommandAES256CTREncrypt, "4", "5", "3", "2")
+	AssertValueNot(t, s, "4", original)
+
+	AssertCommand(t, s, commands.CommandAES256CTRDecrypt, "6", "4", "5", "2")
+	AssertValue(t, s, "6", original)
+}
+
 func TestCommand_Grain128aeadv2(t *testing.T) {
 	s := NewTestSegment()
 	PrintVariableStore(t, s)
@@ -74,6 +240,64 @@ func TestCommand_Grain128aeadv2(t *testing.T) {
 	AssertCommand(t, s, commands.CommandGrain128aeadv2, "6", "2", "3", "4", "5")
 }
 
+func TestCommand_Grain128aeadv2_Seal_Open(t *testing.T) {
+	s := NewTestSegment()
+
+	AssertCommand(t, s, commands.CommandNewilist, "1", "1")
+	AssertCommand(t, s, commands.CommandRandomArray, "2", "b16", "1") // key
+
+	original := types.NewFTBytearrayArrayOrPanic(
+		6,
+		[]byte("first."),
+		[]byte("second"),
+		[]byte("third."),
+	)
+	s.SetVariable("3", original)
+	s.SetVariable("4", types.NewFTBytearrayArrayOrPanic(
+		2,
+		[]byte("a1"),
+		[]byte("a2"),
+		[]byte("a3"),
+	))
+
+	AssertCommand(t, s, commands.CommandGrain128aeadv2Seal, "5", "6", "7", "3", "4", "2")
+	AssertValueNot(t, s, "5", original)
+
+	AssertCommand(t, s, commands.CommandGrain128aeadv2Open, "8", "9", "5", "6", "7", "4", "2")
+	AssertValue(t, s, "8", original)
+	AssertValue(t, s, "9", types.NewFTIntegerArray(1, 1, 1))
+
+	// Changing the associated data of an element fails its authentication
+	s.SetVariable("10", types.NewFTBytearrayArrayOrPanic(
+		2,
+		[]byte("a1"),
+		[]byte("a2"),
+		[]byte("a4"),
+	))
+
+	AssertCommand(t, s, commands.CommandGrain128aeadv2Open, "11", "12", "5", "6", "7", "10", "2")
+	AssertValue(t, s, "12", types.NewFTIntegerArray(1, 1, 0))
+}
+
+func TestCommand_Grain128aeadv2_Seal_SingletonAssociatedData(t *testing.T) {
+	s := NewTestSegment()
+
+	AssertCommand(t, s, commands.CommandNewilist, "1", "1")
+	AssertCommand(t, s, commands.CommandRandomArray, "2", "b16", "1") // key
+
+	original := types.NewFTBytearrayArrayOrPanic(3, []byte("abc"), []byte("def"))
+	s.SetVariable("3", original)
+	s.SetVariable("4", types.NewFTBytearrayArrayOrPanic(5, []byte("table")))
+
+	AssertCommand(t, s, commands.CommandGrain128aeadv2Seal, "5", "6", "7", "3", "4", "2")
+	AssertCommand(t, s, commands.CommandGrain128aeadv2Open, "8", "9", "5", "6", "7", "4", "2")
+	AssertValue(t, s, "8", original)
+	AssertValue(t, s, "9", types.NewFTIntegerArray(1, 1))
+
+	s.SetVariable("10", types.NewFTBytearrayArrayOrPanic(2, []byte("a1"), []byte("a2"), []byte("a3")))
+	AssertCommandFailure(t, s, commands.CommandGrain128aeadv2Seal, []string{"11", "12", "13", "3", "10", "2"}, "associated data must be a singleton or match the length of the data")
+}
+
 func TestCommand_ECDSA256Keygen(t *testing.T) {
 	s := NewTestSegment()
 	PrintVariableStore(t, s)
@@ -170,3 +394,356 @@ func TestCommand_RSA3072Encrypt_Decrypt(t *testing.T) {
 	// Assert decrypted data equal to original data
 	AssertValue(t, s, "5", original)
 }
+
+func TestCommand_RSAOAEP_PSS(t *testing.T) {
+	s := NewTestSegment()
+
+	AssertCommand(t, s, commands.CommandRSAKeygen, "1", "2048")
+	AssertCommand(t, s, commands.CommandRSAPublicKey, "2", "1")
+
+	key := AssertVariable[*types.FTBytearrayArray](t, s, "2")
+	if key.Width() != int64(crypto.RSAPublicKeyBytearraySizeFor(2048)) {
+		t.Fatalf("unexpected public key width %v", key.Width())
+	}
+
+	original := types.NewFTBytearrayArrayOrPanic(4, []byte("abcd"), []byte("efgh"))
+	s.SetVariable("3", original)
+
+	AssertCommand(t, s, commands.CommandRSAOAEPEncrypt, "4", "3", "2")
+	AssertValueNot(t, s, "4", original)
+
+	AssertCommand(t, s, commands.CommandRSAOAEPDecrypt, "5", "4", "1")
+	AssertValue(t, s, "5", original)
+
+	AssertCommand(t, s, commands.CommandRSAPSSSign, "6", "3", "1")
+	AssertCommand(t, s, commands.CommandRSAPSSVerify, "7", "3", "6", "2")
+	AssertValue(t, s, "7", types.NewFTIntegerArray(1, 1))
+
+	// Signatures checked against the other element do not verify
+	s.SetVariable("8", types.NewFTBytearrayArrayOrPanic(4, []byte("efgh"), []byte("abcd")))
+
+	AssertCommand(t, s, commands.CommandRSAPSSVerify, "7", "8", "6", "2")
+	AssertValue(t, s, "7", types.NewFTIntegerArray(0, 0))
+
+	AssertCommandFailure(t, s, commands.CommandRSAKeygen, []string{"9", "1024"}, "key size must be one of")
+}
+
+func TestCommand_Paillier(t *testing.T) {
+	s := NewTestSegment()
+
+	AssertCommand(t, s, commands.CommandPaillierKeygen, "1", "2048")
+	AssertCommand(t, s, commands.CommandPaillierPublicKey, "2", "1")
+
+	s.SetVariable("3", types.NewFTIntegerArray(10, 20, 30, -5))
+	AssertCommandResponse(t, s, commands.CommandPaillierEncrypt, []string{"4", "3", "2"}, "array P512 4")
+
+	// Sum the encrypted values by key into a new array of encrypted zeros
+	AssertCommand(t, s, commands.CommandNewilist, "5", "3")
+	AssertCommand(t, s, commands.CommandNewArray, "6", "P512", "5")
+	s.SetVariable("7", types.NewFTIntegerArray(0, 2, 0, 2))
+	AssertCommand(t, s, commands.CommandReduceSum, "6", "4", "7")
+
+	s.SetVariable("8", types.NewFTIntegerArray(2, 1, 3))
+	AssertCommand(t, s, commands.CommandMul, "9", "6", "8")
+
+	AssertCommand(t, s, commands.CommandSerialise, "10", "9")
+	empty, err := types.NewPaillierArrayOfZeros(512, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetVariable("11", empty)
+	AssertCommand(t, s, commands.CommandDeserialise, "11", "10")
+
+	AssertCommand(t, s, commands.CommandPaillierDecrypt, "12", "11", "1")
+	AssertValue(t, s, "12", types.NewFTIntegerArray(80, 0, 45))
+
+	AssertCommandFailure(t, s, commands.CommandPaillierKeygen, []string{"13", "1024"}, "key size must be one of")
+}
+
+func TestCommand_ShareReconstruct(t *testing.T) {
+	s := NewTestSegment()
+
+	s.SetVariable("1", types.NewFTIntegerArray(10, -20, math.MaxInt64))
+
+	// A single participant holds the whole value
+	AssertCommandResponse(t, s, commands.CommandShare, []string{"2", "1", "0", "0"}, "array i 2")
+	AssertValue(t, s, "2", types.NewFTIntegerArray(10, -20, math.MaxInt64))
+
+	// Other participants only acknowledge the share pushed to them by the dealer
+	AssertCommandResponse(t, s, commands.CommandShare, []string{"3", "1", "1", "0", "1"}, "array i 3")
+	if s.Variables().Exists("3") {
+		t.Error("a participant should not create its own share")
+	}
+
+	AssertCommandFailure(t, s, commands.CommandShare, []string{"4", "1", "1", "1", "2"}, "node 0 is not one of the participants")
+	AssertCommandFailure(t, s, commands.CommandShare, []string{"4", "1", "2", "0", "1"}, "dealer 2 is not one of the participants")
+	AssertCommandFailure(t, s, commands.CommandShare, []string{"4", "1", "0", "0", "1"}, "empty")
+
+	// Shares held locally, which reconstruct to 1, 2 and 3
+	s.SetVariable("5", types.NewFTIntegerArray(-7, math.MinInt64, 5))
+	s.SetVariable("6", types.NewFTIntegerArray(8, math.MinInt64+2, -2))
+	s.SetVariable("7", types.NewFTIntegerArray(3))
+
+	AssertCommand(t, s, commands.CommandReconstruct, "8", "5", "6")
+	AssertValue(t, s, "8", types.NewFTIntegerArray(1, 2, 3))
+
+	AssertCommand(t, s, commands.CommandShareAdd, "9", "5", "6")
+	AssertValue(t, s, "9", types.NewFTIntegerArray(1, 2, 3))
+
+	AssertCommand(t, s, commands.CommandShareMul, "10", "5", "7")
+	AssertCommand(t, s, commands.CommandShareMul, "11", "6", "7")
+	AssertCommand(t, s, commands.CommandReconstruct, "12", "10", "11")
+	AssertValue(t, s, "12", types.NewFTIntegerArray(3, 6, 9))
+
+	AssertCommand(t, s, commands.CommandShareSub, "13", "10", "5")
+	AssertCommand(t, s, commands.CommandShareSub, "14", "11", "6")
+	AssertCommand(t, s, commands.CommandReconstruct, "13", "13", "14")
+	AssertValue(t, s, "13", types.NewFTIntegerArray(2, 4, 6))
+}
+
+func setElGamalTestKeys(s *Segment, hPrivateKey string, hPublicKey string) {
+	s.SetVariable(variables.Handle(hPrivateKey), types.NewFTEd25519IntArrayFromInt64s(7))
+	s.SetVariable(variables.Handle(hPublicKey), types.NewEd25519ArrayFromInt64sOrPanic(7))
+}
+
+func TestCommand_ElGamalEncrypt_Decrypt(t *testing.T) {
+	s := NewTestSegment()
+	setElGamalTestKeys(s, "1", "2")
+
+	s.SetVariable("3", types.NewFTIntegerArray(1, 2, 3))
+
+	AssertCommand(t, s, commands.CommandElGamalEncrypt, "4", "5", "3", "2")
+	AssertValueNot(t, s, "5", types.NewEd25519ArrayFromInt64sOrPanic(1, 2, 3))
+
+	AssertCommand(t, s, commands.CommandElGamalDecrypt, "6", "4", "5", "1")
+	AssertValue(t, s, "6", types.NewEd25519ArrayFromInt64sOrPanic(1, 2, 3))
+}
+
+func TestCommand_ElGamalRerandomise(t *testing.T) {
+	s := NewTestSegment()
+	setElGamalTestKeys(s, "1", "2")
+
+	s.SetVariable("3", types.NewFTEd25519IntArrayFromInt64s(10, 20))
+
+	AssertCommand(t, s, commands.CommandElGamalEncrypt, "4", "5", "3", "2")
+	AssertCommand(t, s, commands.CommandElGamalRerandomise, "6", "7", "4", "5", "2")
+
+	mask := AssertVariable[*types.Ed25519Array](t, s, "4")
+	AssertValueNot(t, s, "6", mask)
+
+	AssertCommand(t, s, commands.CommandElGamalDecrypt, "8", "6", "7", "1")
+	AssertValue(t, s, "8", types.NewEd25519ArrayFromInt64sOrPanic(10, 20))
+}
+
+func TestCommand_ElGamalAdd(t *testing.T) {
+	s := NewTestSegment()
+	setElGamalTestKeys(s, "1", "2")
+
+	s.SetVariable("3", types.NewFTIntegerArray(1, 2, 3))
+	s.SetVariable("4", types.NewFTIntegerArray(10, 20, 30))
+
+	AssertCommand(t, s, commands.CommandElGamalEncrypt, "5", "6", "3", "2")
+	AssertCommand(t, s, commands.CommandElGamalEncrypt, "7", "8", "4", "2")
+	AssertCommand(t, s, commands.CommandElGamalAdd, "9", "10", "5", "6", "7", "8")
+
+	AssertCommand(t, s, commands.CommandElGamalDecrypt, "11", "9", "10", "1")
+	AssertValue(t, s, "11", types.NewEd25519ArrayFromInt64sOrPanic(11, 22, 33))
+}
+
+func TestCommand_ElGamalEncrypt_NonSingletonKey(t *testing.T) {
+	s := NewTestSegment()
+
+	s.SetVariable("1", types.NewEd25519ArrayFromInt64sOrPanic(7, 8))
+	s.SetVariable("2", types.NewFTIntegerArray(1, 2))
+
+	AssertCommandFailure(t, s, commands.CommandElGamalEncrypt, []string{"3", "4", "2", "1"}, "key must be a singleton array")
+}
+
+func TestCommand_ElGamalThreshold_SingleNode(t *testing.T) {
+	s := NewTestSegment()
+
+	AssertCommand(t, s, commands.CommandElGamalDKGDeal, "1", "1", "0")
+	AssertCommand(t, s, commands.CommandElGamalDKGCombine, "2", "3", "4", "1", "1", "0")
+
+	s.SetVariable("5", types.NewFTIntegerArray(4, 5, 6))
+
+	AssertCommand(t, s, commands.CommandElGamalEncrypt, "6", "7", "5", "3")
+	AssertCommand(t, s, commands.CommandElGamalPartialDecrypt, "8", "6", "2")
+	AssertCommand(t, s, commands.CommandElGamalCombinePartials, "9", "7", "1", "0", "8")
+	AssertValue(t, s, "9", types.NewEd25519ArrayFromInt64sOrPanic(4, 5, 6))
+
+	// The key share itself also decrypts when the threshold is one
+	AssertCommand(t, s, commands.CommandElGamalDecrypt, "10", "6", "7", "2")
+	AssertValue(t, s, "10", types.NewEd25519ArrayFromInt64sOrPanic(4, 5, 6))
+}
+
+func TestCommand_ElGamalDKGDeal_InvalidThreshold(t *testing.T) {
+	s := NewTestSegment()
+
+	AssertCommandFailure(t, s, commands.CommandElGamalDKGDeal, []string{"1", "2", "0"}, "threshold must be between 1 and the number of participants")
+	AssertCommandFailure(t, s, commands.CommandElGamalDKGDeal, []string{"1", "1", "3"}, "node 0 is not one of the participants")
+}
+
+func TestCommand_ElGamalCombinePartials_TooFew(t *testing.T) {
+	s := NewTestSegment()
+	s.SetVariable("1", types.NewEd25519ArrayFromInt64sOrPanic(1))
+	s.SetVariable("2", types.NewEd25519ArrayFromInt64sOrPanic(1))
+
+	AssertCommandFailure(t, s, commands.CommandElGamalCombinePartials, []string{"3", "1", "2", "0", "2"}, "at least 2 partial decryptions are required")
+}
+
+func TestCommand_SchnorrProve_Verify(t *testing.T) {
+	s := NewTestSegment()
+
+	s.SetVariable("1", types.NewFTEd25519IntArrayFromInt64s(3, 5))
+	s.SetVariable("2", types.NewEd25519ArrayFromInt64sOrPanic(3, 6))
+
+	AssertCommand(t, s, commands.CommandSchnorrProve, "3", "1")
+	AssertCommand(t, s, commands.CommandSchnorrVerify, "4", "3", "2")
+	AssertValue(t, s, "4", types.NewFTIntegerArray(1, 0))
+}
+
+func TestCommand_ElGamalDecryptProve_Verify(t *testing.T) {
+	s := NewTestSegment()
+	setElGamalTestKeys(s, "1", "2")
+
+	s.SetVariable("3", types.NewFTIntegerArray(1, 2, 3))
+
+	AssertCommand(t, s, commands.CommandElGamalEncrypt, "4", "5", "3", "2")
+	AssertCommand(t, s, commands.CommandElGamalDecryptProve, "6", "7", "4", "5", "1")
+	AssertValue(t, s, "6", types.NewEd25519ArrayFromInt64sOrPanic(1, 2, 3))
+
+	AssertCommand(t, s, commands.CommandElGamalDecryptVerify, "8", "7", "6", "4", "5", "2")
+	AssertValue(t, s, "8", types.NewFTIntegerArray(1, 1, 1))
+
+	s.SetVariable("9", types.NewEd25519ArrayFromInt64sOrPanic(1, 2, 4))
+	AssertCommand(t, s, commands.CommandElGamalDecryptVerify, "10", "7", "9", "4", "5", "2")
+	AssertValue(t, s, "10", types.NewFTIntegerArray(1, 1, 0))
+}
+
+func TestCommand_ElGamalPartialDecryptProve_Verify(t *testing.T) {
+	s := NewTestSegment()
+
+	AssertCommand(t, s, commands.CommandElGamalDKGDeal, "1", "1", "0")
+	AssertCommand(t, s, commands.CommandElGamalDKGCombine, "2", "3", "4", "1", "1", "0")
+
+	s.SetVariable("5", types.NewFTIntegerArray(4, 5))
+
+	AssertCommand(t, s, commands.CommandElGamalEncrypt, "6", "7", "5", "3")
+	AssertCommand(t, s, commands.CommandElGamalPartialDecryptProve, "8", "9", "6", "2")
+	AssertCommand(t, s, commands.CommandElGamalPartialDecryptVerify, "10", "9", "8", "6", "4")
+	AssertValue(t, s, "10", types.NewFTIntegerArray(1, 1))
+}
+
+func TestCommand_ElGamalRerandomiseProve_Verify(t *testing.T) {
+	s := NewTestSegment()
+	setElGamalTestKeys(s, "1", "2")
+
+	s.SetVariable("3", types.NewFTIntegerArray(10, 20))
+
+	AssertCommand(t, s, commands.CommandElGamalEncrypt, "4", "5", "3", "2")
+	AssertCommand(t, s, commands.CommandElGamalRerandomiseProve, "6", "7", "8", "4", "5", "2")
+	AssertCommand(t, s, commands.CommandElGamalRerandomiseVerify, "9", "8", "6", "7", "4", "5", "2")
+	AssertValue(t, s, "9", types.NewFTIntegerArray(1, 1))
+
+	AssertCommand(t, s, commands.CommandElGamalDecrypt, "10", "6", "7", "1")
+	AssertValue(t, s, "10", types.NewEd25519ArrayFromInt64sOrPanic(10, 20))
+}
+
+func TestCommand_SchnorrVerify_InvalidWidth(t *testing.T) {
+	s := NewTestSegment()
+
+	s.SetVariable("1", types.NewFTBytearrayArrayOrPanic(32, make([]byte, 32)))
+	s.SetVariable("2", types.NewEd25519ArrayFromInt64sOrPanic(3))
+
+	AssertCommandFailure(t, s, commands.CommandSchnorrVerify, []string{"3", "1", "2"}, "proofs must be 64 byte arrays")
+}
+
+func TestCommand_ElGamalShuffle_Verify(t *testing.T) {
+	s := NewTestSegment()
+	setElGamalTestKeys(s, "1", "2")
+
+	s.SetVariable("3", types.NewFTIntegerArray(1, 2, 3, 4))
+
+	AssertCommand(t, s, commands.CommandElGamalEncrypt, "4", "5", "3", "2")
+	AssertCommand(t, s, commands.CommandElGamalShuffle, "6", "7", "8", "9", "4", "5", "2")
+	AssertCommand(t, s, commands.CommandElGamalShuffleVerify, "10", "8", "9", "6", "7", "4", "5", "2")
+	AssertValue(t, s, "10", types.NewFTIntegerArray(1))
+
+	// Verifying against the unshuffled ciphertexts fails
+	AssertCommand(t, s, commands.CommandElGamalShuffleVerify, "11", "8", "9", "4", "5", "4", "5", "2")
+	AssertValue(t, s, "11", types.NewFTIntegerArray(0))
+}
+
+func TestCommand_Ed25519PublicKey(t *testing.T) {
+	s := NewTestSegment()
+
+	AssertCommand(t, s, commands.CommandEd25519Keygen, "1")
+	AssertCommand(t, s, commands.CommandEd25519PublicKey, "2", "1")
+
+	pk := AssertVariable[*types.FTBytearrayArray](t, s, "2")
+	if pk.Width() != 32 {
+		t.Errorf("expected a 32 byte public key, got %v", pk.Width())
+	}
+}
+
+func TestCommand_Ed25519Sign_Verify_Correct(t *testing.T) {
+	s := NewTestSegment()
+
+	AssertCommand(t, s, commands.CommandEd25519Keygen, "1")
+	AssertCommand(t, s, commands.CommandEd25519PublicKey, "2", "1")
+
+	AssertCommand(t, s, commands.CommandNewilist, "3", "5")
+	AssertCommand(t, s, commands.CommandRandomArray, "4", "b16", "3")
+
+	AssertCommand(t, s, commands.CommandEd25519Sign, "5", "4", "1")
+	AssertCommand(t, s, commands.CommandEd25519Verify, "6", "4", "5", "2")
+
+	AssertValue(t, s, "6", types.NewFTIntegerArray(1, 1, 1, 1, 1))
+}
+
+func TestCommand_Ed25519Sign_Verify_Incorrect(t *testing.T) {
+	s := NewTestSegment()
+
+	AssertCommand(t, s, commands.CommandEd25519Keygen, "1")
+	AssertCommand(t, s, commands.CommandEd25519PublicKey, "2", "1")
+
+	AssertCommand(t, s, commands.CommandNewilist, "3", "5")
+	AssertCommand(t, s, commands.CommandRandomArray, "4", "b16", "3")
+
+	AssertCommand(t, s, commands.CommandEd25519Sign, "5", "4", "1")
+
+	bs, err := variables.GetAs[*types.FTBytearrayArray](s.Variables(), "4")
+	if err != nil {
+		t.Error(err)
+	}
+	bs.Values()[1][1] = bs.Values()[1][1] - 1
+
+	AssertCommand(t, s, commands.CommandEd25519Verify, "6", "4", "5", "2")
+	AssertValue(t, s, "6", types.NewFTIntegerArray(1, 0, 1, 1, 1))
+}
+
+func TestCommand_X25519Derive(t *testing.T) {
+	s := NewTestSegment()
+
+	AssertCommand(t, s, commands.CommandX25519Keygen, "1")
+	AssertCommand(t, s, commands.CommandX25519PublicKey, "2", "1")
+	AssertCommand(t, s, commands.CommandX25519Keygen, "3")
+	AssertCommand(t, s, commands.CommandX25519PublicKey, "4", "3")
+
+	AssertCommand(t, s, commands.CommandX25519Derive, "5", "1", "4", "32", "aes")
+	AssertCommand(t, s, commands.CommandX25519Derive, "6", "3", "2", "32", "aes")
+
+	key := AssertVariable[*types.FTBytearrayArray](t, s, "5")
+	AssertValue(t, s, "6", key)
+
+	AssertCommandFailure(t, s, commands.CommandX25519Derive, []string{"7", "1", "3", "0"}, "width must be between 1 and 8160")
+
+	// Labels which would be cut short where commands are split on "__" are refused
+	_, args, _, err := parseMessage([]byte(`{"command": "command_x25519_derive 7 1 4 32 aes__v2", "response_required": "True"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertCommandFailure(t, s, commands.CommandX25519Derive, args, `label must be the final argument, non-empty and without "__"`)
+	AssertCommandFailure(t, s, commands.CommandX25519Derive, []string{"7", "1", "4", "32", "aes__v2"}, `label must be the final argument, non-empty and without "__"`)
+}
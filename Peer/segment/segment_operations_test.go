@@ -54,8 +54,6 @@ func TestCommandOpEq_Bytearray(t *testing.T) {
 	AssertValue(t, s, "3", types.NewFTIntegerArray(1, 0, 1))
 }
 func TestCommandOpEq_Ed25519(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
-
 	s := NewTestSegment()
 
 	s.SetVariable("1", types.NewEd25519ArrayFromInt64sOrPanic(10, 20, 30))
@@ -106,8 +104,6 @@ func TestCommandOpNe_Ed25519Int(t *testing.T) {
 	AssertValue(t, s, "3", types.NewFTIntegerArray(0, 1, 0))
 }
 func TestCommandOpNe_Ed25519(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
-
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewEd25519ArrayFromInt64sOrPanic(10, 20, 30))
@@ -215,8 +211,6 @@ func TestCommandOpNeg_Ed25519Int(t *testing.T) {
 	AssertValue(t, s, "2", types.NewFTEd25519IntArrayFromInt64s(-1, -3, 3))
 }
 func TestCommandOpNeg_Ed25519(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
-
 	s := NewTestSegment()
 
 	original := types.NewEd25519ArrayFromInt64sOrPanic(1, 2, 3)
@@ -303,8 +297,6 @@ func TestCommandOpAdd_Ed25519Int(t *testing.T) {
 	AssertValue(t, s, "3", types.NewFTEd25519IntArrayFromInt64s(2, 4, 6))
 }
 func TestCommandOpAdd_Ed25519(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
-
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewEd25519ArrayFromInt64sOrPanic(10, 20, 30))
@@ -341,8 +333,6 @@ func TestCommandOpSub_Ed25519Int(t *testing.T) {
 	AssertValue(t, s, "3", types.NewFTEd25519IntArrayFromInt64s(2, 2, 2))
 }
 func TestCommandOpSub_Ed25519(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
-
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewEd25519ArrayFromInt64sOrPanic(20, 30, 40))
@@ -374,7 +364,6 @@ func TestCommandOpMul_Float(t *testing.T) {
 	AssertValue(t, s, "3", types.NewFTFloatArray(0.5, 2, 4.5))
 }
 func TestCommandOpMul_Ed25519Int(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewFTEd25519IntArrayFromInt64s(3, 4, 5))
@@ -390,8 +379,6 @@ func TestCommandOpMul_Ed25519Int(t *testing.T) {
 	AssertValue(t, s, "6", types.NewEd25519ArrayFromInt64sOrPanic(3, 8, 15))
 }
 func TestCommandOpMul_Ed25519(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
-
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewEd25519ArrayFromInt64sOrPanic(2, 3, 4))
@@ -10,6 +10,7 @@
 package segment
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"log"
@@ -28,6 +29,9 @@ import (
 const pickleTable = `CREATE TABLE pickle (destination character varying, dtype character varying(20), handle character varying(20), 
 opcode character varying(10), data bytea, elementindex integer, chunkindex integer, created timestamp without time zone);`
 
+const keystoreTable = `CREATE TABLE keystore (name character varying, version integer, kind character varying(20), nonce bytea, data bytea, 
+created timestamp without time zone, PRIMARY KEY (name, version));`
+
 func TestCommandInit(t *testing.T) {
 	s := NewTestSegment()
 	s.gpuEnabled = true
@@ -172,8 +176,6 @@ func TestCommandNewArray_Ed25519Int_Value(t *testing.T) {
 }
 
 func TestCommandNewArray_Ed25519_NoValue(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
-
 	s := NewTestSegment()
 
 	AssertCommand(t, s, commands.CommandNewilist, "1", "3")
@@ -182,8 +184,6 @@ func TestCommandNewArray_Ed25519_NoValue(t *testing.T) {
 	AssertValue(t, s, "2", types.NewEd25519ArrayFromInt64sOrPanic(0, 0, 0))
 }
 func TestCommandNewArray_Ed25519_Value(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
-
 	s := NewTestSegment()
 
 	AssertCommand(t, s, commands.CommandNewilist, "1", "3")
@@ -194,8 +194,6 @@ func TestCommandNewArray_Ed25519_Value(t *testing.T) {
 }
 
 func TestCommandNewArray_Ed25519_Empty(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
-
 	s := NewTestSegment()
 
 	AssertCommand(t, s, commands.CommandNewilist, "1", "0")
@@ -207,6 +205,23 @@ func TestCommandNewArray_Ed25519_Empty(t *testing.T) {
 	}
 }
 
+func TestCommandNewArray_Ed25519_OptionsBackend(t *testing.T) {
+	backend := types.NewCPUEd25519Backend()
+
+	s, err := NewSegment(Options{NodeIDString: "0", Ed25519Backend: backend}, "sqlite3", "file::memory:?cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	AssertCommand(t, s, commands.CommandNewilist, "1", "3")
+	AssertCommand(t, s, commands.CommandNewArray, "2", "E", "1")
+
+	xs := AssertVariable[*types.Ed25519Array](t, s, "2")
+	if xs.Backend() != backend {
+		t.Error("array was not created using the backend from the options")
+	}
+}
+
 func TestCommandSetItem_Integer_NoKeys(t *testing.T) {
 	s := NewTestSegment()
 
@@ -304,8 +319,6 @@ func TestCommandSetItem_Bytearray(t *testing.T) {
 }
 
 func TestCommandSetItem_Ed25519(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
-
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewEd25519ArrayFromInt64sOrPanic(1, 2, 3, 4, 5))
@@ -318,8 +331,6 @@ func TestCommandSetItem_Ed25519(t *testing.T) {
 }
 
 func TestCommandSetItem_Ed25519_NoKeys(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
-
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewEd25519ArrayFromInt64sOrPanic(1, 2, 3, 4, 5))
@@ -331,8 +342,6 @@ func TestCommandSetItem_Ed25519_NoKeys(t *testing.T) {
 }
 
 func TestCommandSetItem_Ed25519_EmptyArray_NoKeys(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
-
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewEd25519ArrayFromInt64sOrPanic())
@@ -421,8 +430,6 @@ func TestCommandDelItem_Ed25519Int(t *testing.T) {
 }
 
 func TestCommandDelItem_Ed25519(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
-
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewEd25519ArrayFromInt64sOrPanic(1, 2, 3, 4))
@@ -473,7 +480,6 @@ func TestCommandLen_Ed25519Int(t *testing.T) {
 	AssertValue(t, s, "2", types.NewFTIntegerArray(5))
 }
 func TestCommandLen_Ed25519(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewEd25519ArrayFromInt64sOrPanic(1, 2, 3, 4, 5))
@@ -800,8 +806,6 @@ func TestCommandSetLength_Ed25519Int_Extend(t *testing.T) {
 }
 
 func TestCommandSetLength_Ed25519_Truncate(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
-
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewEd25519ArrayFromInt64sOrPanic(1, 2, 3, 4, 5))
@@ -813,8 +817,6 @@ func TestCommandSetLength_Ed25519_Truncate(t *testing.T) {
 }
 
 func TestCommandSetLength_Ed25519_NoOp(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
-
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewEd25519ArrayFromInt64sOrPanic(1, 2, 3, 4, 5))
@@ -826,7 +828,6 @@ func TestCommandSetLength_Ed25519_NoOp(t *testing.T) {
 }
 
 func TestCommandSetLength_Ed25519_Extend(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewEd25519ArrayFromInt64sOrPanic(1, 2, 3, 4, 5))
@@ -838,7 +839,6 @@ func TestCommandSetLength_Ed25519_Extend(t *testing.T) {
 }
 
 func TestCommandSetLength_Ed25519_Empty_Extend(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewEd25519ArrayFromInt64sOrPanic())
@@ -946,7 +946,6 @@ func TestCommandGetItem_NegativeKey_Error(t *testing.T) {
 	AssertCommandFailure(t, s, commands.CommandGetItem, []string{"3", "1", "2"}, "out of range")
 }
 func TestCommandGetItem_Ed25519_NegativeKey_Error(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewEd25519ArrayFromInt64sOrPanic(1, 2, 3, 4, 5))
@@ -1096,7 +1095,6 @@ func TestCommandGetItem_Ed25519Int_OutOfRange(t *testing.T) {
 }
 
 func TestCommandGetItem_Ed25519(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewEd25519ArrayFromInt64sOrPanic(1, 2, 3, 4, 5))
@@ -1110,7 +1108,6 @@ func TestCommandGetItem_Ed25519(t *testing.T) {
 	AssertValue(t, s, "4", types.NewFTIntegerArray(1, 1))
 }
 func TestCommandGetItem_Ed25519_NoKeys(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewEd25519ArrayFromInt64sOrPanic(1, 2, 3, 4, 5))
@@ -1123,8 +1120,6 @@ func TestCommandGetItem_Ed25519_NoKeys(t *testing.T) {
 	AssertValue(t, s, "4", types.NewFTIntegerArray(1, 1, 1, 1, 1))
 }
 func TestCommandGetItem_Ed25519_OutOfRange(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
-
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewEd25519ArrayFromInt64sOrPanic(1, 2, 3, 4, 5))
@@ -1245,8 +1240,6 @@ func TestCommandLookup_Ed25519Int_WithDefault(t *testing.T) {
 }
 
 func TestCommandLookup_Ed25519_NoDefault(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
-
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewEd25519ArrayFromInt64sOrPanic(1, 2, 3, 4, 5))
@@ -1256,8 +1249,6 @@ func TestCommandLookup_Ed25519_NoDefault(t *testing.T) {
 	AssertValue(t, s, "3", types.NewEd25519ArrayFromInt64sOrPanic(2, 4, 0))
 }
 func TestCommandLookup_Ed25519_WithDefault(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
-
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewEd25519ArrayFromInt64sOrPanic(1, 2, 3, 4, 5))
@@ -1269,8 +1260,6 @@ func TestCommandLookup_Ed25519_WithDefault(t *testing.T) {
 }
 
 func TestCommandLookup_Ed25519_AllOutOfRange(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
-
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewEd25519ArrayFromInt64sOrPanic(1, 2, 3, 4, 5))
@@ -1309,8 +1298,6 @@ func TestCommandIndex_Ed25519Int(t *testing.T) {
 	AssertValue(t, s, "2", types.NewFTIntegerArray(0, 1, 3, 5, 6))
 }
 func TestCommandIndex_Ed25519(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
-
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewEd25519ArrayFromInt64sOrPanic(1, 2, 0, 4, 0, 6, 7))
@@ -1320,8 +1307,6 @@ func TestCommandIndex_Ed25519(t *testing.T) {
 	AssertValue(t, s, "2", types.NewFTIntegerArray(0, 1, 3, 5, 6))
 }
 func TestCommandIndex_Ed25519_Empty(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
-
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewEd25519ArrayFromInt64sOrPanic())
@@ -1497,8 +1482,6 @@ func TestCommandSerialiseAndDeserialise_Ed25519Int(t *testing.T) {
 }
 
 func TestCommandSerialiseAndDeserialise_Ed25519(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
-
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewEd25519ArrayFromInt64sOrPanic(0, 2, 1, 0, 0, 3, 0, 4, 5, 0))
@@ -1511,6 +1494,19 @@ func TestCommandSerialiseAndDeserialise_Ed25519(t *testing.T) {
 	AssertValue(t, s, "3", types.NewEd25519ArrayFromInt64sOrPanic(0, 2, 1, 0, 0, 3, 0, 4, 5, 0))
 }
 
+func TestCommandSerialiseAndDeserialise_Ristretto(t *testing.T) {
+	s := NewTestSegment()
+
+	s.Variables().Set("1", types.NewRistrettoArrayFromInt64s(0, 2, 1, 0, 0, 3, 0, 4, 5, 0))
+	s.Variables().Set("3", types.NewRistrettoArrayFromInt64s())
+
+	AssertCommand(t, s, commands.CommandSerialise, "2", "1")
+	AssertCommand(t, s, commands.CommandDeserialise, "3", "2")
+
+	AssertVariable[*types.FTBytearrayArray](t, s, "2")
+	AssertValue(t, s, "3", types.NewRistrettoArrayFromInt64s(0, 2, 1, 0, 0, 3, 0, 4, 5, 0))
+}
+
 func TestCommandReduceSum_Integer(t *testing.T) {
 	s := NewTestSegment()
 
@@ -1546,8 +1542,6 @@ func TestCommandReduceSum_Ed25519Int(t *testing.T) {
 }
 
 func TestCommandReduceSum_Ed25519(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
-
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewEd25519ArrayFromInt64sOrPanic(5, 8, 1, 2, 50))
@@ -1565,8 +1559,6 @@ func TestCommandReduceSum_Ed25519(t *testing.T) {
 }
 
 func TestCommandReduceSum_Ed25519_Issue96(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
-
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewEd25519ArrayFromInt64sOrPanic(0, 0, 0, 0, 0, 0, 0, 0, 0, 0))
@@ -1591,8 +1583,6 @@ func TestCommandReduceSum_Ed25519_Issue96(t *testing.T) {
 }
 
 func TestCommandReduceISum_Ed25519(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
-
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewEd25519ArrayFromInt64sOrPanic(5, 8, 30, 20, 50))
@@ -1666,8 +1656,6 @@ func TestCommandContains_Ed25519Int(t *testing.T) {
 	AssertValue(t, s, "3", types.NewFTIntegerArray(1, 1, 0, 1))
 }
 func TestCommandContains_Ed25519(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
-
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewEd25519ArrayFromInt64sOrPanic(1, 2, 3, 4, 5, 6, 7))
@@ -1679,8 +1667,6 @@ func TestCommandContains_Ed25519(t *testing.T) {
 }
 
 func TestCommandContains_Ed25519_Empty(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
-
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewEd25519ArrayFromInt64sOrPanic())
@@ -1735,8 +1721,6 @@ func TestCommandCumSum_Ed25519Int(t *testing.T) {
 	AssertValue(t, s, "2", types.NewFTEd25519IntArrayFromInt64s(1, 3, 6, 10, 15, 21, 28))
 }
 func TestCommandCumSum_Ed25519(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
-
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewEd25519ArrayFromInt64sOrPanic(1, 2, 3, 4, 5, 6, 7))
@@ -1746,8 +1730,6 @@ func TestCommandCumSum_Ed25519(t *testing.T) {
 }
 
 func TestCommandCumsum_Ed25519_Empty(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
-
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewEd25519ArrayFromInt64sOrPanic())
@@ -1788,8 +1770,6 @@ func TestCommandMux_Ed25519Int(t *testing.T) {
 	AssertValue(t, s, "4", types.NewFTEd25519IntArrayFromInt64s(10, 22, 30, 40, 55, 66, 70))
 }
 func TestCommandMux_Ed25519(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
-
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewFTIntegerArray(1, 0, 1, 2, 0, 0, 3))                       // Cond
@@ -1802,8 +1782,6 @@ func TestCommandMux_Ed25519(t *testing.T) {
 }
 
 func TestCommandMux_Ed25519_Empty(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
-
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewFTIntegerArray()) // Cond
@@ -2250,6 +2228,59 @@ func TestCommandRandomPerm(t *testing.T) {
 	}
 }
 
+func newTestPrivacySegment(epsilon float64, delta float64) *Segment {
+	o := Options{
+		NodeIDString:         "0",
+		DbChunkSize:          1000000000,
+		PrivacyBudgetEpsilon: epsilon,
+		PrivacyBudgetDelta:   delta,
+	}
+	s, _ := NewSegment(o, "sqlite3", "file::memory:?cache=shared")
+	return s
+}
+
+func TestCommand_Noise(t *testing.T) {
+	s := newTestPrivacySegment(1, 1e-5)
+
+	s.Variables().Set("1", types.NewFTIntegerArray(make([]int64, 100)...))
+	s.Variables().Set("2", types.NewFTFloatArray(make([]float64, 100)...))
+
+	AssertCommandResponse(t, s, commands.CommandGeometricNoise, []string{"1", "0.25", "1"}, commands.Ack)
+	AssertCommandResponse(t, s, commands.CommandLaplaceNoise, []string{"2", "0.25", "1"}, commands.Ack)
+	AssertCommandResponse(t, s, commands.CommandGaussianNoise, []string{"1", "0.25", "1e-5", "1"}, commands.Ack)
+	AssertValueNot(t, s, "1", types.NewFTIntegerArray(make([]int64, 100)...))
+	AssertValueNot(t, s, "2", types.NewFTFloatArray(make([]float64, 100)...))
+
+	AssertCommandResponse(t, s, commands.CommandPrivacyBudget, []string{"3"}, "array f 3")
+	AssertValue(t, s, "3", types.NewFTFloatArray(0.25, 0))
+
+	// The budget is spent before the noise is added, and refused releases leave the data alone
+	AssertCommandFailure(t, s, commands.CommandGaussianNoise, []string{"1", "0.1", "1e-5", "1"}, "privacy budget is exhausted")
+	AssertCommandFailure(t, s, commands.CommandGeometricNoise, []string{"1", "0.5", "1"}, "privacy budget is exhausted")
+	AssertCommandFailure(t, s, commands.CommandGeometricNoise, []string{"3", "0", "1"}, "epsilon and sensitivity must be positive")
+
+	// Integer noise is only added to Integer arrays, of queries with an integer sensitivity
+	AssertCommandFailure(t, s, commands.CommandGeometricNoise, []string{"2", "0.01", "1"}, "")
+	AssertCommandFailure(t, s, commands.CommandGaussianNoise, []string{"2", "0.01", "1e-6", "1"}, "")
+	AssertCommandFailure(t, s, commands.CommandGeometricNoise, []string{"1", "0.01", "0.5"}, "must be an integer")
+	AssertCommandFailure(t, s, commands.CommandGaussianNoise, []string{"1", "0.01", "1e-6", "1.5"}, "must be an integer")
+	AssertCommandResponse(t, s, commands.CommandGeometricNoise, []string{"1", "0.25", "1"}, commands.Ack)
+	AssertCommandFailure(t, s, commands.CommandGeometricNoise, []string{"1", "0.01", "1"}, "privacy budget is exhausted")
+
+	// A new session starts with a fresh budget
+	AssertCommand(t, s, commands.CommandInit)
+	AssertCommand(t, s, commands.CommandPrivacyBudget, "3")
+	AssertValue(t, s, "3", types.NewFTFloatArray(1, 1e-5))
+}
+
+func TestCommand_Noise_NoBudget(t *testing.T) {
+	s := NewTestSegment()
+
+	s.Variables().Set("1", types.NewFTIntegerArray(1, 2, 3))
+	AssertCommandFailure(t, s, commands.CommandGeometricNoise, []string{"1", "0.1", "1"}, "no privacy budget")
+	AssertValue(t, s, "1", types.NewFTIntegerArray(1, 2, 3))
+}
+
 func TestCommandAsType_Int_Float(t *testing.T) {
 
 	s := NewTestSegment()
@@ -2282,8 +2313,6 @@ func TestCommandAsType_Int_Ed25519Int(t *testing.T) {
 	))
 }
 func TestCommandAsType_Int_Ed25519(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
-
 	s := NewTestSegment()
 
 	AssertCommand(t, s, commands.CommandNewilist, "1")
@@ -2338,8 +2367,6 @@ func TestCommandAsType_Ed25519Int_Int(t *testing.T) {
 	AssertValue(t, s, "2", types.NewFTIntegerArray(1, 2, 3))
 }
 func TestCommandAsType_Ed25519Int_Ed25519(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
-
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewFTEd25519IntArrayFromInt64s(1, 2, 3))
@@ -2662,6 +2689,88 @@ func TestCommand_SaveAndLoad_ByteArrayArray(t *testing.T) {
 	))
 }
 
+func TestCommand_SaveAndLoad_RistrettoArray(t *testing.T) {
+	db, err := CreateTmpTable(pickleTable)
+	if err != nil {
+		panic(err)
+	}
+	defer db.Close()
+
+	destination := "test"
+
+	s := NewTestSegment()
+
+	AssertCommand(t, s, commands.CommandNewilist, "1", "3")
+	AssertCommand(t, s, commands.CommandNewilist, "2", "7")
+	AssertCommand(t, s, commands.CommandNewArray, "3", "R", "1", "2")
+	AssertCommand(t, s, commands.CommandNewilist, "4", "1", "2", "3")
+	AssertCommand(t, s, commands.CommandAsType, "5", "4", "R")
+	AssertCommand(t, s, commands.CommandAdd, "6", "3", "5")
+	AssertCommand(t, s, commands.CommandStartSave, destination)
+	AssertCommand(t, s, commands.CommandSave, "6", "array")
+	AssertCommand(t, s, commands.CommandFinishSave, destination)
+	s.variables.Clear() // Empty variable store to simulate a restart.
+	AssertCommand(t, s, commands.CommandStartLoad, destination)
+	AssertCommand(t, s, commands.CommandLoad, "7", "6", "R")
+	AssertCommand(t, s, commands.CommandFinishLoad, destination)
+
+	AssertValue(t, s, "7", types.NewRistrettoArrayFromInt64s(8, 9, 10))
+}
+
+func TestCommand_Filters(t *testing.T) {
+	db, err := CreateTmpTable(pickleTable)
+	if err != nil {
+		panic(err)
+	}
+	defer db.Close()
+
+	destination := "test"
+
+	s := NewTestSegment()
+
+	s.SetVariable("1", types.NewFTIntegerArray(1, 2, 3, 4))
+	s.SetVariable("2", types.NewFTIntegerArray(3, 4, 5, 6))
+	s.SetVariable("3", types.NewFTIntegerArray(1, 3, 5, 7))
+
+	for _, tc := range []struct {
+		command string
+		width   string
+	}{
+		{commands.CommandBloomFilter, "B11"},
+		{commands.CommandCuckooFilter, "C33"},
+	} {
+		AssertCommandResponse(t, s, tc.command, []string{"4", "1", "0.01", "8"}, "array "+tc.width+" 4")
+		AssertCommandResponse(t, s, tc.command, []string{"5", "2", "0.01", "8"}, "array "+tc.width+" 5")
+
+		AssertCommand(t, s, commands.CommandFilterUnion, "6", "4", "5")
+		AssertCommand(t, s, commands.CommandFilterIntersect, "7", "4", "5")
+
+		AssertCommand(t, s, commands.CommandStartSave, destination)
+		AssertCommand(t, s, commands.CommandSave, "6", "array")
+		AssertCommand(t, s, commands.CommandFinishSave, destination)
+		AssertCommand(t, s, commands.CommandStartLoad, destination)
+		AssertCommand(t, s, commands.CommandLoad, "8", "6", tc.width)
+		AssertCommand(t, s, commands.CommandFinishLoad, destination)
+
+		// Filters never report false negatives, so only the items of neither can be missing
+		AssertCommandResponse(t, s, commands.CommandFilterContains, []string{"9", "8", "3"}, "array i 9")
+		v := AssertVariable[*types.FTIntegerArray](t, s, "9")
+		if xs := v.Values(); xs[0] != 1 || xs[1] != 1 || xs[2] != 1 {
+			t.Errorf("%v: union is missing items: %v", tc.width, xs)
+		}
+
+		AssertCommand(t, s, commands.CommandFilterContains, "10", "7", "3")
+		v = AssertVariable[*types.FTIntegerArray](t, s, "10")
+		if xs := v.Values(); xs[1] != 1 {
+			t.Errorf("%v: intersection is missing items: %v", tc.width, xs)
+		}
+	}
+
+	AssertCommand(t, s, commands.CommandBloomFilter, "11", "1", "0.01", "8")
+	AssertCommandFailure(t, s, commands.CommandFilterUnion, []string{"12", "4", "11"}, "filters are not compatible")
+	AssertCommandFailure(t, s, commands.CommandBloomFilter, []string{"11", "1", "1.5"}, "false positive rate")
+}
+
 func TestCommand_SaveAndLoad_Listmap(t *testing.T) {
 	db, err := CreateTmpTable(pickleTable)
 	if err != nil {
@@ -3022,6 +3131,69 @@ func TestCommand_SaveAndLoad_ED25519Integer(t *testing.T) {
 	AssertValue(t, s, "4", types.NewFTEd25519IntArrayFromInt64s(0, 0, 0))
 }
 
+func newTestKeystoreSegment(masterKey byte) *Segment {
+	o := Options{
+		NodeIDString:      "0",
+		DbChunkSize:       1000000000,
+		KeystoreMasterKey: bytes.Repeat([]byte{masterKey}, 32),
+	}
+	s, _ := NewSegment(o, "sqlite3", "file::memory:?cache=shared")
+	return s
+}
+
+func TestCommand_Keystore_GenerateLoadRotate(t *testing.T) {
+	db, err := CreateTmpTable(keystoreTable)
+	if err != nil {
+		panic(err)
+	}
+	defer db.Close()
+
+	s := newTestKeystoreSegment(1)
+
+	AssertCommand(t, s, commands.CommandKeystoreGenerate, "aes", "b32")
+	AssertCommand(t, s, commands.CommandKeystoreGenerate, "elgamal", "I")
+	AssertCommandFailure(t, s, commands.CommandKeystoreGenerate, []string{"aes", "b32"}, "already exists")
+	AssertCommandFailure(t, s, commands.CommandKeystoreGenerate, []string{"other", "f"}, "unsupported key kind")
+
+	AssertCommandResponse(t, s, commands.CommandKeystoreLoad, []string{"1", "aes"}, "array b32 1")
+	AssertCommandResponse(t, s, commands.CommandKeystoreLoad, []string{"2", "elgamal"}, "array I 2")
+	v1 := AssertVariable[*types.FTBytearrayArray](t, s, "1")
+
+	// Keys are sealed in the table
+	var sealed []byte
+	err = db.QueryRow(`SELECT data FROM keystore WHERE name = 'aes'`).Scan(&sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, v1.Values()[0]) {
+		t.Fatal("key is stored in the clear")
+	}
+
+	// Rotation adds a version, and earlier versions can still be loaded
+	AssertCommand(t, s, commands.CommandKeystoreRotate, "aes")
+	AssertCommand(t, s, commands.CommandKeystoreLoad, "3", "aes")
+	AssertValueNot(t, s, "3", v1)
+	AssertCommand(t, s, commands.CommandKeystoreLoad, "4", "aes", "1")
+	AssertValue(t, s, "4", v1)
+
+	AssertCommand(t, s, commands.CommandKeystoreDelete, "aes", "2")
+	AssertCommand(t, s, commands.CommandKeystoreLoad, "5", "aes")
+	AssertValue(t, s, "5", v1)
+
+	// A segment with another master key cannot unseal the keys
+	other := newTestKeystoreSegment(2)
+	AssertCommandFailure(t, other, commands.CommandKeystoreLoad, []string{"1", "aes"}, "unable to unseal key aes")
+
+	AssertCommand(t, s, commands.CommandKeystoreDelete, "aes")
+	AssertCommandFailure(t, s, commands.CommandKeystoreLoad, []string{"6", "aes"}, "key not found")
+}
+
+func TestCommand_Keystore_NoMasterKey(t *testing.T) {
+	s := NewTestSegment()
+
+	AssertCommandFailure(t, s, commands.CommandKeystoreGenerate, []string{"aes", "b32"}, "no keystore master key")
+}
+
 func TestCommandPyLen_Int(t *testing.T) {
 	s := NewTestSegment()
 
@@ -3057,8 +3229,6 @@ func TestCommandPyLen_Ed25519int(t *testing.T) {
 }
 
 func TestCommandPyLen_Ed25519(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
-
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewEd25519ArrayFromInt64sOrPanic(1, 2, 3, 4, 5, 6, 7))
@@ -3178,7 +3348,6 @@ func TestCommandBroadcastValue_Ed25519Int_ErrorWhenNotSingleton(t *testing.T) {
 	AssertCommandFailure(t, s, commands.CommandBroadcastValue, []string{"1", "2"}, "cannot broadcast array of size 3")
 }
 func TestCommandBroadcastValue_Ed25519(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewEd25519ArrayFromInt64sOrPanic(5))
@@ -3189,7 +3358,6 @@ func TestCommandBroadcastValue_Ed25519(t *testing.T) {
 	AssertValue(t, s, "1", types.NewEd25519ArrayFromInt64sOrPanic(5, 5, 5, 5))
 }
 func TestCommandBroadcastValue_Ed25519_ErrorWhenNotSingleton(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewEd25519ArrayFromInt64sOrPanic(5, 2))
@@ -3257,8 +3425,6 @@ func TestCommandEmptySliceToIndices(t *testing.T) {
 }
 
 func TestCommandEdFolded_EdFoldedProject(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
-
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewEd25519ArrayFromInt64sOrPanic(1, 2, 3, 4, 5))
@@ -3279,8 +3445,6 @@ func TestCommandEdFolded_EdFoldedProject(t *testing.T) {
 }
 
 func TestCommandEdAffine_EdAffineProject(t *testing.T) {
-	types.SkipIfGPUUnavailable(t)
-
 	s := NewTestSegment()
 
 	s.Variables().Set("1", types.NewEd25519ArrayFromInt64sOrPanic(1, 2, 3, 4, 5))
@@ -0,0 +1,797 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package segment
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AUSTRAC/ftillite/Peer/segment/commands"
+	"github.com/AUSTRAC/ftillite/Peer/segment/crypto"
+	"github.com/AUSTRAC/ftillite/Peer/segment/grant"
+	fthttp "github.com/AUSTRAC/ftillite/Peer/segment/net/http"
+	"github.com/AUSTRAC/ftillite/Peer/segment/seal"
+	"github.com/AUSTRAC/ftillite/Peer/segment/types"
+	"github.com/AUSTRAC/ftillite/Peer/segment/variables"
+)
+
+type testCA struct {
+	dir  string
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func writePEM(t *testing.T, path string, blockType string, bs []byte) {
+	t.Helper()
+
+	err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: bs}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ftillite test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca := &testCA{t.TempDir(), cert, key}
+	writePEM(t, filepath.Join(ca.dir, "ca.pem"), "CERTIFICATE", der)
+	return ca
+}
+
+// issue writes a certificate and key for the node, and returns their paths.
+func (ca *testCA) issue(t *testing.T, nodeID string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: nodeID},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(ca.dir, nodeID+".pem")
+	keyFile := filepath.Join(ca.dir, nodeID+".key")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+	return certFile, keyFile
+}
+
+func (ca *testCA) caFile() string {
+	return filepath.Join(ca.dir, "ca.pem")
+}
+
+func (ca *testCA) clientConfig(t *testing.T, nodeID string) *tls.Config {
+	t.Helper()
+
+	certFile, keyFile := ca.issue(t, nodeID)
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}, InsecureSkipVerify: true}
+}
+
+// newTestPeers starts a segment for each node, with the options set by configure, and
+// introduces them to each other.
+func newTestPeers(t *testing.T, configure func(nodeID string, o *Options), nodeIDs ...string) []*Segment {
+	t.Helper()
+
+	segments := make([]*Segment, len(nodeIDs))
+	peers := make([]string, len(nodeIDs))
+	for i, nodeID := range nodeIDs {
+		o := Options{
+			NodeIDString: nodeID,
+			Address:      "127.0.0.1:0",
+			DbChunkSize:  1000000000,
+		}
+		configure(nodeID, &o)
+
+		s, err := NewSegment(o, "sqlite3", "file::memory:?cache=shared")
+		if err != nil {
+			t.Fatal(err)
+		}
+		go s.StartHTTPServer()
+		t.Cleanup(func() { s.httpServer.Close() })
+
+		segments[i] = s
+		peers[i] = fmt.Sprintf("%v~%v", nodeID, s.httpListener.Addr())
+	}
+
+	for _, s := range segments {
+		AssertCommand(t, s, commands.CommandNetInit, peers...)
+	}
+	return segments
+}
+
+// newTestTLSPeers starts the segments serving over mutual TLS with certificates from ca.
+func newTestTLSPeers(t *testing.T, ca *testCA, nodeIDs ...string) []*Segment {
+	t.Helper()
+
+	return newTestPeers(t, func(nodeID string, o *Options) {
+		o.TLSCertFile, o.TLSKeyFile = ca.issue(t, nodeID)
+		o.TLSCAFile = ca.caFile()
+	}, nodeIDs...)
+}
+
+// newTestGrantPeers starts the segments requiring grants signed by the returned coordinator key.
+func newTestGrantPeers(t *testing.T, ca *testCA, nodeIDs ...string) ([]*Segment, ed25519.PrivateKey) {
+	t.Helper()
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return newTestPeers(t, func(nodeID string, o *Options) {
+		if ca != nil {
+			o.TLSCertFile, o.TLSKeyFile = ca.issue(t, nodeID)
+			o.TLSCAFile = ca.caFile()
+		}
+		o.CoordinatorGrantKey = public
+	}, nodeIDs...), private
+}
+
+func transmitURL(scheme string, s *Segment, path string) string {
+	return fmt.Sprintf("%v://%v/transmit/%v", scheme, s.httpListener.Addr(), path)
+}
+
+func testGrant(key ed25519.PrivateKey, source string, handle string, destination string) string {
+	return grant.Grant{Source: source, Handle: handle, Destination: destination, Expiry: time.Now().Add(time.Minute)}.Sign(key)
+}
+
+func TestTransmit_MutualTLS(t *testing.T) {
+	ca := newTestCA(t)
+	peers := newTestTLSPeers(t, ca, "1", "2")
+
+	peers[0].SetVariable("1", types.NewFTIntegerArray(1, 2, 3))
+	AssertCommand(t, peers[0], commands.CommandTransmit, "2", "2", "1", "i", "array")
+	AssertValue(t, peers[1], "2", types.NewFTIntegerArray(1, 2, 3))
+
+	for _, tc := range []struct {
+		name   string
+		config *tls.Config
+		method string
+		path   string
+		status int
+	}{
+		{"enrolled peer", ca.clientConfig(t, "2"), http.MethodGet, "1/i/rec/0", http.StatusOK},
+		{"unknown node", ca.clientConfig(t, "3"), http.MethodGet, "1/i/rec/0", http.StatusForbidden},
+		{"impersonating another node", ca.clientConfig(t, "2"), http.MethodPost, "1/i/req/3/1/array", http.StatusForbidden},
+	} {
+		client := http.Client{Transport: &http.Transport{TLSClientConfig: tc.config}}
+		req, err := http.NewRequest(tc.method, transmitURL("https", peers[0], tc.path), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%v: %v", tc.name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.status {
+			t.Errorf("%v: expected HTTP %v, got %v", tc.name, tc.status, resp.StatusCode)
+		}
+	}
+
+	// Peers without a certificate from the CA can not connect at all
+	for name, config := range map[string]*tls.Config{
+		"no certificate":    {InsecureSkipVerify: true},
+		"another CA's node": newTestCA(t).clientConfig(t, "2"),
+	} {
+		client := http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+		if resp, err := client.Get(transmitURL("https", peers[0], "1/i/rec/0")); err == nil {
+			resp.Body.Close()
+			t.Errorf("%v: expected the handshake to fail, got HTTP %v", name, resp.StatusCode)
+		}
+	}
+}
+
+func TestTransmit_MutualTLS_PinnedNodeID(t *testing.T) {
+	ca := newTestCA(t)
+	peers := newTestTLSPeers(t, ca, "1", "2")
+
+	// Node 1 is told that node 2 is at its own address, so the certificate it is presented with
+	// is for the wrong node
+	AssertCommand(t, peers[0], commands.CommandNetInit, fmt.Sprintf("2~%v", peers[0].httpListener.Addr()))
+
+	peers[0].SetVariable("1", types.NewFTIntegerArray(1, 2, 3))
+	AssertCommandFailure(t, peers[0], commands.CommandTransmit, []string{"2", "2", "1", "i", "array"}, `expected node "2"`)
+}
+
+func TestTransmit_Grants(t *testing.T) {
+	peers, coordinator := newTestGrantPeers(t, nil, "1", "2")
+
+	peers[0].SetVariable("1", types.NewFTIntegerArray(1, 2, 3))
+	args := []string{"2", "2", "1", "i", "array"}
+
+	AssertCommandFailure(t, peers[0], commands.CommandTransmit, args, "transfer grant is missing")
+	AssertCommandFailure(t, peers[0], commands.CommandTransmit, append(args, testGrant(coordinator, "1", "1", "3")), "not node 2")
+	AssertCommandFailure(t, peers[0], commands.CommandTransmit, append(args, testGrant(coordinator, "1", "4", "2")), "not handle 1")
+	if _, err := peers[1].GetVariable("2"); err == nil {
+		t.Error("expected nothing to have been transmitted without a valid grant")
+	}
+
+	AssertCommand(t, peers[0], commands.CommandTransmit, append(args, testGrant(coordinator, "1", "1", "2"))...)
+	AssertValue(t, peers[1], "2", types.NewFTIntegerArray(1, 2, 3))
+
+	// Peers sign the grants for the shares they deal themselves
+	AssertCommandResponse(t, peers[0], commands.CommandShare, []string{"3", "1", "1", "1", "2"}, "array i 3")
+	if _, err := peers[1].GetVariable("3"); err != nil {
+		t.Errorf("expected node 2 to have received its share: %v", err)
+	}
+
+	_, other, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, token := range map[string]string{
+		"no grant":            "",
+		"another signer":      testGrant(other, "1", "1", "2"),
+		"grant for a handle":  testGrant(coordinator, "1", "4", "2"),
+		"grant for a node":    testGrant(coordinator, "2", "1", "2"),
+		"grant which expired": grant.Grant{Source: "1", Handle: "1", Destination: "2", Expiry: time.Now()}.Sign(coordinator),
+	} {
+		req, err := http.NewRequest(http.MethodGet, transmitURL("http", peers[0], "1/i/rec/0"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set(fthttp.TransferGrantHeader, token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("%v: expected HTTP %v, got %v", name, http.StatusForbidden, resp.StatusCode)
+		}
+	}
+}
+
+func TestTransmit_GrantsInMessages(t *testing.T) {
+	peers, coordinator := newTestGrantPeers(t, nil, "1", "2")
+
+	peers[0].SetVariable("1", types.NewFTIntegerArray(1, 2, 3))
+
+	// A grant whose signature would contain "__" in base64url, which commands are split on
+	var token string
+	for i := 0; token == ""; i++ {
+		g := grant.Grant{Source: "1", Handle: "1", Destination: "2", Expiry: time.Now().Add(time.Minute + time.Duration(i)*time.Second)}
+		signed := g.Sign(coordinator)
+		signature, err := hex.DecodeString(signed[strings.Index(signed, ".")+1:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(base64.RawURLEncoding.EncodeToString(signature), "__") {
+			token = signed
+		}
+	}
+
+	message := fmt.Sprintf(`{"command": "%v 2 2 1 i array %v", "response_required": "True"}`, commands.CommandTransmit, token)
+	name, args, _, err := parseMessage([]byte(message))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args[len(args)-1] != token {
+		t.Fatalf("expected the grant %q, got %q", token, args[len(args)-1])
+	}
+	AssertCommand(t, peers[0], name, args...)
+	AssertValue(t, peers[1], "2", types.NewFTIntegerArray(1, 2, 3))
+}
+
+func TestTransmit_MutualTLSGrants(t *testing.T) {
+	ca := newTestCA(t)
+	peers, coordinator := newTestGrantPeers(t, ca, "1", "2", "3")
+
+	peers[0].SetVariable("1", types.NewFTIntegerArray(1, 2, 3))
+	AssertCommand(t, peers[0], commands.CommandTransmit, "2", "2", "1", "i", "array", testGrant(coordinator, "1", "1", "2"))
+	AssertValue(t, peers[1], "2", types.NewFTIntegerArray(1, 2, 3))
+
+	// A grant can only be used by the node it names
+	token := testGrant(coordinator, "1", "1", "2")
+	for nodeID, status := range map[string]int{"2": http.StatusOK, "3": http.StatusForbidden} {
+		client := http.Client{Transport: &http.Transport{TLSClientConfig: ca.clientConfig(t, nodeID)}}
+		req, err := http.NewRequest(http.MethodGet, transmitURL("https", peers[0], "1/i/rec/0"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set(fthttp.TransferGrantHeader, token)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("node %v: %v", nodeID, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Errorf("node %v: expected HTTP %v, got %v", nodeID, status, resp.StatusCode)
+		}
+	}
+}
+
+func getTransmitted(t *testing.T, client *http.Client, url string, acceptEncoding string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestTransmit_Compression(t *testing.T) {
+	peers := newTestPeers(t, func(nodeID string, o *Options) {
+		o.CompressionThreshold = 64
+	}, "1", "2")
+
+	values := make([]int64, 1000)
+	for i := range values {
+		values[i] = int64(i % 3)
+	}
+	peers[0].SetVariable("1", types.NewFTIntegerArray(values...))
+	peers[0].SetVariable("2", types.NewFTIntegerArray(1, 2, 3))
+	bytearrays, err := types.NewFTBytearrayArrayFromBytes(make([]byte, 16*1000), 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	peers[0].SetVariable("3", bytearrays)
+
+	AssertCommand(t, peers[0], commands.CommandTransmit, "2", "4", "1", "i", "array")
+	AssertValue(t, peers[1], "4", types.NewFTIntegerArray(values...))
+
+	// A compressed array is cached only while it is unchanged
+	changed := append([]int64{}, values...)
+	changed[0] = 7
+	peers[0].SetVariable("1", types.NewFTIntegerArray(changed...))
+	AssertCommand(t, peers[0], commands.CommandTransmit, "2", "4", "1", "i", "array")
+	AssertValue(t, peers[1], "4", types.NewFTIntegerArray(changed...))
+	peers[0].SetVariable("1", types.NewFTIntegerArray(values...))
+	AssertCommand(t, peers[0], commands.CommandTransmit, "2", "5", "2", "i", "array")
+	AssertValue(t, peers[1], "5", types.NewFTIntegerArray(1, 2, 3))
+	AssertCommand(t, peers[0], commands.CommandTransmit, "2", "6", "3", "b16", "array")
+	AssertValue(t, peers[1], "6", bytearrays)
+
+	// Ed25519 points are transmitted in extended coordinates, which are wider than the typecode
+	points, err := types.NewEd25519ArrayFromInt64sWithBackend(peers[0].Ed25519Backend(), make([]int64, 100)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	peers[0].SetVariable("7", points)
+	AssertCommand(t, peers[0], commands.CommandTransmit, "2", "8", "7", "E", "array")
+	AssertValue(t, peers[1], "8", points)
+
+	for _, tc := range []struct {
+		name           string
+		path           string
+		acceptEncoding string
+		length         string
+	}{
+		{"large array", "1/i/rec/0", "gzip", "8000"},
+		{"weighted gzip", "1/i/rec/0", "br;q=1.0, gzip;q=0.5", "8000"},
+		{"gzip refused", "1/i/rec/0", "gzip;q=0", ""},
+		{"identity", "1/i/rec/0", "identity", ""},
+		{"below the threshold", "2/i/rec/0", "gzip", ""},
+		{"Ed25519 points", "7/E/rec/0", "gzip", "16000"},
+	} {
+		resp := getTransmitted(t, http.DefaultClient, transmitURL("http", peers[0], tc.path), tc.acceptEncoding)
+		if compressed := resp.Header.Get("Content-Encoding") == "gzip"; compressed != (tc.length != "") {
+			t.Errorf("%v: expected compressed to be %v", tc.name, tc.length != "")
+		}
+		if length := resp.Header.Get(fthttp.UncompressedLengthHeader); length != tc.length {
+			t.Errorf("%v: expected an uncompressed length of %q, got %q", tc.name, tc.length, length)
+		}
+	}
+}
+
+func TestTransmit_CompressionPeers(t *testing.T) {
+	ca := newTestCA(t)
+	peers := newTestPeers(t, func(nodeID string, o *Options) {
+		o.TLSCertFile, o.TLSKeyFile = ca.issue(t, nodeID)
+		o.TLSCAFile = ca.caFile()
+		o.CompressionThreshold = 64
+		o.CompressionPeers = map[string]int{"3": 0}
+	}, "1", "2", "3")
+
+	values := make([]int64, 1000)
+	peers[0].SetVariable("1", types.NewFTIntegerArray(values...))
+
+	for nodeID, compressed := range map[string]bool{"2": true, "3": false} {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: ca.clientConfig(t, nodeID)}}
+		resp := getTransmitted(t, client, transmitURL("https", peers[0], "1/i/rec/0"), "gzip")
+		if (resp.Header.Get("Content-Encoding") == "gzip") != compressed {
+			t.Errorf("node %v: expected compressed to be %v", nodeID, compressed)
+		}
+	}
+
+	AssertCommand(t, peers[0], commands.CommandTransmit, "3", "2", "1", "i", "array")
+	AssertValue(t, peers[2], "2", types.NewFTIntegerArray(values...))
+}
+
+func TestDownloadStream_CompressedLength(t *testing.T) {
+	uncompressed := make([]byte, 800)
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(uncompressed)
+	zw.Close()
+
+	for _, tc := range []struct {
+		name         string
+		length       string
+		elementWidth int
+		expected     string
+	}{
+		{"valid", "800", 8, ""},
+		{"longer than stated", "792", 8, "decompressed array is 793 bytes, expected 792"},
+		{"shorter than stated", "808", 8, "decompressed array is 800 bytes, expected 808"},
+		{"not whole elements", "800", 24, "not a multiple of the element width 24"},
+		{"no length", "", 8, "no uncompressed length"},
+		{"beyond the compression ratio", "8000000000", 8, "too long for"},
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Header().Set(fthttp.UncompressedLengthHeader, tc.length)
+			w.Header().Set("Content-Length", fmt.Sprint(buf.Len()))
+			if r.Method != http.MethodHead {
+				w.Write(buf.Bytes())
+			}
+		}))
+
+		options := fthttp.DefaultOptions()
+		options.AcceptGzip = true
+		options.ElementWidth = tc.elementWidth
+		options.Retries = 1
+
+		var out bytes.Buffer
+		_, err := fthttp.DownloadStreamOpts(context.Background(), server.URL, &out, options)
+		server.Close()
+
+		if tc.expected == "" {
+			if err != nil || !bytes.Equal(out.Bytes(), uncompressed) {
+				t.Errorf("%v: unexpected download of %v bytes, %v", tc.name, out.Len(), err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), tc.expected) {
+			t.Errorf("%v: expected an error containing %q, got %v", tc.name, tc.expected, err)
+		}
+	}
+}
+
+// interruptingWriter aborts the response once limit bytes of its body have been written.
+type interruptingWriter struct {
+	http.ResponseWriter
+	limit int
+}
+
+func (w *interruptingWriter) Write(b []byte) (int, error) {
+	if len(b) > w.limit {
+		w.ResponseWriter.Write(b[:w.limit])
+		w.ResponseWriter.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}
+	w.limit -= len(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func TestDownloadStream_Resume(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 1<<14)
+	digest := sha256.Sum256(content)
+
+	for _, tc := range []struct {
+		name          string
+		interruptions int
+		etags         []string
+		digest        string
+		expected      string
+	}{
+		{"uninterrupted", 0, []string{`"1"`}, hex.EncodeToString(digest[:]), ""},
+		{"interrupted more often than the retries", 4, []string{`"1"`}, hex.EncodeToString(digest[:]), ""},
+		{"changed while resuming", 1, []string{`"1"`, `"2"`}, hex.EncodeToString(digest[:]), "the content changed"},
+		{"corrupted", 2, []string{`"1"`}, strings.Repeat("00", sha256.Size), "does not match its digest"},
+	} {
+		var ranges []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			etag := tc.etags[0]
+			if r.Method == http.MethodGet {
+				ranges = append(ranges, r.Header.Get("Range"))
+				if len(ranges) <= len(tc.etags) {
+					etag = tc.etags[len(ranges)-1]
+				} else {
+					etag = tc.etags[len(tc.etags)-1]
+				}
+			}
+			w.Header().Set("ETag", etag)
+			w.Header().Set(fthttp.ContentDigestHeader, tc.digest)
+
+			if r.Method == http.MethodGet && len(ranges) <= tc.interruptions {
+				w = &interruptingWriter{w, 50000}
+			}
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+		}))
+
+		options := fthttp.DefaultOptions()
+		options.BufferSize = 4096
+		options.Retries = 2
+		options.RetryWait = time.Millisecond
+
+		var out bytes.Buffer
+		_, err := fthttp.DownloadStreamOpts(context.Background(), server.URL, &out, options)
+		server.Close()
+
+		if tc.expected != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("%v: expected an error containing %q, got %v", tc.name, tc.expected, err)
+			}
+			continue
+		}
+
+		if err != nil || !bytes.Equal(out.Bytes(), content) {
+			t.Errorf("%v: unexpected download of %v bytes, %v", tc.name, out.Len(), err)
+		}
+		if len(ranges) != tc.interruptions+1 || ranges[0] != "" {
+			t.Errorf("%v: unexpected requests for %q", tc.name, ranges)
+		}
+		for _, r := range ranges[1:] {
+			if !strings.HasPrefix(r, "bytes=") {
+				t.Errorf("%v: expected the download to be resumed, not restarted", tc.name)
+			}
+		}
+	}
+}
+
+// newTestSealPeers returns peers which seal the arrays they transmit to each other, where the
+// peers in unsealed have no seal key and are unknown to the others.
+func newTestSealPeers(t *testing.T, nodeIDs []string, unsealed ...string) []*Segment {
+	t.Helper()
+
+	sealed := make(map[string]bool)
+	private := make(map[string][]byte)
+	public := make(map[string][]byte)
+	for _, nodeID := range nodeIDs {
+		k, err := crypto.X25519Keygen()
+		if err != nil {
+			t.Fatal(err)
+		}
+		private[nodeID] = k
+		public[nodeID], err = crypto.X25519PublicKey(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sealed[nodeID] = true
+	}
+
+	return newTestPeers(t, func(nodeID string, o *Options) {
+		o.CompressionThreshold = 64
+		o.TransferSealPeers = make(map[string][]byte)
+		for peer, k := range public {
+			if peer != nodeID {
+				o.TransferSealPeers[peer] = k
+			}
+		}
+		if sealed[nodeID] {
+			o.TransferSealKey = private[nodeID]
+		}
+	}, append(nodeIDs, unsealed...)...)
+}
+
+func TestTransmit_Sealed(t *testing.T) {
+	peers := newTestSealPeers(t, []string{"1", "2", "3"}, "4")
+
+	values := make([]int64, 1000)
+	for i := range values {
+		values[i] = int64(i % 3)
+	}
+	peers[0].SetVariable("1", types.NewFTIntegerArray(values...))
+	bytearrays, err := types.NewFTBytearrayArrayFromBytes(bytes.Repeat([]byte("0123456789abcdef"), 100), 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	peers[0].SetVariable("2", bytearrays)
+
+	AssertCommand(t, peers[0], commands.CommandTransmit, "2", "3", "1", "i", "array")
+	AssertValue(t, peers[1], "3", types.NewFTIntegerArray(values...))
+	AssertCommand(t, peers[0], commands.CommandTransmit, "3", "4", "2", "b16", "array")
+	AssertValue(t, peers[2], "4", bytearrays)
+
+	// A peer without a seal key is neither sent arrays nor sends them
+	AssertCommandFailure(t, peers[0], commands.CommandTransmit, []string{"4", "5", "2", "b16", "array"}, "HTTP 500")
+	peers[3].SetVariable("6", types.NewFTIntegerArray(1, 2, 3))
+	AssertCommandFailure(t, peers[3], commands.CommandTransmit, []string{"1", "7", "6", "i", "array"}, "HTTP 500")
+	for _, h := range []string{"5", "7"} {
+		if _, err := peers[0].GetVariable(variables.Handle(h)); err == nil {
+			t.Errorf("expected handle %v not to have been transmitted", h)
+		}
+		if _, err := peers[3].GetVariable(variables.Handle(h)); err == nil {
+			t.Errorf("expected handle %v not to have been transmitted", h)
+		}
+	}
+
+	for _, tc := range []struct {
+		destination string
+		status      int
+	}{
+		{"2", http.StatusOK},
+		{"3", http.StatusOK},
+		{"4", http.StatusForbidden},
+		{"", http.StatusForbidden},
+	} {
+		req, err := http.NewRequest(http.MethodGet, transmitURL("http", peers[0], "2/b16/rec/0"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set(fthttp.TransferDestinationHeader, tc.destination)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != tc.status {
+			t.Errorf("node %q: expected HTTP %v, got %v", tc.destination, tc.status, resp.StatusCode)
+			continue
+		}
+		if tc.status != http.StatusOK {
+			continue
+		}
+		if algorithm := resp.Header.Get(fthttp.SealedHeader); algorithm != seal.Algorithm {
+			t.Errorf("node %q: expected the array to be sealed, got %q", tc.destination, algorithm)
+		}
+		if encoding := resp.Header.Get("Content-Encoding"); encoding != "" {
+			t.Errorf("node %q: expected a sealed array to have no Content-Encoding, got %q", tc.destination, encoding)
+		}
+		if bytes.Contains(body, []byte("0123456789abcdef")) {
+			t.Errorf("node %q: the sealed array contains the array", tc.destination)
+		}
+
+		// Only the destination can open the array
+		for i, s := range peers[1:3] {
+			_, err := s.seals.Open(seal.Algorithm, "1", "2/0", body)
+			if opened := err == nil; opened != (s.node.NodeIDString == tc.destination) {
+				t.Errorf("node %q: unexpected result opening the array on node %v: %v", tc.destination, i+2, err)
+			}
+		}
+	}
+}
+
+func TestTransmit_GrantsResume(t *testing.T) {
+	peers, coordinator := newTestGrantPeers(t, nil, "1", "2", "3")
+
+	values := make([]int64, 1000)
+	for i := range values {
+		values[i] = int64(i)
+	}
+	peers[0].SetVariable("1", types.NewFTIntegerArray(values...))
+
+	get := func(token string, destination string, ifRange string) *http.Response {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodGet, transmitURL("http", peers[0], "1/i/rec/0"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set(fthttp.TransferGrantHeader, token)
+		req.Header.Set(fthttp.TransferDestinationHeader, destination)
+		if ifRange != "" {
+			req.Header.Set("Range", "bytes=4000-")
+			req.Header.Set("If-Range", ifRange)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	// A grant which expires at the start of the next second
+	expiry := time.Now().Truncate(time.Second).Add(time.Second)
+	token := grant.Grant{Source: "1", Handle: "1", Destination: "2", Expiry: expiry}.Sign(coordinator)
+	other := grant.Grant{Source: "1", Handle: "1", Destination: "2", Expiry: expiry.Add(-time.Second)}.Sign(coordinator)
+
+	resp := get(token, "2", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected HTTP %v, got %v", http.StatusOK, resp.StatusCode)
+	}
+	etag := resp.Header.Get("ETag")
+
+	time.Sleep(time.Until(expiry))
+
+	// Once the grant has expired, only the download it authorised can be resumed
+	for name, tc := range map[string]struct {
+		token       string
+		destination string
+		ifRange     string
+		status      int
+	}{
+		"resumed":             {token, "2", etag, http.StatusPartialContent},
+		"restarted":           {token, "2", "", http.StatusForbidden},
+		"another destination": {token, "3", etag, http.StatusForbidden},
+		"another content":     {token, "2", `"0"`, http.StatusForbidden},
+		"another grant":       {other, "2", etag, http.StatusForbidden},
+	} {
+		if resp := get(tc.token, tc.destination, tc.ifRange); resp.StatusCode != tc.status {
+			t.Errorf("%v: expected HTTP %v, got %v", name, tc.status, resp.StatusCode)
+		}
+	}
+	// Nor can a download be resumed once the content has changed
+	peers[0].SetVariable("1", types.NewFTIntegerArray(values[1:]...))
+	if resp := get(token, "2", etag); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("changed content: expected HTTP %v, got %v", http.StatusForbidden, resp.StatusCode)
+	}
+}
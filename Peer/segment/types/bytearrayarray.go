@@ -110,6 +110,19 @@ func (v *FTBytearrayArray) AsType(tc TypeCode) (TypeVal, error) {
 			}
 		}
 		return NewFTEd25519IntArray(xs...), nil
+	case RistrettoB:
+		if v.Width() != RistrettoPointBytes {
+			return nil, errors.New("can only convert from b32 to Ristretto")
+		}
+		xs := make([]*edwards25519.Point, len(v.array))
+		var err error
+		for i, v := range v.array {
+			xs[i], err = RistrettoDecode(v)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return &RistrettoArray{xs}, nil
 
 	default:
 		return nil, fmt.Errorf("conversion not supported: %v -> %v", v.TypeCode(), tc)
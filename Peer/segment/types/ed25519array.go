@@ -9,222 +9,176 @@
 
 package types
 
-// #cgo CFLAGS: -I${SRCDIR}/../../lib
-// #cgo LDFLAGS: -L${SRCDIR}/../../lib -lftcrypto -Wl,-rpath=${SRCDIR}/../../lib
-// #include "ftcrypto.h"
-import "C"
 import (
+	"bytes"
 	"errors"
 	"fmt"
-	"math"
-	"sort"
 
 	"filippo.io/edwards25519"
 )
 
-var FT_EXTENDED_POINT_BYTES C.ulong = 160
-
-var errLengthsDoNotMatch = errors.New("array sizes do not match")
-var errIndexesOutOfRange = errors.New("indexes out of range")
-
-func InitializeGPU() error {
-	err := C.ft_crypto_init()
-	if err != C.FT_ERR_NO_ERROR {
-		return FTError(err)
-	}
-	return nil
-}
-
-type GPUMemoryStats struct {
-	Free  uint64
-	Total uint64
-}
-
-func (m GPUMemoryStats) Used() uint64 {
-	return m.Total - m.Free
-}
-
-func GetGPUMemoryStats() (GPUMemoryStats, error) {
-	free := C.size_t(0)
-	total := C.size_t(0)
-
-	err := C.ft_crypto_device_memory(&free, &total)
-	if err != C.FT_ERR_NO_ERROR {
-		return GPUMemoryStats{}, FTError(err)
-	}
-	return GPUMemoryStats{
-		Free:  uint64(free),
-		Total: uint64(total),
-	}, nil
-}
-
-type FTError C.ft_error
-
-func (e FTError) Error() string {
-	cStr := C.ft_error_str(C.int(e))
-	return C.GoString(cStr)
-}
-
 type Ed25519Array struct {
-	handle         C.ft_ge25519_array
+	backend        Ed25519Backend
+	handle         Ed25519Handle
 	referenceCount int
 }
 
+// The constructors without a backend argument create arrays using the pure Go CPU
+// backend. Segments create their arrays with the ...WithBackend variants so that the
+// backend can be chosen through Options.
+
 func NewEmptyEd25519Array() *Ed25519Array {
-	return &Ed25519Array{nil, 0}
+	return NewEmptyEd25519ArrayWithBackend(defaultEd25519Backend)
+}
+func NewEmptyEd25519ArrayWithBackend(b Ed25519Backend) *Ed25519Array {
+	return &Ed25519Array{b, nil, 0}
 }
-func newEd25519ArrayFromHandle(h C.ft_ge25519_array) *Ed25519Array {
-	return &Ed25519Array{h, 0}
+func newEd25519ArrayFromHandle(b Ed25519Backend, h Ed25519Handle) *Ed25519Array {
+	return &Ed25519Array{b, h, 0}
 }
 
 func NewEd25519Array(size int64, value *edwards25519.Scalar) (*Ed25519Array, error) {
+	return NewEd25519ArrayWithBackend(defaultEd25519Backend, size, value)
+}
+func NewEd25519ArrayWithBackend(b Ed25519Backend, size int64, value *edwards25519.Scalar) (*Ed25519Array, error) {
 	if size == 0 {
-		return NewEmptyEd25519Array(), nil
-	}
-
-	arr := (C.ft_ge25519_array)(nil)
-
-	var v *C.uchar
-	if value != nil {
-		bytes := value.Bytes()
-		v = (*C.uchar)(&bytes[0])
+		return NewEmptyEd25519ArrayWithBackend(b), nil
 	}
 
-	err := C.ft_array_init_scalar(&arr, C.size_t(size), v)
-	if err != C.FT_ERR_NO_ERROR {
-		return nil, FTError(err)
+	arr, err := b.InitScalar(size, value)
+	if err != nil {
+		return nil, err
 	}
 
-	return newEd25519ArrayFromHandle(arr), nil
+	return newEd25519ArrayFromHandle(b, arr), nil
 }
+
 func NewEd25519ArrayFromBytes(bytes []byte) (*Ed25519Array, error) {
+	return NewEd25519ArrayFromBytesWithBackend(defaultEd25519Backend, bytes)
+}
+func NewEd25519ArrayFromBytesWithBackend(b Ed25519Backend, bytes []byte) (*Ed25519Array, error) {
 	if len(bytes) == 0 {
-		return NewEmptyEd25519Array(), nil
+		return NewEmptyEd25519ArrayWithBackend(b), nil
 	}
 
-	arr := (C.ft_ge25519_array)(nil)
-
-	resultCode := C.ft_array_from_bytes(&arr, (*C.uchar)(&bytes[0]), C.ulong(len(bytes)))
-	if resultCode != C.FT_ERR_NO_ERROR {
-		return nil, FTError(resultCode)
+	arr, err := b.FromBytes(bytes)
+	if err != nil {
+		return nil, err
 	}
 
-	return newEd25519ArrayFromHandle(arr), nil
+	return newEd25519ArrayFromHandle(b, arr), nil
 }
 
 func NewEd25519ArrayFromFoldedBytearrayArray(value *FTBytearrayArray) (*Ed25519Array, error) {
-	if value.Width() != C.FT_FOLDED_POINT_BYTES {
-		return NewEmptyEd25519Array(), fmt.Errorf("bytearray array was not a b%v", C.FT_FOLDED_POINT_BYTES)
+	return NewEd25519ArrayFromFoldedBytearrayArrayWithBackend(defaultEd25519Backend, value)
+}
+func NewEd25519ArrayFromFoldedBytearrayArrayWithBackend(b Ed25519Backend, value *FTBytearrayArray) (*Ed25519Array, error) {
+	if value.Width() != Ed25519FoldedPointBytes {
+		return NewEmptyEd25519ArrayWithBackend(b), fmt.Errorf("bytearray array was not a b%v", Ed25519FoldedPointBytes)
 	}
 
-	bytes := make([]byte, 0, len(value.array)*C.FT_FOLDED_POINT_BYTES)
+	bytes := make([]byte, 0, len(value.array)*Ed25519FoldedPointBytes)
 	for _, v := range value.array {
 		bytes = append(bytes, v...)
 	}
 
-	return NewEd25519ArrayFromFoldedBytes(bytes)
+	return NewEd25519ArrayFromFoldedBytesWithBackend(b, bytes)
 }
 
 func NewEd25519ArrayFromFoldedBytes(bytes []byte) (*Ed25519Array, error) {
+	return NewEd25519ArrayFromFoldedBytesWithBackend(defaultEd25519Backend, bytes)
+}
+func NewEd25519ArrayFromFoldedBytesWithBackend(b Ed25519Backend, bytes []byte) (*Ed25519Array, error) {
 	if len(bytes) == 0 {
-		return NewEmptyEd25519Array(), nil
+		return NewEmptyEd25519ArrayWithBackend(b), nil
 	}
 
-	arr := (C.ft_ge25519_array)(nil)
-
-	var result C.long = -1
-
-	resultCode := C.ft_array_from_bytes_folded(&result, &arr, (*C.uchar)(&bytes[0]), C.ulong(len(bytes)))
-	if resultCode != C.FT_ERR_NO_ERROR {
-		return nil, FTError(resultCode)
+	arr, result, err := b.FromFoldedBytes(bytes)
+	if err != nil {
+		return nil, err
 	}
 
 	if result != -1 {
 		panic(fmt.Sprintf("conversion last failed at index %v", result))
 	}
 
-	return newEd25519ArrayFromHandle(arr), nil
+	return newEd25519ArrayFromHandle(b, arr), nil
 }
 
 func NewEd25519ArrayFromAffineBytearrayArray(value *FTBytearrayArray) (*Ed25519Array, error) {
-	if value.Width() != C.FT_AFFINE_POINT_BYTES {
-		return NewEmptyEd25519Array(), fmt.Errorf("bytearray array was not a b%v", C.FT_AFFINE_POINT_BYTES)
+	return NewEd25519ArrayFromAffineBytearrayArrayWithBackend(defaultEd25519Backend, value)
+}
+func NewEd25519ArrayFromAffineBytearrayArrayWithBackend(b Ed25519Backend, value *FTBytearrayArray) (*Ed25519Array, error) {
+	if value.Width() != Ed25519AffinePointBytes {
+		return NewEmptyEd25519ArrayWithBackend(b), fmt.Errorf("bytearray array was not a b%v", Ed25519AffinePointBytes)
 	}
 
-	bytes := make([]byte, 0, len(value.array)*C.FT_AFFINE_POINT_BYTES)
+	bytes := make([]byte, 0, len(value.array)*Ed25519AffinePointBytes)
 	for _, v := range value.array {
 		bytes = append(bytes, v...)
 	}
 
-	return NewEd25519ArrayFromAffineBytes(bytes)
+	return NewEd25519ArrayFromAffineBytesWithBackend(b, bytes)
 }
 
 func NewEd25519ArrayFromAffineBytes(bytes []byte) (*Ed25519Array, error) {
+	return NewEd25519ArrayFromAffineBytesWithBackend(defaultEd25519Backend, bytes)
+}
+func NewEd25519ArrayFromAffineBytesWithBackend(b Ed25519Backend, bytes []byte) (*Ed25519Array, error) {
 	if len(bytes) == 0 {
-		return NewEmptyEd25519Array(), nil
+		return NewEmptyEd25519ArrayWithBackend(b), nil
 	}
 
-	arr := (C.ft_ge25519_array)(nil)
-
-	resultCode := C.ft_array_from_bytes_affine(&arr, (*C.uchar)(&bytes[0]), C.ulong(len(bytes)))
-	if resultCode != C.FT_ERR_NO_ERROR {
-		return nil, FTError(resultCode)
-	}
-
-	var result C.long = -1
-	resultCode = C.ft_array_validate(&result, arr)
-	if resultCode != C.FT_ERR_NO_ERROR {
-		return nil, FTError(resultCode)
+	arr, result, err := b.FromAffineBytes(bytes)
+	if err != nil {
+		return nil, err
 	}
 
 	if result != -1 {
 		panic(fmt.Sprintf("conversion last failed at index %v", result))
 	}
 
-	return newEd25519ArrayFromHandle(arr), nil
+	return newEd25519ArrayFromHandle(b, arr), nil
 }
 
 func NewEd25519ArrayFromInt(values []*edwards25519.Scalar) (*Ed25519Array, error) {
+	return NewEd25519ArrayFromIntWithBackend(defaultEd25519Backend, values)
+}
+func NewEd25519ArrayFromIntWithBackend(b Ed25519Backend, values []*edwards25519.Scalar) (*Ed25519Array, error) {
 	if len(values) == 0 {
-		return NewEmptyEd25519Array(), nil
+		return NewEmptyEd25519ArrayWithBackend(b), nil
 	}
 
-	arr := (C.ft_ge25519_array)(nil)
-
-	bytes := ScalarsToBytes(values)
-
-	resultCode := C.ft_array_from_scalars(&arr, (*C.uchar)(&bytes[0]), C.ulong(len(values)))
-	if resultCode != C.FT_ERR_NO_ERROR {
-		return nil, FTError(resultCode)
+	arr, err := b.FromScalars(values)
+	if err != nil {
+		return nil, err
 	}
 
-	return newEd25519ArrayFromHandle(arr), nil
+	return newEd25519ArrayFromHandle(b, arr), nil
 }
 
 func NewEd25519ArrayFromInt64s(values ...int64) (*Ed25519Array, error) {
+	return NewEd25519ArrayFromInt64sWithBackend(defaultEd25519Backend, values...)
+}
+func NewEd25519ArrayFromInt64sWithBackend(b Ed25519Backend, values ...int64) (*Ed25519Array, error) {
 	if len(values) == 0 {
-		return NewEmptyEd25519Array(), nil
+		return NewEmptyEd25519ArrayWithBackend(b), nil
 	}
 
-	for _, v := range values {
+	ints := make([]uint64, len(values))
+	for i, v := range values {
 		if v < 0 {
 			return nil, errors.New("only positive integers up to 9223372036854775807 are supported")
 		}
+		ints[i] = uint64(v)
 	}
 
-	arr := (C.ft_ge25519_array)(nil)
-
-	ints := make([]C.uint64_t, len(values))
-	for i, v := range values {
-		ints[i] = C.uint64_t(v)
-	}
-
-	resultCode := C.ft_array_from_small_scalars(&arr, (*C.uint64_t)(&ints[0]), C.size_t(len(ints)))
-	if resultCode != C.FT_ERR_NO_ERROR {
-		return nil, FTError(resultCode)
+	arr, err := b.FromSmallScalars(ints)
+	if err != nil {
+		return nil, err
 	}
 
-	return newEd25519ArrayFromHandle(arr), nil
+	return newEd25519ArrayFromHandle(b, arr), nil
 }
 func NewEd25519ArrayFromInt64sOrPanic(values ...int64) *Ed25519Array {
 	xs, err := NewEd25519ArrayFromInt64s(values...)
@@ -234,18 +188,25 @@ func NewEd25519ArrayFromInt64sOrPanic(values ...int64) *Ed25519Array {
 	return xs
 }
 
+// NewEd25519ArrayFromPointsWithBackend creates an array holding copies of the given points.
+func NewEd25519ArrayFromPointsWithBackend(b Ed25519Backend, points []*edwards25519.Point) (*Ed25519Array, error) {
+	bytes := make([]byte, 0, len(points)*Ed25519FoldedPointBytes)
+	for _, p := range points {
+		bytes = append(bytes, p.Bytes()...)
+	}
+	return NewEd25519ArrayFromFoldedBytesWithBackend(b, bytes)
+}
+
 func NewEd25519ArrayFromPoint(size int64, points *Ed25519Array, pointIndex int64) (*Ed25519Array, error) {
 	if size == 0 {
-		return NewEmptyEd25519Array(), nil
+		return NewEmptyEd25519ArrayWithBackend(points.backend), nil
 	}
 
-	arr := (C.ft_ge25519_array)(nil)
-
-	resultCode := C.ft_array_init_point(&arr, C.size_t(size), points.handle, C.size_t(pointIndex))
-	if resultCode != C.FT_ERR_NO_ERROR {
-		return nil, FTError(resultCode)
+	arr, err := points.backend.InitPoint(size, points.handle, pointIndex)
+	if err != nil {
+		return nil, err
 	}
-	return newEd25519ArrayFromHandle(arr), nil
+	return newEd25519ArrayFromHandle(points.backend, arr), nil
 }
 
 func (xs *Ed25519Array) String() string {
@@ -256,6 +217,26 @@ func (xs *Ed25519Array) IsEmpty() bool {
 	return xs.handle == nil
 }
 
+// Backend returns the backend which stores the points of the array.
+func (xs *Ed25519Array) Backend() Ed25519Backend {
+	return xs.backend
+}
+
+// WithBackend returns a copy of the array stored by the given backend.
+func (xs *Ed25519Array) WithBackend(b Ed25519Backend) (*Ed25519Array, error) {
+	if xs.backend == b {
+		return xs.Copy()
+	}
+	return NewEd25519ArrayFromBytesWithBackend(b, xs.ToBytes())
+}
+
+func (xs *Ed25519Array) checkBackend(ys *Ed25519Array) error {
+	if xs.backend != ys.backend {
+		return errBackendsDoNotMatch
+	}
+	return nil
+}
+
 func (xs *Ed25519Array) TypeCode() TypeCode {
 	return "E"
 }
@@ -263,7 +244,7 @@ func (xs *Ed25519Array) Name() string {
 	return "Ed25519Array"
 }
 func (xs *Ed25519Array) EstimatedSize() int64 {
-	return xs.Length() * int64(FT_EXTENDED_POINT_BYTES)
+	return xs.Length() * Ed25519ExtendedPointBytes
 }
 func (xs *Ed25519Array) DebugString() string {
 	return fmt.Sprintf("%v(Length=%v,Memory=%v)", xs.Name(), xs.Length(), PrintSize(uint64(xs.EstimatedSize())))
@@ -290,10 +271,15 @@ func (xs *Ed25519Array) Equals(other TypeVal) bool {
 			return false
 		}
 
-		results := make([]int64, xs.Length())
+		if xs.backend != ys.backend {
+			// Arrays from different backends are compared by their canonical encodings.
+			xsBytes, _ := xs.ToFoldedBytes()
+			ysBytes, _ := ys.ToFoldedBytes()
+			return bytes.Equal(xsBytes, ysBytes)
+		}
 
-		err := C.ft_equal((*C.int64_t)(&results[0]), xs.handle, ys.handle)
-		if err != C.FT_ERR_NO_ERROR {
+		results, err := xs.backend.Equal(xs.handle, ys.handle)
+		if err != nil {
 			panic(fmt.Sprintf("ft_equals: %v", err))
 		}
 
@@ -309,13 +295,13 @@ func (xs *Ed25519Array) Equals(other TypeVal) bool {
 }
 func (xs *Ed25519Array) Copy() (*Ed25519Array, error) {
 	if xs.IsEmpty() {
-		return NewEmptyEd25519Array(), nil
+		return NewEmptyEd25519ArrayWithBackend(xs.backend), nil
 	}
 	return xs.GetRange(0, xs.Length(), 1)
 }
 func (v *Ed25519Array) Lookup(indexes *FTIntegerArray, defaultValue ArrayTypeVal) (ArrayTypeVal, error) {
 	if defaultValue == nil {
-		zero, err := NewEd25519ArrayFromInt64s(0)
+		zero, err := NewEd25519ArrayFromInt64sWithBackend(v.backend, 0)
 		if err != nil {
 			return nil, err
 		}
@@ -363,6 +349,9 @@ func (v *Ed25519Array) Get(indexes *FTIntegerArray, defaultValue ArrayTypeVal) (
 	if !ok || defaultEd25519.Length() != 1 {
 		return nil, errors.New("default value must be a singleton Ed25519 array")
 	}
+	if err := v.checkBackend(defaultEd25519); err != nil {
+		return nil, err
+	}
 
 	result, err := NewEd25519ArrayFromPoint(indexes.Length(), defaultEd25519, 0)
 	if err != nil {
@@ -397,47 +386,38 @@ func (xs *Ed25519Array) Broadcast(length int64) error {
 	}
 
 	if length == 0 {
-		C.ft_array_free(xs.handle)
+		xs.backend.Free(xs.handle)
 		xs.handle = nil
 
 		return nil
 	}
 
-	arr := (C.ft_ge25519_array)(nil)
-
-	resultCode := C.ft_array_init_point(&arr, C.size_t(length), xs.handle, 0)
-	if resultCode != C.FT_ERR_NO_ERROR {
-		return FTError(resultCode)
+	arr, err := xs.backend.InitPoint(length, xs.handle, 0)
+	if err != nil {
+		return err
 	}
 
-	C.ft_array_free(xs.handle)
+	xs.backend.Free(xs.handle)
 	xs.handle = arr
 
 	return nil
 }
 
 func (xs *Ed25519Array) GetSubset(indexes []int64) (*Ed25519Array, error) {
-	arr := (C.ft_ge25519_array)(nil)
-
 	if len(indexes) == 0 {
-		return NewEmptyEd25519Array(), nil
+		return NewEmptyEd25519ArrayWithBackend(xs.backend), nil
 	}
 
 	if xs.IsEmpty() {
-		return NewEmptyEd25519Array(), errIndexesOutOfRange
+		return NewEmptyEd25519ArrayWithBackend(xs.backend), errIndexesOutOfRange
 	}
 
-	is := make([]C.size_t, len(indexes))
-	for i, v := range indexes {
-		is[i] = C.size_t(v)
-	}
-
-	err := C.ft_array_get_subset(&arr, (*C.size_t)(&is[0]), C.ulong(len(is)), xs.handle)
-	if err != C.FT_ERR_NO_ERROR {
-		return nil, FTError(err)
+	arr, err := xs.backend.GetSubset(xs.handle, indexes)
+	if err != nil {
+		return nil, err
 	}
 
-	return newEd25519ArrayFromHandle(arr), nil
+	return newEd25519ArrayFromHandle(xs.backend, arr), nil
 }
 func (xs *Ed25519Array) AssignZeroToSubset(indexes []int64) error {
 	if len(indexes) == 0 {
@@ -451,26 +431,7 @@ func (xs *Ed25519Array) AssignZeroToSubset(indexes []int64) error {
 		return nil
 	}
 
-	uniqueIndexes := make([]C.size_t, 0, len(indexes))
-	uniqueIndexeValuesMap := make(map[C.size_t]struct{})
-	for _, v := range indexes {
-		idx := C.size_t(v)
-
-		if _, exists := uniqueIndexeValuesMap[idx]; !exists {
-			uniqueIndexeValuesMap[idx] = struct{}{}
-			uniqueIndexes = append(uniqueIndexes, idx)
-		}
-	}
-
-	sort.Slice(uniqueIndexes, func(i, j int) bool {
-		return i < j
-	})
-
-	resultCode := C.ft_array_assign_zero_to_subset(xs.handle, (*C.size_t)(&uniqueIndexes[0]), C.ulong(len(uniqueIndexes)))
-	if resultCode != C.FT_ERR_NO_ERROR {
-		return FTError(resultCode)
-	}
-	return nil
+	return xs.backend.AssignZeroToSubset(xs.handle, indexes)
 }
 func (xs *Ed25519Array) AssignToSubset(indexes []int64, values *Ed25519Array) error {
 	if len(indexes) == 0 {
@@ -483,47 +444,16 @@ func (xs *Ed25519Array) AssignToSubset(indexes []int64, values *Ed25519Array) er
 		}
 		return nil
 	}
-	// Due to a bug in libftcrypto, we want to reduce the size of the index array
-	// to less than or equal the size of the xs Ed25519 array. We do this by
-	// removing duplicate indexes (taking the last), and then taking the
-	// subset of the values array using the original position of the index in
-	// the indexes array. For example:
 
-	// indexes: [0,24,12,3,12]
-	// values: [1,2,3,4,5]
-
-	// uniqueIndexes: [0,24,3,12]
-	// uniqueValueIndexes: [0,1,3,4]
-	// uniqueValues: [1,2,4,5]
-
-	uniqueIndexeValuesMap := make(map[C.size_t]int64)
-	for i, v := range indexes {
-		uniqueIndexeValuesMap[C.size_t(v)] = int64(i)
-	}
-
-	uniqueIndexes := make([]C.size_t, 0, len(uniqueIndexeValuesMap))
-	uniqueValueIndexes := make([]int64, 0, len(uniqueIndexeValuesMap))
-
-	for k, v := range uniqueIndexeValuesMap {
-		uniqueIndexes = append(uniqueIndexes, k)
-		uniqueValueIndexes = append(uniqueValueIndexes, v)
-	}
-
-	uniqueValues, err := values.GetSubset(uniqueValueIndexes)
-	if err != nil {
+	if err := xs.checkBackend(values); err != nil {
 		return err
 	}
-	defer uniqueValues.Free()
 
-	resultCode := C.ft_array_assign_to_subset(xs.handle, (*C.size_t)(&uniqueIndexes[0]), C.ulong(len(uniqueIndexes)), uniqueValues.handle)
-	if resultCode != C.FT_ERR_NO_ERROR {
-		return FTError(resultCode)
-	}
-	return nil
+	return xs.backend.AssignToSubset(xs.handle, indexes, values.handle)
 }
 func (v *Ed25519Array) Clone() (TypeVal, error) {
 	if v.IsEmpty() {
-		return NewEmptyEd25519Array(), nil
+		return NewEmptyEd25519ArrayWithBackend(v.backend), nil
 	}
 	return v.GetRange(0, v.Length(), 1)
 }
@@ -538,32 +468,24 @@ func (v *Ed25519Array) Remove(indexes *FTIntegerArray) error {
 		return nil
 	}
 
-	is := make([]C.size_t, indexes.Length())
-	for i, v := range indexes.Values() {
-		is[i] = C.size_t(v)
+	if indexes.Length() == 0 {
+		return nil
 	}
 
-	err := C.ft_array_delete_subset(v.handle, (*C.size_t)(&is[0]), C.ulong(len(is)))
-	if err != C.FT_ERR_NO_ERROR {
-		return FTError(err)
-	}
-	return nil
+	return v.backend.DeleteSubset(v.handle, indexes.Values())
 }
 
 func (xs *Ed25519Array) GetRange(start int64, stop int64, step int64) (*Ed25519Array, error) {
 	if xs.IsEmpty() {
-		return NewEmptyEd25519Array(), errors.New("can not get range on empty array")
+		return NewEmptyEd25519ArrayWithBackend(xs.backend), errors.New("can not get range on empty array")
 	}
 
-	arr := (C.ft_ge25519_array)(nil)
-
-	err := C.ft_array_get_range(&arr, C.size_t(start), C.size_t(stop), C.size_t(step), xs.handle)
-
-	if err != C.FT_ERR_NO_ERROR {
-		return nil, FTError(err)
+	arr, err := xs.backend.GetRange(xs.handle, start, stop, step)
+	if err != nil {
+		return nil, err
 	}
 
-	return newEd25519ArrayFromHandle(arr), nil
+	return newEd25519ArrayFromHandle(xs.backend, arr), nil
 }
 
 func (xs *Ed25519Array) ReferenceCount() int {
@@ -580,7 +502,7 @@ func (xs *Ed25519Array) Free() {
 	}
 
 	if xs.handle != nil {
-		C.ft_array_free(xs.handle)
+		xs.backend.Free(xs.handle)
 		xs.handle = nil
 		xs.referenceCount = 0
 	}
@@ -591,9 +513,7 @@ func (xs *Ed25519Array) Length() int64 {
 		return 0
 	}
 
-	result := C.ft_array_get_length(xs.handle)
-
-	return int64(result)
+	return xs.backend.Length(xs.handle)
 }
 
 func (xs *Ed25519Array) SetLength(x int64) error {
@@ -602,28 +522,22 @@ func (xs *Ed25519Array) SetLength(x int64) error {
 			return nil
 		}
 
-		arr := (C.ft_ge25519_array)(nil)
-
-		err := C.ft_array_init_scalar(&arr, C.size_t(x), nil)
-		if err != C.FT_ERR_NO_ERROR {
-			return FTError(err)
+		arr, err := xs.backend.InitScalar(x, nil)
+		if err != nil {
+			return err
 		}
 
 		xs.handle = arr
 		return nil
 
 	} else if x == 0 {
-		C.ft_array_free(xs.handle)
+		xs.backend.Free(xs.handle)
 		xs.handle = nil
 
 		return nil
 	}
 
-	resultCode := C.ft_array_set_length(xs.handle, C.size_t(x))
-	if resultCode != C.FT_ERR_NO_ERROR {
-		return FTError(resultCode)
-	}
-	return nil
+	return xs.backend.SetLength(xs.handle, x)
 }
 
 func (xs *Ed25519Array) ToBytes() []byte {
@@ -631,41 +545,38 @@ func (xs *Ed25519Array) ToBytes() []byte {
 		return make([]byte, 0)
 	}
 
-	len := C.ft_array_get_length(xs.handle)
-	nBytes := len * FT_EXTENDED_POINT_BYTES
-	b := make([]byte, nBytes)
-
-	C.ft_array_to_bytes((*C.uchar)(&b[0]), nBytes, xs.handle)
-
-	return b
+	return xs.backend.ToBytes(xs.handle)
 }
 
 func (xs *Ed25519Array) ToFoldedBytes() ([]byte, int64) {
 	if xs.IsEmpty() {
-		return make([]byte, 0), C.FT_FOLDED_POINT_BYTES
+		return make([]byte, 0), Ed25519FoldedPointBytes
 	}
 
-	len := C.ft_array_get_length(xs.handle)
-	nBytes := len * C.FT_FOLDED_POINT_BYTES
-	b := make([]byte, nBytes)
+	return xs.backend.ToFoldedBytes(xs.handle), Ed25519FoldedPointBytes
+}
 
-	C.ft_array_to_bytes_folded((*C.uchar)(&b[0]), nBytes, xs.handle)
+// Points returns a copy of the points in the array.
+func (xs *Ed25519Array) Points() ([]*edwards25519.Point, error) {
+	bs, width := xs.ToFoldedBytes()
 
-	return b, C.FT_FOLDED_POINT_BYTES
+	points := make([]*edwards25519.Point, len(bs)/int(width))
+	for i := range points {
+		p, err := new(edwards25519.Point).SetBytes(bs[int64(i)*width : int64(i+1)*width])
+		if err != nil {
+			return nil, err
+		}
+		points[i] = p
+	}
+	return points, nil
 }
 
 func (xs *Ed25519Array) ToAffineBytes() ([]byte, int64) {
 	if xs.IsEmpty() {
-		return make([]byte, 0), C.FT_AFFINE_POINT_BYTES
+		return make([]byte, 0), Ed25519AffinePointBytes
 	}
 
-	len := C.ft_array_get_length(xs.handle)
-	nBytes := len * C.FT_AFFINE_POINT_BYTES
-	b := make([]byte, nBytes)
-
-	C.ft_array_to_bytes_affine((*C.uchar)(&b[0]), nBytes, xs.handle)
-
-	return b, C.FT_AFFINE_POINT_BYTES
+	return xs.backend.ToAffineBytes(xs.handle), Ed25519AffinePointBytes
 }
 
 func (xs *Ed25519Array) Index() *FTIntegerArray {
@@ -673,8 +584,7 @@ func (xs *Ed25519Array) Index() *FTIntegerArray {
 		return NewFTIntegerArray()
 	}
 
-	values := make([]int64, xs.Length())
-	C.ft_index((*C.int64_t)(&values[0]), xs.handle)
+	values := xs.backend.Index(xs.handle)
 
 	result := make([]int64, 0)
 	for i, v := range values {
@@ -691,11 +601,7 @@ func (xs *Ed25519Array) Scale(y *edwards25519.Scalar) error {
 		return nil
 	}
 
-	bytes := y.Bytes()
-
-	C.ft_scale(xs.handle, (*C.uchar)(&bytes[0]))
-
-	return nil
+	return xs.backend.Scale(xs.handle, y)
 }
 
 func (xs *Ed25519Array) Contains(values ArrayTypeVal) (*FTIntegerArray, error) {
@@ -712,11 +618,13 @@ func (xs *Ed25519Array) Contains(values ArrayTypeVal) (*FTIntegerArray, error) {
 		return NewFTIntegerArray(), nil
 	}
 
-	results := make([]int64, ys.Length())
+	if err := xs.checkBackend(ys); err != nil {
+		return nil, err
+	}
 
-	resultCode := C.ft_array_contains((*C.int64_t)(&results[0]), ys.handle, xs.handle)
-	if resultCode != C.FT_ERR_NO_ERROR {
-		return nil, FTError(resultCode)
+	results, err := xs.backend.Contains(xs.handle, ys.handle)
+	if err != nil {
+		return nil, err
 	}
 
 	return &FTIntegerArray{results}, nil
@@ -724,7 +632,7 @@ func (xs *Ed25519Array) Contains(values ArrayTypeVal) (*FTIntegerArray, error) {
 
 func (xs *Ed25519Array) CumSum() (ArrayTypeVal, error) {
 	if xs.IsEmpty() {
-		return NewEmptyEd25519Array(), nil
+		return NewEmptyEd25519ArrayWithBackend(xs.backend), nil
 	}
 
 	ys, err := xs.Copy()
@@ -732,9 +640,9 @@ func (xs *Ed25519Array) CumSum() (ArrayTypeVal, error) {
 		return nil, err
 	}
 
-	resultCode := C.ft_array_scan(ys.handle)
-	if resultCode != C.FT_ERR_NO_ERROR {
-		return nil, FTError(resultCode)
+	err = ys.backend.Scan(ys.handle)
+	if err != nil {
+		return nil, err
 	}
 
 	return ys, nil
@@ -749,7 +657,10 @@ func (xs Ed25519Array) Mux(condition *FTIntegerArray, ifFalse ArrayTypeVal) (Arr
 		return nil, errLengthsDoNotMatch
 	}
 	if xs.IsEmpty() {
-		return NewEmptyEd25519Array(), nil
+		return NewEmptyEd25519ArrayWithBackend(xs.backend), nil
+	}
+	if err := xs.checkBackend(fs); err != nil {
+		return nil, err
 	}
 
 	results, err := xs.Copy()
@@ -757,9 +668,9 @@ func (xs Ed25519Array) Mux(condition *FTIntegerArray, ifFalse ArrayTypeVal) (Arr
 		return nil, err
 	}
 
-	resultCode := C.ft_array_mux(results.handle, (*C.int64_t)(&condition.Values()[0]), fs.handle)
-	if resultCode != C.FT_ERR_NO_ERROR {
-		return nil, FTError(resultCode)
+	err = results.backend.Mux(results.handle, condition.Values(), fs.handle)
+	if err != nil {
+		return nil, err
 	}
 	return results, nil
 
@@ -786,8 +697,6 @@ func (xs *Ed25519Array) ReduceSum(indexes *FTIntegerArray, values ArrayTypeVal)
 	return xs.ReduceISum(indexes, values)
 }
 
-const maxChunkSize int = 4_000_000
-
 func (xs *Ed25519Array) ReduceISum(indexes *FTIntegerArray, values ArrayTypeVal) error {
 	if xs.IsEmpty() {
 		if len(indexes.array) > 0 {
@@ -801,36 +710,15 @@ func (xs *Ed25519Array) ReduceISum(indexes *FTIntegerArray, values ArrayTypeVal)
 		return fmt.Errorf("values is not an %v", xs.Name())
 	}
 
-	reduceISumChunk := func(start int64, end int64) error {
-		indexesChunk := indexes.array[start:end]
-		summandChunk, err := vs.GetRange(int64(start), int64(end), 1)
-		if err != nil {
-			return err
-		}
-		defer summandChunk.Free()
-
-		is := make([]C.size_t, len(indexesChunk))
-		for i, v := range indexesChunk {
-			is[i] = C.size_t(v)
-		}
-
-		resultCode := C.ft_reduce_isum(xs.handle, (*C.size_t)(&is[0]), C.ulong(len(is)), summandChunk.handle)
-		if resultCode != C.FT_ERR_NO_ERROR {
-			return FTError(resultCode)
-		}
+	if len(indexes.array) == 0 {
 		return nil
 	}
 
-	for i := 0; i < len(indexes.array); i += maxChunkSize {
-		end := int64(math.Min(float64(len(indexes.array)), float64(i+maxChunkSize)))
-
-		err := reduceISumChunk(int64(i), end)
-		if err != nil {
-			return err
-		}
+	if err := xs.checkBackend(vs); err != nil {
+		return err
 	}
 
-	return nil
+	return xs.backend.ReduceISum(xs.handle, indexes.array, vs.handle)
 }
 
 func asEd25519Array(xs ArrayTypeVal) (*Ed25519Array, error) {
@@ -850,10 +738,13 @@ func (v *Ed25519Array) Eq(other ArrayTypeVal) (*FTIntegerArray, error) {
 	results := make([]int64, v.Length())
 
 	if !v.IsEmpty() {
-		resultCode := C.ft_equal((*C.int64_t)(&results[0]), v.handle, bs.handle)
+		if err := v.checkBackend(bs); err != nil {
+			return nil, err
+		}
 
-		if resultCode != C.FT_ERR_NO_ERROR {
-			return nil, FTError(resultCode)
+		results, err = v.backend.Equal(v.handle, bs.handle)
+		if err != nil {
+			return nil, err
 		}
 	}
 
@@ -873,9 +764,13 @@ func (v *Ed25519Array) Ne(other ArrayTypeVal) (*FTIntegerArray, error) {
 	results := make([]int64, v.Length())
 
 	if !v.IsEmpty() {
-		resultCode := C.ft_not_equal((*C.int64_t)(&results[0]), v.handle, bs.handle)
-		if resultCode != C.FT_ERR_NO_ERROR {
-			return nil, FTError(resultCode)
+		if err := v.checkBackend(bs); err != nil {
+			return nil, err
+		}
+
+		results, err = v.backend.NotEqual(v.handle, bs.handle)
+		if err != nil {
+			return nil, err
 		}
 	}
 
@@ -884,16 +779,19 @@ func (v *Ed25519Array) Ne(other ArrayTypeVal) (*FTIntegerArray, error) {
 
 func (xs *Ed25519Array) Neg() (ArrayNegTypeVal, error) {
 	if xs.IsEmpty() {
-		return NewEmptyEd25519Array(), nil
+		return NewEmptyEd25519ArrayWithBackend(xs.backend), nil
 	}
 
 	result, err := xs.Clone()
 	if err != nil {
-		return NewEmptyEd25519Array(), err
+		return NewEmptyEd25519ArrayWithBackend(xs.backend), err
 	}
 
 	ed25519Result := result.(*Ed25519Array)
-	C.ft_neg(ed25519Result.handle)
+	err = ed25519Result.backend.Neg(ed25519Result.handle)
+	if err != nil {
+		return NewEmptyEd25519ArrayWithBackend(xs.backend), err
+	}
 
 	return ed25519Result, nil
 }
@@ -909,7 +807,11 @@ func (xs *Ed25519Array) Add(other ArrayTypeVal) (ArrayTypeVal, error) {
 	}
 
 	if xs.IsEmpty() {
-		return NewEmptyEd25519Array(), nil
+		return NewEmptyEd25519ArrayWithBackend(xs.backend), nil
+	}
+
+	if err := xs.checkBackend(ys); err != nil {
+		return nil, err
 	}
 
 	result, err := xs.Copy()
@@ -917,9 +819,9 @@ func (xs *Ed25519Array) Add(other ArrayTypeVal) (ArrayTypeVal, error) {
 		return nil, err
 	}
 
-	resultCode := C.ft_add(result.handle, ys.handle)
-	if resultCode != C.FT_ERR_NO_ERROR {
-		return nil, FTError(resultCode)
+	err = result.backend.Add(result.handle, ys.handle)
+	if err != nil {
+		return nil, err
 	}
 
 	return result, nil
@@ -936,7 +838,11 @@ func (xs *Ed25519Array) Sub(other ArrayTypeVal) (ArrayTypeVal, error) {
 	}
 
 	if xs.IsEmpty() {
-		return NewEmptyEd25519Array(), nil
+		return NewEmptyEd25519ArrayWithBackend(xs.backend), nil
+	}
+
+	if err := xs.checkBackend(ys); err != nil {
+		return nil, err
 	}
 
 	result, err := xs.Copy()
@@ -944,9 +850,9 @@ func (xs *Ed25519Array) Sub(other ArrayTypeVal) (ArrayTypeVal, error) {
 		return nil, err
 	}
 
-	resultCode := C.ft_sub(result.handle, ys.handle)
-	if resultCode != C.FT_ERR_NO_ERROR {
-		return nil, FTError(resultCode)
+	err = result.backend.Sub(result.handle, ys.handle)
+	if err != nil {
+		return nil, err
 	}
 	return result, nil
 }
@@ -962,7 +868,7 @@ func (xs *Ed25519Array) Mul(other ArrayTypeVal) (ArrayTypeVal, error) {
 	}
 
 	if xs.IsEmpty() {
-		return NewEmptyEd25519Array(), nil
+		return NewEmptyEd25519ArrayWithBackend(xs.backend), nil
 	}
 
 	result, err := xs.Copy()
@@ -970,11 +876,9 @@ func (xs *Ed25519Array) Mul(other ArrayTypeVal) (ArrayTypeVal, error) {
 		return nil, err
 	}
 
-	bytes := ScalarsToBytes(ys.Values())
-
-	resultCode := C.ft_mul(result.handle, (*C.uchar)(&bytes[0]), C.ulong(ys.Length()))
-	if resultCode != C.FT_ERR_NO_ERROR {
-		return nil, FTError(resultCode)
+	err = result.backend.Mul(result.handle, ys.Values())
+	if err != nil {
+		return nil, err
 	}
 	return result, nil
 }
@@ -17,33 +17,22 @@ import (
 	"golang.org/x/exp/slices"
 )
 
-var GPUHasBeenInitialized = false
-
 func TestMain(m *testing.M) {
 
-	if (*EnableGPUFlag || EnableGPUEnv) && !GPUHasBeenInitialized {
+	if *EnableGPUFlag || EnableGPUEnv {
 		fmt.Printf("Initializing GPU\n")
-		err := InitializeGPU()
+		backend, err := NewGPUEd25519Backend()
 		if err != nil {
 			panic(err)
 		}
-		GPUHasBeenInitialized = true
+		defaultEd25519Backend = backend
 	}
 
 	code := m.Run()
 	os.Exit(code)
 }
 
-func TestErrorString(t *testing.T) {
-	errString := FTError(1).Error()
-	if errString != "out of memory" {
-		t.Errorf("FTError(1) should return 'out of memory', but got '%v'", errString)
-	}
-}
-
 func TestArrayInit_NoValue(t *testing.T) {
-	SkipIfGPUUnavailable(t)
-
 	xs, err := NewEd25519Array(2, nil)
 	defer xs.Free()
 
@@ -57,8 +46,6 @@ func TestArrayInit_NoValue(t *testing.T) {
 }
 
 func TestArrayInit_WithValue(t *testing.T) {
-	SkipIfGPUUnavailable(t)
-
 	xs, err := NewEd25519Array(2, Int64ToScalar(5))
 	defer xs.Free()
 
@@ -81,8 +68,6 @@ func TestArrayInit_WithValue(t *testing.T) {
 }
 
 func TestArrayInit_ZeroLength(t *testing.T) {
-	SkipIfGPUUnavailable(t)
-
 	xs, err := NewEd25519Array(0, nil)
 	defer xs.Free()
 
@@ -96,8 +81,6 @@ func TestArrayInit_ZeroLength(t *testing.T) {
 }
 
 func TestArrayToBytes(t *testing.T) {
-	SkipIfGPUUnavailable(t)
-
 	xs, err := NewEd25519ArrayFromInt64s(5, 10)
 	defer xs.Free()
 	if err != nil {
@@ -106,8 +89,6 @@ func TestArrayToBytes(t *testing.T) {
 }
 
 func TestArrayToFromFoldedBytes(t *testing.T) {
-	SkipIfGPUUnavailable(t)
-
 	xs, err := NewEd25519ArrayFromInt64s(5, 10, 15, 20, 25, 30, 35, 40)
 	if err != nil {
 		t.Fatal(err)
@@ -129,8 +110,6 @@ func TestArrayToFromFoldedBytes(t *testing.T) {
 }
 
 func TestArrayFromBytes(t *testing.T) {
-	SkipIfGPUUnavailable(t)
-
 	xs, err := NewEd25519ArrayFromInt64s(5, 10)
 	defer xs.Free()
 	if err != nil {
@@ -151,8 +130,6 @@ func TestArrayFromBytes(t *testing.T) {
 }
 
 func TestArrayFromEmptyBytes(t *testing.T) {
-	SkipIfGPUUnavailable(t)
-
 	bs := []byte{}
 
 	xs, err := NewEd25519ArrayFromBytes(bs)
@@ -166,8 +143,6 @@ func TestArrayFromEmptyBytes(t *testing.T) {
 	}
 }
 func TestArrayFromEmptyAffineBytes(t *testing.T) {
-	SkipIfGPUUnavailable(t)
-
 	bs := []byte{}
 
 	xs, err := NewEd25519ArrayFromAffineBytes(bs)
@@ -182,8 +157,6 @@ func TestArrayFromEmptyAffineBytes(t *testing.T) {
 }
 
 func TestArrayFromEmptyFoldedBytes(t *testing.T) {
-	SkipIfGPUUnavailable(t)
-
 	bs := []byte{}
 
 	xs, err := NewEd25519ArrayFromFoldedBytes(bs)
@@ -198,8 +171,6 @@ func TestArrayFromEmptyFoldedBytes(t *testing.T) {
 }
 
 func TestArrayFromInts(t *testing.T) {
-	SkipIfGPUUnavailable(t)
-
 	xs, err := NewEd25519ArrayFromInt64s(5, 10)
 	defer xs.Free()
 	if err != nil {
@@ -227,8 +198,6 @@ func TestArrayFromInts(t *testing.T) {
 }
 
 func TestArrayFromInt64s(t *testing.T) {
-	SkipIfGPUUnavailable(t)
-
 	xs, err := NewEd25519ArrayFromInt64s(5, 10)
 	defer xs.Free()
 	if err != nil {
@@ -256,8 +225,6 @@ func TestArrayFromInt64s(t *testing.T) {
 }
 
 func TestIndex(t *testing.T) {
-	SkipIfGPUUnavailable(t)
-
 	values := NewEd25519ArrayFromInt64sOrPanic(0, 1, 2, 0, 1, 2)
 
 	expected := []int64{1, 2, 4, 5}
@@ -269,8 +236,6 @@ func TestIndex(t *testing.T) {
 }
 
 func TestReduceISum(t *testing.T) {
-	SkipIfGPUUnavailable(t)
-
 	arr, err := NewEd25519ArrayFromInt64s(5, 8, 4, 5, 50)
 	if err != nil {
 		t.Fatal(err)
@@ -298,8 +263,6 @@ func TestReduceISum(t *testing.T) {
 }
 
 func TestPairwiseEquals(t *testing.T) {
-	SkipIfGPUUnavailable(t)
-
 	xs, err := NewEd25519ArrayFromInt64s(5, 10)
 	defer xs.Free()
 	if err != nil {
@@ -323,8 +286,6 @@ func TestPairwiseEquals(t *testing.T) {
 }
 
 func TestPairwiseNotEquals(t *testing.T) {
-	SkipIfGPUUnavailable(t)
-
 	xs, err := NewEd25519ArrayFromInt64s(5, 10)
 	defer xs.Free()
 	if err != nil {
@@ -348,8 +309,6 @@ func TestPairwiseNotEquals(t *testing.T) {
 }
 
 func TestReduceSumAssignToSubsetIssue(t *testing.T) {
-	SkipIfGPUUnavailable(t)
-
 	xs, err := NewEd25519Array(5, nil)
 	if err != nil {
 		t.Error(err)
@@ -371,8 +330,6 @@ func TestReduceSumAssignToSubsetIssue(t *testing.T) {
 }
 
 func TestAssignToSubsetIssue(t *testing.T) {
-	SkipIfGPUUnavailable(t)
-
 	is := make([]int64, 30)
 	is[29] = 1
 
@@ -0,0 +1,100 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package types
+
+import (
+	"errors"
+
+	"filippo.io/edwards25519"
+)
+
+// Sizes of the serialised forms of a single Ed25519 point. These match the layouts
+// used by libftcrypto so that serialised arrays can be moved between backends.
+const (
+	Ed25519ExtendedPointBytes = 160
+	Ed25519FoldedPointBytes   = 32
+	Ed25519AffinePointBytes   = 64
+)
+
+var errLengthsDoNotMatch = errors.New("array sizes do not match")
+var errIndexesOutOfRange = errors.New("indexes out of range")
+var errBackendsDoNotMatch = errors.New("arrays are stored by different Ed25519 backends")
+
+type GPUMemoryStats struct {
+	Free  uint64
+	Total uint64
+}
+
+func (m GPUMemoryStats) Used() uint64 {
+	return m.Total - m.Free
+}
+
+// Ed25519Handle is an opaque reference to an array of points owned by an Ed25519Backend.
+// Only the backend which created a handle knows what it refers to.
+type Ed25519Handle any
+
+// Ed25519Backend is implemented by the libraries which store and operate on arrays of
+// Ed25519 points. The methods mirror the ft_* primitives of libftcrypto, and
+// operations which take more than one handle are only ever given handles created by
+// the same backend.
+//
+// Each Ed25519Array remembers the backend it was created with, so several backends can
+// be used in the same process. Implementations must be comparable with ==, as arrays
+// check that their backends match before combining them.
+type Ed25519Backend interface {
+	// InitScalar creates an array where every point is value*G, or the identity if value is nil.
+	InitScalar(size int64, value *edwards25519.Scalar) (Ed25519Handle, error)
+	// InitPoint creates an array where every point is a copy of points[index].
+	InitPoint(size int64, points Ed25519Handle, index int64) (Ed25519Handle, error)
+	FromScalars(values []*edwards25519.Scalar) (Ed25519Handle, error)
+	FromSmallScalars(values []uint64) (Ed25519Handle, error)
+
+	// FromBytes, FromFoldedBytes and FromAffineBytes deserialise points. The folded and
+	// affine variants return the index of the last point which failed to decode, or -1.
+	FromBytes(bs []byte) (Ed25519Handle, error)
+	FromFoldedBytes(bs []byte) (Ed25519Handle, int64, error)
+	FromAffineBytes(bs []byte) (Ed25519Handle, int64, error)
+	ToBytes(h Ed25519Handle) []byte
+	ToFoldedBytes(h Ed25519Handle) []byte
+	ToAffineBytes(h Ed25519Handle) []byte
+
+	Length(h Ed25519Handle) int64
+	SetLength(h Ed25519Handle, length int64) error
+	Free(h Ed25519Handle)
+
+	GetRange(h Ed25519Handle, start, stop, step int64) (Ed25519Handle, error)
+	GetSubset(h Ed25519Handle, indexes []int64) (Ed25519Handle, error)
+	AssignToSubset(h Ed25519Handle, indexes []int64, values Ed25519Handle) error
+	AssignZeroToSubset(h Ed25519Handle, indexes []int64) error
+	DeleteSubset(h Ed25519Handle, indexes []int64) error
+
+	Equal(h Ed25519Handle, other Ed25519Handle) ([]int64, error)
+	NotEqual(h Ed25519Handle, other Ed25519Handle) ([]int64, error)
+	// Contains returns, for each point in values, 1 if it is present in h and 0 otherwise.
+	Contains(h Ed25519Handle, values Ed25519Handle) ([]int64, error)
+	// Index returns 1 for each point which is not the identity and 0 otherwise.
+	Index(h Ed25519Handle) []int64
+
+	// Scan replaces each point with the sum of itself and all the points before it.
+	Scan(h Ed25519Handle) error
+	// Mux replaces each point where condition is 0 with the corresponding point from ifFalse.
+	Mux(h Ed25519Handle, condition []int64, ifFalse Ed25519Handle) error
+	// ReduceISum adds each summand to the point at the corresponding index.
+	ReduceISum(h Ed25519Handle, indexes []int64, summands Ed25519Handle) error
+
+	Scale(h Ed25519Handle, value *edwards25519.Scalar) error
+	Mul(h Ed25519Handle, values []*edwards25519.Scalar) error
+	Add(h Ed25519Handle, other Ed25519Handle) error
+	Sub(h Ed25519Handle, other Ed25519Handle) error
+	Neg(h Ed25519Handle) error
+}
+
+// defaultEd25519Backend is used by the constructors which are not given a backend.
+var defaultEd25519Backend Ed25519Backend = NewCPUEd25519Backend()
@@ -0,0 +1,600 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package types
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+
+	"filippo.io/edwards25519"
+	"filippo.io/edwards25519/field"
+)
+
+// cpuEd25519Backend is a pure Go implementation of the libftcrypto primitives built on
+// filippo.io/edwards25519. It is used whenever the GPU has not been initialised.
+//
+// The serialised forms are byte-for-byte compatible with libftcrypto:
+//   - extended: X, Y, Z and T, each as five little-endian 64-bit limbs in radix 2^51
+//   - folded:   the 32 byte compressed encoding from RFC 8032
+//   - affine:   x followed by y, each as 32 little-endian bytes
+//
+// Extended points are always written with Z = 1 so that equal points serialise to equal
+// bytes. Any valid projective representation is accepted when reading, provided each limb is
+// less than 2^51.
+type cpuEd25519Backend struct{}
+
+// NewCPUEd25519Backend returns the pure Go backend. It needs no initialisation and is
+// always available.
+func NewCPUEd25519Backend() Ed25519Backend {
+	return cpuEd25519Backend{}
+}
+
+type cpuEd25519Points struct {
+	points []edwards25519.Point
+}
+
+// cpuParallelThreshold is the array length from which point arithmetic is spread across
+// all available cores.
+const cpuParallelThreshold = 1024
+
+const mask51 = (1 << 51) - 1
+
+func cpuPoints(h Ed25519Handle) []edwards25519.Point {
+	return h.(*cpuEd25519Points).points
+}
+
+func newCPUPoints(size int64) *cpuEd25519Points {
+	points := make([]edwards25519.Point, size)
+	identity := edwards25519.NewIdentityPoint()
+	for i := range points {
+		points[i].Set(identity)
+	}
+	return &cpuEd25519Points{points}
+}
+
+// parallelFor calls f over disjoint ranges which together cover [0, n).
+func parallelFor(n int, f func(start, end int)) {
+	workers := runtime.GOMAXPROCS(0)
+	if n < cpuParallelThreshold || workers == 1 {
+		f(0, n)
+		return
+	}
+
+	chunk := (n + workers - 1) / workers
+
+	var wg sync.WaitGroup
+	for start := 0; start < n; start += chunk {
+		end := start + chunk
+		if end > n {
+			end = n
+		}
+
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			f(start, end)
+		}(start, end)
+	}
+	wg.Wait()
+}
+
+func uint64ToScalar(x uint64) *edwards25519.Scalar {
+	bs := make([]byte, 32)
+	binary.LittleEndian.PutUint64(bs, x)
+
+	s, err := edwards25519.NewScalar().SetCanonicalBytes(bs)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func checkIndexes(indexes []int64, length int) error {
+	for _, v := range indexes {
+		if v < 0 || v >= int64(length) {
+			return errIndexesOutOfRange
+		}
+	}
+	return nil
+}
+
+func (cpuEd25519Backend) InitScalar(size int64, value *edwards25519.Scalar) (Ed25519Handle, error) {
+	xs := newCPUPoints(size)
+	if value == nil {
+		return xs, nil
+	}
+
+	p := edwards25519.NewIdentityPoint().ScalarBaseMult(value)
+	for i := range xs.points {
+		xs.points[i].Set(p)
+	}
+	return xs, nil
+}
+
+func (cpuEd25519Backend) InitPoint(size int64, points Ed25519Handle, index int64) (Ed25519Handle, error) {
+	ps := cpuPoints(points)
+	if index < 0 || index >= int64(len(ps)) {
+		return nil, errIndexesOutOfRange
+	}
+
+	xs := newCPUPoints(size)
+	for i := range xs.points {
+		xs.points[i].Set(&ps[index])
+	}
+	return xs, nil
+}
+
+func (cpuEd25519Backend) FromScalars(values []*edwards25519.Scalar) (Ed25519Handle, error) {
+	xs := newCPUPoints(int64(len(values)))
+
+	parallelFor(len(values), func(start, end int) {
+		for i := start; i < end; i++ {
+			xs.points[i].ScalarBaseMult(values[i])
+		}
+	})
+	return xs, nil
+}
+
+func (cpuEd25519Backend) FromSmallScalars(values []uint64) (Ed25519Handle, error) {
+	xs := newCPUPoints(int64(len(values)))
+
+	parallelFor(len(values), func(start, end int) {
+		for i := start; i < end; i++ {
+			xs.points[i].ScalarBaseMult(uint64ToScalar(values[i]))
+		}
+	})
+	return xs, nil
+}
+
+func (cpuEd25519Backend) FromBytes(bs []byte) (Ed25519Handle, error) {
+	if len(bs)%Ed25519ExtendedPointBytes != 0 {
+		return nil, fmt.Errorf("byte array length must be a multiple of %v", Ed25519ExtendedPointBytes)
+	}
+
+	xs := newCPUPoints(int64(len(bs) / Ed25519ExtendedPointBytes))
+
+	for i := range xs.points {
+		b := bs[i*Ed25519ExtendedPointBytes : (i+1)*Ed25519ExtendedPointBytes]
+
+		var coordinates [4]*field.Element
+		for j := range coordinates {
+			var err error
+			coordinates[j], err = fieldElementFromLimbs(b[j*40 : (j+1)*40])
+			if err != nil {
+				return nil, fmt.Errorf("invalid point at index %v: %w", i, err)
+			}
+		}
+
+		_, err := xs.points[i].SetExtendedCoordinates(coordinates[0], coordinates[1], coordinates[2], coordinates[3])
+		if err != nil {
+			return nil, fmt.Errorf("invalid point at index %v: %w", i, err)
+		}
+	}
+	return xs, nil
+}
+
+func (cpuEd25519Backend) FromFoldedBytes(bs []byte) (Ed25519Handle, int64, error) {
+	if len(bs)%Ed25519FoldedPointBytes != 0 {
+		return nil, -1, fmt.Errorf("byte array length must be a multiple of %v", Ed25519FoldedPointBytes)
+	}
+
+	xs := newCPUPoints(int64(len(bs) / Ed25519FoldedPointBytes))
+	failed := int64(-1)
+
+	for i := range xs.points {
+		b := bs[i*Ed25519FoldedPointBytes : (i+1)*Ed25519FoldedPointBytes]
+
+		if _, err := xs.points[i].SetBytes(b); err != nil {
+			failed = int64(i)
+		}
+	}
+	return xs, failed, nil
+}
+
+func (cpuEd25519Backend) FromAffineBytes(bs []byte) (Ed25519Handle, int64, error) {
+	if len(bs)%Ed25519AffinePointBytes != 0 {
+		return nil, -1, fmt.Errorf("byte array length must be a multiple of %v", Ed25519AffinePointBytes)
+	}
+
+	xs := newCPUPoints(int64(len(bs) / Ed25519AffinePointBytes))
+	failed := int64(-1)
+	one := new(field.Element).One()
+
+	for i := range xs.points {
+		b := bs[i*Ed25519AffinePointBytes : (i+1)*Ed25519AffinePointBytes]
+
+		x, errX := new(field.Element).SetBytes(b[:32])
+		y, errY := new(field.Element).SetBytes(b[32:])
+		if errX != nil || errY != nil {
+			failed = int64(i)
+			continue
+		}
+
+		t := new(field.Element).Multiply(x, y)
+		if _, err := xs.points[i].SetExtendedCoordinates(x, y, one, t); err != nil {
+			xs.points[i].Set(edwards25519.NewIdentityPoint())
+			failed = int64(i)
+		}
+	}
+	return xs, failed, nil
+}
+
+// affineCoordinates returns the x and y coordinates of p with Z = 1.
+func affineCoordinates(p *edwards25519.Point) (x, y *field.Element) {
+	X, Y, Z, _ := p.ExtendedCoordinates()
+
+	zInv := new(field.Element).Invert(Z)
+	x = new(field.Element).Multiply(X, zInv)
+	y = new(field.Element).Multiply(Y, zInv)
+	return x, y
+}
+
+func (cpuEd25519Backend) ToBytes(h Ed25519Handle) []byte {
+	ps := cpuPoints(h)
+	b := make([]byte, len(ps)*Ed25519ExtendedPointBytes)
+	one := new(field.Element).One()
+
+	parallelFor(len(ps), func(start, end int) {
+		for i := start; i < end; i++ {
+			x, y := affineCoordinates(&ps[i])
+			t := new(field.Element).Multiply(x, y)
+
+			offset := i * Ed25519ExtendedPointBytes
+			putFieldElementLimbs(b[offset:offset+40], x)
+			putFieldElementLimbs(b[offset+40:offset+80], y)
+			putFieldElementLimbs(b[offset+80:offset+120], one)
+			putFieldElementLimbs(b[offset+120:offset+160], t)
+		}
+	})
+	return b
+}
+
+func (cpuEd25519Backend) ToFoldedBytes(h Ed25519Handle) []byte {
+	ps := cpuPoints(h)
+	b := make([]byte, len(ps)*Ed25519FoldedPointBytes)
+
+	parallelFor(len(ps), func(start, end int) {
+		for i := start; i < end; i++ {
+			copy(b[i*Ed25519FoldedPointBytes:], ps[i].Bytes())
+		}
+	})
+	return b
+}
+
+func (cpuEd25519Backend) ToAffineBytes(h Ed25519Handle) []byte {
+	ps := cpuPoints(h)
+	b := make([]byte, len(ps)*Ed25519AffinePointBytes)
+
+	parallelFor(len(ps), func(start, end int) {
+		for i := start; i < end; i++ {
+			x, y := affineCoordinates(&ps[i])
+
+			offset := i * Ed25519AffinePointBytes
+			copy(b[offset:offset+32], x.Bytes())
+			copy(b[offset+32:offset+64], y.Bytes())
+		}
+	})
+	return b
+}
+
+func (cpuEd25519Backend) Length(h Ed25519Handle) int64 {
+	return int64(len(cpuPoints(h)))
+}
+
+func (cpuEd25519Backend) SetLength(h Ed25519Handle, length int64) error {
+	xs := h.(*cpuEd25519Points)
+
+	if length <= int64(len(xs.points)) {
+		xs.points = xs.points[:length]
+		return nil
+	}
+
+	ys := newCPUPoints(length)
+	copy(ys.points, xs.points)
+	xs.points = ys.points
+	return nil
+}
+
+func (cpuEd25519Backend) Free(h Ed25519Handle) {
+	h.(*cpuEd25519Points).points = nil
+}
+
+func (cpuEd25519Backend) GetRange(h Ed25519Handle, start, stop, step int64) (Ed25519Handle, error) {
+	ps := cpuPoints(h)
+	if start < 0 || stop > int64(len(ps)) || step <= 0 {
+		return nil, errIndexesOutOfRange
+	}
+
+	xs := &cpuEd25519Points{make([]edwards25519.Point, 0)}
+	for i := start; i < stop; i += step {
+		var p edwards25519.Point
+		p.Set(&ps[i])
+		xs.points = append(xs.points, p)
+	}
+	return xs, nil
+}
+
+func (cpuEd25519Backend) GetSubset(h Ed25519Handle, indexes []int64) (Ed25519Handle, error) {
+	ps := cpuPoints(h)
+	if err := checkIndexes(indexes, len(ps)); err != nil {
+		return nil, err
+	}
+
+	xs := newCPUPoints(int64(len(indexes)))
+	for i, idx := range indexes {
+		xs.points[i].Set(&ps[idx])
+	}
+	return xs, nil
+}
+
+func (cpuEd25519Backend) AssignToSubset(h Ed25519Handle, indexes []int64, values Ed25519Handle) error {
+	ps := cpuPoints(h)
+	vs := cpuPoints(values)
+
+	if len(vs) < len(indexes) {
+		return errLengthsDoNotMatch
+	}
+	if err := checkIndexes(indexes, len(ps)); err != nil {
+		return err
+	}
+
+	for i, idx := range indexes {
+		ps[idx].Set(&vs[i])
+	}
+	return nil
+}
+
+func (cpuEd25519Backend) AssignZeroToSubset(h Ed25519Handle, indexes []int64) error {
+	ps := cpuPoints(h)
+	if err := checkIndexes(indexes, len(ps)); err != nil {
+		return err
+	}
+
+	identity := edwards25519.NewIdentityPoint()
+	for _, idx := range indexes {
+		ps[idx].Set(identity)
+	}
+	return nil
+}
+
+func (cpuEd25519Backend) DeleteSubset(h Ed25519Handle, indexes []int64) error {
+	xs := h.(*cpuEd25519Points)
+	if err := checkIndexes(indexes, len(xs.points)); err != nil {
+		return err
+	}
+
+	remove := make(map[int64]struct{}, len(indexes))
+	for _, idx := range indexes {
+		remove[idx] = struct{}{}
+	}
+
+	kept := xs.points[:0]
+	for i := range xs.points {
+		if _, ok := remove[int64(i)]; !ok {
+			kept = append(kept, xs.points[i])
+		}
+	}
+	xs.points = kept
+	return nil
+}
+
+func cpuCompare(h Ed25519Handle, other Ed25519Handle, equal int) ([]int64, error) {
+	ps := cpuPoints(h)
+	os := cpuPoints(other)
+	if len(ps) != len(os) {
+		return nil, errLengthsDoNotMatch
+	}
+
+	results := make([]int64, len(ps))
+	parallelFor(len(ps), func(start, end int) {
+		for i := start; i < end; i++ {
+			results[i] = BToI(ps[i].Equal(&os[i]) == equal)
+		}
+	})
+	return results, nil
+}
+
+func (cpuEd25519Backend) Equal(h Ed25519Handle, other Ed25519Handle) ([]int64, error) {
+	return cpuCompare(h, other, 1)
+}
+
+func (cpuEd25519Backend) NotEqual(h Ed25519Handle, other Ed25519Handle) ([]int64, error) {
+	return cpuCompare(h, other, 0)
+}
+
+func (b cpuEd25519Backend) Contains(h Ed25519Handle, values Ed25519Handle) ([]int64, error) {
+	set := make(map[[Ed25519FoldedPointBytes]byte]struct{})
+
+	bs := b.ToFoldedBytes(h)
+	for i := 0; i < len(bs); i += Ed25519FoldedPointBytes {
+		var k [Ed25519FoldedPointBytes]byte
+		copy(k[:], bs[i:])
+		set[k] = struct{}{}
+	}
+
+	vs := b.ToFoldedBytes(values)
+	results := make([]int64, len(vs)/Ed25519FoldedPointBytes)
+	for i := range results {
+		var k [Ed25519FoldedPointBytes]byte
+		copy(k[:], vs[i*Ed25519FoldedPointBytes:])
+		_, ok := set[k]
+		results[i] = BToI(ok)
+	}
+	return results, nil
+}
+
+func (cpuEd25519Backend) Index(h Ed25519Handle) []int64 {
+	ps := cpuPoints(h)
+	identity := edwards25519.NewIdentityPoint()
+
+	results := make([]int64, len(ps))
+	for i := range ps {
+		results[i] = BToI(ps[i].Equal(identity) == 0)
+	}
+	return results
+}
+
+func (cpuEd25519Backend) Scan(h Ed25519Handle) error {
+	ps := cpuPoints(h)
+	for i := 1; i < len(ps); i++ {
+		ps[i].Add(&ps[i-1], &ps[i])
+	}
+	return nil
+}
+
+func (cpuEd25519Backend) Mux(h Ed25519Handle, condition []int64, ifFalse Ed25519Handle) error {
+	ps := cpuPoints(h)
+	fs := cpuPoints(ifFalse)
+	if len(ps) != len(fs) || len(ps) != len(condition) {
+		return errLengthsDoNotMatch
+	}
+
+	for i, c := range condition {
+		if c == 0 {
+			ps[i].Set(&fs[i])
+		}
+	}
+	return nil
+}
+
+func (cpuEd25519Backend) ReduceISum(h Ed25519Handle, indexes []int64, summands Ed25519Handle) error {
+	ps := cpuPoints(h)
+	ss := cpuPoints(summands)
+	if len(ss) < len(indexes) {
+		return errLengthsDoNotMatch
+	}
+	if err := checkIndexes(indexes, len(ps)); err != nil {
+		return err
+	}
+
+	for i, idx := range indexes {
+		ps[idx].Add(&ps[idx], &ss[i])
+	}
+	return nil
+}
+
+func (cpuEd25519Backend) Scale(h Ed25519Handle, value *edwards25519.Scalar) error {
+	ps := cpuPoints(h)
+
+	parallelFor(len(ps), func(start, end int) {
+		for i := start; i < end; i++ {
+			ps[i].ScalarMult(value, &ps[i])
+		}
+	})
+	return nil
+}
+
+func (cpuEd25519Backend) Mul(h Ed25519Handle, values []*edwards25519.Scalar) error {
+	ps := cpuPoints(h)
+	if len(ps) != len(values) {
+		return errLengthsDoNotMatch
+	}
+
+	parallelFor(len(ps), func(start, end int) {
+		for i := start; i < end; i++ {
+			ps[i].ScalarMult(values[i], &ps[i])
+		}
+	})
+	return nil
+}
+
+func cpuBinaryOp(h Ed25519Handle, other Ed25519Handle, f func(v, p, q *edwards25519.Point)) error {
+	ps := cpuPoints(h)
+	os := cpuPoints(other)
+	if len(ps) != len(os) {
+		return errLengthsDoNotMatch
+	}
+
+	parallelFor(len(ps), func(start, end int) {
+		for i := start; i < end; i++ {
+			f(&ps[i], &ps[i], &os[i])
+		}
+	})
+	return nil
+}
+
+func (cpuEd25519Backend) Add(h Ed25519Handle, other Ed25519Handle) error {
+	return cpuBinaryOp(h, other, func(v, p, q *edwards25519.Point) { v.Add(p, q) })
+}
+
+func (cpuEd25519Backend) Sub(h Ed25519Handle, other Ed25519Handle) error {
+	return cpuBinaryOp(h, other, func(v, p, q *edwards25519.Point) { v.Subtract(p, q) })
+}
+
+func (cpuEd25519Backend) Neg(h Ed25519Handle) error {
+	ps := cpuPoints(h)
+	for i := range ps {
+		ps[i].Negate(&ps[i])
+	}
+	return nil
+}
+
+// putFieldElementLimbs writes e to b as five little-endian 64-bit limbs in radix 2^51.
+func putFieldElementLimbs(b []byte, e *field.Element) {
+	bs := e.Bytes()
+
+	var w [4]uint64
+	for i := range w {
+		w[i] = binary.LittleEndian.Uint64(bs[i*8:])
+	}
+
+	limbs := [5]uint64{
+		w[0] & mask51,
+		(w[0]>>51 | w[1]<<13) & mask51,
+		(w[1]>>38 | w[2]<<26) & mask51,
+		(w[2]>>25 | w[3]<<39) & mask51,
+		(w[3] >> 12) & mask51,
+	}
+	for i, l := range limbs {
+		binary.LittleEndian.PutUint64(b[i*8:], l)
+	}
+}
+
+var errLimbOutOfRange = errors.New("field element limb is out of range")
+
+// checkExtendedLimbs checks that each limb of points in extended coordinates is less than 2^51.
+func checkExtendedLimbs(bs []byte) error {
+	for i := 0; i+8 <= len(bs); i += 8 {
+		if binary.LittleEndian.Uint64(bs[i:]) > mask51 {
+			return errLimbOutOfRange
+		}
+	}
+	return nil
+}
+
+// fieldElementFromLimbs reads a field element written as five little-endian 64-bit limbs in
+// radix 2^51. Each limb must be less than 2^51, so that the element is less than 2^255, but the
+// element does not need to be reduced modulo p.
+func fieldElementFromLimbs(b []byte) (*field.Element, error) {
+	var l [5]uint64
+	for i := range l {
+		l[i] = binary.LittleEndian.Uint64(b[i*8:])
+		if l[i] > mask51 {
+			return nil, errLimbOutOfRange
+		}
+	}
+
+	w := [4]uint64{
+		l[0] | l[1]<<51,
+		l[1]>>13 | l[2]<<38,
+		l[2]>>26 | l[3]<<25,
+		l[3]>>39 | l[4]<<12,
+	}
+
+	bs := make([]byte, 32)
+	for i, v := range w {
+		binary.LittleEndian.PutUint64(bs[i*8:], v)
+	}
+
+	return new(field.Element).SetBytes(bs)
+}
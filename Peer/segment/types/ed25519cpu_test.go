@@ -0,0 +1,230 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package types
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"filippo.io/edwards25519"
+)
+
+func newCPUEd25519ArrayFromInt64s(t *testing.T, values ...int64) *Ed25519Array {
+	t.Helper()
+
+	ints := make([]uint64, len(values))
+	for i, v := range values {
+		ints[i] = uint64(v)
+	}
+
+	h, err := cpuEd25519Backend{}.FromSmallScalars(ints)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newEd25519ArrayFromHandle(cpuEd25519Backend{}, h)
+}
+
+func TestCPUFoldedBytesMatchRFC8032(t *testing.T) {
+	xs := newCPUEd25519ArrayFromInt64s(t, 1, 0)
+	defer xs.Free()
+
+	bs, width := xs.ToFoldedBytes()
+	if width != Ed25519FoldedPointBytes {
+		t.Fatalf("expected width %v, got %v", Ed25519FoldedPointBytes, width)
+	}
+
+	expected := append(edwards25519.NewGeneratorPoint().Bytes(), edwards25519.NewIdentityPoint().Bytes()...)
+	if !bytes.Equal(bs, expected) {
+		t.Errorf("expected %x, got %x", expected, bs)
+	}
+}
+
+func TestCPUExtendedBytesLayout(t *testing.T) {
+	xs := newCPUEd25519ArrayFromInt64s(t, 0)
+	defer xs.Free()
+
+	bs := xs.ToBytes()
+	if len(bs) != Ed25519ExtendedPointBytes {
+		t.Fatalf("expected %v bytes, got %v", Ed25519ExtendedPointBytes, len(bs))
+	}
+
+	// The identity is (0, 1, 1, 0) with each coordinate stored as five 51-bit limbs.
+	limbs := make([]uint64, 20)
+	for i := range limbs {
+		limbs[i] = binary.LittleEndian.Uint64(bs[i*8:])
+	}
+	expected := []uint64{
+		0, 0, 0, 0, 0,
+		1, 0, 0, 0, 0,
+		1, 0, 0, 0, 0,
+		0, 0, 0, 0, 0,
+	}
+	for i := range expected {
+		if limbs[i] != expected[i] {
+			t.Fatalf("expected limbs %v, got %v", expected, limbs)
+		}
+	}
+}
+
+func TestCPUExtendedBytesLimbsInRange(t *testing.T) {
+	xs := newCPUEd25519ArrayFromInt64s(t, 0)
+	defer xs.Free()
+	bs := xs.ToBytes()
+
+	for _, limb := range []uint64{1 << 51, 1<<64 - 1} {
+		// The last limb of Y, which would carry out of the top of the element
+		b := append([]byte{}, bs...)
+		binary.LittleEndian.PutUint64(b[72:], limb)
+		if _, err := (cpuEd25519Backend{}).FromBytes(b); err == nil {
+			t.Errorf("expected a limb of %v to be rejected", limb)
+		}
+		if err := checkExtendedLimbs(b); err != errLimbOutOfRange {
+			t.Errorf("expected %v, got %v", errLimbOutOfRange, err)
+		}
+	}
+
+	if _, err := (cpuEd25519Backend{}).FromBytes(bs); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCPUAffineBytesRoundTrip(t *testing.T) {
+	xs := newCPUEd25519ArrayFromInt64s(t, 1, 7, 0, 123456789)
+	defer xs.Free()
+
+	bs, width := xs.ToAffineBytes()
+	if width != Ed25519AffinePointBytes || len(bs) != 4*Ed25519AffinePointBytes {
+		t.Fatalf("unexpected affine encoding length %v", len(bs))
+	}
+
+	ys, err := NewEd25519ArrayFromAffineBytes(bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ys.Free()
+
+	if !xs.Equals(ys) {
+		t.Errorf("affine round trip did not preserve points")
+	}
+}
+
+func TestCPUCumSumAndMux(t *testing.T) {
+	xs := newCPUEd25519ArrayFromInt64s(t, 1, 2, 3)
+	defer xs.Free()
+
+	sums, err := xs.CumSum()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := newCPUEd25519ArrayFromInt64s(t, 1, 3, 6)
+	defer expected.Free()
+
+	if !sums.Equals(expected) {
+		t.Errorf("cumsum did not produce the expected points")
+	}
+
+	ys := newCPUEd25519ArrayFromInt64s(t, 4, 5, 6)
+	defer ys.Free()
+
+	muxed, err := xs.Mux(NewFTIntegerArray(1, 0, 1), ys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = newCPUEd25519ArrayFromInt64s(t, 1, 5, 3)
+	defer expected.Free()
+
+	if !muxed.Equals(expected) {
+		t.Errorf("mux did not produce the expected points")
+	}
+}
+
+// wrappedEd25519Backend behaves like the CPU backend but is a distinct backend, which lets
+// the tests mix arrays from two backends.
+type wrappedEd25519Backend struct {
+	cpuEd25519Backend
+}
+
+// countingEd25519Backend behaves like the CPU backend, and counts the ranges it copies.
+type countingEd25519Backend struct {
+	cpuEd25519Backend
+	ranges *int
+}
+
+func (b countingEd25519Backend) GetRange(h Ed25519Handle, start, stop, step int64) (Ed25519Handle, error) {
+	*b.ranges++
+	return b.cpuEd25519Backend.GetRange(h, start, stop, step)
+}
+
+func TestEd25519ArraysFromDifferentBackends(t *testing.T) {
+	xs, err := NewEd25519ArrayFromInt64sWithBackend(NewCPUEd25519Backend(), 1, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer xs.Free()
+
+	ys, err := NewEd25519ArrayFromInt64sWithBackend(wrappedEd25519Backend{}, 1, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ys.Free()
+
+	if !xs.Equals(ys) {
+		t.Errorf("arrays with the same points should be equal regardless of backend")
+	}
+
+	if _, err := xs.Add(ys); err != errBackendsDoNotMatch {
+		t.Errorf("expected %v, got %v", errBackendsDoNotMatch, err)
+	}
+	if _, err := xs.Sub(ys); err != errBackendsDoNotMatch {
+		t.Errorf("expected %v, got %v", errBackendsDoNotMatch, err)
+	}
+
+	// Nothing is allocated before the backends are found not to match
+	ranges := 0
+	ws, err := NewEd25519ArrayFromInt64sWithBackend(countingEd25519Backend{ranges: &ranges}, 1, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Free()
+	if _, err := ws.Add(ys); err != errBackendsDoNotMatch {
+		t.Errorf("expected %v, got %v", errBackendsDoNotMatch, err)
+	}
+	if _, err := ws.Sub(ys); err != errBackendsDoNotMatch {
+		t.Errorf("expected %v, got %v", errBackendsDoNotMatch, err)
+	}
+	if ranges != 0 {
+		t.Errorf("expected no copies to have been made, got %v", ranges)
+	}
+
+	zs, err := ys.WithBackend(xs.Backend())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zs.Free()
+
+	if zs.Backend() != xs.Backend() {
+		t.Fatalf("WithBackend did not change the backend")
+	}
+
+	sums, err := xs.Add(zs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := NewEd25519ArrayFromInt64sOrPanic(2, 4, 6)
+	defer expected.Free()
+
+	if !sums.Equals(expected) {
+		t.Errorf("sum of converted arrays was incorrect")
+	}
+}
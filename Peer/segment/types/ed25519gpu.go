@@ -0,0 +1,447 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+//go:build !nogpu && cgo
+
+package types
+
+// #cgo CFLAGS: -I${SRCDIR}/../../lib
+// #cgo LDFLAGS: -L${SRCDIR}/../../lib -lftcrypto -Wl,-rpath=${SRCDIR}/../../lib
+// #include "ftcrypto.h"
+import "C"
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"filippo.io/edwards25519"
+)
+
+func init() {
+	if C.FT_FOLDED_POINT_BYTES != Ed25519FoldedPointBytes ||
+		C.FT_AFFINE_POINT_BYTES != Ed25519AffinePointBytes {
+		panic("libftcrypto point sizes do not match the sizes expected by the types package")
+	}
+}
+
+var gpuInitOnce sync.Once
+var gpuInitErr error
+
+// NewGPUEd25519Backend returns the libftcrypto backend, initialising the GPU the first
+// time it is called.
+func NewGPUEd25519Backend() (Ed25519Backend, error) {
+	gpuInitOnce.Do(func() {
+		err := C.ft_crypto_init()
+		if err != C.FT_ERR_NO_ERROR {
+			gpuInitErr = FTError(err)
+		}
+	})
+	if gpuInitErr != nil {
+		return nil, gpuInitErr
+	}
+	return gpuEd25519Backend{}, nil
+}
+
+func GetGPUMemoryStats() (GPUMemoryStats, error) {
+	free := C.size_t(0)
+	total := C.size_t(0)
+
+	err := C.ft_crypto_device_memory(&free, &total)
+	if err != C.FT_ERR_NO_ERROR {
+		return GPUMemoryStats{}, FTError(err)
+	}
+	return GPUMemoryStats{
+		Free:  uint64(free),
+		Total: uint64(total),
+	}, nil
+}
+
+type FTError C.ft_error
+
+func (e FTError) Error() string {
+	cStr := C.ft_error_str(C.int(e))
+	return C.GoString(cStr)
+}
+
+// gpuEd25519Backend stores points in device memory and delegates all operations to libftcrypto.
+type gpuEd25519Backend struct{}
+
+func gpuHandle(h Ed25519Handle) C.ft_ge25519_array {
+	if h == nil {
+		return nil
+	}
+	return h.(C.ft_ge25519_array)
+}
+
+func toSizeTs(xs []int64) []C.size_t {
+	is := make([]C.size_t, len(xs))
+	for i, v := range xs {
+		is[i] = C.size_t(v)
+	}
+	return is
+}
+
+func (gpuEd25519Backend) InitScalar(size int64, value *edwards25519.Scalar) (Ed25519Handle, error) {
+	arr := (C.ft_ge25519_array)(nil)
+
+	var v *C.uchar
+	if value != nil {
+		bytes := value.Bytes()
+		v = (*C.uchar)(&bytes[0])
+	}
+
+	err := C.ft_array_init_scalar(&arr, C.size_t(size), v)
+	if err != C.FT_ERR_NO_ERROR {
+		return nil, FTError(err)
+	}
+	return arr, nil
+}
+
+func (gpuEd25519Backend) InitPoint(size int64, points Ed25519Handle, index int64) (Ed25519Handle, error) {
+	arr := (C.ft_ge25519_array)(nil)
+
+	resultCode := C.ft_array_init_point(&arr, C.size_t(size), gpuHandle(points), C.size_t(index))
+	if resultCode != C.FT_ERR_NO_ERROR {
+		return nil, FTError(resultCode)
+	}
+	return arr, nil
+}
+
+func (gpuEd25519Backend) FromScalars(values []*edwards25519.Scalar) (Ed25519Handle, error) {
+	arr := (C.ft_ge25519_array)(nil)
+
+	bytes := ScalarsToBytes(values)
+
+	resultCode := C.ft_array_from_scalars(&arr, (*C.uchar)(&bytes[0]), C.ulong(len(values)))
+	if resultCode != C.FT_ERR_NO_ERROR {
+		return nil, FTError(resultCode)
+	}
+	return arr, nil
+}
+
+func (gpuEd25519Backend) FromSmallScalars(values []uint64) (Ed25519Handle, error) {
+	arr := (C.ft_ge25519_array)(nil)
+
+	ints := make([]C.uint64_t, len(values))
+	for i, v := range values {
+		ints[i] = C.uint64_t(v)
+	}
+
+	resultCode := C.ft_array_from_small_scalars(&arr, (*C.uint64_t)(&ints[0]), C.size_t(len(ints)))
+	if resultCode != C.FT_ERR_NO_ERROR {
+		return nil, FTError(resultCode)
+	}
+	return arr, nil
+}
+
+func (gpuEd25519Backend) FromBytes(bs []byte) (Ed25519Handle, error) {
+	if err := checkExtendedLimbs(bs); err != nil {
+		return nil, err
+	}
+
+	arr := (C.ft_ge25519_array)(nil)
+
+	resultCode := C.ft_array_from_bytes(&arr, (*C.uchar)(&bs[0]), C.ulong(len(bs)))
+	if resultCode != C.FT_ERR_NO_ERROR {
+		return nil, FTError(resultCode)
+	}
+	return arr, nil
+}
+
+func (gpuEd25519Backend) FromFoldedBytes(bs []byte) (Ed25519Handle, int64, error) {
+	arr := (C.ft_ge25519_array)(nil)
+
+	var result C.long = -1
+
+	resultCode := C.ft_array_from_bytes_folded(&result, &arr, (*C.uchar)(&bs[0]), C.ulong(len(bs)))
+	if resultCode != C.FT_ERR_NO_ERROR {
+		return nil, -1, FTError(resultCode)
+	}
+	return arr, int64(result), nil
+}
+
+func (gpuEd25519Backend) FromAffineBytes(bs []byte) (Ed25519Handle, int64, error) {
+	arr := (C.ft_ge25519_array)(nil)
+
+	resultCode := C.ft_array_from_bytes_affine(&arr, (*C.uchar)(&bs[0]), C.ulong(len(bs)))
+	if resultCode != C.FT_ERR_NO_ERROR {
+		return nil, -1, FTError(resultCode)
+	}
+
+	var result C.long = -1
+	resultCode = C.ft_array_validate(&result, arr)
+	if resultCode != C.FT_ERR_NO_ERROR {
+		return nil, -1, FTError(resultCode)
+	}
+	return arr, int64(result), nil
+}
+
+func (gpuEd25519Backend) ToBytes(h Ed25519Handle) []byte {
+	len := C.ft_array_get_length(gpuHandle(h))
+	nBytes := len * Ed25519ExtendedPointBytes
+	b := make([]byte, nBytes)
+
+	C.ft_array_to_bytes((*C.uchar)(&b[0]), nBytes, gpuHandle(h))
+
+	return b
+}
+
+func (gpuEd25519Backend) ToFoldedBytes(h Ed25519Handle) []byte {
+	len := C.ft_array_get_length(gpuHandle(h))
+	nBytes := len * C.FT_FOLDED_POINT_BYTES
+	b := make([]byte, nBytes)
+
+	C.ft_array_to_bytes_folded((*C.uchar)(&b[0]), nBytes, gpuHandle(h))
+
+	return b
+}
+
+func (gpuEd25519Backend) ToAffineBytes(h Ed25519Handle) []byte {
+	len := C.ft_array_get_length(gpuHandle(h))
+	nBytes := len * C.FT_AFFINE_POINT_BYTES
+	b := make([]byte, nBytes)
+
+	C.ft_array_to_bytes_affine((*C.uchar)(&b[0]), nBytes, gpuHandle(h))
+
+	return b
+}
+
+func (gpuEd25519Backend) Length(h Ed25519Handle) int64 {
+	return int64(C.ft_array_get_length(gpuHandle(h)))
+}
+
+func (gpuEd25519Backend) SetLength(h Ed25519Handle, length int64) error {
+	resultCode := C.ft_array_set_length(gpuHandle(h), C.size_t(length))
+	if resultCode != C.FT_ERR_NO_ERROR {
+		return FTError(resultCode)
+	}
+	return nil
+}
+
+func (gpuEd25519Backend) Free(h Ed25519Handle) {
+	C.ft_array_free(gpuHandle(h))
+}
+
+func (gpuEd25519Backend) GetRange(h Ed25519Handle, start, stop, step int64) (Ed25519Handle, error) {
+	arr := (C.ft_ge25519_array)(nil)
+
+	err := C.ft_array_get_range(&arr, C.size_t(start), C.size_t(stop), C.size_t(step), gpuHandle(h))
+	if err != C.FT_ERR_NO_ERROR {
+		return nil, FTError(err)
+	}
+	return arr, nil
+}
+
+func (gpuEd25519Backend) GetSubset(h Ed25519Handle, indexes []int64) (Ed25519Handle, error) {
+	arr := (C.ft_ge25519_array)(nil)
+
+	is := toSizeTs(indexes)
+
+	err := C.ft_array_get_subset(&arr, (*C.size_t)(&is[0]), C.ulong(len(is)), gpuHandle(h))
+	if err != C.FT_ERR_NO_ERROR {
+		return nil, FTError(err)
+	}
+	return arr, nil
+}
+
+func (b gpuEd25519Backend) AssignToSubset(h Ed25519Handle, indexes []int64, values Ed25519Handle) error {
+	// Due to a bug in libftcrypto, we want to reduce the size of the index array
+	// to less than or equal the size of the xs Ed25519 array. We do this by
+	// removing duplicate indexes (taking the last), and then taking the
+	// subset of the values array using the original position of the index in
+	// the indexes array. For example:
+
+	// indexes: [0,24,12,3,12]
+	// values: [1,2,3,4,5]
+
+	// uniqueIndexes: [0,24,3,12]
+	// uniqueValueIndexes: [0,1,3,4]
+	// uniqueValues: [1,2,4,5]
+
+	uniqueIndexeValuesMap := make(map[C.size_t]int64)
+	for i, v := range indexes {
+		uniqueIndexeValuesMap[C.size_t(v)] = int64(i)
+	}
+
+	uniqueIndexes := make([]C.size_t, 0, len(uniqueIndexeValuesMap))
+	uniqueValueIndexes := make([]int64, 0, len(uniqueIndexeValuesMap))
+
+	for k, v := range uniqueIndexeValuesMap {
+		uniqueIndexes = append(uniqueIndexes, k)
+		uniqueValueIndexes = append(uniqueValueIndexes, v)
+	}
+
+	uniqueValues, err := b.GetSubset(values, uniqueValueIndexes)
+	if err != nil {
+		return err
+	}
+	defer b.Free(uniqueValues)
+
+	resultCode := C.ft_array_assign_to_subset(gpuHandle(h), (*C.size_t)(&uniqueIndexes[0]), C.ulong(len(uniqueIndexes)), gpuHandle(uniqueValues))
+	if resultCode != C.FT_ERR_NO_ERROR {
+		return FTError(resultCode)
+	}
+	return nil
+}
+
+func (gpuEd25519Backend) AssignZeroToSubset(h Ed25519Handle, indexes []int64) error {
+	uniqueIndexes := make([]C.size_t, 0, len(indexes))
+	uniqueIndexeValuesMap := make(map[C.size_t]struct{})
+	for _, v := range indexes {
+		idx := C.size_t(v)
+
+		if _, exists := uniqueIndexeValuesMap[idx]; !exists {
+			uniqueIndexeValuesMap[idx] = struct{}{}
+			uniqueIndexes = append(uniqueIndexes, idx)
+		}
+	}
+
+	sort.Slice(uniqueIndexes, func(i, j int) bool {
+		return i < j
+	})
+
+	resultCode := C.ft_array_assign_zero_to_subset(gpuHandle(h), (*C.size_t)(&uniqueIndexes[0]), C.ulong(len(uniqueIndexes)))
+	if resultCode != C.FT_ERR_NO_ERROR {
+		return FTError(resultCode)
+	}
+	return nil
+}
+
+func (gpuEd25519Backend) DeleteSubset(h Ed25519Handle, indexes []int64) error {
+	is := toSizeTs(indexes)
+
+	err := C.ft_array_delete_subset(gpuHandle(h), (*C.size_t)(&is[0]), C.ulong(len(is)))
+	if err != C.FT_ERR_NO_ERROR {
+		return FTError(err)
+	}
+	return nil
+}
+
+func (b gpuEd25519Backend) Equal(h Ed25519Handle, other Ed25519Handle) ([]int64, error) {
+	results := make([]int64, b.Length(h))
+
+	resultCode := C.ft_equal((*C.int64_t)(&results[0]), gpuHandle(h), gpuHandle(other))
+	if resultCode != C.FT_ERR_NO_ERROR {
+		return nil, FTError(resultCode)
+	}
+	return results, nil
+}
+
+func (b gpuEd25519Backend) NotEqual(h Ed25519Handle, other Ed25519Handle) ([]int64, error) {
+	results := make([]int64, b.Length(h))
+
+	resultCode := C.ft_not_equal((*C.int64_t)(&results[0]), gpuHandle(h), gpuHandle(other))
+	if resultCode != C.FT_ERR_NO_ERROR {
+		return nil, FTError(resultCode)
+	}
+	return results, nil
+}
+
+func (b gpuEd25519Backend) Contains(h Ed25519Handle, values Ed25519Handle) ([]int64, error) {
+	results := make([]int64, b.Length(values))
+
+	resultCode := C.ft_array_contains((*C.int64_t)(&results[0]), gpuHandle(values), gpuHandle(h))
+	if resultCode != C.FT_ERR_NO_ERROR {
+		return nil, FTError(resultCode)
+	}
+	return results, nil
+}
+
+func (b gpuEd25519Backend) Index(h Ed25519Handle) []int64 {
+	values := make([]int64, b.Length(h))
+	C.ft_index((*C.int64_t)(&values[0]), gpuHandle(h))
+	return values
+}
+
+func (gpuEd25519Backend) Scan(h Ed25519Handle) error {
+	resultCode := C.ft_array_scan(gpuHandle(h))
+	if resultCode != C.FT_ERR_NO_ERROR {
+		return FTError(resultCode)
+	}
+	return nil
+}
+
+func (gpuEd25519Backend) Mux(h Ed25519Handle, condition []int64, ifFalse Ed25519Handle) error {
+	resultCode := C.ft_array_mux(gpuHandle(h), (*C.int64_t)(&condition[0]), gpuHandle(ifFalse))
+	if resultCode != C.FT_ERR_NO_ERROR {
+		return FTError(resultCode)
+	}
+	return nil
+}
+
+const maxChunkSize int = 4_000_000
+
+func (b gpuEd25519Backend) ReduceISum(h Ed25519Handle, indexes []int64, summands Ed25519Handle) error {
+	reduceISumChunk := func(start int64, end int64) error {
+		indexesChunk := indexes[start:end]
+		summandChunk, err := b.GetRange(summands, start, end, 1)
+		if err != nil {
+			return err
+		}
+		defer b.Free(summandChunk)
+
+		is := toSizeTs(indexesChunk)
+
+		resultCode := C.ft_reduce_isum(gpuHandle(h), (*C.size_t)(&is[0]), C.ulong(len(is)), gpuHandle(summandChunk))
+		if resultCode != C.FT_ERR_NO_ERROR {
+			return FTError(resultCode)
+		}
+		return nil
+	}
+
+	for i := 0; i < len(indexes); i += maxChunkSize {
+		end := int64(math.Min(float64(len(indexes)), float64(i+maxChunkSize)))
+
+		err := reduceISumChunk(int64(i), end)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (gpuEd25519Backend) Scale(h Ed25519Handle, value *edwards25519.Scalar) error {
+	bytes := value.Bytes()
+
+	C.ft_scale(gpuHandle(h), (*C.uchar)(&bytes[0]))
+
+	return nil
+}
+
+func (gpuEd25519Backend) Mul(h Ed25519Handle, values []*edwards25519.Scalar) error {
+	bytes := ScalarsToBytes(values)
+
+	resultCode := C.ft_mul(gpuHandle(h), (*C.uchar)(&bytes[0]), C.ulong(len(values)))
+	if resultCode != C.FT_ERR_NO_ERROR {
+		return FTError(resultCode)
+	}
+	return nil
+}
+
+func (gpuEd25519Backend) Add(h Ed25519Handle, other Ed25519Handle) error {
+	resultCode := C.ft_add(gpuHandle(h), gpuHandle(other))
+	if resultCode != C.FT_ERR_NO_ERROR {
+		return FTError(resultCode)
+	}
+	return nil
+}
+
+func (gpuEd25519Backend) Sub(h Ed25519Handle, other Ed25519Handle) error {
+	resultCode := C.ft_sub(gpuHandle(h), gpuHandle(other))
+	if resultCode != C.FT_ERR_NO_ERROR {
+		return FTError(resultCode)
+	}
+	return nil
+}
+
+func (gpuEd25519Backend) Neg(h Ed25519Handle) error {
+	C.ft_neg(gpuHandle(h))
+	return nil
+}
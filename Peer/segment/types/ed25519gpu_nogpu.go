@@ -0,0 +1,28 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+//go:build nogpu || !cgo
+
+package types
+
+import "errors"
+
+// Builds with the nogpu tag, or without cgo, do not link against libftcrypto, which allows
+// peers (and CI) to be built on machines without CUDA. Ed25519 arrays always use the CPU
+// backend.
+
+var errGPUNotCompiled = errors.New("GPU support was not compiled into this binary (built with the nogpu tag or without cgo)")
+
+func NewGPUEd25519Backend() (Ed25519Backend, error) {
+	return nil, errGPUNotCompiled
+}
+
+func GetGPUMemoryStats() (GPUMemoryStats, error) {
+	return GPUMemoryStats{}, errGPUNotCompiled
+}
@@ -0,0 +1,21 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+//go:build !nogpu && cgo
+
+package types
+
+import "testing"
+
+func TestErrorString(t *testing.T) {
+	errString := FTError(1).Error()
+	if errString != "out of memory" {
+		t.Errorf("FTError(1) should return 'out of memory', but got '%v'", errString)
+	}
+}
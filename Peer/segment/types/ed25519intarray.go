@@ -83,6 +83,8 @@ func (v *FTEd25519IntArray) AsType(tc TypeCode) (TypeVal, error) {
 		return NewFTIntegerArray(xs...), nil
 	case Ed25519B:
 		return NewEd25519ArrayFromInt(v.array)
+	case RistrettoB:
+		return NewRistrettoArrayFromInt(v.array), nil
 	case BytearrayB:
 		if tc.Length() != 32 {
 			return nil, errors.New("arrays of Ed25519 can only be converted to bytearrays of length 32")
@@ -545,9 +547,13 @@ func (v *FTEd25519IntArray) Mul(other ArrayTypeVal) (ArrayTypeVal, error) {
 		return &FTEd25519IntArray{result}, nil
 	}
 
+	if rs, err := asRistrettoArray(other); err == nil {
+		return rs.Mul(v)
+	}
+
 	cs, err := asEd25519Array(other)
 	if err != nil {
-		return nil, errors.New("other was expected to be an Ed25519Int or Ed25519 array")
+		return nil, errors.New("other was expected to be an Ed25519Int, Ed25519 or Ristretto array")
 	}
 
 	return cs.Mul(v)
@@ -0,0 +1,285 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+
+	"golang.org/x/exp/slices"
+)
+
+// Bloom and Cuckoo filters are approximate sets of byte strings, which may report that an item
+// is in the set when it is not, but never that it is not when it is. A filter is held in a
+// single byte slice, whose first byte is its parameter: the number of hash functions for a
+// Bloom filter, and the width of a fingerprint in bytes for a Cuckoo filter. A parameter of 0
+// is the empty filter, which contains nothing and is compatible with every filter of the same
+// width.
+//
+// Integers are added as their 8 byte little-endian encoding, which is the bytearray they
+// convert to with astype.
+
+// CuckooBucketSize is the number of fingerprints in each bucket of a Cuckoo filter.
+const CuckooBucketSize = 4
+
+const cuckooMaxKicks = 500
+
+var (
+	errFilterParameters = errors.New("filters have different parameters")
+	errCuckooFilterFull = errors.New("Cuckoo filter is full")
+)
+
+func filterHash(item []byte) [sha256.Size]byte {
+	return sha256.Sum256(item)
+}
+
+// BloomFilterSize returns the width in bytes and number of hash functions of a Bloom filter
+// holding capacity items with the given false positive rate.
+func BloomFilterSize(capacity int, fpRate float64) (int, int, error) {
+	if !(fpRate > 0 && fpRate < 1) {
+		return 0, 0, errors.New("false positive rate must be in (0, 1)")
+	}
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	m := math.Ceil(-float64(capacity) * math.Log(fpRate) / (math.Ln2 * math.Ln2))
+	m = 8 * math.Ceil(m/8)
+	k := math.Round(m / float64(capacity) * math.Ln2)
+	if k < 1 {
+		k = 1
+	}
+	if k > math.MaxUint8 {
+		return 0, 0, errors.New("false positive rate is too small")
+	}
+
+	return 1 + int(m/8), int(k), nil
+}
+
+// CuckooFilterSize returns the width in bytes and fingerprint width of a Cuckoo filter holding
+// capacity items with the given false positive rate.
+func CuckooFilterSize(capacity int, fpRate float64) (int, int, error) {
+	if !(fpRate > 0 && fpRate < 1) {
+		return 0, 0, errors.New("false positive rate must be in (0, 1)")
+	}
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	// An item is compared against the 2*CuckooBucketSize fingerprints of its two buckets
+	fpBits := math.Ceil(math.Log2(2 * CuckooBucketSize / fpRate))
+	fpBytes := int(math.Ceil(fpBits / 8))
+	if fpBytes > 4 {
+		return 0, 0, errors.New("false positive rate is too small")
+	}
+
+	// The number of buckets is a power of 2, for a load of at most 90%
+	buckets := int(math.Ceil(float64(capacity) / (0.9 * CuckooBucketSize)))
+	buckets = 1 << bits.Len(uint(buckets-1))
+
+	return 1 + buckets*CuckooBucketSize*fpBytes, fpBytes, nil
+}
+
+func bloomIndexes(filter []byte, item []byte) []uint64 {
+	h := filterHash(item)
+	h1 := binary.LittleEndian.Uint64(h[0:8])
+	h2 := binary.LittleEndian.Uint64(h[8:16]) | 1
+
+	m := uint64(len(filter)-1) * 8
+	result := make([]uint64, filter[0])
+	for i := range result {
+		result[i] = (h1 + uint64(i)*h2) % m
+	}
+	return result
+}
+
+func bloomAdd(filter []byte, item []byte) {
+	for _, j := range bloomIndexes(filter, item) {
+		filter[1+j/8] |= 1 << (j % 8)
+	}
+}
+
+func bloomContains(filter []byte, item []byte) bool {
+	if filter[0] == 0 {
+		return false
+	}
+	for _, j := range bloomIndexes(filter, item) {
+		if filter[1+j/8]&(1<<(j%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func checkBloomFilter(filter []byte) error {
+	if len(filter) < 2 {
+		return errors.New("Bloom filter is too small")
+	}
+	return nil
+}
+
+type cuckooFilter struct {
+	data    []byte
+	fpBytes int
+	buckets uint64
+}
+
+func newCuckooFilter(filter []byte) cuckooFilter {
+	fpBytes := int(filter[0])
+	return cuckooFilter{filter, fpBytes, uint64((len(filter) - 1) / (CuckooBucketSize * fpBytes))}
+}
+
+func checkCuckooFilter(filter []byte) error {
+	fpBytes := int(filter[0])
+	if fpBytes == 0 {
+		return nil
+	}
+	if fpBytes > 4 {
+		return fmt.Errorf("invalid Cuckoo filter fingerprint width: %v", fpBytes)
+	}
+	size := len(filter) - 1
+	buckets := size / (CuckooBucketSize * fpBytes)
+	if size%(CuckooBucketSize*fpBytes) != 0 || buckets == 0 || buckets&(buckets-1) != 0 {
+		return errors.New("Cuckoo filter width does not match its fingerprint width")
+	}
+	return nil
+}
+
+func (f cuckooFilter) slot(bucket uint64, i int) []byte {
+	offset := 1 + (int(bucket)*CuckooBucketSize+i)*f.fpBytes
+	return f.data[offset : offset+f.fpBytes]
+}
+
+func (f cuckooFilter) fingerprint(item []byte) ([]byte, uint64) {
+	h := filterHash(item)
+	fp := make([]byte, f.fpBytes)
+	copy(fp, h[8:])
+
+	// The zero fingerprint marks an empty slot
+	if isZero(fp) {
+		fp[f.fpBytes-1] = 1
+	}
+	return fp, binary.LittleEndian.Uint64(h[0:8]) & (f.buckets - 1)
+}
+
+func (f cuckooFilter) alternate(bucket uint64, fp []byte) uint64 {
+	h := filterHash(fp)
+	return (bucket ^ binary.LittleEndian.Uint64(h[0:8])) & (f.buckets - 1)
+}
+
+func (f cuckooFilter) bucketContains(bucket uint64, fp []byte) bool {
+	for i := 0; i < CuckooBucketSize; i++ {
+		if slices.Equal(f.slot(bucket, i), fp) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f cuckooFilter) containsFingerprint(bucket uint64, fp []byte) bool {
+	return f.bucketContains(bucket, fp) || f.bucketContains(f.alternate(bucket, fp), fp)
+}
+
+func (f cuckooFilter) insertIntoBucket(bucket uint64, fp []byte) bool {
+	for i := 0; i < CuckooBucketSize; i++ {
+		if s := f.slot(bucket, i); isZero(s) {
+			copy(s, fp)
+			return true
+		}
+	}
+	return false
+}
+
+// insert adds a fingerprint to one of its buckets, moving other fingerprints to their
+// alternate buckets to make room if both are full.
+func (f cuckooFilter) insert(bucket uint64, fp []byte) error {
+	if f.containsFingerprint(bucket, fp) {
+		return nil
+	}
+	if f.insertIntoBucket(bucket, fp) {
+		return nil
+	}
+
+	fp = append([]byte{}, fp...)
+	bucket = f.alternate(bucket, fp)
+	for kick := 0; kick < cuckooMaxKicks; kick++ {
+		if f.insertIntoBucket(bucket, fp) {
+			return nil
+		}
+
+		s := f.slot(bucket, kick%CuckooBucketSize)
+		victim := append([]byte{}, s...)
+		copy(s, fp)
+
+		fp = victim
+		bucket = f.alternate(bucket, fp)
+	}
+	return errCuckooFilterFull
+}
+
+func cuckooAdd(filter []byte, item []byte) error {
+	f := newCuckooFilter(filter)
+	fp, bucket := f.fingerprint(item)
+	return f.insert(bucket, fp)
+}
+
+func cuckooContains(filter []byte, item []byte) bool {
+	if filter[0] == 0 {
+		return false
+	}
+	f := newCuckooFilter(filter)
+	fp, bucket := f.fingerprint(item)
+	return f.containsFingerprint(bucket, fp)
+}
+
+// cuckooUnion adds each fingerprint of b to a copy of a.
+func cuckooUnion(a []byte, b []byte) ([]byte, error) {
+	result := append([]byte{}, a...)
+	f, g := newCuckooFilter(result), newCuckooFilter(b)
+
+	for bucket := uint64(0); bucket < g.buckets; bucket++ {
+		for i := 0; i < CuckooBucketSize; i++ {
+			if fp := g.slot(bucket, i); !isZero(fp) {
+				if err := f.insert(bucket, fp); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// cuckooIntersect keeps the fingerprints of a which are also in b.
+func cuckooIntersect(a []byte, b []byte) []byte {
+	result := append([]byte{}, a...)
+	f, g := newCuckooFilter(result), newCuckooFilter(b)
+
+	for bucket := uint64(0); bucket < f.buckets; bucket++ {
+		for i := 0; i < CuckooBucketSize; i++ {
+			if fp := f.slot(bucket, i); !isZero(fp) && !g.containsFingerprint(bucket, fp) {
+				copy(fp, make([]byte, f.fpBytes))
+			}
+		}
+	}
+	return result
+}
+
+func isZero(bs []byte) bool {
+	for _, b := range bs {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,565 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package types
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"golang.org/x/exp/slices"
+)
+
+// FilterArray is an array of Bloom filters, of typecode B<width>, or of Cuckoo filters, of
+// typecode C<width>, where width is the size of each filter in bytes. Filters are built from
+// Bytearray or Integer arrays with NewBloomFilter and NewCuckooFilter, and combined with Union
+// and Intersect. The binary form of an array is each filter in turn, see filter.go.
+type FilterArray struct {
+	kind  BaseTypeCode
+	width int
+	array [][]byte
+}
+
+func (v *FilterArray) Kind() BaseTypeCode { return v.kind }
+func (v *FilterArray) Width() int         { return v.width }
+func (v *FilterArray) TypeCode() TypeCode {
+	return TypeCode(fmt.Sprintf("%v%v", v.kind, v.width))
+}
+func (v *FilterArray) Equals(other TypeVal) bool {
+	if os, ok := other.(*FilterArray); ok {
+		if v.kind == os.kind && v.width == os.width && v.Length() == os.Length() {
+			for i, t := range v.array {
+				if !slices.Equal(t, os.array[i]) {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+func (v *FilterArray) Name() string {
+	if v.kind == CuckooFilterB {
+		return "CuckooFilterArray"
+	}
+	return "BloomFilterArray"
+}
+
+func (v *FilterArray) GetBinaryArray(index int) ([]byte, error) {
+	data := make([]byte, 0, len(v.array)*v.width)
+	for _, x := range v.array {
+		data = append(data, x...)
+	}
+	return data, nil
+}
+func (v *FilterArray) EstimatedSize() int64 { return v.Length() * int64(v.width) }
+func (v *FilterArray) String() string       { return fmt.Sprintf("%v", v.array) }
+func (v *FilterArray) DebugString() string {
+	return fmt.Sprintf("%v(Length=%v,Memory=%v)", v.Name(), v.Length(), PrintSize(uint64(v.EstimatedSize())))
+}
+func (v *FilterArray) Clone() (TypeVal, error) {
+	return v.withValues(cloneFilters(v.array)), nil
+}
+func (v *FilterArray) AsType(tc TypeCode) (TypeVal, error) {
+	if tc == v.TypeCode() {
+		return v.Clone()
+	}
+	return nil, fmt.Errorf("conversion not supported: %v -> %v", v.TypeCode(), tc)
+}
+
+func (v *FilterArray) withValues(xs [][]byte) *FilterArray {
+	return &FilterArray{v.kind, v.width, xs}
+}
+
+func (v *FilterArray) empty() []byte {
+	return make([]byte, v.width)
+}
+
+func (v *FilterArray) checkCompatible(other *FilterArray) error {
+	if v.kind != other.kind || v.width != other.width {
+		return fmt.Errorf("filters are not compatible: %v and %v", v.TypeCode(), other.TypeCode())
+	}
+	return nil
+}
+
+func (v *FilterArray) Length() int64 { return int64(len(v.array)) }
+
+// SetLength pads the array with empty filters.
+func (v *FilterArray) SetLength(x int64) error {
+	if v.Length() == x {
+		return nil
+	}
+
+	xs := make([][]byte, x)
+
+	for i := range xs {
+		if i < len(v.array) {
+			xs[i] = v.array[i]
+		} else {
+			xs[i] = v.empty()
+		}
+	}
+
+	v.array = xs
+	return nil
+}
+func (v *FilterArray) Remove(indexes *FTIntegerArray) error {
+	xs, err := SliceRemove(v.array, indexes.array)
+	if err != nil {
+		return err
+	}
+	v.array = xs
+	return nil
+}
+func (v *FilterArray) Broadcast(length int64) error {
+	xs, err := SliceBroadcast(v.array, length)
+	if err != nil {
+		return err
+	}
+	v.array = xs
+	return nil
+}
+func (v *FilterArray) Lookup(indexes *FTIntegerArray, defaultValue ArrayTypeVal) (ArrayTypeVal, error) {
+	if defaultValue == nil {
+		defaultValue = v.withValues([][]byte{v.empty()})
+	}
+	return v.Get(indexes, defaultValue)
+}
+func (v *FilterArray) Get(indexes *FTIntegerArray, defaultValue ArrayTypeVal) (ArrayTypeVal, error) {
+	if indexes == nil {
+		xs, err := v.Clone()
+		if err != nil {
+			return nil, err
+		}
+		return xs.(ArrayTypeVal), nil
+	}
+
+	var d []byte
+
+	if defaultValue != nil {
+		vs, ok := defaultValue.(*FilterArray)
+		if !ok {
+			return nil, fmt.Errorf("defaultValue is not an %v", v.Name())
+		}
+		if err := v.checkCompatible(vs); err != nil {
+			return nil, err
+		}
+		if len(vs.array) != 1 {
+			return nil, errors.New("not a singleton array")
+		}
+		d = vs.array[0]
+	}
+
+	result := make([][]byte, len(indexes.array))
+
+	for i, k := range indexes.array {
+		key := int(k)
+
+		var x []byte
+		if key >= len(v.array) || key < 0 {
+			if d == nil {
+				return nil, fmt.Errorf("out of range: %d", key)
+			}
+
+			x = d
+		} else {
+			x = v.array[key]
+		}
+
+		result[i] = append([]byte{}, x...)
+	}
+
+	return v.withValues(result), nil
+}
+func (v *FilterArray) Set(indexes *FTIntegerArray, values ArrayTypeVal) error {
+	vs, ok := values.(*FilterArray)
+	if !ok {
+		return fmt.Errorf("values is not an %v", v.Name())
+	}
+	if err := v.checkCompatible(vs); err != nil {
+		return err
+	}
+	return SliceSet(v.array, indexes.array, cloneFilters(vs.array))
+}
+func (v *FilterArray) Element(i int64) interface{} {
+	return append([]byte{}, v.array[i]...)
+}
+
+// Index returns the positions of the filters which are not empty.
+func (v *FilterArray) Index() *FTIntegerArray {
+	result := make([]int64, 0)
+
+	for i, x := range v.array {
+		if x[0] != 0 {
+			result = append(result, int64(i))
+		}
+	}
+
+	return &FTIntegerArray{result}
+}
+
+// Contains compares whole filters. Use MayContain to test the items of a filter.
+func (v *FilterArray) Contains(values ArrayTypeVal) (*FTIntegerArray, error) {
+	items, ok := values.(*FilterArray)
+	if !ok {
+		return nil, fmt.Errorf("values is not an %v", v.Name())
+	}
+
+	set := make(map[string]struct{}, len(v.array))
+	for _, x := range v.array {
+		set[string(x)] = struct{}{}
+	}
+
+	results := make([]int64, len(items.array))
+	for i, x := range items.array {
+		_, ok := set[string(x)]
+		results[i] = BToI(ok)
+	}
+
+	return &FTIntegerArray{results}, nil
+}
+
+// reduce combines the filters at each index by their union.
+func (v *FilterArray) reduce(indexes *FTIntegerArray, values ArrayTypeVal, useCurrentValue bool) error {
+	xs, ok := values.(*FilterArray)
+	if !ok {
+		return fmt.Errorf("values is not an %v", v.Name())
+	}
+	if err := v.checkCompatible(xs); err != nil {
+		return err
+	}
+	if len(indexes.array) != len(xs.array) {
+		return errLengthsDoNotMatch
+	}
+	for _, i := range indexes.array {
+		if i < 0 || i >= v.Length() {
+			return errIndexesOutOfRange
+		}
+	}
+
+	updated := make(map[int64]struct{})
+
+	for i, j := range indexes.array {
+		if _, ok := updated[j]; ok || useCurrentValue {
+			x, err := v.union(v.array[j], xs.array[i])
+			if err != nil {
+				return err
+			}
+			v.array[j] = x
+		} else {
+			v.array[j] = append([]byte{}, xs.array[i]...)
+			updated[j] = struct{}{}
+		}
+	}
+	return nil
+}
+
+func (v *FilterArray) ReduceSum(indexes *FTIntegerArray, values ArrayTypeVal) error {
+	return v.reduce(indexes, values, false)
+}
+func (v *FilterArray) ReduceISum(indexes *FTIntegerArray, values ArrayTypeVal) error {
+	return v.reduce(indexes, values, true)
+}
+
+// CumSum returns the running union of the filters.
+func (v *FilterArray) CumSum() (ArrayTypeVal, error) {
+	results := make([][]byte, len(v.array))
+
+	sum := v.empty()
+
+	for i, x := range v.array {
+		var err error
+		sum, err = v.union(sum, x)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = sum
+	}
+
+	return v.withValues(results), nil
+}
+
+func (v *FilterArray) Mux(condition *FTIntegerArray, ifFalse ArrayTypeVal) (ArrayTypeVal, error) {
+	fs, ok := ifFalse.(*FilterArray)
+	if !ok {
+		return nil, fmt.Errorf("ifFalse is not an %v", v.Name())
+	}
+	if err := v.checkCompatible(fs); err != nil {
+		return nil, err
+	}
+	result, err := SliceMux(v.array, condition.array, fs.array)
+	if err != nil {
+		return nil, err
+	}
+	return v.withValues(cloneFilters(result)), nil
+}
+
+func asFilterArray(xs ArrayTypeVal) (*FilterArray, error) {
+	ys, ok := xs.(*FilterArray)
+	if !ok {
+		return nil, fmt.Errorf("value is not an %v", xs.Name())
+	}
+	return ys, nil
+}
+
+func (v *FilterArray) Eq(other ArrayTypeVal) (*FTIntegerArray, error) {
+	bs, err := asFilterArray(other)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := SliceMapBinary(v.array, bs.array, func(a, b []byte) int64 { return BToI(slices.Equal(a, b)) })
+	if err != nil {
+		return nil, err
+	}
+
+	return &FTIntegerArray{result}, nil
+}
+func (v *FilterArray) Ne(other ArrayTypeVal) (*FTIntegerArray, error) {
+	bs, err := asFilterArray(other)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := SliceMapBinary(v.array, bs.array, func(a, b []byte) int64 { return BToI(!slices.Equal(a, b)) })
+	if err != nil {
+		return nil, err
+	}
+
+	return &FTIntegerArray{result}, nil
+}
+
+func (v *FilterArray) union(a, b []byte) ([]byte, error) {
+	switch {
+	case a[0] == 0:
+		return append([]byte{}, b...), nil
+	case b[0] == 0:
+		return append([]byte{}, a...), nil
+	case a[0] != b[0]:
+		return nil, errFilterParameters
+	}
+
+	if v.kind == CuckooFilterB {
+		return cuckooUnion(a, b)
+	}
+
+	result := append([]byte{}, a...)
+	for i := 1; i < len(result); i++ {
+		result[i] |= b[i]
+	}
+	return result, nil
+}
+
+func (v *FilterArray) intersect(a, b []byte) ([]byte, error) {
+	switch {
+	case a[0] == 0 || b[0] == 0:
+		return v.empty(), nil
+	case a[0] != b[0]:
+		return nil, errFilterParameters
+	}
+
+	if v.kind == CuckooFilterB {
+		return cuckooIntersect(a, b), nil
+	}
+
+	result := append([]byte{}, a...)
+	for i := 1; i < len(result); i++ {
+		result[i] &= b[i]
+	}
+	return result, nil
+}
+
+// Union returns filters of the items in either of the corresponding filters. The union of
+// Cuckoo filters fails if the result does not have room for the items of both.
+func (v *FilterArray) Union(other *FilterArray) (*FilterArray, error) {
+	if err := v.checkCompatible(other); err != nil {
+		return nil, err
+	}
+	result, err := SliceMapBinaryE(v.array, other.array, v.union)
+	if err != nil {
+		return nil, err
+	}
+	return v.withValues(result), nil
+}
+
+// Intersect returns filters of the items in both of the corresponding filters. The
+// intersection of Bloom filters has a higher false positive rate than a filter built from the
+// intersection of the items.
+func (v *FilterArray) Intersect(other *FilterArray) (*FilterArray, error) {
+	if err := v.checkCompatible(other); err != nil {
+		return nil, err
+	}
+	result, err := SliceMapBinaryE(v.array, other.array, v.intersect)
+	if err != nil {
+		return nil, err
+	}
+	return v.withValues(result), nil
+}
+
+// MayContain tests whether each item may be in the corresponding filter, or in the only
+// filter of a singleton array.
+func (v *FilterArray) MayContain(items ArrayTypeVal) (*FTIntegerArray, error) {
+	xs, err := filterItems(items)
+	if err != nil {
+		return nil, err
+	}
+
+	filters := v.array
+	if len(filters) == 1 && len(xs) != 1 {
+		filters, err = SliceBroadcast(filters, int64(len(xs)))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	contains := bloomContains
+	if v.kind == CuckooFilterB {
+		contains = cuckooContains
+	}
+
+	result, err := SliceMapBinary(filters, xs, func(f, x []byte) int64 { return BToI(contains(f, x)) })
+	if err != nil {
+		return nil, err
+	}
+
+	return &FTIntegerArray{result}, nil
+}
+
+func (v *FilterArray) Values() [][]byte {
+	return v.array
+}
+
+func cloneFilters(xs [][]byte) [][]byte {
+	ys := make([][]byte, len(xs))
+	for i, x := range xs {
+		ys[i] = append([]byte{}, x...)
+	}
+	return ys
+}
+
+// filterItems returns the items of a Bytearray or Integer array as byte strings.
+func filterItems(items ArrayTypeVal) ([][]byte, error) {
+	switch items := items.(type) {
+	case *FTBytearrayArray:
+		return items.array, nil
+	case *FTIntegerArray:
+		xs := make([][]byte, len(items.array))
+		for i, x := range items.array {
+			xs[i] = binary.LittleEndian.AppendUint64(nil, uint64(x))
+		}
+		return xs, nil
+	default:
+		return nil, errors.New("filters hold the items of Bytearray or Integer arrays")
+	}
+}
+
+func checkFilterKind(kind BaseTypeCode) error {
+	if kind != BloomFilterB && kind != CuckooFilterB {
+		return fmt.Errorf("not a filter typecode: %v", kind)
+	}
+	return nil
+}
+
+// NewBloomFilter returns a singleton array of a Bloom filter of the items, sized for capacity
+// items with the given false positive rate. Capacity is the number of items if it is 0.
+func NewBloomFilter(items ArrayTypeVal, capacity int, fpRate float64) (*FilterArray, error) {
+	xs, err := filterItems(items)
+	if err != nil {
+		return nil, err
+	}
+	if capacity == 0 {
+		capacity = len(xs)
+	}
+
+	width, k, err := BloomFilterSize(capacity, fpRate)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := make([]byte, width)
+	filter[0] = byte(k)
+	for _, x := range xs {
+		bloomAdd(filter, x)
+	}
+
+	return &FilterArray{BloomFilterB, width, [][]byte{filter}}, nil
+}
+
+// NewCuckooFilter returns a singleton array of a Cuckoo filter of the items, sized for
+// capacity items with the given false positive rate. Capacity is the number of items if it is
+// 0. It fails if the items do not fit, which is only likely if there are more than capacity.
+func NewCuckooFilter(items ArrayTypeVal, capacity int, fpRate float64) (*FilterArray, error) {
+	xs, err := filterItems(items)
+	if err != nil {
+		return nil, err
+	}
+	if capacity == 0 {
+		capacity = len(xs)
+	}
+
+	width, fpBytes, err := CuckooFilterSize(capacity, fpRate)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := make([]byte, width)
+	filter[0] = byte(fpBytes)
+	for _, x := range xs {
+		if err := cuckooAdd(filter, x); err != nil {
+			return nil, err
+		}
+	}
+
+	return &FilterArray{CuckooFilterB, width, [][]byte{filter}}, nil
+}
+
+// NewEmptyFilterArray returns an array of empty filters of the given typecode.
+func NewEmptyFilterArray(tc TypeCode, length int64) (*FilterArray, error) {
+	if err := checkFilterKind(tc.GetBase()); err != nil {
+		return nil, err
+	}
+	if tc.Length() < 2 {
+		return nil, fmt.Errorf("invalid filter width: %v", tc.Length())
+	}
+
+	xs := &FilterArray{tc.GetBase(), tc.Length(), [][]byte{}}
+	err := xs.SetLength(length)
+	if err != nil {
+		return nil, err
+	}
+	return xs, nil
+}
+
+// NewFilterArrayFromBytes reads the binary form given by GetBinaryArray, for filters of the
+// given typecode.
+func NewFilterArrayFromBytes(tc TypeCode, bs []byte) (*FilterArray, error) {
+	xs, err := NewEmptyFilterArray(tc, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(bs)%xs.width != 0 {
+		return nil, errors.New("can't convert bytes to filter array")
+	}
+
+	check := checkBloomFilter
+	if xs.kind == CuckooFilterB {
+		check = checkCuckooFilter
+	}
+
+	xs.array = make([][]byte, len(bs)/xs.width)
+	for i := range xs.array {
+		xs.array[i] = append([]byte{}, bs[i*xs.width:(i+1)*xs.width]...)
+		if err := check(xs.array[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return xs, nil
+}
@@ -0,0 +1,133 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package types
+
+import (
+	"testing"
+)
+
+func filterTestItems(from int64, to int64) *FTIntegerArray {
+	xs := make([]int64, 0, to-from)
+	for i := from; i < to; i++ {
+		xs = append(xs, i)
+	}
+	return NewFTIntegerArray(xs...)
+}
+
+func countContained(t *testing.T, filter *FilterArray, items *FTIntegerArray) int64 {
+	t.Helper()
+
+	mask, err := filter.MayContain(items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	for _, x := range mask.Values() {
+		count += x
+	}
+	return count
+}
+
+func Test_FilterArray_FalsePositiveRate(t *testing.T) {
+	for _, build := range []func(ArrayTypeVal, int, float64) (*FilterArray, error){NewBloomFilter, NewCuckooFilter} {
+		filter, err := build(filterTestItems(0, 5000), 0, 0.01)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n := countContained(t, filter, filterTestItems(0, 5000)); n != 5000 {
+			t.Errorf("%v: only %v of the items are in the filter", filter.Name(), n)
+		}
+
+		// Twice the expected rate, well outside the sampling error for this many items
+		if n := countContained(t, filter, filterTestItems(5000, 25000)); n > 400 {
+			t.Errorf("%v: %v false positives in 20000", filter.Name(), n)
+		}
+	}
+}
+
+func Test_FilterArray_UnionIntersect(t *testing.T) {
+	for _, build := range []func(ArrayTypeVal, int, float64) (*FilterArray, error){NewBloomFilter, NewCuckooFilter} {
+		a, err := build(filterTestItems(0, 100), 200, 0.001)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := build(filterTestItems(50, 150), 200, 0.001)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		union, err := a.Union(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := countContained(t, union, filterTestItems(0, 150)); n != 150 {
+			t.Errorf("%v: only %v of the items are in the union", a.Name(), n)
+		}
+
+		intersection, err := a.Intersect(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := countContained(t, intersection, filterTestItems(50, 100)); n != 50 {
+			t.Errorf("%v: only %v of the items are in the intersection", a.Name(), n)
+		}
+		if n := countContained(t, intersection, filterTestItems(0, 50)); n > 5 {
+			t.Errorf("%v: %v items of only one filter are in the intersection", a.Name(), n)
+		}
+
+		// The empty filter is the identity of the union
+		empty, err := NewEmptyFilterArray(a.TypeCode(), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, err := empty.Union(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !u.Equals(a) {
+			t.Errorf("%v: union with the empty filter should not change the filter", a.Name())
+		}
+
+		bs, err := union.GetBinaryArray(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		xs, err := FromBytes(union.TypeCode(), bs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !xs.Equals(union) {
+			t.Errorf("%v: decoded array should equal the original", a.Name())
+		}
+	}
+}
+
+func Test_FilterArray_Bytearrays(t *testing.T) {
+	xs := NewFTBytearrayArrayOrPanic(8, []byte{5, 0, 0, 0, 0, 0, 0, 0}, []byte{1, 2, 3, 4, 5, 6, 7, 8})
+
+	filter, err := NewCuckooFilter(xs, 0, 0.001)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Integers are added as the bytearrays they convert to
+	contains, err := filter.MayContain(NewFTIntegerArray(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !contains.Equals(NewFTIntegerArray(1)) {
+		t.Errorf("unexpected contains %v", contains)
+	}
+
+	if _, err := NewBloomFilter(NewFTFloatArray(1), 0, 0.01); err == nil {
+		t.Error("filters of floats should not be built")
+	}
+}
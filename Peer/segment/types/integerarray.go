@@ -109,6 +109,8 @@ func (v *FTIntegerArray) AsType(tc TypeCode) (TypeVal, error) {
 		return NewFTEd25519IntArrayFromInt64s(v.array...), nil
 	case Ed25519B:
 		return NewEd25519ArrayFromInt64s(v.array...)
+	case RistrettoB:
+		return NewRistrettoArrayFromInt64s(v.array...), nil
 	default:
 		return nil, fmt.Errorf("conversion not supported: %v -> %v", v.TypeCode(), tc)
 	}
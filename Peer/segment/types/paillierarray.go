@@ -0,0 +1,545 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package types
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// PaillierArray is an array of Paillier ciphertexts under a single public key. Adding two
+// arrays adds the plaintexts, and multiplying by an Integer array multiplies the plaintexts by
+// the integers, without either needing the private key. Encryption and decryption are in the
+// crypto package.
+//
+// The typecode gives the width of a ciphertext in bytes, which is twice the width of the
+// modulus n. The binary form of an array is n followed by each ciphertext, all big-endian and
+// of the ciphertext width, so that the array can be used on another peer without separately
+// sending the public key. Arrays of the trivial encryption of zero do not need a key, and take
+// it from the other operand. Their n is written as zero.
+type PaillierArray struct {
+	n        *big.Int
+	nSquared *big.Int
+	width    int
+	array    []*big.Int
+}
+
+var errPaillierKeysDoNotMatch = errors.New("Paillier arrays are encrypted under different public keys")
+
+func (v *PaillierArray) Width() int         { return v.width }
+func (v *PaillierArray) TypeCode() TypeCode { return Paillier(v.width) }
+func (v *PaillierArray) Equals(other TypeVal) bool {
+	if os, ok := other.(*PaillierArray); ok {
+		if v.width == os.width && v.Length() == os.Length() {
+			if (v.n == nil) != (os.n == nil) || (v.n != nil && v.n.Cmp(os.n) != 0) {
+				return false
+			}
+			for i, t := range v.array {
+				if t.Cmp(os.array[i]) != 0 {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+func (v *PaillierArray) Name() string { return "PaillierArray" }
+
+func (v *PaillierArray) GetBinaryArray(index int) ([]byte, error) {
+	if v.n == nil && len(v.array) == 0 {
+		return []byte{}, nil
+	}
+
+	data := make([]byte, (1+len(v.array))*v.width)
+	if v.n != nil {
+		v.n.FillBytes(data[:v.width])
+	}
+
+	for i, x := range v.array {
+		offset := (1 + i) * v.width
+		x.FillBytes(data[offset : offset+v.width])
+	}
+
+	return data, nil
+}
+func (v *PaillierArray) EstimatedSize() int64 { return v.Length() * int64(v.width) }
+func (v *PaillierArray) String() string {
+	s := "["
+	for _, x := range v.array {
+		s += " " + x.Text(16)
+	}
+	s += " ]"
+	return s
+}
+func (v *PaillierArray) DebugString() string {
+	return fmt.Sprintf("%v(Length=%v,Memory=%v)", v.Name(), v.Length(), PrintSize(uint64(v.EstimatedSize())))
+}
+func (v *PaillierArray) Clone() (TypeVal, error) {
+	return v.withValues(cloneBigInts(v.array)), nil
+}
+func (v *PaillierArray) AsType(tc TypeCode) (TypeVal, error) {
+	if tc == v.TypeCode() {
+		return v.Clone()
+	}
+	return nil, fmt.Errorf("conversion not supported: %v -> %v", v.TypeCode(), tc)
+}
+
+func (v *PaillierArray) withValues(xs []*big.Int) *PaillierArray {
+	return &PaillierArray{v.n, v.nSquared, v.width, xs}
+}
+
+// checkKey returns the key shared by both arrays, taking it from whichever one has it.
+func (v *PaillierArray) checkKey(other *PaillierArray) (*PaillierArray, error) {
+	if v.width != other.width {
+		return nil, fmt.Errorf("Paillier arrays have different widths: %v and %v", v.TypeCode(), other.TypeCode())
+	}
+	if v.n == nil {
+		return other, nil
+	}
+	if other.n != nil && v.n.Cmp(other.n) != 0 {
+		return nil, errPaillierKeysDoNotMatch
+	}
+	return v, nil
+}
+
+func (v *PaillierArray) Length() int64 { return int64(len(v.array)) }
+
+// SetLength pads the array with the trivial encryption of zero, which is 1.
+func (v *PaillierArray) SetLength(x int64) error {
+	if v.Length() == x {
+		return nil
+	}
+
+	xs := make([]*big.Int, x)
+
+	for i := range xs {
+		if i < len(v.array) {
+			xs[i] = v.array[i]
+		} else {
+			xs[i] = big.NewInt(1)
+		}
+	}
+
+	v.array = xs
+	return nil
+}
+func (v *PaillierArray) Remove(indexes *FTIntegerArray) error {
+	xs, err := SliceRemove(v.array, indexes.array)
+	if err != nil {
+		return err
+	}
+	v.array = xs
+	return nil
+}
+func (v *PaillierArray) Broadcast(length int64) error {
+	xs, err := SliceBroadcast(v.array, length)
+	if err != nil {
+		return err
+	}
+	v.array = xs
+	return nil
+}
+func (v *PaillierArray) Lookup(indexes *FTIntegerArray, defaultValue ArrayTypeVal) (ArrayTypeVal, error) {
+	if defaultValue == nil {
+		defaultValue = v.withValues([]*big.Int{big.NewInt(1)})
+	}
+	return v.Get(indexes, defaultValue)
+}
+func (v *PaillierArray) Get(indexes *FTIntegerArray, defaultValue ArrayTypeVal) (ArrayTypeVal, error) {
+	if indexes == nil {
+		xs, err := v.Clone()
+		if err != nil {
+			return nil, err
+		}
+		return xs.(ArrayTypeVal), nil
+	}
+
+	var d *big.Int
+	k := v
+
+	if defaultValue != nil {
+		vs, ok := defaultValue.(*PaillierArray)
+		if !ok {
+			return nil, fmt.Errorf("defaultValue is not an %v", v.Name())
+		}
+		var err error
+		k, err = v.checkKey(vs)
+		if err != nil {
+			return nil, err
+		}
+		if len(vs.array) != 1 {
+			return nil, errors.New("not a singleton array")
+		}
+		d = vs.array[0]
+	}
+
+	result := make([]*big.Int, len(indexes.array))
+
+	for i, k := range indexes.array {
+		key := int(k)
+
+		var x *big.Int
+		if key >= len(v.array) || key < 0 {
+			if d == nil {
+				return nil, fmt.Errorf("out of range: %d", key)
+			}
+
+			x = d
+		} else {
+			x = v.array[key]
+		}
+
+		result[i] = new(big.Int).Set(x)
+	}
+
+	return k.withValues(result), nil
+}
+func (v *PaillierArray) Set(indexes *FTIntegerArray, values ArrayTypeVal) error {
+	vs, ok := values.(*PaillierArray)
+	if !ok {
+		return fmt.Errorf("values is not an %v", v.Name())
+	}
+	k, err := v.checkKey(vs)
+	if err != nil {
+		return err
+	}
+	v.n, v.nSquared = k.n, k.nSquared
+	return SliceSet(v.array, indexes.array, vs.array)
+}
+func (v *PaillierArray) Element(i int64) interface{} {
+	return new(big.Int).Set(v.array[i])
+}
+
+// Index returns the positions of the ciphertexts which are not the trivial encryption of zero.
+// Other encryptions of zero can not be recognised without the private key.
+func (v *PaillierArray) Index() *FTIntegerArray {
+	result := make([]int64, 0)
+
+	one := big.NewInt(1)
+
+	for i, x := range v.array {
+		if x.Cmp(one) != 0 {
+			result = append(result, int64(i))
+		}
+	}
+
+	return &FTIntegerArray{result}
+}
+
+// Contains compares ciphertexts, not plaintexts.
+func (v *PaillierArray) Contains(values ArrayTypeVal) (*FTIntegerArray, error) {
+	items, ok := values.(*PaillierArray)
+	if !ok {
+		return nil, fmt.Errorf("values is not an %v", v.Name())
+	}
+
+	set := make(map[string]struct{}, len(v.array))
+	for _, x := range v.array {
+		set[string(x.Bytes())] = struct{}{}
+	}
+
+	results := make([]int64, len(items.array))
+	for i, x := range items.array {
+		_, ok := set[string(x.Bytes())]
+		results[i] = BToI(ok)
+	}
+
+	return &FTIntegerArray{results}, nil
+}
+func (v *PaillierArray) reduce(indexes *FTIntegerArray, values ArrayTypeVal, useCurrentValue bool) error {
+	xs, ok := values.(*PaillierArray)
+	if !ok {
+		return fmt.Errorf("values is not an %v", v.Name())
+	}
+	k, err := v.checkKey(xs)
+	if err != nil {
+		return err
+	}
+	if len(indexes.array) != len(xs.array) {
+		return errLengthsDoNotMatch
+	}
+	for _, i := range indexes.array {
+		if i < 0 || i >= v.Length() {
+			return errIndexesOutOfRange
+		}
+	}
+
+	v.n, v.nSquared = k.n, k.nSquared
+	updated := make(map[int64]struct{})
+
+	for i, j := range indexes.array {
+		if _, ok := updated[j]; ok || useCurrentValue {
+			v.array[j] = v.add(v.array[j], xs.array[i])
+		} else {
+			v.array[j] = new(big.Int).Set(xs.array[i])
+			updated[j] = struct{}{}
+		}
+	}
+	return nil
+}
+
+func (v *PaillierArray) ReduceSum(indexes *FTIntegerArray, values ArrayTypeVal) error {
+	return v.reduce(indexes, values, false)
+}
+func (v *PaillierArray) ReduceISum(indexes *FTIntegerArray, values ArrayTypeVal) error {
+	return v.reduce(indexes, values, true)
+}
+
+func (v *PaillierArray) CumSum() (ArrayTypeVal, error) {
+	results := make([]*big.Int, len(v.array))
+
+	sum := big.NewInt(1)
+
+	for i, x := range v.array {
+		sum = v.add(sum, x)
+		results[i] = sum
+	}
+
+	return v.withValues(results), nil
+}
+
+func (v *PaillierArray) Mux(condition *FTIntegerArray, ifFalse ArrayTypeVal) (ArrayTypeVal, error) {
+	fs, ok := ifFalse.(*PaillierArray)
+	if !ok {
+		return nil, fmt.Errorf("ifFalse is not an %v", v.Name())
+	}
+	k, err := v.checkKey(fs)
+	if err != nil {
+		return nil, err
+	}
+	result, err := SliceMux(v.array, condition.array, fs.array)
+	if err != nil {
+		return nil, err
+	}
+	return k.withValues(result), nil
+}
+
+func asPaillierArray(xs ArrayTypeVal) (*PaillierArray, error) {
+	ys, ok := xs.(*PaillierArray)
+	if !ok {
+		return nil, fmt.Errorf("value is not an %v", xs.Name())
+	}
+	return ys, nil
+}
+
+// Eq compares ciphertexts, not plaintexts.
+func (v *PaillierArray) Eq(other ArrayTypeVal) (*FTIntegerArray, error) {
+	bs, err := asPaillierArray(other)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := SliceMapBinary(v.array, bs.array, func(a, b *big.Int) int64 { return BToI(a.Cmp(b) == 0) })
+	if err != nil {
+		return nil, err
+	}
+
+	return &FTIntegerArray{result}, nil
+}
+
+// Ne compares ciphertexts, not plaintexts.
+func (v *PaillierArray) Ne(other ArrayTypeVal) (*FTIntegerArray, error) {
+	bs, err := asPaillierArray(other)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := SliceMapBinary(v.array, bs.array, func(a, b *big.Int) int64 { return BToI(a.Cmp(b) != 0) })
+	if err != nil {
+		return nil, err
+	}
+
+	return &FTIntegerArray{result}, nil
+}
+
+// An array without a key only holds the trivial encryption of zero, 1, which add and scale
+// keep as 1.
+
+func (v *PaillierArray) add(a, b *big.Int) *big.Int {
+	x := new(big.Int).Mul(a, b)
+	if v.nSquared == nil {
+		return x
+	}
+	return x.Mod(x, v.nSquared)
+}
+
+// scale returns an encryption of the plaintext of a multiplied by k.
+func (v *PaillierArray) scale(a *big.Int, k int64) (*big.Int, error) {
+	if v.nSquared == nil {
+		return new(big.Int).Set(a), nil
+	}
+	if k >= 0 {
+		return new(big.Int).Exp(a, big.NewInt(k), v.nSquared), nil
+	}
+
+	inverse := new(big.Int).ModInverse(a, v.nSquared)
+	if inverse == nil {
+		return nil, errors.New("Paillier ciphertext is not invertible")
+	}
+	e := new(big.Int).Neg(big.NewInt(k))
+	return inverse.Exp(inverse, e, v.nSquared), nil
+}
+
+// Neg returns encryptions of the negated plaintexts.
+func (v *PaillierArray) Neg() (ArrayNegTypeVal, error) {
+	result := make([]*big.Int, len(v.array))
+	for i, a := range v.array {
+		var err error
+		result[i], err = v.scale(a, -1)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return v.withValues(result), nil
+}
+
+// Add returns encryptions of the sums of the plaintexts.
+func (v *PaillierArray) Add(other ArrayTypeVal) (ArrayTypeVal, error) {
+	bs, err := asPaillierArray(other)
+	if err != nil {
+		return nil, err
+	}
+	k, err := v.checkKey(bs)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := SliceMapBinary(v.array, bs.array, k.add)
+	if err != nil {
+		return nil, err
+	}
+
+	return k.withValues(result), nil
+}
+
+// Sub returns encryptions of the differences of the plaintexts.
+func (v *PaillierArray) Sub(other ArrayTypeVal) (ArrayTypeVal, error) {
+	bs, err := asPaillierArray(other)
+	if err != nil {
+		return nil, err
+	}
+	k, err := v.checkKey(bs)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := SliceMapBinaryE(v.array, bs.array, func(a, b *big.Int) (*big.Int, error) {
+		c, err := k.scale(b, -1)
+		if err != nil {
+			return nil, err
+		}
+		return k.add(a, c), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return k.withValues(result), nil
+}
+
+// Mul returns encryptions of the plaintexts multiplied by the corresponding integers.
+func (v *PaillierArray) Mul(other ArrayTypeVal) (ArrayTypeVal, error) {
+	bs, err := asFTIntegerArray(other)
+	if err != nil {
+		return nil, errors.New("Paillier arrays can only be multiplied by Integer arrays")
+	}
+
+	result, err := SliceMapBinaryE(v.array, bs.array, v.scale)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.withValues(result), nil
+}
+
+// Modulus returns the modulus n of the public key, or nil for an array without a key.
+func (v *PaillierArray) Modulus() *big.Int {
+	return v.n
+}
+func (v *PaillierArray) Values() []*big.Int {
+	return v.array
+}
+
+func cloneBigInts(xs []*big.Int) []*big.Int {
+	ys := make([]*big.Int, len(xs))
+	for i, x := range xs {
+		ys[i] = new(big.Int).Set(x)
+	}
+	return ys
+}
+
+// NewPaillierArray returns an array of ciphertexts under the public key with modulus n. Each
+// ciphertext must be less than n^2.
+func NewPaillierArray(n *big.Int, xs ...*big.Int) (*PaillierArray, error) {
+	width := 2 * ((n.BitLen() + 7) / 8)
+	nSquared := new(big.Int).Mul(n, n)
+
+	for _, x := range xs {
+		if x.Sign() < 0 || x.Cmp(nSquared) >= 0 {
+			return nil, errors.New("Paillier ciphertext is out of range")
+		}
+	}
+
+	return &PaillierArray{n, nSquared, width, cloneBigInts(xs)}, nil
+}
+
+// NewPaillierArrayOfZeros returns an array of the trivial encryption of zero, which does not
+// have a public key until it is combined with an array that does.
+func NewPaillierArrayOfZeros(width int, length int64) (*PaillierArray, error) {
+	if width < 2 || width%2 != 0 {
+		return nil, fmt.Errorf("invalid Paillier ciphertext width: %v", width)
+	}
+
+	xs := &PaillierArray{nil, nil, width, []*big.Int{}}
+	err := xs.SetLength(length)
+	if err != nil {
+		return nil, err
+	}
+	return xs, nil
+}
+
+// NewPaillierArrayFromBytes reads the binary form given by GetBinaryArray, for ciphertexts of
+// the given width.
+func NewPaillierArrayFromBytes(bs []byte, width int) (*PaillierArray, error) {
+	if len(bs) == 0 {
+		return NewPaillierArrayOfZeros(width, 0)
+	}
+	if width < 2 || width%2 != 0 {
+		return nil, fmt.Errorf("invalid Paillier ciphertext width: %v", width)
+	}
+	if len(bs)%width != 0 {
+		return nil, errors.New("can't convert bytes to Paillier array")
+	}
+
+	n := new(big.Int).SetBytes(bs[:width])
+	if n.Sign() != 0 && 2*((n.BitLen()+7)/8) != width {
+		return nil, errors.New("Paillier modulus does not match the typecode")
+	}
+
+	bs = bs[width:]
+	xs := make([]*big.Int, len(bs)/width)
+	for i := range xs {
+		xs[i] = new(big.Int).SetBytes(bs[i*width : (i+1)*width])
+	}
+
+	if n.Sign() == 0 {
+		// Without a key, the only ciphertext is the trivial encryption of zero
+		for _, x := range xs {
+			if x.Cmp(big.NewInt(1)) != 0 {
+				return nil, errors.New("Paillier array has no public key")
+			}
+		}
+		return &PaillierArray{nil, nil, width, xs}, nil
+	}
+	return NewPaillierArray(n, xs...)
+}
@@ -0,0 +1,124 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package types
+
+import (
+	"math/big"
+	"testing"
+)
+
+func assertPaillierValues(t *testing.T, name string, xs ArrayTypeVal, expected ...int64) {
+	t.Helper()
+
+	values := xs.(*PaillierArray).Values()
+	if len(values) != len(expected) {
+		t.Fatalf("%v: expected %v values, got %v", name, len(expected), len(values))
+	}
+	for i, x := range values {
+		if x.Cmp(big.NewInt(expected[i])) != 0 {
+			t.Errorf("%v: expected %v at %v, got %v", name, expected[i], i, x)
+		}
+	}
+}
+
+func Test_PaillierArray_Keyless(t *testing.T) {
+	zeros, err := NewPaillierArrayOfZeros(4, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewPaillierArrayOfZeros(4, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Arrays without a key stay trivial encryptions of zero
+	neg, err := zeros.Neg()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertPaillierValues(t, "Neg", neg.(ArrayTypeVal), 1, 1, 1)
+
+	cumsum, err := zeros.CumSum()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertPaillierValues(t, "CumSum", cumsum, 1, 1, 1)
+
+	sum, err := zeros.Add(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertPaillierValues(t, "Add", sum, 1, 1, 1)
+
+	difference, err := zeros.Sub(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertPaillierValues(t, "Sub", difference, 1, 1, 1)
+
+	product, err := zeros.Mul(NewFTIntegerArray(-2, 0, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertPaillierValues(t, "Mul", product, 1, 1, 1)
+
+	if err := zeros.ReduceSum(NewFTIntegerArray(0, 0, 2), other); err != nil {
+		t.Fatal(err)
+	}
+	assertPaillierValues(t, "ReduceSum", zeros, 1, 1, 1)
+	if zeros.Modulus() != nil {
+		t.Error("expected the array to still have no key")
+	}
+
+	// They can be transmitted and saved without a key
+	bs, err := other.GetBinaryArray(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := NewPaillierArrayFromBytes(bs, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !read.Equals(other) {
+		t.Errorf("expected %v, got %v", other, read)
+	}
+	bs[len(bs)-1] = 2
+	if _, err := NewPaillierArrayFromBytes(bs, 4); err == nil {
+		t.Error("expected a ciphertext other than 1 without a key to be rejected")
+	}
+
+	// Combined with an array that has a key, they take its key
+	keyed, err := NewPaillierArray(big.NewInt(3233), big.NewInt(2), big.NewInt(3), big.NewInt(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := other.Get(NewFTIntegerArray(0, 3), keyed.withValues([]*big.Int{big.NewInt(7)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertPaillierValues(t, "Get with a key", got, 1, 7)
+	if got.(*PaillierArray).Modulus() == nil {
+		t.Error("expected the result of Get to have taken the key of the default")
+	}
+	sum, err = zeros.Add(keyed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertPaillierValues(t, "Add with a key", sum, 2, 3, 5)
+
+	if err := zeros.ReduceSum(NewFTIntegerArray(1, 1, 0), keyed); err != nil {
+		t.Fatal(err)
+	}
+	assertPaillierValues(t, "ReduceSum with a key", zeros, 5, 6, 1)
+	if zeros.Modulus().Cmp(big.NewInt(3233)) != 0 {
+		t.Error("expected the array to have taken the key")
+	}
+}
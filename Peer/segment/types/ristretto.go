@@ -0,0 +1,159 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package types
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+
+	"filippo.io/edwards25519"
+	"filippo.io/edwards25519/field"
+)
+
+// Ristretto255 is the prime order group of RFC 9496, built on edwards25519. Each group element
+// is represented by one of the edwards25519 points in its coset of the 4-torsion subgroup, and
+// has a single canonical 32 byte encoding, so that equal elements always encode equally and
+// no encoding decodes to a point of small order.
+
+const RistrettoPointBytes = 32
+
+var errInvalidRistrettoEncoding = errors.New("invalid Ristretto255 encoding")
+
+var (
+	ristrettoD              *field.Element // the edwards25519 curve constant d
+	ristrettoSqrtM1         *field.Element // SQRT_M1
+	ristrettoInvSqrtAMinusD *field.Element // INVSQRT_A_MINUS_D
+	ristrettoFieldOne       = new(field.Element).One()
+	ristrettoFieldZero      = new(field.Element).Zero()
+)
+
+func init() {
+	p := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+
+	d := new(big.Int).ModInverse(big.NewInt(121666), p)
+	d.Mul(d, big.NewInt(-121665))
+	ristrettoD = fieldElementFromBigInt(d.Mod(d, p))
+
+	e := new(big.Int).Rsh(new(big.Int).Sub(p, big.NewInt(1)), 2)
+	ristrettoSqrtM1 = fieldElementFromBigInt(new(big.Int).Exp(big.NewInt(2), e, p))
+
+	// a - d, where a = -1
+	aMinusD := new(field.Element).Negate(ristrettoFieldOne)
+	aMinusD.Subtract(aMinusD, ristrettoD)
+	ristrettoInvSqrtAMinusD, _ = new(field.Element).SqrtRatio(ristrettoFieldOne, aMinusD)
+}
+
+func fieldElementFromBigInt(x *big.Int) *field.Element {
+	buf := make([]byte, 32)
+	x.FillBytes(buf)
+	for i, j := 0, len(buf)-1; i < j; i, j = i+1, j-1 {
+		buf[i], buf[j] = buf[j], buf[i]
+	}
+
+	e, err := new(field.Element).SetBytes(buf)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+// RistrettoDecode returns a point representing the encoded element, or an error if the
+// encoding is not canonical.
+func RistrettoDecode(bs []byte) (*edwards25519.Point, error) {
+	if len(bs) != RistrettoPointBytes {
+		return nil, errInvalidRistrettoEncoding
+	}
+
+	s, err := new(field.Element).SetBytes(bs)
+	if err != nil {
+		return nil, err
+	}
+
+	// SetBytes ignores the top bit and reduces, so check that the encoding was canonical
+	if !bytes.Equal(s.Bytes(), bs) || s.IsNegative() == 1 {
+		return nil, errInvalidRistrettoEncoding
+	}
+
+	ss := new(field.Element).Square(s)
+	u1 := new(field.Element).Subtract(ristrettoFieldOne, ss)
+	u2 := new(field.Element).Add(ristrettoFieldOne, ss)
+	u2Sqr := new(field.Element).Square(u2)
+
+	// v = -(d * u1^2) - u2^2
+	v := new(field.Element).Square(u1)
+	v.Multiply(v, ristrettoD)
+	v.Negate(v)
+	v.Subtract(v, u2Sqr)
+
+	invSqrt, wasSquare := new(field.Element).SqrtRatio(ristrettoFieldOne, new(field.Element).Multiply(v, u2Sqr))
+
+	denX := new(field.Element).Multiply(invSqrt, u2)
+	denY := new(field.Element).Multiply(invSqrt, denX)
+	denY.Multiply(denY, v)
+
+	x := new(field.Element).Multiply(s, denX)
+	x.Add(x, x)
+	x.Absolute(x)
+	y := new(field.Element).Multiply(u1, denY)
+	t := new(field.Element).Multiply(x, y)
+
+	if wasSquare == 0 || t.IsNegative() == 1 || y.Equal(ristrettoFieldZero) == 1 {
+		return nil, errInvalidRistrettoEncoding
+	}
+
+	return new(edwards25519.Point).SetExtendedCoordinates(x, y, new(field.Element).One(), t)
+}
+
+// RistrettoEncode returns the canonical encoding of the element represented by the point.
+func RistrettoEncode(p *edwards25519.Point) []byte {
+	x0, y0, z0, t0 := p.ExtendedCoordinates()
+
+	u1 := new(field.Element).Add(z0, y0)
+	u1.Multiply(u1, new(field.Element).Subtract(z0, y0))
+	u2 := new(field.Element).Multiply(x0, y0)
+
+	r := new(field.Element).Square(u2)
+	r.Multiply(r, u1)
+	invSqrt, _ := new(field.Element).SqrtRatio(ristrettoFieldOne, r)
+
+	den1 := new(field.Element).Multiply(invSqrt, u1)
+	den2 := new(field.Element).Multiply(invSqrt, u2)
+	zInv := new(field.Element).Multiply(den1, den2)
+	zInv.Multiply(zInv, t0)
+
+	ix0 := new(field.Element).Multiply(x0, ristrettoSqrtM1)
+	iy0 := new(field.Element).Multiply(y0, ristrettoSqrtM1)
+	enchantedDenominator := new(field.Element).Multiply(den1, ristrettoInvSqrtAMinusD)
+
+	rotate := new(field.Element).Multiply(t0, zInv).IsNegative()
+	x := new(field.Element).Select(iy0, x0, rotate)
+	y := new(field.Element).Select(ix0, y0, rotate)
+	denInv := new(field.Element).Select(enchantedDenominator, den2, rotate)
+
+	negY := new(field.Element).Negate(y)
+	y.Select(negY, y, new(field.Element).Multiply(x, zInv).IsNegative())
+
+	s := new(field.Element).Subtract(z0, y)
+	s.Multiply(s, denInv)
+	s.Absolute(s)
+
+	return s.Bytes()
+}
+
+// RistrettoEqual reports whether two points represent the same element, without encoding them.
+func RistrettoEqual(p, q *edwards25519.Point) bool {
+	x1, y1, _, _ := p.ExtendedCoordinates()
+	x2, y2, _, _ := q.ExtendedCoordinates()
+
+	a := new(field.Element).Multiply(x1, y2).Equal(new(field.Element).Multiply(y1, x2))
+	b := new(field.Element).Multiply(y1, y2).Equal(new(field.Element).Multiply(x1, x2))
+	return a|b == 1
+}
@@ -0,0 +1,435 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package types
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"filippo.io/edwards25519"
+)
+
+// RistrettoArray is an array of Ristretto255 elements. Unlike Ed25519Array the group has prime
+// order, so scaling by a non-zero scalar never maps a non-identity element to the identity, and
+// the array is always serialised with the canonical encoding of each element.
+type RistrettoArray struct {
+	array []*edwards25519.Point
+}
+
+func (v *RistrettoArray) TypeCode() TypeCode { return Ristretto }
+func (v *RistrettoArray) Equals(other TypeVal) bool {
+	if os, ok := other.(*RistrettoArray); ok {
+		if v.Length() == os.Length() {
+			for i, t := range v.array {
+				if !RistrettoEqual(t, os.array[i]) {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+func (v *RistrettoArray) Name() string { return "RistrettoArray" }
+
+func (v *RistrettoArray) GetBinaryArray(index int) ([]byte, error) {
+	data := make([]byte, 0, len(v.array)*RistrettoPointBytes)
+
+	for _, v := range v.array {
+		data = append(data, RistrettoEncode(v)...)
+	}
+
+	return data, nil
+}
+func (v *RistrettoArray) EstimatedSize() int64 { return v.Length() * Ed25519ExtendedPointBytes }
+func (v *RistrettoArray) String() string {
+	s := "["
+	for _, v := range v.array {
+		s += " " + hex.EncodeToString(RistrettoEncode(v))
+	}
+	s += " ]"
+	return s
+}
+func (v *RistrettoArray) DebugString() string {
+	return fmt.Sprintf("%v(Length=%v,Memory=%v)", v.Name(), v.Length(), PrintSize(uint64(v.EstimatedSize())))
+}
+func (v *RistrettoArray) Clone() (TypeVal, error) {
+	return NewRistrettoArray(v.array...), nil
+}
+func (v *RistrettoArray) AsType(tc TypeCode) (TypeVal, error) {
+	switch tc.GetBase() {
+	case RistrettoB:
+		return v.Clone()
+	case BytearrayB:
+		if tc.Length() != RistrettoPointBytes {
+			return nil, errors.New("arrays of Ristretto255 can only be converted to bytearrays of length 32")
+		}
+		xs := make([][]byte, len(v.array))
+		for i, v := range v.array {
+			xs[i] = RistrettoEncode(v)
+		}
+		return NewFTBytearrayArray(tc.Length(), xs...)
+	default:
+		return nil, fmt.Errorf("conversion not supported: %v -> %v", v.TypeCode(), tc)
+	}
+}
+
+func (v *RistrettoArray) Length() int64 { return int64(len(v.array)) }
+func (v *RistrettoArray) SetLength(x int64) error {
+	if v.Length() == x {
+		return nil
+	}
+
+	xs := make([]*edwards25519.Point, x)
+
+	for i := range xs {
+		if i < len(v.array) {
+			xs[i] = v.array[i]
+		} else {
+			xs[i] = edwards25519.NewIdentityPoint()
+		}
+	}
+
+	v.array = xs
+	return nil
+}
+func (v *RistrettoArray) Remove(indexes *FTIntegerArray) error {
+	xs, err := SliceRemove(v.array, indexes.array)
+	if err != nil {
+		return err
+	}
+	v.array = xs
+	return nil
+}
+func (v *RistrettoArray) Broadcast(length int64) error {
+	xs, err := SliceBroadcast(v.array, length)
+	if err != nil {
+		return err
+	}
+	v.array = xs
+	return nil
+}
+func (v *RistrettoArray) Lookup(indexes *FTIntegerArray, defaultValue ArrayTypeVal) (ArrayTypeVal, error) {
+	if defaultValue == nil {
+		defaultValue = NewRistrettoArray(edwards25519.NewIdentityPoint())
+	}
+	return v.Get(indexes, defaultValue)
+}
+func (v *RistrettoArray) Get(indexes *FTIntegerArray, defaultValue ArrayTypeVal) (ArrayTypeVal, error) {
+	if indexes == nil {
+		xs, err := v.Clone()
+		if err != nil {
+			return nil, err
+		}
+		return xs.(ArrayTypeVal), nil
+	}
+
+	var d *edwards25519.Point
+
+	if defaultValue != nil {
+		vs, ok := defaultValue.(*RistrettoArray)
+		if !ok {
+			return nil, fmt.Errorf("defaultValue is not an %v", v.Name())
+		}
+		var err error
+		d, err = vs.Single()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := make([]*edwards25519.Point, len(indexes.array))
+
+	for i, k := range indexes.array {
+		key := int(k)
+
+		var x *edwards25519.Point
+		if key >= len(v.array) || key < 0 {
+			if d == nil {
+				return nil, fmt.Errorf("out of range: %d", key)
+			}
+
+			x = d
+		} else {
+			x = v.array[key]
+		}
+
+		result[i] = new(edwards25519.Point).Set(x)
+	}
+
+	return &RistrettoArray{result}, nil
+}
+func (v *RistrettoArray) Set(indexes *FTIntegerArray, values ArrayTypeVal) error {
+	vs, ok := values.(*RistrettoArray)
+	if !ok {
+		return fmt.Errorf("values is not an %v", v.Name())
+	}
+	return SliceSet(v.array, indexes.array, vs.array)
+}
+func (v *RistrettoArray) Element(i int64) interface{} {
+	return RistrettoEncode(v.array[i])
+}
+
+// Index returns the positions of the elements which are not the identity.
+func (v *RistrettoArray) Index() *FTIntegerArray {
+	result := make([]int64, 0)
+
+	identity := edwards25519.NewIdentityPoint()
+
+	for i := 0; i < len(v.array); i++ {
+		if !RistrettoEqual(v.array[i], identity) {
+			result = append(result, int64(i))
+		}
+	}
+
+	return &FTIntegerArray{result}
+}
+func (v *RistrettoArray) Contains(values ArrayTypeVal) (*FTIntegerArray, error) {
+	items, ok := values.(*RistrettoArray)
+	if !ok {
+		return nil, fmt.Errorf("values is not an %v", v.Name())
+	}
+
+	// Equal elements have equal encodings, so the encodings can be used as keys
+	set := make(map[string]struct{}, len(v.array))
+	for _, x := range v.array {
+		set[string(RistrettoEncode(x))] = struct{}{}
+	}
+
+	results := make([]int64, len(items.array))
+	for i, x := range items.array {
+		_, ok := set[string(RistrettoEncode(x))]
+		results[i] = BToI(ok)
+	}
+
+	return &FTIntegerArray{results}, nil
+}
+func (v *RistrettoArray) reduce(indexes *FTIntegerArray, values *RistrettoArray, useCurrentValue bool) error {
+	if len(indexes.array) != len(values.array) {
+		return errLengthsDoNotMatch
+	}
+	for _, k := range indexes.array {
+		if k < 0 || k >= v.Length() {
+			return errIndexesOutOfRange
+		}
+	}
+
+	updated := make(map[int64]struct{})
+
+	for i, k := range indexes.array {
+		if useCurrentValue {
+			v.array[k] = new(edwards25519.Point).Add(v.array[k], values.array[i])
+		} else {
+			if _, ok := updated[k]; ok {
+				v.array[k] = new(edwards25519.Point).Add(v.array[k], values.array[i])
+			} else {
+				v.array[k] = new(edwards25519.Point).Set(values.array[i])
+				updated[k] = struct{}{}
+			}
+		}
+	}
+	return nil
+}
+
+func (v *RistrettoArray) ReduceSum(indexes *FTIntegerArray, values ArrayTypeVal) error {
+	xs, ok := values.(*RistrettoArray)
+	if !ok {
+		return fmt.Errorf("values is not an %v", v.Name())
+	}
+
+	return v.reduce(indexes, xs, false)
+}
+func (v *RistrettoArray) ReduceISum(indexes *FTIntegerArray, values ArrayTypeVal) error {
+	xs, ok := values.(*RistrettoArray)
+	if !ok {
+		return fmt.Errorf("values is not an %v", v.Name())
+	}
+
+	return v.reduce(indexes, xs, true)
+}
+
+func (v *RistrettoArray) CumSum() (ArrayTypeVal, error) {
+	results := make([]*edwards25519.Point, len(v.array))
+
+	sum := edwards25519.NewIdentityPoint()
+
+	for i, v := range v.array {
+		sum = new(edwards25519.Point).Add(sum, v)
+		results[i] = sum
+	}
+
+	return &RistrettoArray{results}, nil
+}
+
+func (v *RistrettoArray) Mux(condition *FTIntegerArray, ifFalse ArrayTypeVal) (ArrayTypeVal, error) {
+	fs, ok := ifFalse.(*RistrettoArray)
+	if !ok {
+		return nil, fmt.Errorf("ifFalse is not an %v", v.Name())
+	}
+	result, err := SliceMux(v.array, condition.array, fs.array)
+	if err != nil {
+		return nil, err
+	}
+	return &RistrettoArray{result}, nil
+}
+
+func asRistrettoArray(xs ArrayTypeVal) (*RistrettoArray, error) {
+	ys, ok := xs.(*RistrettoArray)
+	if !ok {
+		return nil, fmt.Errorf("value is not an %v", xs.Name())
+	}
+	return ys, nil
+}
+
+func (v *RistrettoArray) Eq(other ArrayTypeVal) (*FTIntegerArray, error) {
+	bs, err := asRistrettoArray(other)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := SliceMapBinary(v.array, bs.array, func(a, b *edwards25519.Point) int64 { return BToI(RistrettoEqual(a, b)) })
+	if err != nil {
+		return nil, err
+	}
+
+	return &FTIntegerArray{result}, nil
+}
+func (v *RistrettoArray) Ne(other ArrayTypeVal) (*FTIntegerArray, error) {
+	bs, err := asRistrettoArray(other)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := SliceMapBinary(v.array, bs.array, func(a, b *edwards25519.Point) int64 { return BToI(!RistrettoEqual(a, b)) })
+	if err != nil {
+		return nil, err
+	}
+
+	return &FTIntegerArray{result}, nil
+}
+func (v *RistrettoArray) Neg() (ArrayNegTypeVal, error) {
+	result, err := SliceMapUnary(v.array, func(a *edwards25519.Point) *edwards25519.Point {
+		return new(edwards25519.Point).Negate(a)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &RistrettoArray{result}, nil
+}
+
+func (v *RistrettoArray) Add(other ArrayTypeVal) (ArrayTypeVal, error) {
+	bs, err := asRistrettoArray(other)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := SliceMapBinary(v.array, bs.array, func(a, b *edwards25519.Point) *edwards25519.Point { return new(edwards25519.Point).Add(a, b) })
+	if err != nil {
+		return nil, err
+	}
+
+	return &RistrettoArray{result}, nil
+}
+func (v *RistrettoArray) Sub(other ArrayTypeVal) (ArrayTypeVal, error) {
+	bs, err := asRistrettoArray(other)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := SliceMapBinary(v.array, bs.array, func(a, b *edwards25519.Point) *edwards25519.Point { return new(edwards25519.Point).Subtract(a, b) })
+	if err != nil {
+		return nil, err
+	}
+
+	return &RistrettoArray{result}, nil
+}
+
+// Mul multiplies each element by the corresponding Ed25519Int scalar.
+func (v *RistrettoArray) Mul(other ArrayTypeVal) (ArrayTypeVal, error) {
+	bs, err := asFTEd25519IntArray(other)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := SliceMapBinary(v.array, bs.array, func(a *edwards25519.Point, b *edwards25519.Scalar) *edwards25519.Point {
+		return new(edwards25519.Point).ScalarMult(b, a)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &RistrettoArray{result}, nil
+}
+
+// Scale multiplies every element by the same scalar, in place.
+func (v *RistrettoArray) Scale(y *edwards25519.Scalar) error {
+	for i, x := range v.array {
+		v.array[i] = new(edwards25519.Point).ScalarMult(y, x)
+	}
+	return nil
+}
+
+func (v *RistrettoArray) Single() (*edwards25519.Point, error) {
+	if len(v.array) != 1 {
+		return nil, errors.New("not a singleton array")
+	}
+	return v.array[0], nil
+}
+func (v *RistrettoArray) Points() []*edwards25519.Point {
+	return v.array
+}
+
+func NewRistrettoArray(xs ...*edwards25519.Point) *RistrettoArray {
+	ys := make([]*edwards25519.Point, len(xs))
+	for i, x := range xs {
+		ys[i] = new(edwards25519.Point).Set(x)
+	}
+
+	return &RistrettoArray{ys}
+}
+
+// NewRistrettoArrayFromInt returns the multiples of the generator by each scalar.
+func NewRistrettoArrayFromInt(xs []*edwards25519.Scalar) *RistrettoArray {
+	ys := make([]*edwards25519.Point, len(xs))
+	for i, x := range xs {
+		ys[i] = new(edwards25519.Point).ScalarBaseMult(x)
+	}
+
+	return &RistrettoArray{ys}
+}
+func NewRistrettoArrayFromInt64s(xs ...int64) *RistrettoArray {
+	ys := make([]*edwards25519.Scalar, len(xs))
+	for i, x := range xs {
+		ys[i] = Int64ToScalar(x)
+	}
+
+	return NewRistrettoArrayFromInt(ys)
+}
+
+// NewRistrettoArrayFromBytes decodes concatenated canonical encodings, and fails if any of them
+// is not canonical.
+func NewRistrettoArrayFromBytes(bs []byte) (*RistrettoArray, error) {
+	if len(bs)%RistrettoPointBytes != 0 {
+		return nil, errors.New("can't convert bytes to Ristretto array")
+	}
+
+	count := len(bs) / RistrettoPointBytes
+	xs := make([]*edwards25519.Point, count)
+	for i := 0; i < count; i++ {
+		p, err := RistrettoDecode(bs[i*RistrettoPointBytes : (i+1)*RistrettoPointBytes])
+		if err != nil {
+			return nil, err
+		}
+		xs[i] = p
+	}
+	return &RistrettoArray{xs}, nil
+}
@@ -0,0 +1,123 @@
+// =====================================
+//
+// Copyright (c) 2023, AUSTRAC Australian Government
+// All rights reserved.
+//
+// Licensed under BSD 3 clause license
+//
+// #####################################
+
+package types
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// Multiples of the generator from RFC 9496 appendix A.1.
+var ristrettoGeneratorMultiples = []string{
+	"0000000000000000000000000000000000000000000000000000000000000000",
+	"e2f2ae0a6abc4e71a884a961c500515f58e30b6aa582dd8db6a65945e08d2d76",
+	"6a493210f7499cd17fecb510ae0cea23a110e8d5b901f8acadd3095c73a3b919",
+	"94741f5d5d52755ece4f23f044ee27d5d1ea1e2bd196b462166b16152a9d0259",
+	"da80862773358b466ffadfe0b3293ab3d9fd53c5ea6c955358f568322daf6a57",
+}
+
+func Test_RistrettoArray_GeneratorMultiples(t *testing.T) {
+	xs := NewRistrettoArrayFromInt64s(0, 1, 2, 3, 4)
+
+	for i, v := range xs.Points() {
+		got := hex.EncodeToString(RistrettoEncode(v))
+		if got != ristrettoGeneratorMultiples[i] {
+			t.Errorf("%vB: expected %v, got %v", i, ristrettoGeneratorMultiples[i], got)
+		}
+	}
+
+	bs, err := xs.GetBinaryArray(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ys, err := NewRistrettoArrayFromBytes(bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !xs.Equals(ys) {
+		t.Error("decoded array should equal the original")
+	}
+}
+
+func Test_RistrettoArray_InvalidEncodings(t *testing.T) {
+	for _, v := range []string{
+		// Non-canonical field elements
+		"00ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+		"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff7f",
+		"edffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff7f",
+		// Negative field elements
+		"0100000000000000000000000000000000000000000000000000000000000000",
+		"01ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff7f",
+		// Non-square x^2
+		"26948d35ca62e643e26a83177332e6b6afeb9d08e4268b650f1f5bbd8d81d371",
+		// Negative xy value
+		"3eb858e78f5a7254d8c9731174a94f76755fd3941c0ac93735c07ba14579630e",
+		// s = -1, which causes y = 0
+		"ecffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff7f",
+	} {
+		bs, _ := hex.DecodeString(v)
+		if _, err := RistrettoDecode(bs); err == nil {
+			t.Errorf("%v should not decode", v)
+		}
+	}
+}
+
+func Test_RistrettoArray_Arithmetic(t *testing.T) {
+	xs := NewRistrettoArrayFromInt64s(1, 2, 3)
+	ys := NewRistrettoArrayFromInt64s(4, 5, 6)
+
+	sum, err := xs.Add(ys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sum.Equals(NewRistrettoArrayFromInt64s(5, 7, 9)) {
+		t.Errorf("unexpected sum %v", sum)
+	}
+
+	diff, err := xs.Sub(ys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.Equals(NewRistrettoArrayFromInt64s(-3, -3, -3)) {
+		t.Errorf("unexpected difference %v", diff)
+	}
+
+	product, err := NewFTEd25519IntArrayFromInt64s(2, 3, 4).Mul(xs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !product.Equals(NewRistrettoArrayFromInt64s(2, 6, 12)) {
+		t.Errorf("unexpected product %v", product)
+	}
+
+	err = ys.Scale(Int64ToScalar(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ys.Equals(NewRistrettoArrayFromInt64s(8, 10, 12)) {
+		t.Errorf("unexpected scaled array %v", ys)
+	}
+
+	err = xs.ReduceSum(NewFTIntegerArray(0, 0, 2), NewRistrettoArrayFromInt64s(1, 1, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !xs.Equals(NewRistrettoArrayFromInt64s(2, 2, 1)) {
+		t.Errorf("unexpected reduced array %v", xs)
+	}
+
+	contains, err := xs.Contains(NewRistrettoArrayFromInt64s(1, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !contains.Equals(NewFTIntegerArray(1, 0)) {
+		t.Errorf("unexpected contains %v", contains)
+	}
+}
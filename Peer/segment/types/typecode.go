@@ -23,16 +23,21 @@ const (
 	Ed25519IntB BaseTypeCode = 'I'
 	Ed25519B    BaseTypeCode = 'E'
 	BytearrayB  BaseTypeCode = 'b'
+	RistrettoB  BaseTypeCode = 'R'
+	PaillierB   BaseTypeCode = 'P'
+
+	BloomFilterB  BaseTypeCode = 'B'
+	CuckooFilterB BaseTypeCode = 'C'
 )
 
 func (b BaseTypeCode) String() string { return string(b) }
 
 type TypeCode string
 
-var TypeCodeRegEx = regexp.MustCompile(`([ifIE]|b[1-9][0-9]*)\s*`)
+var TypeCodeRegEx = regexp.MustCompile(`([ifIER]|[bPBC][1-9][0-9]*)\s*`)
 
 func ParseTypeCode(v string) (TypeCode, error) {
-	if m, _ := regexp.MatchString("^[ifIE]|b\\d+$", v); m {
+	if m, _ := regexp.MatchString("^[ifIER]|[bPBC]\\d+$", v); m {
 		return TypeCode(v), nil
 	}
 	return Integer, fmt.Errorf("unknown typecode: %v", v)
@@ -52,15 +57,39 @@ const (
 	Float      TypeCode = TypeCode(FloatB)
 	Ed25519Int TypeCode = TypeCode(Ed25519IntB)
 	Ed25519    TypeCode = TypeCode(Ed25519B)
+	Ristretto  TypeCode = TypeCode(RistrettoB)
 )
 
 func Bytearray(length int) TypeCode {
 	return TypeCode(fmt.Sprintf("%v%v", BytearrayB, length))
 }
+
+// Paillier returns the typecode of Paillier ciphertexts of the given width in bytes.
+func Paillier(width int) TypeCode {
+	return TypeCode(fmt.Sprintf("%v%v", PaillierB, width))
+}
+
+// BloomFilter and CuckooFilter return the typecodes of filters of the given width in bytes.
+func BloomFilter(width int) TypeCode {
+	return TypeCode(fmt.Sprintf("%v%v", BloomFilterB, width))
+}
+func CuckooFilter(width int) TypeCode {
+	return TypeCode(fmt.Sprintf("%v%v", CuckooFilterB, width))
+}
+
 func (tc TypeCode) GetBase() BaseTypeCode { return BaseTypeCode(tc[0]) }
 func (tc TypeCode) IsBytearray() bool     { return tc.GetBase() == BytearrayB }
+
+// HasWidth reports whether the width of the typecode is given after its base, as in b32.
+func (tc TypeCode) HasWidth() bool {
+	switch tc.GetBase() {
+	case BytearrayB, PaillierB, BloomFilterB, CuckooFilterB:
+		return true
+	}
+	return false
+}
 func (tc TypeCode) Length() int {
-	if !tc.IsBytearray() {
+	if !tc.HasWidth() {
 		switch tc.GetBase() {
 		case 'i':
 			return 8
@@ -70,6 +99,8 @@ func (tc TypeCode) Length() int {
 			return 64
 		case 'I':
 			return 32
+		case 'R':
+			return 32
 		default:
 			panic("no valid typecode found for length method")
 		}
@@ -80,6 +111,15 @@ func (tc TypeCode) Length() int {
 	}
 	return l
 }
+
+// ElementBytes returns the width of each element of an array in the binary form returned by
+// GetBinaryArray, in which Ed25519 points are in extended coordinates.
+func (tc TypeCode) ElementBytes() int {
+	if tc.GetBase() == Ed25519B {
+		return Ed25519ExtendedPointBytes
+	}
+	return tc.Length()
+}
 func (tc TypeCode) GetTypeCodeAsSlice() []TypeCode {
 	return ParseTypeCodes(string(tc))
 }
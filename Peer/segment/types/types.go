@@ -135,6 +135,11 @@ type Freer interface {
 }
 
 func FromBytes(t TypeCode, bs []byte) (ArrayTypeVal, error) {
+	return FromBytesWithBackend(t, bs, defaultEd25519Backend)
+}
+
+// FromBytesWithBackend is FromBytes with Ed25519 arrays being created using the given backend.
+func FromBytesWithBackend(t TypeCode, bs []byte, ed25519Backend Ed25519Backend) (ArrayTypeVal, error) {
 	switch t.GetBase() {
 	case IntegerB:
 		return NewFTIntegerArrayFromBytes(bs)
@@ -145,12 +150,32 @@ func FromBytes(t TypeCode, bs []byte) (ArrayTypeVal, error) {
 	case Ed25519IntB:
 		return NewFTEd25519IntArrayFromBytes(bs)
 	case Ed25519B:
-		return NewEd25519ArrayFromBytes(bs)
+		return NewEd25519ArrayFromBytesWithBackend(ed25519Backend, bs)
+	case RistrettoB:
+		return NewRistrettoArrayFromBytes(bs)
+	case PaillierB:
+		return NewPaillierArrayFromBytes(bs, t.Length())
+	case BloomFilterB, CuckooFilterB:
+		return NewFilterArrayFromBytes(t, bs)
 	default:
 		return nil, fmt.Errorf("unrecognised type code: %v", t)
 	}
 }
 
+// AsTypeWithBackend is xs.AsType(tc), except that Ed25519 arrays are created using the
+// given backend.
+func AsTypeWithBackend(xs ArrayTypeVal, tc TypeCode, ed25519Backend Ed25519Backend) (TypeVal, error) {
+	if tc.GetBase() == Ed25519B {
+		switch v := xs.(type) {
+		case *FTIntegerArray:
+			return NewEd25519ArrayFromInt64sWithBackend(ed25519Backend, v.array...)
+		case *FTEd25519IntArray:
+			return NewEd25519ArrayFromIntWithBackend(ed25519Backend, v.array)
+		}
+	}
+	return xs.AsType(tc)
+}
+
 func PrintSize(b uint64) string {
 	if b < 1024 {
 		return fmt.Sprintf("%v B", b)